@@ -29,13 +29,19 @@ type config struct {
 	Database *Database     `mapstructure:"database" json:"database"`
 	Mysql    *MysqlConfig  `mapstructure:"mysql" json:"mysql"`
 	// Casbin    *CasbinConfig    `mapstructure:"casbin" json:"casbin"`
-	Jwt       *JwtConfig       `mapstructure:"jwt" json:"jwt"`
-	RateLimit *RateLimitConfig `mapstructure:"rate-limit" json:"rateLimit"`
-	Ldap      *LdapConfig      `mapstructure:"ldap" json:"ldap"`
-	Email     *EmailConfig     `mapstructure:"email" json:"email"`
-	DingTalk  *DingTalkConfig  `mapstructure:"dingtalk" json:"dingTalk"`
-	WeCom     *WeComConfig     `mapstructure:"wecom" json:"weCom"`
-	FeiShu    *FeiShuConfig    `mapstructure:"feishu" json:"feiShu"`
+	Jwt        *JwtConfig        `mapstructure:"jwt" json:"jwt"`
+	RateLimit  *RateLimitConfig  `mapstructure:"rate-limit" json:"rateLimit"`
+	Ldap       *LdapConfig       `mapstructure:"ldap" json:"ldap"`
+	Email      *EmailConfig      `mapstructure:"email" json:"email"`
+	DingTalk   *DingTalkConfig   `mapstructure:"dingtalk" json:"dingTalk"`
+	WeCom      *WeComConfig      `mapstructure:"wecom" json:"weCom"`
+	FeiShu     *FeiShuConfig     `mapstructure:"feishu" json:"feiShu"`
+	HttpSource *HttpSourceConfig `mapstructure:"http-source" json:"httpSource"`
+	Oidc       *OidcConfig       `mapstructure:"oidc" json:"oidc"`
+	Scim       *ScimConfig       `mapstructure:"scim" json:"scim"`
+
+	PasswordPolicy *PasswordPolicyConfig `mapstructure:"password-policy" json:"passwordPolicy"`
+	LoginLock      *LoginLockConfig      `mapstructure:"login-lock" json:"loginLock"`
 }
 
 // 设置读取配置信息
@@ -132,6 +138,10 @@ func InitConfig() {
 	if ldapUserPasswordEncryptionType != "" {
 		Conf.Ldap.UserPasswordEncryptionType = ldapUserPasswordEncryptionType
 	}
+	ldapLoginMode := os.Getenv("LDAP_LOGIN_MODE")
+	if ldapLoginMode != "" {
+		Conf.Ldap.LoginMode = ldapLoginMode
+	}
 }
 
 type SystemConfig struct {
@@ -181,24 +191,99 @@ type JwtConfig struct {
 	MaxRefresh int    `mapstructure:"max-refresh" json:"maxRefresh"`
 }
 
+type PasswordPolicyConfig struct {
+	MinLength        int    `mapstructure:"min-length" json:"minLength"`
+	RequireUpper     bool   `mapstructure:"require-upper" json:"requireUpper"`
+	RequireLower     bool   `mapstructure:"require-lower" json:"requireLower"`
+	RequireDigit     bool   `mapstructure:"require-digit" json:"requireDigit"`
+	RequireSpecial   bool   `mapstructure:"require-special" json:"requireSpecial"`
+	DisallowUserInfo bool   `mapstructure:"disallow-user-info" json:"disallowUserInfo"`
+	DictionaryFile   string `mapstructure:"dictionary-file" json:"dictionaryFile"`
+	HistoryCount     int    `mapstructure:"history-count" json:"historyCount"`
+	MaxAge           int    `mapstructure:"max-age" json:"maxAge"`
+	ExpireWarnDays   int    `mapstructure:"expire-warn-days" json:"expireWarnDays"`
+	ExpireCheckTime  string `mapstructure:"expire-check-time" json:"expireCheckTime"`
+	LdapChangedAttr  string `mapstructure:"ldap-changed-attr" json:"ldapChangedAttr"`
+}
+
+type LoginLockConfig struct {
+	Enable          bool `mapstructure:"enable" json:"enable"`
+	UserMaxFailures int  `mapstructure:"user-max-failures" json:"userMaxFailures"`
+	IpMaxFailures   int  `mapstructure:"ip-max-failures" json:"ipMaxFailures"`
+	FailureWindow   int  `mapstructure:"failure-window" json:"failureWindow"`
+	LockDuration    int  `mapstructure:"lock-duration" json:"lockDuration"`
+}
+
 type RateLimitConfig struct {
 	FillInterval int64 `mapstructure:"fill-interval" json:"fillInterval"`
 	Capacity     int64 `mapstructure:"capacity" json:"capacity"`
 }
 
 type LdapConfig struct {
-	Url                        string `mapstructure:"url" json:"url"`
-	MaxConn                    int    `mapstructure:"max-conn" json:"maxConn"`
-	BaseDN                     string `mapstructure:"base-dn" json:"baseDN"`
-	AdminDN                    string `mapstructure:"admin-dn" json:"adminDN"`
-	AdminPass                  string `mapstructure:"admin-pass" json:"adminPass"`
-	UserDN                     string `mapstructure:"user-dn" json:"userDN"`
-	UserInitPassword           string `mapstructure:"user-init-password" json:"userInitPassword"`
-	GroupNameModify            bool   `mapstructure:"group-name-modify" json:"groupNameModify"`
-	UserNameModify             bool   `mapstructure:"user-name-modify" json:"userNameModify"`
-	DefaultEmailSuffix         string `mapstructure:"default-email-suffix" json:"defaultEmailSuffix"`
-	UserPasswordEncryptionType string `mapstructure:"user-password-encryption-type" json:"userPasswordEncryptionType"`
-	EnableSync                 bool   `mapstructure:"enable-sync" json:"enableSync"`
+	Url                        string                `mapstructure:"url" json:"url"`
+	MaxConn                    int                   `mapstructure:"max-conn" json:"maxConn"`
+	IdleTimeout                int                   `mapstructure:"idle-timeout" json:"idleTimeout"`
+	AcquireTimeout             int                   `mapstructure:"acquire-timeout" json:"acquireTimeout"`
+	HealthCheckInterval        int                   `mapstructure:"health-check-interval" json:"healthCheckInterval"`
+	InitRetries                int                   `mapstructure:"init-retries" json:"initRetries"`
+	PageSize                   int                   `mapstructure:"page-size" json:"pageSize"`
+	BaseDN                     string                `mapstructure:"base-dn" json:"baseDN"`
+	AdminDN                    string                `mapstructure:"admin-dn" json:"adminDN"`
+	AdminPass                  string                `mapstructure:"admin-pass" json:"adminPass"`
+	UserDN                     string                `mapstructure:"user-dn" json:"userDN"`
+	UserInitPassword           string                `mapstructure:"user-init-password" json:"userInitPassword"`
+	GroupNameModify            bool                  `mapstructure:"group-name-modify" json:"groupNameModify"`
+	UserNameModify             bool                  `mapstructure:"user-name-modify" json:"userNameModify"`
+	DefaultEmailSuffix         string                `mapstructure:"default-email-suffix" json:"defaultEmailSuffix"`
+	UserPasswordEncryptionType string                `mapstructure:"user-password-encryption-type" json:"userPasswordEncryptionType"`
+	EnableSync                 bool                  `mapstructure:"enable-sync" json:"enableSync"`
+	LoginMode                  string                `mapstructure:"login-mode" json:"loginMode"`
+	UserSchema                 *LdapUserSchemaConfig `mapstructure:"user-schema" json:"userSchema"`
+	GroupSchema                string                `mapstructure:"group-schema" json:"groupSchema"`
+	MemberOf                   bool                  `mapstructure:"member-of" json:"memberOf"`
+	NestedGroupMaterialize     bool                  `mapstructure:"nested-group-materialize" json:"nestedGroupMaterialize"`
+	Posix                      *LdapPosixConfig      `mapstructure:"posix" json:"posix"`
+	Backend                    string                `mapstructure:"backend" json:"backend"`
+	AD                         *LdapADConfig         `mapstructure:"ad" json:"ad"`
+	TLS                        *LdapTLSConfig        `mapstructure:"tls" json:"tls"`
+}
+
+// LdapTLSConfig LDAP连接的TLS配置, 对连接池中的所有连接生效
+type LdapTLSConfig struct {
+	StartTLS   bool   `mapstructure:"start-tls" json:"startTLS"`
+	CAFile     string `mapstructure:"ca-file" json:"caFile"`
+	CertFile   string `mapstructure:"cert-file" json:"certFile"`
+	KeyFile    string `mapstructure:"key-file" json:"keyFile"`
+	MinVersion string `mapstructure:"min-version" json:"minVersion"`
+	ServerName string `mapstructure:"server-name" json:"serverName"`
+}
+
+// LdapADConfig 后端为Active Directory时的配置
+type LdapADConfig struct {
+	UpnSuffix  string                `mapstructure:"upn-suffix" json:"upnSuffix"`
+	UserSchema *LdapUserSchemaConfig `mapstructure:"user-schema" json:"userSchema"`
+}
+
+// LdapPosixConfig POSIX账号与分组, 用于Linux主机登录
+type LdapPosixConfig struct {
+	Enable         bool   `mapstructure:"enable" json:"enable"`
+	Shadow         bool   `mapstructure:"shadow" json:"shadow"`
+	UidMin         uint   `mapstructure:"uid-min" json:"uidMin"`
+	UidMax         uint   `mapstructure:"uid-max" json:"uidMax"`
+	GidMin         uint   `mapstructure:"gid-min" json:"gidMin"`
+	GidMax         uint   `mapstructure:"gid-max" json:"gidMax"`
+	UserGidNumber  uint   `mapstructure:"user-gid-number" json:"userGidNumber"`
+	PrivateGroupDN string `mapstructure:"private-group-dn" json:"privateGroupDN"`
+	HomeDirectory  string `mapstructure:"home-directory" json:"homeDirectory"`
+	LoginShell     string `mapstructure:"login-shell" json:"loginShell"`
+}
+
+// LdapUserSchemaConfig 用户条目的objectClass与属性映射, 字段名为model.User的json字段名
+type LdapUserSchemaConfig struct {
+	ObjectClasses    []string            `mapstructure:"object-classes" json:"objectClasses"`
+	Attributes       map[string][]string `mapstructure:"attributes" json:"attributes"`
+	SkipAttributes   []string            `mapstructure:"skip-attributes" json:"skipAttributes"`
+	ImportAttributes map[string]string   `mapstructure:"import-attributes" json:"importAttributes"`
 }
 type EmailConfig struct {
 	Host string `mapstructure:"host" json:"host"`
@@ -209,37 +294,99 @@ type EmailConfig struct {
 }
 
 type DingTalkConfig struct {
-	AppKey        string   `mapstructure:"app-key" json:"appKey"`
-	AppSecret     string   `mapstructure:"app-secret" json:"appSecret"`
-	AgentId       string   `mapstructure:"agent-id" json:"agentId"`
-	RootOuName    string   `mapstructure:"root-ou-name" json:"rootOuName"`
-	Flag          string   `mapstructure:"flag" json:"flag"`
-	EnableSync    bool     `mapstructure:"enable-sync" json:"enableSync"`
-	DeptSyncTime  string   `mapstructure:"dept-sync-time" json:"deptSyncTime"`
-	UserSyncTime  string   `mapstructure:"user-sync-time" json:"userSyncTime"`
-	DeptList      []string `mapstructure:"dept-list" json:"deptList"`
-	IsUpdateSyncd bool     `mapstructure:"is-update-syncd" json:"isUpdateSyncd"`
-	ULeaveRange   uint     `mapstructure:"user-leave-range" json:"userLevelRange"`
+	AppKey         string   `mapstructure:"app-key" json:"appKey"`
+	AppSecret      string   `mapstructure:"app-secret" json:"appSecret"`
+	AgentId        string   `mapstructure:"agent-id" json:"agentId"`
+	RootOuName     string   `mapstructure:"root-ou-name" json:"rootOuName"`
+	Flag           string   `mapstructure:"flag" json:"flag"`
+	EnableSync     bool     `mapstructure:"enable-sync" json:"enableSync"`
+	DeptSyncTime   string   `mapstructure:"dept-sync-time" json:"deptSyncTime"`
+	UserSyncTime   string   `mapstructure:"user-sync-time" json:"userSyncTime"`
+	DeptList       []string `mapstructure:"dept-list" json:"deptList"`
+	IsUpdateSyncd  bool     `mapstructure:"is-update-syncd" json:"isUpdateSyncd"`
+	IsDeleteSyncd  bool     `mapstructure:"is-delete-syncd" json:"isDeleteSyncd"`
+	DryRun         bool     `mapstructure:"dry-run" json:"dryRun"`
+	ULeaveRange    uint     `mapstructure:"user-leave-range" json:"userLevelRange"`
+	EnableSso      bool     `mapstructure:"enable-sso" json:"enableSso"`
+	SsoRedirectUri string   `mapstructure:"sso-redirect-uri" json:"ssoRedirectUri"`
 }
 
 type WeComConfig struct {
-	Flag          string `mapstructure:"flag" json:"flag"`
-	CorpID        string `mapstructure:"corp-id" json:"corpId"`
-	AgentID       int    `mapstructure:"agent-id" json:"agentId"`
-	CorpSecret    string `mapstructure:"corp-secret" json:"corpSecret"`
-	EnableSync    bool   `mapstructure:"enable-sync" json:"enableSync"`
-	DeptSyncTime  string `mapstructure:"dept-sync-time" json:"deptSyncTime"`
-	UserSyncTime  string `mapstructure:"user-sync-time" json:"userSyncTime"`
-	IsUpdateSyncd bool   `mapstructure:"is-update-syncd" json:"isUpdateSyncd"`
+	Flag           string `mapstructure:"flag" json:"flag"`
+	CorpID         string `mapstructure:"corp-id" json:"corpId"`
+	AgentID        int    `mapstructure:"agent-id" json:"agentId"`
+	CorpSecret     string `mapstructure:"corp-secret" json:"corpSecret"`
+	EnableSync     bool   `mapstructure:"enable-sync" json:"enableSync"`
+	DeptSyncTime   string `mapstructure:"dept-sync-time" json:"deptSyncTime"`
+	UserSyncTime   string `mapstructure:"user-sync-time" json:"userSyncTime"`
+	IsUpdateSyncd  bool   `mapstructure:"is-update-syncd" json:"isUpdateSyncd"`
+	IsDeleteSyncd  bool   `mapstructure:"is-delete-syncd" json:"isDeleteSyncd"`
+	DryRun         bool   `mapstructure:"dry-run" json:"dryRun"`
+	EnableSso      bool   `mapstructure:"enable-sso" json:"enableSso"`
+	SsoRedirectUri string `mapstructure:"sso-redirect-uri" json:"ssoRedirectUri"`
 }
 
 type FeiShuConfig struct {
-	Flag          string   `mapstructure:"flag" json:"flag"`
-	AppID         string   `mapstructure:"app-id" json:"appId"`
-	AppSecret     string   `mapstructure:"app-secret" json:"appSecret"`
-	EnableSync    bool     `mapstructure:"enable-sync" json:"enableSync"`
-	DeptSyncTime  string   `mapstructure:"dept-sync-time" json:"deptSyncTime"`
-	UserSyncTime  string   `mapstructure:"user-sync-time" json:"userSyncTime"`
-	DeptList      []string `mapstructure:"dept-list" json:"deptList"`
-	IsUpdateSyncd bool     `mapstructure:"is-update-syncd" json:"isUpdateSyncd"`
+	Flag           string   `mapstructure:"flag" json:"flag"`
+	AppID          string   `mapstructure:"app-id" json:"appId"`
+	AppSecret      string   `mapstructure:"app-secret" json:"appSecret"`
+	EnableSync     bool     `mapstructure:"enable-sync" json:"enableSync"`
+	DeptSyncTime   string   `mapstructure:"dept-sync-time" json:"deptSyncTime"`
+	UserSyncTime   string   `mapstructure:"user-sync-time" json:"userSyncTime"`
+	DeptList       []string `mapstructure:"dept-list" json:"deptList"`
+	IsUpdateSyncd  bool     `mapstructure:"is-update-syncd" json:"isUpdateSyncd"`
+	IsDeleteSyncd  bool     `mapstructure:"is-delete-syncd" json:"isDeleteSyncd"`
+	DryRun         bool     `mapstructure:"dry-run" json:"dryRun"`
+	EnableSso      bool     `mapstructure:"enable-sso" json:"enableSso"`
+	SsoRedirectUri string   `mapstructure:"sso-redirect-uri" json:"ssoRedirectUri"`
+}
+
+// HttpSourceConfig 通用HTTP身份源, 从分页的JSON接口获取部门与用户
+type HttpSourceConfig struct {
+	Flag            string                `mapstructure:"flag" json:"flag"`
+	Name            string                `mapstructure:"name" json:"name"`
+	RootDeptId      string                `mapstructure:"root-dept-id" json:"rootDeptId"`
+	DeptUrl         string                `mapstructure:"dept-url" json:"deptUrl"`
+	UserUrl         string                `mapstructure:"user-url" json:"userUrl"`
+	LeaverUrl       string                `mapstructure:"leaver-url" json:"leaverUrl"`
+	AuthHeader      string                `mapstructure:"auth-header" json:"authHeader"`
+	Token           string                `mapstructure:"token" json:"token"`
+	Page            *HttpSourcePageConfig `mapstructure:"page" json:"page"`
+	DeptItemsPath   string                `mapstructure:"dept-items-path" json:"deptItemsPath"`
+	UserItemsPath   string                `mapstructure:"user-items-path" json:"userItemsPath"`
+	UserDeptIdsPath string                `mapstructure:"user-dept-ids-path" json:"userDeptIdsPath"`
+	LeaverItemsPath string                `mapstructure:"leaver-items-path" json:"leaverItemsPath"`
+	LeaverIdPath    string                `mapstructure:"leaver-id-path" json:"leaverIdPath"`
+	LeaverIdField   string                `mapstructure:"leaver-id-field" json:"leaverIdField"`
+	LeaverByMissing bool                  `mapstructure:"leaver-by-missing" json:"leaverByMissing"`
+	EnableSync      bool                  `mapstructure:"enable-sync" json:"enableSync"`
+	DeptSyncTime    string                `mapstructure:"dept-sync-time" json:"deptSyncTime"`
+	UserSyncTime    string                `mapstructure:"user-sync-time" json:"userSyncTime"`
+	IsUpdateSyncd   bool                  `mapstructure:"is-update-syncd" json:"isUpdateSyncd"`
+	IsDeleteSyncd   bool                  `mapstructure:"is-delete-syncd" json:"isDeleteSyncd"`
+	DryRun          bool                  `mapstructure:"dry-run" json:"dryRun"`
+}
+
+// HttpSourcePageConfig 通用HTTP身份源的分页方式
+type HttpSourcePageConfig struct {
+	Type        string `mapstructure:"type" json:"type"`
+	PageParam   string `mapstructure:"page-param" json:"pageParam"`
+	SizeParam   string `mapstructure:"size-param" json:"sizeParam"`
+	Size        int    `mapstructure:"size" json:"size"`
+	StartPage   int    `mapstructure:"start-page" json:"startPage"`
+	CursorParam string `mapstructure:"cursor-param" json:"cursorParam"`
+	CursorPath  string `mapstructure:"cursor-path" json:"cursorPath"`
+}
+
+type OidcConfig struct {
+	Enable         bool   `mapstructure:"enable" json:"enable"`
+	Issuer         string `mapstructure:"issuer" json:"issuer"`
+	SigningKeyFile string `mapstructure:"signing-key-file" json:"signingKeyFile"`
+	CodeTTL        int    `mapstructure:"code-ttl" json:"codeTTL"`
+	TokenTTL       int    `mapstructure:"token-ttl" json:"tokenTTL"`
+}
+
+type ScimConfig struct {
+	Enable        bool   `mapstructure:"enable" json:"enable"`
+	RootGroupName string `mapstructure:"root-group-name" json:"rootGroupName"`
 }
@@ -23,6 +23,15 @@ var (
 	OperationLog  = &OperationLogController{}
 	Base          = &BaseController{}
 	FieldRelation = &FieldRelationController{}
+	Mfa           = &MfaController{}
+	Oidc          = &OidcController{}
+	Sso           = &SsoController{}
+	Session       = &SessionController{}
+	AccessToken   = &AccessTokenController{}
+	SshKey        = &SshKeyController{}
+	Directory     = &DirectoryController{}
+	Sync          = &SyncController{}
+	Scim          = &ScimController{}
 
 	validate = validator.New()
 	trans    ut.Translator
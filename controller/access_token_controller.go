@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccessTokenController struct{}
+
+// List 访问令牌列表
+// @Summary 获取当前用户的访问令牌列表
+// @Tags 访问令牌
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /accessToken/list [get]
+// @Security ApiKeyAuth
+func (m *AccessTokenController) List(c *gin.Context) {
+	req := new(request.AccessTokenListReq)
+	Run(c, req, func() (any, any) {
+		return logic.AccessToken.List(c, req)
+	})
+}
+
+// Add 创建访问令牌
+// @Summary 创建访问令牌
+// @Description 创建访问令牌, 令牌可访问的接口须在当前用户权限范围内, 令牌明文仅在创建时返回一次
+// @Tags 访问令牌
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.AccessTokenAddReq true "创建访问令牌的结构体"
+// @Success 200 {object} response.ResponseBody
+// @Router /accessToken/add [post]
+// @Security ApiKeyAuth
+func (m *AccessTokenController) Add(c *gin.Context) {
+	req := new(request.AccessTokenAddReq)
+	Run(c, req, func() (any, any) {
+		return logic.AccessToken.Add(c, req)
+	})
+}
+
+// Delete 删除访问令牌
+// @Summary 删除访问令牌
+// @Tags 访问令牌
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.AccessTokenDeleteReq true "访问令牌ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /accessToken/delete [post]
+// @Security ApiKeyAuth
+func (m *AccessTokenController) Delete(c *gin.Context) {
+	req := new(request.AccessTokenDeleteReq)
+	Run(c, req, func() (any, any) {
+		return logic.AccessToken.Delete(c, req)
+	})
+}
@@ -41,6 +41,22 @@ func (m *BaseController) ChangePwd(c *gin.Context) {
 	})
 }
 
+// ChangeExpiredPwd 登录时修改已过期的密码
+// @Summary 修改已过期的密码
+// @Description 登录时提示密码已过期, 使用返回的凭据修改密码
+// @Tags 基础管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.BaseChangeExpiredPwdReq true "修改过期密码请求数据"
+// @Success 200 {object} response.ResponseBody
+// @Router /base/changeExpiredPwd [post]
+func (m *BaseController) ChangeExpiredPwd(c *gin.Context) {
+	req := new(request.BaseChangeExpiredPwdReq)
+	Run(c, req, func() (any, any) {
+		return logic.Base.ChangeExpiredPwd(c, req)
+	})
+}
+
 // Dashboard 系统首页展示数据
 // @Summary 获取仪表盘数据
 // @Description 获取系统仪表盘概览数据
@@ -72,34 +88,34 @@ func (m *BaseController) EncryptPasswd(c *gin.Context) {
 	})
 }
 
-// DecryptPasswd 密码解密为明文
-// @Summary 密码解密
-// @Description 将加密后的密码解密为明文
+// GetConfig 获取系统配置
+// @Summary 获取系统配置
+// @Description 获取系统配置信息，用于前端判断是否显示同步按钮
 // @Tags 基础管理
 // @Accept application/json
 // @Produce application/json
-// @Param passwd query string true "需要解密的加密密码"
 // @Success 200 {object} response.ResponseBody
-// @Router /base/decryptpwd [get]
-func (m *BaseController) DecryptPasswd(c *gin.Context) {
-	req := new(request.DecryptPasswdReq)
+// @Router /base/config [get]
+func (m *BaseController) GetConfig(c *gin.Context) {
+	req := new(request.BaseConfigReq)
 	Run(c, req, func() (any, any) {
-		return logic.Base.DecryptPasswd(c, req)
+		return logic.Base.GetConfig(c, req)
 	})
 }
 
-// GetConfig 获取系统配置
-// @Summary 获取系统配置
-// @Description 获取系统配置信息，用于前端判断是否显示同步按钮
+// LdapPoolStats 获取LDAP连接池统计
+// @Summary 获取LDAP连接池统计
+// @Description 获取LDAP连接池的连接数、等待次数、重连次数等统计信息
 // @Tags 基础管理
 // @Accept application/json
 // @Produce application/json
 // @Success 200 {object} response.ResponseBody
-// @Router /base/config [get]
-func (m *BaseController) GetConfig(c *gin.Context) {
-	req := new(request.BaseConfigReq)
+// @Router /system/ldapPoolStats [get]
+// @Security ApiKeyAuth
+func (m *BaseController) LdapPoolStats(c *gin.Context) {
+	req := new(request.BaseLdapPoolStatsReq)
 	Run(c, req, func() (any, any) {
-		return logic.Base.GetConfig(c, req)
+		return logic.Base.LdapPoolStats(c, req)
 	})
 }
 
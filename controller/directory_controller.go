@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DirectoryController struct{}
+
+// List LDAP目录列表
+// @Summary 获取LDAP目录列表
+// @Description 获取除配置文件中默认目录之外的LDAP目录
+// @Tags LDAP目录管理
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /directory/list [get]
+// @Security ApiKeyAuth
+func (m *DirectoryController) List(c *gin.Context) {
+	req := new(request.DirectoryListReq)
+	Run(c, req, func() (any, any) {
+		return logic.Directory.List(c, req)
+	})
+}
+
+// Add 添加LDAP目录
+// @Summary 添加LDAP目录
+// @Description 添加LDAP目录, 连接并绑定管理员成功后才保存
+// @Tags LDAP目录管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.DirectoryAddReq true "添加LDAP目录的结构体"
+// @Success 200 {object} response.ResponseBody
+// @Router /directory/add [post]
+// @Security ApiKeyAuth
+func (m *DirectoryController) Add(c *gin.Context) {
+	req := new(request.DirectoryAddReq)
+	Run(c, req, func() (any, any) {
+		return logic.Directory.Add(c, req)
+	})
+}
+
+// Update 更新LDAP目录
+// @Summary 更新LDAP目录
+// @Tags LDAP目录管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.DirectoryUpdateReq true "更新LDAP目录的结构体"
+// @Success 200 {object} response.ResponseBody
+// @Router /directory/update [post]
+// @Security ApiKeyAuth
+func (m *DirectoryController) Update(c *gin.Context) {
+	req := new(request.DirectoryUpdateReq)
+	Run(c, req, func() (any, any) {
+		return logic.Directory.Update(c, req)
+	})
+}
+
+// Delete 删除LDAP目录
+// @Summary 删除LDAP目录
+// @Description 目录下还有用户或分组时不能删除
+// @Tags LDAP目录管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.DirectoryDeleteReq true "目录ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /directory/delete [post]
+// @Security ApiKeyAuth
+func (m *DirectoryController) Delete(c *gin.Context) {
+	req := new(request.DirectoryDeleteReq)
+	Run(c, req, func() (any, any) {
+		return logic.Directory.Delete(c, req)
+	})
+}
@@ -241,3 +241,105 @@ func (m *GroupController) SyncSqlGroups(c *gin.Context) {
 		return logic.Sql.SyncSqlGroups(c, req)
 	})
 }
+
+// PosixBackfill 回填分组的POSIX信息
+// @Summary 回填分组的POSIX信息
+// @Description 为已有cn类型分组分配gidNumber并在LDAP中追加posixGroup
+// @Tags 分组管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.GroupPosixBackfillReq true "分组ID列表，为空时回填全部"
+// @Success 200 {object} response.ResponseBody
+// @Router /group/posixBackfill [post]
+// @Security ApiKeyAuth
+func (m *GroupController) PosixBackfill(c *gin.Context) {
+	req := new(request.GroupPosixBackfillReq)
+	Run(c, req, func() (any, any) {
+		return logic.Group.PosixBackfill(c, req)
+	})
+}
+
+// ConvertSchema 转换分组的objectClass
+// @Summary 转换分组的objectClass
+// @Description 在groupOfUniqueNames与groupOfNames之间转换已有分组, 成员随之迁移
+// @Tags 分组管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.GroupConvertSchemaReq true "分组ID列表与目标objectClass"
+// @Success 200 {object} response.ResponseBody
+// @Router /group/convertSchema [post]
+// @Security ApiKeyAuth
+func (m *GroupController) ConvertSchema(c *gin.Context) {
+	req := new(request.GroupConvertSchemaReq)
+	Run(c, req, func() (any, any) {
+		return logic.Group.ConvertSchema(c, req)
+	})
+}
+
+// AddGroup 添加成员分组
+// @Summary 添加成员分组
+// @Description 将分组作为成员加入另一个分组, 成员分组中的用户同时成为该分组的有效成员
+// @Tags 分组管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.GroupAddGroupReq true "分组ID与成员分组ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /group/addGroup [post]
+// @Security ApiKeyAuth
+func (m *GroupController) AddGroup(c *gin.Context) {
+	req := new(request.GroupAddGroupReq)
+	Run(c, req, func() (any, any) {
+		return logic.Group.AddGroup(c, req)
+	})
+}
+
+// RemoveGroup 移除成员分组
+// @Summary 移除成员分组
+// @Description 将成员分组从分组中移除
+// @Tags 分组管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.GroupRemoveGroupReq true "分组ID与成员分组ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /group/removeGroup [post]
+// @Security ApiKeyAuth
+func (m *GroupController) RemoveGroup(c *gin.Context) {
+	req := new(request.GroupRemoveGroupReq)
+	Run(c, req, func() (any, any) {
+		return logic.Group.RemoveGroup(c, req)
+	})
+}
+
+// EffectiveMembers 获取分组的有效成员
+// @Summary 获取分组的有效成员
+// @Description 获取分组的直接成员与通过成员分组继承的成员
+// @Tags 分组管理
+// @Accept application/json
+// @Produce application/json
+// @Param groupId query int true "分组ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /group/effectiveMembers [get]
+// @Security ApiKeyAuth
+func (m *GroupController) EffectiveMembers(c *gin.Context) {
+	req := new(request.GroupEffectiveMembersReq)
+	Run(c, req, func() (any, any) {
+		return logic.Group.EffectiveMembers(c, req)
+	})
+}
+
+// SyncNestedMembers 重新写入嵌套分组的成员
+// @Summary 重新写入嵌套分组的成员
+// @Description 按有效成员重新写入LDAP中的分组成员, 用于开启展开嵌套分组后修复已有数据
+// @Tags 分组管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.GroupSyncNestedMembersReq true "分组ID列表，为空时处理全部"
+// @Success 200 {object} response.ResponseBody
+// @Router /group/syncNestedMembers [post]
+// @Security ApiKeyAuth
+func (m *GroupController) SyncNestedMembers(c *gin.Context) {
+	req := new(request.GroupSyncNestedMembersReq)
+	Run(c, req, func() (any, any) {
+		return logic.Group.SyncNestedMembers(c, req)
+	})
+}
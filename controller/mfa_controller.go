@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MfaController struct{}
+
+// SetupByTicket 登录过程中生成二次验证密钥
+// @Summary 登录过程中生成二次验证密钥
+// @Description 角色强制要求二次验证但用户尚未绑定时, 使用登录第一步返回的凭据生成密钥
+// @Tags 二次验证
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.BaseMfaSetupReq true "登录第一步返回的二次验证凭据"
+// @Success 200 {object} response.ResponseBody
+// @Router /base/mfa/setup [post]
+func (m *MfaController) SetupByTicket(c *gin.Context) {
+	req := new(request.BaseMfaSetupReq)
+	Run(c, req, func() (any, any) {
+		return logic.Mfa.SetupByTicket(c, req)
+	})
+}
+
+// Info 获取当前用户的二次验证状态
+// @Summary 获取当前用户的二次验证状态
+// @Description 获取当前用户的二次验证状态
+// @Tags 二次验证
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /user/mfa/info [get]
+// @Security ApiKeyAuth
+func (m *MfaController) Info(c *gin.Context) {
+	req := new(request.MfaInfoReq)
+	Run(c, req, func() (any, any) {
+		return logic.Mfa.Info(c, req)
+	})
+}
+
+// Setup 生成二次验证密钥
+// @Summary 生成二次验证密钥
+// @Description 生成TOTP密钥及otpauth://地址, 使用验证码确认后生效
+// @Tags 二次验证
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /user/mfa/setup [post]
+// @Security ApiKeyAuth
+func (m *MfaController) Setup(c *gin.Context) {
+	req := new(request.MfaSetupReq)
+	Run(c, req, func() (any, any) {
+		return logic.Mfa.Setup(c, req)
+	})
+}
+
+// Enable 启用二次验证
+// @Summary 启用二次验证
+// @Description 使用验证码确认绑定, 返回恢复码
+// @Tags 二次验证
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.MfaEnableReq true "二次验证码"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/mfa/enable [post]
+// @Security ApiKeyAuth
+func (m *MfaController) Enable(c *gin.Context) {
+	req := new(request.MfaEnableReq)
+	Run(c, req, func() (any, any) {
+		return logic.Mfa.Enable(c, req)
+	})
+}
+
+// Disable 关闭二次验证
+// @Summary 关闭二次验证
+// @Description 关闭当前用户的二次验证
+// @Tags 二次验证
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.MfaDisableReq true "二次验证码或恢复码"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/mfa/disable [post]
+// @Security ApiKeyAuth
+func (m *MfaController) Disable(c *gin.Context) {
+	req := new(request.MfaDisableReq)
+	Run(c, req, func() (any, any) {
+		return logic.Mfa.Disable(c, req)
+	})
+}
+
+// Reset 重置用户二次验证
+// @Summary 重置用户二次验证
+// @Description 管理员重置用户的二次验证, 用户需重新绑定
+// @Tags 二次验证
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.MfaResetReq true "用户ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/mfa/reset [post]
+// @Security ApiKeyAuth
+func (m *MfaController) Reset(c *gin.Context) {
+	req := new(request.MfaResetReq)
+	Run(c, req, func() (any, any) {
+		return logic.Mfa.Reset(c, req)
+	})
+}
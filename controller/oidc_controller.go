@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OidcController struct{}
+
+// 授权页登录后保存会话的cookie
+const oidcSessionCookie = "oidc_session"
+
+// 授权页登录表单
+var oidcLoginTpl = template.Must(template.New("oidc").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>登录 - {{.ClientName}}</title>
+<style>
+body{font-family:sans-serif;background:#f0f2f5;display:flex;justify-content:center;padding-top:10vh}
+form{background:#fff;padding:32px;border-radius:4px;width:320px;box-shadow:0 1px 4px rgba(0,0,0,.1)}
+h3{margin-top:0}input{width:100%;box-sizing:border-box;margin:8px 0;padding:8px}
+button{width:100%;padding:8px;background:#1890ff;color:#fff;border:0;border-radius:2px;cursor:pointer}
+.err{color:#f5222d;font-size:14px}a{font-size:14px}
+</style>
+</head>
+<body>
+{{if .Error}}{{if not .Params}}<form><h3>授权失败</h3><p class="err">{{.Error}}</p></form>{{end}}{{end}}
+{{if .Params}}
+<form method="post" action="">
+<h3>登录以访问「{{.ClientName}}」</h3>
+{{if .Error}}<p class="err">{{.Error}}</p>{{end}}
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">{{end}}
+{{if .MfaTicket}}
+<input type="hidden" name="mfa_ticket" value="{{.MfaTicket}}">
+<input name="mfa_code" placeholder="二次验证码或恢复码" autocomplete="one-time-code" autofocus>
+<button type="submit">验证</button>
+<p><a href="{{.LoginURL}}">重新登录</a></p>
+{{else}}
+<input name="username" placeholder="用户名" autocomplete="username" autofocus>
+<input name="password" type="password" placeholder="密码" autocomplete="current-password">
+<button type="submit">登录</button>
+{{end}}
+</form>
+{{end}}
+</body>
+</html>`))
+
+// Discovery OIDC发现文档
+// @Summary OIDC发现文档
+// @Tags OIDC
+// @Produce application/json
+// @Success 200 {object} map[string]any
+// @Router /oidc/.well-known/openid-configuration [get]
+func (m *OidcController) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, logic.OidcDiscovery())
+}
+
+// JWKS 签名公钥
+// @Summary OIDC签名公钥
+// @Tags OIDC
+// @Produce application/json
+// @Success 200 {object} map[string]any
+// @Router /oidc/jwks [get]
+func (m *OidcController) JWKS(c *gin.Context) {
+	data, err := logic.OidcJWKS()
+	if err != nil {
+		common.Log.Errorf("获取OIDC签名公钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, logic.OidcError{Code: "server_error", Description: "签名密钥不可用"})
+		return
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// Authorize 授权端点, 未登录时展示登录页, 登录校验复用后台的登录逻辑(含二次验证)
+// @Summary OIDC授权端点
+// @Tags OIDC
+// @Produce text/html
+// @Router /oidc/authorize [get]
+func (m *OidcController) Authorize(c *gin.Context) {
+	req := new(request.OidcAuthorizeReq)
+	_ = c.ShouldBind(req)
+
+	client, err := logic.OidcCheckAuthorize(req)
+	if err != nil {
+		var oidcErr *logic.OidcError
+		if errors.As(err, &oidcErr) {
+			c.Redirect(http.StatusFound, logic.OidcErrorRedirect(req, oidcErr))
+			return
+		}
+		renderOidcPage(c, http.StatusBadRequest, nil, nil, err.Error(), "")
+		return
+	}
+
+	// 已在授权页登录过且会话未过期, 直接签发授权码
+	if c.Request.Method == http.MethodGet {
+		if session, err := c.Cookie(oidcSessionCookie); err == nil {
+			if user, authTime, err := logic.OidcSessionUser(session); err == nil {
+				c.Redirect(http.StatusFound, logic.OidcIssueCode(req, user, authTime))
+				return
+			}
+		}
+		renderOidcPage(c, http.StatusOK, client, req, "", "")
+		return
+	}
+
+	var user *model.User
+	if req.MfaTicket != "" {
+		user, _, err = logic.MfaLogin(req.MfaTicket, req.MfaCode, c.ClientIP())
+		if err != nil {
+			renderOidcPage(c, http.StatusOK, client, req, err.Error(), req.MfaTicket)
+			return
+		}
+	} else {
+		user, err = logic.CommonLogin(req.Username, req.Password, c.ClientIP())
+		if err != nil {
+			renderOidcPage(c, http.StatusOK, client, req, err.Error(), "")
+			return
+		}
+		if challenge, ok := logic.MfaChallenge(user); ok {
+			if enrolled, _ := challenge["mfaEnrolled"].(bool); !enrolled {
+				renderOidcPage(c, http.StatusOK, client, req, "所属角色要求开启二次验证，请先登录管理后台完成绑定", "")
+				return
+			}
+			renderOidcPage(c, http.StatusOK, client, req, "", challenge["mfaTicket"].(string))
+			return
+		}
+	}
+
+	token, expires, err := logic.OidcSessionToken(user)
+	if err != nil {
+		common.Log.Errorf("签发OIDC会话失败: %v", err)
+	} else {
+		secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcSessionCookie, token, int(time.Until(expires).Seconds()), "/"+config.Conf.System.UrlPathPrefix+"/oidc", "", secure, true)
+	}
+	c.Redirect(http.StatusFound, logic.OidcIssueCode(req, user, time.Now().Unix()))
+}
+
+// Token 令牌端点
+// @Summary OIDC令牌端点
+// @Tags OIDC
+// @Accept application/x-www-form-urlencoded
+// @Produce application/json
+// @Success 200 {object} map[string]any
+// @Router /oidc/token [post]
+func (m *OidcController) Token(c *gin.Context) {
+	req := new(request.OidcTokenReq)
+	_ = c.ShouldBind(req)
+	// client_secret_basic
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	data, oidcErr := logic.OidcExchangeCode(req)
+	if oidcErr != nil {
+		status := http.StatusBadRequest
+		if oidcErr.Code == "invalid_client" {
+			status = http.StatusUnauthorized
+		} else if oidcErr.Code == "server_error" {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, oidcErr)
+		return
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// UserInfo 用户信息端点
+// @Summary OIDC用户信息端点
+// @Tags OIDC
+// @Produce application/json
+// @Param Authorization header string true "Bearer access_token"
+// @Success 200 {object} map[string]any
+// @Router /oidc/userinfo [get]
+func (m *OidcController) UserInfo(c *gin.Context) {
+	accessToken := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer"))
+	data, oidcErr := logic.OidcUserInfo(accessToken)
+	if oidcErr != nil {
+		c.Header("WWW-Authenticate", `Bearer error="`+oidcErr.Code+`"`)
+		c.JSON(http.StatusUnauthorized, oidcErr)
+		return
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// ClientList OIDC应用列表
+// @Summary 获取OIDC应用列表
+// @Tags OIDC
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /oidc/client/list [get]
+// @Security ApiKeyAuth
+func (m *OidcController) ClientList(c *gin.Context) {
+	req := new(request.OidcClientListReq)
+	Run(c, req, func() (any, any) {
+		return logic.OidcClient.List(c, req)
+	})
+}
+
+// ClientAdd 添加OIDC应用
+// @Summary 添加OIDC应用
+// @Description 添加OIDC应用, client_secret仅在创建时返回一次
+// @Tags OIDC
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.OidcClientAddReq true "添加OIDC应用的结构体"
+// @Success 200 {object} response.ResponseBody
+// @Router /oidc/client/add [post]
+// @Security ApiKeyAuth
+func (m *OidcController) ClientAdd(c *gin.Context) {
+	req := new(request.OidcClientAddReq)
+	Run(c, req, func() (any, any) {
+		return logic.OidcClient.Add(c, req)
+	})
+}
+
+// ClientUpdate 更新OIDC应用
+// @Summary 更新OIDC应用
+// @Tags OIDC
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.OidcClientUpdateReq true "更新OIDC应用的结构体"
+// @Success 200 {object} response.ResponseBody
+// @Router /oidc/client/update [post]
+// @Security ApiKeyAuth
+func (m *OidcController) ClientUpdate(c *gin.Context) {
+	req := new(request.OidcClientUpdateReq)
+	Run(c, req, func() (any, any) {
+		return logic.OidcClient.Update(c, req)
+	})
+}
+
+// ClientResetSecret 重置OIDC应用密钥
+// @Summary 重置OIDC应用密钥
+// @Tags OIDC
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.OidcClientResetSecretReq true "应用ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /oidc/client/resetSecret [post]
+// @Security ApiKeyAuth
+func (m *OidcController) ClientResetSecret(c *gin.Context) {
+	req := new(request.OidcClientResetSecretReq)
+	Run(c, req, func() (any, any) {
+		return logic.OidcClient.ResetSecret(c, req)
+	})
+}
+
+// ClientDelete 删除OIDC应用
+// @Summary 删除OIDC应用
+// @Tags OIDC
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.OidcClientDeleteReq true "应用ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /oidc/client/delete [post]
+// @Security ApiKeyAuth
+func (m *OidcController) ClientDelete(c *gin.Context) {
+	req := new(request.OidcClientDeleteReq)
+	Run(c, req, func() (any, any) {
+		return logic.OidcClient.Delete(c, req)
+	})
+}
+
+// renderOidcPage 渲染授权页, client为空时只展示错误
+func renderOidcPage(c *gin.Context, status int, client *model.OidcClient, req *request.OidcAuthorizeReq, errMsg, mfaTicket string) {
+	data := gin.H{
+		"Error":     errMsg,
+		"MfaTicket": mfaTicket,
+	}
+	if client != nil && req != nil {
+		params := map[string]string{
+			"response_type":         req.ResponseType,
+			"client_id":             req.ClientID,
+			"redirect_uri":          req.RedirectURI,
+			"scope":                 req.Scope,
+			"state":                 req.State,
+			"nonce":                 req.Nonce,
+			"code_challenge":        req.CodeChallenge,
+			"code_challenge_method": req.CodeChallengeMethod,
+		}
+		q := url.Values{}
+		for k, v := range params {
+			if v == "" {
+				delete(params, k)
+				continue
+			}
+			q.Set(k, v)
+		}
+		data["ClientName"] = client.Name
+		data["Params"] = params
+		data["LoginURL"] = c.Request.URL.Path + "?" + q.Encode()
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	_ = oidcLoginTpl.Execute(c.Writer, data)
+}
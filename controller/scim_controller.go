@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+type ScimController struct{}
+
+// scimContentType SCIM响应的媒体类型
+const scimContentType = "application/scim+json; charset=utf-8"
+
+// scimRun SCIM接口按RFC7644返回资源本身与对应的http状态码, 不使用平台统一的响应格式
+func scimRun(c *gin.Context, bind func() error, status int, fn func() (any, any)) {
+	c.Header("Content-Type", scimContentType)
+	if bind != nil {
+		if err := bind(); err != nil {
+			code, rsp := logic.ScimErrorOf(&tools.ScimError{ScimType: tools.ScimInvalidSyntax, Err: err})
+			c.JSON(code, rsp)
+			return
+		}
+	}
+	data, rspError := fn()
+	if rspError != nil {
+		code, rsp := logic.ScimErrorOf(rspError)
+		c.JSON(code, rsp)
+		return
+	}
+	if status == http.StatusNoContent {
+		c.Status(status)
+		return
+	}
+	c.JSON(status, data)
+}
+
+// ServiceProviderConfig 服务端支持的功能
+// @Summary SCIM服务端配置
+// @Tags SCIM
+// @Produce application/scim+json
+// @Success 200 {object} map[string]any
+// @Router /scim/v2/ServiceProviderConfig [get]
+// @Security ApiKeyAuth
+func (m *ScimController) ServiceProviderConfig(c *gin.Context) {
+	scimRun(c, nil, http.StatusOK, func() (any, any) {
+		return logic.Scim.ServiceProviderConfig(c, nil)
+	})
+}
+
+// Schemas 支持的schema
+// @Summary SCIM schema定义
+// @Tags SCIM
+// @Produce application/scim+json
+// @Param id path string false "schema"
+// @Success 200 {object} response.ScimListRsp
+// @Router /scim/v2/Schemas [get]
+// @Security ApiKeyAuth
+func (m *ScimController) Schemas(c *gin.Context) {
+	req := &request.ScimInfoReq{ID: c.Param("id")}
+	scimRun(c, nil, http.StatusOK, func() (any, any) {
+		return logic.Scim.Schemas(c, req)
+	})
+}
+
+// ResourceTypes 支持的资源类型
+// @Summary SCIM资源类型
+// @Tags SCIM
+// @Produce application/scim+json
+// @Param id path string false "资源类型"
+// @Success 200 {object} response.ScimListRsp
+// @Router /scim/v2/ResourceTypes [get]
+// @Security ApiKeyAuth
+func (m *ScimController) ResourceTypes(c *gin.Context) {
+	req := &request.ScimInfoReq{ID: c.Param("id")}
+	scimRun(c, nil, http.StatusOK, func() (any, any) {
+		return logic.Scim.ResourceTypes(c, req)
+	})
+}
+
+// UserList 用户列表
+// @Summary SCIM用户列表
+// @Description 支持filter过滤与startIndex、count分页
+// @Tags SCIM
+// @Produce application/scim+json
+// @Param filter query string false "过滤条件, 如 userName eq \"zhangsan\""
+// @Param startIndex query int false "起始序号, 从1开始"
+// @Param count query int false "每页数量"
+// @Success 200 {object} response.ScimListRsp
+// @Router /scim/v2/Users [get]
+// @Security ApiKeyAuth
+func (m *ScimController) UserList(c *gin.Context) {
+	req := new(request.ScimListReq)
+	scimRun(c, func() error { return c.ShouldBindQuery(req) }, http.StatusOK, func() (any, any) {
+		return logic.Scim.UserList(c, req)
+	})
+}
+
+// UserInfo 获取用户
+// @Summary 获取SCIM用户
+// @Tags SCIM
+// @Produce application/scim+json
+// @Param id path int true "用户ID"
+// @Success 200 {object} model.ScimUser
+// @Router /scim/v2/Users/{id} [get]
+// @Security ApiKeyAuth
+func (m *ScimController) UserInfo(c *gin.Context) {
+	req := &request.ScimInfoReq{ID: c.Param("id")}
+	scimRun(c, nil, http.StatusOK, func() (any, any) {
+		return logic.Scim.UserInfo(c, req)
+	})
+}
+
+// UserAdd 创建用户
+// @Summary 创建SCIM用户
+// @Description 创建的用户来源为scim, 默认为普通用户角色
+// @Tags SCIM
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Param data body model.ScimUser true "用户"
+// @Success 201 {object} model.ScimUser
+// @Router /scim/v2/Users [post]
+// @Security ApiKeyAuth
+func (m *ScimController) UserAdd(c *gin.Context) {
+	req := new(request.ScimUserReq)
+	scimRun(c, func() error { return c.ShouldBindWith(&req.User, binding.JSON) }, http.StatusCreated, func() (any, any) {
+		return logic.Scim.UserAdd(c, req)
+	})
+}
+
+// UserReplace 替换用户
+// @Summary 替换SCIM用户
+// @Tags SCIM
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Param id path int true "用户ID"
+// @Param data body model.ScimUser true "用户"
+// @Success 200 {object} model.ScimUser
+// @Router /scim/v2/Users/{id} [put]
+// @Security ApiKeyAuth
+func (m *ScimController) UserReplace(c *gin.Context) {
+	req := &request.ScimUserReq{ID: c.Param("id")}
+	scimRun(c, func() error { return c.ShouldBindWith(&req.User, binding.JSON) }, http.StatusOK, func() (any, any) {
+		return logic.Scim.UserReplace(c, req)
+	})
+}
+
+// UserPatch 修改用户
+// @Summary 修改SCIM用户
+// @Description 支持add、replace、remove操作, active为false时用户离职
+// @Tags SCIM
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Param id path int true "用户ID"
+// @Param data body request.ScimPatchReq true "PATCH操作"
+// @Success 200 {object} model.ScimUser
+// @Router /scim/v2/Users/{id} [patch]
+// @Security ApiKeyAuth
+func (m *ScimController) UserPatch(c *gin.Context) {
+	req := &request.ScimPatchReq{ID: c.Param("id")}
+	scimRun(c, func() error { return c.ShouldBindWith(req, binding.JSON) }, http.StatusOK, func() (any, any) {
+		return logic.Scim.UserPatch(c, req)
+	})
+}
+
+// UserDelete 删除用户
+// @Summary 删除SCIM用户
+// @Tags SCIM
+// @Param id path int true "用户ID"
+// @Success 204
+// @Router /scim/v2/Users/{id} [delete]
+// @Security ApiKeyAuth
+func (m *ScimController) UserDelete(c *gin.Context) {
+	req := &request.ScimDeleteReq{ID: c.Param("id")}
+	scimRun(c, nil, http.StatusNoContent, func() (any, any) {
+		return logic.Scim.UserDelete(c, req)
+	})
+}
+
+// GroupList 分组列表
+// @Summary SCIM分组列表
+// @Description 只包含cn类型的分组, 支持filter过滤与startIndex、count分页
+// @Tags SCIM
+// @Produce application/scim+json
+// @Param filter query string false "过滤条件, 如 displayName eq \"研发\""
+// @Param startIndex query int false "起始序号, 从1开始"
+// @Param count query int false "每页数量"
+// @Param excludedAttributes query string false "不需要返回的属性, 如members"
+// @Success 200 {object} response.ScimListRsp
+// @Router /scim/v2/Groups [get]
+// @Security ApiKeyAuth
+func (m *ScimController) GroupList(c *gin.Context) {
+	req := new(request.ScimListReq)
+	scimRun(c, func() error { return c.ShouldBindQuery(req) }, http.StatusOK, func() (any, any) {
+		return logic.Scim.GroupList(c, req)
+	})
+}
+
+// GroupInfo 获取分组
+// @Summary 获取SCIM分组
+// @Tags SCIM
+// @Produce application/scim+json
+// @Param id path int true "分组ID"
+// @Success 200 {object} model.ScimGroup
+// @Router /scim/v2/Groups/{id} [get]
+// @Security ApiKeyAuth
+func (m *ScimController) GroupInfo(c *gin.Context) {
+	req := &request.ScimInfoReq{ID: c.Param("id")}
+	scimRun(c, nil, http.StatusOK, func() (any, any) {
+		return logic.Scim.GroupInfo(c, req)
+	})
+}
+
+// GroupAdd 创建分组
+// @Summary 创建SCIM分组
+// @Description 分组创建在配置的SCIM根部门之下
+// @Tags SCIM
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Param data body model.ScimGroup true "分组"
+// @Success 201 {object} model.ScimGroup
+// @Router /scim/v2/Groups [post]
+// @Security ApiKeyAuth
+func (m *ScimController) GroupAdd(c *gin.Context) {
+	req := new(request.ScimGroupReq)
+	scimRun(c, func() error { return c.ShouldBindWith(&req.Group, binding.JSON) }, http.StatusCreated, func() (any, any) {
+		return logic.Scim.GroupAdd(c, req)
+	})
+}
+
+// GroupReplace 替换分组
+// @Summary 替换SCIM分组
+// @Tags SCIM
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Param id path int true "分组ID"
+// @Param data body model.ScimGroup true "分组"
+// @Success 200 {object} model.ScimGroup
+// @Router /scim/v2/Groups/{id} [put]
+// @Security ApiKeyAuth
+func (m *ScimController) GroupReplace(c *gin.Context) {
+	req := &request.ScimGroupReq{ID: c.Param("id")}
+	scimRun(c, func() error { return c.ShouldBindWith(&req.Group, binding.JSON) }, http.StatusOK, func() (any, any) {
+		return logic.Scim.GroupReplace(c, req)
+	})
+}
+
+// GroupPatch 修改分组
+// @Summary 修改SCIM分组
+// @Description 支持add、replace、remove操作, 常用于添加与移除成员
+// @Tags SCIM
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Param id path int true "分组ID"
+// @Param data body request.ScimPatchReq true "PATCH操作"
+// @Success 200 {object} model.ScimGroup
+// @Router /scim/v2/Groups/{id} [patch]
+// @Security ApiKeyAuth
+func (m *ScimController) GroupPatch(c *gin.Context) {
+	req := &request.ScimPatchReq{ID: c.Param("id")}
+	scimRun(c, func() error { return c.ShouldBindWith(req, binding.JSON) }, http.StatusOK, func() (any, any) {
+		return logic.Scim.GroupPatch(c, req)
+	})
+}
+
+// GroupDelete 删除分组
+// @Summary 删除SCIM分组
+// @Tags SCIM
+// @Param id path int true "分组ID"
+// @Success 204
+// @Router /scim/v2/Groups/{id} [delete]
+// @Security ApiKeyAuth
+func (m *ScimController) GroupDelete(c *gin.Context) {
+	req := &request.ScimDeleteReq{ID: c.Param("id")}
+	scimRun(c, nil, http.StatusNoContent, func() (any, any) {
+		return logic.Scim.GroupDelete(c, req)
+	})
+}
+
+// Bulk 批量操作
+// @Summary SCIM批量操作
+// @Description 按顺序执行用户与分组的创建、替换、修改与删除, 可以通过bulkId引用前面创建的资源
+// @Tags SCIM
+// @Accept application/scim+json
+// @Produce application/scim+json
+// @Param data body request.ScimBulkReq true "批量操作"
+// @Success 200 {object} response.ScimBulkRsp
+// @Router /scim/v2/Bulk [post]
+// @Security ApiKeyAuth
+func (m *ScimController) Bulk(c *gin.Context) {
+	req := new(request.ScimBulkReq)
+	// 读取时限制请求体大小, 分块传输的请求没有Content-Length, 超过限制时返回413
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, logic.ScimMaxPayloadSize)
+	scimRun(c, func() error { return c.ShouldBindWith(req, binding.JSON) }, http.StatusOK, func() (any, any) {
+		return logic.Scim.Bulk(c, req)
+	})
+}
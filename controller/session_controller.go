@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct{}
+
+// Mine 当前用户的会话列表
+// @Summary 获取当前用户的会话列表
+// @Tags 会话管理
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /session/mine [get]
+// @Security ApiKeyAuth
+func (m *SessionController) Mine(c *gin.Context) {
+	req := new(request.SessionMineReq)
+	Run(c, req, func() (any, any) {
+		return logic.Session.Mine(c, req)
+	})
+}
+
+// RevokeMine 吊销当前用户的会话
+// @Summary 吊销当前用户的会话
+// @Tags 会话管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SessionRevokeReq true "会话ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /session/revokeMine [post]
+// @Security ApiKeyAuth
+func (m *SessionController) RevokeMine(c *gin.Context) {
+	req := new(request.SessionRevokeReq)
+	Run(c, req, func() (any, any) {
+		return logic.Session.RevokeMine(c, req)
+	})
+}
+
+// List 指定用户的会话列表
+// @Summary 获取指定用户的会话列表
+// @Tags 会话管理
+// @Accept application/json
+// @Produce application/json
+// @Param userId query int true "用户ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /session/list [get]
+// @Security ApiKeyAuth
+func (m *SessionController) List(c *gin.Context) {
+	req := new(request.SessionListReq)
+	Run(c, req, func() (any, any) {
+		return logic.Session.List(c, req)
+	})
+}
+
+// Revoke 吊销指定会话
+// @Summary 吊销指定会话
+// @Tags 会话管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SessionRevokeReq true "会话ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /session/revoke [post]
+// @Security ApiKeyAuth
+func (m *SessionController) Revoke(c *gin.Context) {
+	req := new(request.SessionRevokeReq)
+	Run(c, req, func() (any, any) {
+		return logic.Session.Revoke(c, req)
+	})
+}
+
+// RevokeUser 吊销用户的全部会话
+// @Summary 吊销用户的全部会话
+// @Tags 会话管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SessionRevokeUserReq true "用户ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /session/revokeUser [post]
+// @Security ApiKeyAuth
+func (m *SessionController) RevokeUser(c *gin.Context) {
+	req := new(request.SessionRevokeUserReq)
+	Run(c, req, func() (any, any) {
+		return logic.Session.RevokeUser(c, req)
+	})
+}
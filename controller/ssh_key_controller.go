@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SshKeyController struct{}
+
+// Mine 当前用户的SSH公钥列表
+// @Summary 获取当前用户的SSH公钥列表
+// @Tags SSH公钥
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /user/sshKey/mine [get]
+// @Security ApiKeyAuth
+func (m *SshKeyController) Mine(c *gin.Context) {
+	req := new(request.SshKeyMineReq)
+	Run(c, req, func() (any, any) {
+		return logic.SshKey.Mine(c, req)
+	})
+}
+
+// AddMine 当前用户添加SSH公钥
+// @Summary 当前用户添加SSH公钥
+// @Tags SSH公钥
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SshKeyAddMineReq true "SSH公钥"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/sshKey/addMine [post]
+// @Security ApiKeyAuth
+func (m *SshKeyController) AddMine(c *gin.Context) {
+	req := new(request.SshKeyAddMineReq)
+	Run(c, req, func() (any, any) {
+		return logic.SshKey.AddMine(c, req)
+	})
+}
+
+// DeleteMine 当前用户删除SSH公钥
+// @Summary 当前用户删除SSH公钥
+// @Tags SSH公钥
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SshKeyDeleteReq true "SSH公钥ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/sshKey/deleteMine [post]
+// @Security ApiKeyAuth
+func (m *SshKeyController) DeleteMine(c *gin.Context) {
+	req := new(request.SshKeyDeleteReq)
+	Run(c, req, func() (any, any) {
+		return logic.SshKey.DeleteMine(c, req)
+	})
+}
+
+// List 指定用户的SSH公钥列表
+// @Summary 获取指定用户的SSH公钥列表
+// @Tags SSH公钥
+// @Accept application/json
+// @Produce application/json
+// @Param userId query int true "用户ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/sshKey/list [get]
+// @Security ApiKeyAuth
+func (m *SshKeyController) List(c *gin.Context) {
+	req := new(request.SshKeyListReq)
+	Run(c, req, func() (any, any) {
+		return logic.SshKey.List(c, req)
+	})
+}
+
+// Add 为用户添加SSH公钥
+// @Summary 为用户添加SSH公钥
+// @Tags SSH公钥
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SshKeyAddReq true "用户ID与SSH公钥"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/sshKey/add [post]
+// @Security ApiKeyAuth
+func (m *SshKeyController) Add(c *gin.Context) {
+	req := new(request.SshKeyAddReq)
+	Run(c, req, func() (any, any) {
+		return logic.SshKey.Add(c, req)
+	})
+}
+
+// Delete 删除用户的SSH公钥
+// @Summary 删除用户的SSH公钥
+// @Tags SSH公钥
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SshKeyDeleteReq true "SSH公钥ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/sshKey/delete [post]
+// @Security ApiKeyAuth
+func (m *SshKeyController) Delete(c *gin.Context) {
+	req := new(request.SshKeyDeleteReq)
+	Run(c, req, func() (any, any) {
+		return logic.SshKey.Delete(c, req)
+	})
+}
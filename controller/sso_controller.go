@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SsoController struct{}
+
+// Providers 获取已开启的扫码登录方式
+// @Summary 获取已开启的扫码登录方式
+// @Description 返回已开启的第三方登录方式: dingtalk、feishu、wecom
+// @Tags 基础管理
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /base/sso/providers [get]
+func (m *SsoController) Providers(c *gin.Context) {
+	req := new(request.SsoProvidersReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sso.Providers(c, req)
+	})
+}
+
+// Authorize 获取第三方登录授权地址
+// @Summary 获取第三方登录授权地址
+// @Description 前端跳转到返回的地址扫码, 回调后将code与state提交到登录接口
+// @Tags 基础管理
+// @Accept application/json
+// @Produce application/json
+// @Param provider query string true "登录方式: dingtalk、feishu、wecom"
+// @Success 200 {object} response.ResponseBody
+// @Router /base/sso/authorize [get]
+func (m *SsoController) Authorize(c *gin.Context) {
+	req := new(request.SsoAuthorizeReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sso.Authorize(c, req)
+	})
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model/request"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SyncController struct{}
+
+// PlanList 同步计划列表
+// @Summary 获取同步计划列表
+// @Description 获取预演同步时生成的同步计划, 列表中不包含计划明细
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/plan/list [get]
+// @Security ApiKeyAuth
+func (m *SyncController) PlanList(c *gin.Context) {
+	req := new(request.SyncPlanListReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.PlanList(c, req)
+	})
+}
+
+// PlanInfo 同步计划详情
+// @Summary 获取同步计划详情
+// @Description 获取同步计划中待新建、更新、移动、禁用、删除的分组与用户
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Param id query int true "同步计划ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/plan/info [get]
+// @Security ApiKeyAuth
+func (m *SyncController) PlanInfo(c *gin.Context) {
+	req := new(request.SyncPlanInfoReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.PlanInfo(c, req)
+	})
+}
+
+// PlanApply 执行同步计划
+// @Summary 执行同步计划
+// @Description 按审核过的同步计划执行, 计划生成后已变化的项不会执行; 执行失败的计划可以再次执行, 只重试失败的项
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SyncPlanApplyReq true "同步计划ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/plan/apply [post]
+// @Security ApiKeyAuth
+func (m *SyncController) PlanApply(c *gin.Context) {
+	req := new(request.SyncPlanApplyReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.PlanApply(c, req)
+	})
+}
+
+// PlanDelete 删除同步计划
+// @Summary 删除同步计划
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SyncPlanDeleteReq true "同步计划ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/plan/delete [post]
+// @Security ApiKeyAuth
+func (m *SyncController) PlanDelete(c *gin.Context) {
+	req := new(request.SyncPlanDeleteReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.PlanDelete(c, req)
+	})
+}
+
+// RunList 同步记录列表
+// @Summary 获取同步记录列表
+// @Description 获取每次同步的来源、触发方式、起止时间与各动作的数量
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/run/list [get]
+// @Security ApiKeyAuth
+func (m *SyncController) RunList(c *gin.Context) {
+	req := new(request.SyncRunListReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.RunList(c, req)
+	})
+}
+
+// RunItems 同步记录的执行结果
+// @Summary 获取同步记录的执行结果
+// @Description 获取一次同步中每个分组与用户的执行结果及失败原因
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Param runId query int true "同步记录ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/run/items [get]
+// @Security ApiKeyAuth
+func (m *SyncController) RunItems(c *gin.Context) {
+	req := new(request.SyncRunItemsReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.RunItems(c, req)
+	})
+}
+
+// SourceList 身份源列表
+// @Summary 获取身份源列表
+// @Description 获取已注册的身份源及其同步配置与连接配置项
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/source/list [get]
+// @Security ApiKeyAuth
+func (m *SyncController) SourceList(c *gin.Context) {
+	req := new(request.SyncSourceListReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.SourceList(c, req)
+	})
+}
+
+// SourceDepts 从身份源同步部门
+// @Summary 从身份源同步部门
+// @Description 按来源标识从已注册的身份源同步部门, 预演时只生成同步计划
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SyncSourceReq true "来源标识与是否预演"
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/source/depts [post]
+// @Security ApiKeyAuth
+func (m *SyncController) SourceDepts(c *gin.Context) {
+	req := new(request.SyncSourceReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.SourceDepts(c, req)
+	})
+}
+
+// SourceUsers 从身份源同步用户
+// @Summary 从身份源同步用户
+// @Description 按来源标识从已注册的身份源同步用户, 预演时只生成同步计划
+// @Tags 同步管理
+// @Accept application/json
+// @Produce application/json
+// @Param data body request.SyncSourceReq true "来源标识与是否预演"
+// @Success 200 {object} response.ResponseBody
+// @Router /sync/source/users [post]
+// @Security ApiKeyAuth
+func (m *SyncController) SourceUsers(c *gin.Context) {
+	req := new(request.SyncSourceReq)
+	Run(c, req, func() (any, any) {
+		return logic.Sync.SourceUsers(c, req)
+	})
+}
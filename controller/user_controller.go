@@ -127,6 +127,57 @@ func (m UserController) ChangeUserStatus(c *gin.Context) {
 	})
 }
 
+// Unlock 解除用户登录锁定
+// @Summary 解除用户登录锁定
+// @Description 解除用户因连续登录失败导致的锁定
+// @Tags 用户管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.UserUnlockReq true "用户ID列表"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/unlock [post]
+// @Security ApiKeyAuth
+func (m UserController) Unlock(c *gin.Context) {
+	req := new(request.UserUnlockReq)
+	Run(c, req, func() (any, any) {
+		return logic.User.Unlock(c, req)
+	})
+}
+
+// PosixBackfill 回填用户的POSIX账号信息
+// @Summary 回填用户的POSIX账号信息
+// @Description 为已有用户分配uidNumber并在LDAP中追加posixAccount
+// @Tags 用户管理
+// @Accept application/json
+// @Produce application/json
+// @Param  data body request.UserPosixBackfillReq true "用户ID列表，为空时回填全部"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/posixBackfill [post]
+// @Security ApiKeyAuth
+func (m UserController) PosixBackfill(c *gin.Context) {
+	req := new(request.UserPosixBackfillReq)
+	Run(c, req, func() (any, any) {
+		return logic.User.PosixBackfill(c, req)
+	})
+}
+
+// EffectiveGroups 获取用户的有效分组
+// @Summary 获取用户的有效分组
+// @Description 获取用户直接所属的分组与通过嵌套分组继承的分组
+// @Tags 用户管理
+// @Accept application/json
+// @Produce application/json
+// @Param userId query int true "用户ID"
+// @Success 200 {object} response.ResponseBody
+// @Router /user/effectiveGroups [get]
+// @Security ApiKeyAuth
+func (m UserController) EffectiveGroups(c *gin.Context) {
+	req := new(request.UserEffectiveGroupsReq)
+	Run(c, req, func() (any, any) {
+		return logic.User.EffectiveGroups(c, req)
+	})
+}
+
 // GetUserInfo 获取当前登录用户信息
 // @Summary 获取当前登录用户信息
 // @Description 获取当前登录用户信息
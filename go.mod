@@ -9,6 +9,7 @@ require (
 	github.com/fsnotify/fsnotify v1.5.4
 	github.com/gin-gonic/gin v1.6.3
 	github.com/glebarez/sqlite v1.7.0
+	github.com/go-asn1-ber/asn1-ber v1.5.1
 	github.com/go-ldap/ldap/v3 v3.4.2
 	github.com/go-playground/locales v0.14.0
 	github.com/go-playground/universal-translator v0.18.0
@@ -31,8 +32,10 @@ require (
 
 require (
 	github.com/chyroc/lark v0.0.96
+	github.com/golang-jwt/jwt/v4 v4.5.2
 	github.com/tidwall/gjson v1.13.0
 	github.com/wenerme/go-wecom v0.0.0-20220617125121-2ee950da3e63
+	golang.org/x/crypto v0.5.0
 	gorm.io/datatypes v1.1.0
 )
 
@@ -77,7 +80,6 @@ require (
 	github.com/Knetic/govaluate v3.0.1-0.20171022003610-9aa49832a739+incompatible // indirect
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
 	github.com/gin-contrib/sse v0.1.0 // indirect
-	github.com/go-asn1-ber/asn1-ber v1.5.1 // indirect
 	github.com/go-sql-driver/mysql v1.7.0 // indirect
 	github.com/golang-sql/civil v0.0.0-20220223132316-b832511892a9 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
@@ -108,7 +110,6 @@ require (
 	github.com/ugorji/go/codec v1.2.3 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
-	golang.org/x/crypto v0.5.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 	golang.org/x/text v0.8.0 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
@@ -28,17 +28,35 @@ var (
 	WeCom         = &WeComLogic{}
 	FeiShu        = &FeiShuLogic{}
 	OpenLdap      = &OpenLdapLogic{}
+	HttpSource    = &HttpSourceLogic{}
 	Sql           = &SqlLogic{}
 	Base          = &BaseLogic{}
 	FieldRelation = &FieldRelationLogic{}
+	Mfa           = &MfaLogic{}
+	OidcClient    = &OidcClientLogic{}
+	Sso           = &SsoLogic{}
+	Session       = &SessionLogic{}
+	AccessToken   = &AccessTokenLogic{}
+	SshKey        = &SshKeyLogic{}
+	Directory     = &DirectoryLogic{}
+	Sync          = &SyncLogic{}
+	Scim          = &ScimLogic{}
 
 	json = jsoniter.ConfigCompatibleWithStandardLibrary
 )
 
 // CommonAddGroup 标准创建分组
 func CommonAddGroup(group *model.Group) error {
+	if group.GroupType == "cn" && group.GroupSchema == "" {
+		group.GroupSchema = common.DefaultGroupSchema()
+	}
+	err := allocPosixGroup(group)
+	if err != nil {
+		return err
+	}
+
 	// 先在ldap中创建组
-	err := ildap.Group.Add(group)
+	err = ildap.Group.Add(group)
 	if err != nil {
 		return err
 	}
@@ -118,18 +136,26 @@ func CommonAddUser(user *model.User, groups []*model.Group) error {
 		user.Mobile = generateMobile()
 	}
 
+	// 数据库中只保存密码哈希，先留存明文密码用于通知邮件与写入ldap
+	passwd := user.Password
+
+	err := allocPosixUser(user)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("%s", "分配uidNumber失败："+err.Error()))
+	}
+
 	// 先将用户添加到MySQL
-	err := isql.User.Add(user)
+	err = isql.User.Add(user)
 	if err != nil {
 		return tools.NewMySqlError(fmt.Errorf("%s", "向MySQL创建用户失败："+err.Error()))
 	}
 
 	// 发送用户创建成功通知邮件
-	if err := tools.SendUserCreationNotification(user.Username, user.Nickname, user.Mail, tools.NewParPasswd(user.Password)); err != nil {
+	if err := tools.SendUserCreationNotification(user.Username, user.Nickname, user.Mail, passwd); err != nil {
 		common.Log.Warnf("发送用户创建通知邮件失败，用户: %s, 邮箱: %s, 错误: %v", user.Username, user.Mail, err)
 	}
 	// 再将用户添加到ldap
-	err = ildap.User.Add(user)
+	err = ildap.User.Add(user, passwd)
 	if err != nil {
 		return tools.NewLdapError(fmt.Errorf("%s", "AddUser向LDAP创建用户失败："+err.Error()))
 	}
@@ -150,6 +176,9 @@ func CommonAddUser(user *model.User, groups []*model.Group) error {
 			return tools.NewMySqlError(fmt.Errorf("%s", "向Ldap添加用户到分组关系失败："+err.Error()))
 		}
 	}
+	if err := materializeNestedGroups(groupIds(groups)...); err != nil {
+		return tools.NewLdapError(fmt.Errorf("%s", "展开嵌套分组成员失败："+err.Error()))
+	}
 	return nil
 }
 
@@ -213,6 +242,9 @@ func CommonUpdateUser(oldUser, newUser *model.User, groupId []uint) error {
 			return tools.NewMySqlError(fmt.Errorf("%s", "在ldap将用户从分组移除失败："+err.Error()))
 		}
 	}
+	if err := materializeNestedGroups(append(groupIds(addgroups), groupIds(removegroups)...)...); err != nil {
+		return tools.NewLdapError(fmt.Errorf("%s", "展开嵌套分组成员失败："+err.Error()))
+	}
 	return nil
 }
 
@@ -339,55 +371,52 @@ func ConvertUserData(flag string, remoteData []map[string]any) (users []*model.U
 func InitCron() {
 	c := cron.New(cron.WithSeconds())
 
-	if config.Conf.DingTalk.EnableSync {
-		//启动定时任务
-		_, err := c.AddFunc(config.Conf.DingTalk.DeptSyncTime, func() {
-			DingTalk.SyncDingTalkDepts(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步部门的定时任务失败: %v", err)
-		}
-		//每天凌晨1点执行一次
-		_, err = c.AddFunc(config.Conf.DingTalk.UserSyncTime, func() {
-			DingTalk.SyncDingTalkUsers(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步用户的定时任务失败: %v", err)
+	// 按已注册身份源的配置启动定时同步
+	for _, p := range syncProviders {
+		cfg := p.Config()
+		if !cfg.EnableSync {
+			continue
 		}
-	}
-	if config.Conf.WeCom.EnableSync {
-		_, err := c.AddFunc(config.Conf.WeCom.DeptSyncTime, func() {
-			WeCom.SyncWeComDepts(nil, nil)
+		_, err := c.AddFunc(cfg.DeptSyncTime, func() {
+			syncDepts(nil, p, p.Config().DryRun)
 		})
 		if err != nil {
-			common.Log.Errorf("启动同步部门的定时任务失败: %v", err)
+			common.Log.Errorf("启动同步%s部门的定时任务失败: %v", p.Name(), err)
 		}
-		//每天凌晨1点执行一次
-		_, err = c.AddFunc(config.Conf.WeCom.UserSyncTime, func() {
-			WeCom.SyncWeComUsers(nil, nil)
+		_, err = c.AddFunc(cfg.UserSyncTime, func() {
+			syncUsers(nil, p, p.Config().DryRun)
 		})
 		if err != nil {
-			common.Log.Errorf("启动同步用户的定时任务失败: %v", err)
+			common.Log.Errorf("启动同步%s用户的定时任务失败: %v", p.Name(), err)
 		}
 	}
-	if config.Conf.FeiShu.EnableSync {
-		_, err := c.AddFunc(config.Conf.FeiShu.DeptSyncTime, func() {
-			FeiShu.SyncFeiShuDepts(nil, nil)
-		})
-		if err != nil {
-			common.Log.Errorf("启动同步部门的定时任务失败: %v", err)
+
+	// 提醒密码即将过期的用户
+	if policy := config.Conf.PasswordPolicy; policy != nil && policy.MaxAge > 0 {
+		spec := policy.ExpireCheckTime
+		if spec == "" {
+			spec = "0 0 9 * * *" // 默认每天9点执行一次
 		}
-		//每天凌晨1点执行一次
-		_, err = c.AddFunc(config.Conf.FeiShu.UserSyncTime, func() {
-			FeiShu.SyncFeiShuUsers(nil, nil)
-		})
+		_, err := c.AddFunc(spec, NotifyPwdExpiring)
 		if err != nil {
-			common.Log.Errorf("启动同步用户的定时任务失败: %v", err)
+			common.Log.Errorf("启动密码过期提醒的定时任务失败: %v", err)
 		}
 	}
 
+	// 每小时清理一次已过期的会话
+	_, err := c.AddFunc("0 0 * * * *", CleanSessions)
+	if err != nil {
+		common.Log.Errorf("启动清理过期会话的定时任务失败: %v", err)
+	}
+
+	// 每10分钟删除一次已过期的SSH公钥
+	_, err = c.AddFunc("0 */10 * * * *", CleanExpiredSshKeys)
+	if err != nil {
+		common.Log.Errorf("启动清理过期SSH公钥的定时任务失败: %v", err)
+	}
+
 	// 自动检索未同步数据
-	_, err := c.AddFunc("0 */2 * * * *", func() {
+	_, err = c.AddFunc("0 */2 * * * *", func() {
 		// 开发调试时调整为10秒执行一次
 		// _, err := c.AddFunc("*/10 * * * * *", func() {
 		_ = SearchGroupDiff()
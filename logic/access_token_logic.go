@@ -0,0 +1,178 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+)
+
+type AccessTokenLogic struct{}
+
+const (
+	// 访问令牌前缀, 用于和jwt区分
+	accessTokenPrefix = "glat_"
+	// 最近使用时间的更新间隔, 避免每个请求都写库
+	accessTokenTouchInterval = time.Minute
+)
+
+// IsAccessToken 判断请求携带的是否为访问令牌
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// AccessTokenAuth 校验访问令牌, 返回令牌所属用户
+func AccessTokenAuth(token, ip string) (*model.User, *model.AccessToken, error) {
+	accessToken := new(model.AccessToken)
+	err := isql.AccessToken.Find(tools.H{"token_hash": hashAccessToken(token)}, accessToken)
+	if err != nil {
+		return nil, nil, errors.New("访问令牌无效")
+	}
+	now := time.Now()
+	if accessToken.ExpiresAt != nil && now.After(*accessToken.ExpiresAt) {
+		return nil, nil, errors.New("访问令牌已过期")
+	}
+	user := new(model.User)
+	err = isql.User.Find(tools.H{"id": accessToken.UserID}, user)
+	if err != nil {
+		return nil, nil, errors.New("访问令牌所属用户不存在")
+	}
+	if user.Status != 1 {
+		return nil, nil, errors.New("访问令牌所属用户已被禁用")
+	}
+
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) > accessTokenTouchInterval || accessToken.LastUsedIp != ip {
+		err = isql.AccessToken.UpdateLastUsed(accessToken.ID, tools.H{"last_used_at": now, "last_used_ip": ip})
+		if err != nil {
+			common.Log.Warnf("更新访问令牌[%s]的使用时间失败: %v", accessToken.Name, err)
+		}
+	}
+	return user, accessToken, nil
+}
+
+// AccessTokenAllowed 判断访问令牌是否被授权访问该接口
+func AccessTokenAllowed(token *model.AccessToken, method, path string) bool {
+	return funk.ContainsString(accessTokenScopes(token), method+":"+path)
+}
+
+// List 当前用户的访问令牌列表
+func (l AccessTokenLogic) List(c *gin.Context, req any) (data any, rspError any) {
+	_, ok := req.(*request.AccessTokenListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	tokens, err := isql.AccessToken.List(ctxUser.ID)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取访问令牌列表失败: %s", err.Error()))
+	}
+	rets := make([]response.AccessTokenRsp, 0, len(tokens))
+	for _, token := range tokens {
+		rets = append(rets, response.AccessTokenRsp{AccessToken: *token, Scopes: accessTokenScopes(token)})
+	}
+	return rets, nil
+}
+
+// Add 创建访问令牌, 令牌明文仅在创建时返回一次
+func (l AccessTokenLogic) Add(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.AccessTokenAddReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if _, ok := c.Get("accessToken"); ok {
+		return nil, tools.NewValidatorError(fmt.Errorf("不能使用访问令牌创建访问令牌"))
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	if isql.AccessToken.Exist(tools.H{"user_id": ctxUser.ID, "name": r.Name}) {
+		return nil, tools.NewValidatorError(fmt.Errorf("访问令牌名称已存在"))
+	}
+
+	apis, err := isql.Api.GetApisById(r.ApiIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取接口信息失败: %s", err.Error()))
+	}
+	if len(apis) == 0 {
+		return nil, tools.NewValidatorError(fmt.Errorf("请选择访问令牌可以访问的接口"))
+	}
+	// 令牌的权限只能是所属用户权限的子集
+	var subs []string
+	for _, role := range ctxUser.Roles {
+		if role.Status == 1 {
+			subs = append(subs, role.Keyword)
+		}
+	}
+	scopes := make([]string, 0, len(apis))
+	for _, api := range apis {
+		if !common.CasbinCheck(subs, api.Path, api.Method) {
+			return nil, tools.NewValidatorError(fmt.Errorf("没有接口 %s %s 的权限", api.Method, api.Path))
+		}
+		scopes = append(scopes, api.Method+":"+api.Path)
+	}
+
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	token := accessTokenPrefix + hex.EncodeToString(buf)
+	accessToken := &model.AccessToken{
+		UserID:    ctxUser.ID,
+		Name:      r.Name,
+		TokenHash: hashAccessToken(token),
+		Prefix:    token[:len(accessTokenPrefix)+8],
+		Scopes:    strings.Join(scopes, "\n"),
+	}
+	if r.ExpireDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, int(r.ExpireDays))
+		accessToken.ExpiresAt = &expiresAt
+	}
+	err = isql.AccessToken.Add(accessToken)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("创建访问令牌失败: %s", err.Error()))
+	}
+	return response.AccessTokenRsp{AccessToken: *accessToken, Scopes: scopes, Token: token}, nil
+}
+
+// Delete 删除当前用户的访问令牌
+func (l AccessTokenLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.AccessTokenDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	err = isql.AccessToken.Delete(tools.H{"id": r.Ids, "user_id": ctxUser.ID})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("删除访问令牌失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func accessTokenScopes(token *model.AccessToken) []string {
+	if token.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(token.Scopes, "\n")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/eryajf/go-ldap-admin/model"
 	"github.com/eryajf/go-ldap-admin/model/request"
 	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
 	"github.com/eryajf/go-ldap-admin/public/tools"
 	"github.com/eryajf/go-ldap-admin/public/version"
 	"github.com/eryajf/go-ldap-admin/service/ildap"
@@ -68,9 +69,14 @@ func (l BaseLogic) ChangePwd(c *gin.Context, req any) (data any, rspError any) {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "通过邮箱查询用户失败"+err.Error()))
 	}
 
-	newpass, err := ildap.User.NewPwd(user.Username)
+	// 在本地生成符合密码策略的新密码, 再写入LDAP
+	newpass, rspErr := randomPolicyPasswd(user)
+	if rspErr != nil {
+		return nil, rspErr
+	}
+	err = ildap.User.ChangePwd(user.UserDN, "", newpass)
 	if err != nil {
-		return nil, tools.NewLdapError(fmt.Errorf("%s", "LDAP生成新密码失败"+err.Error()))
+		return nil, tools.NewLdapError(fmt.Errorf("%s", "LDAP更新密码失败"+err.Error()))
 	}
 
 	err = tools.SendMail([]string{user.Mail}, newpass)
@@ -79,10 +85,12 @@ func (l BaseLogic) ChangePwd(c *gin.Context, req any) (data any, rspError any) {
 	}
 
 	// 更新数据库密码
-	err = isql.User.ChangePwd(user.Username, tools.NewGenPasswd(newpass))
+	err = isql.User.ChangePwd(user.Username, tools.GenPasswd(newpass))
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "在MySQL更新密码失败: "+err.Error()))
 	}
+	// 重置密码后用户需使用新密码重新登录
+	RevokeUserSessions(user.ID)
 
 	return nil, nil
 }
@@ -119,6 +127,10 @@ func (l BaseLogic) Dashboard(c *gin.Context, req any) (data any, rspError any) {
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("获取日志总数失败"))
 	}
+	syncRunCount, err := isql.SyncRun.Count()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取同步记录总数失败"))
+	}
 
 	rst := make([]*response.DashboardList, 0)
 
@@ -165,6 +177,13 @@ func (l BaseLogic) Dashboard(c *gin.Context, req any) (data any, rspError any) {
 			Icon:      "documentation",
 			Path:      "#/log/operation-log",
 		},
+		&response.DashboardList{
+			DataType:  "sync",
+			DataName:  "同步",
+			DataCount: syncRunCount,
+			Icon:      "guide",
+			Path:      "#/log/sync-run",
+		},
 	)
 
 	return rst, nil
@@ -181,17 +200,6 @@ func (l BaseLogic) EncryptPasswd(c *gin.Context, req any) (data any, rspError an
 	return tools.NewGenPasswd(r.Passwd), nil
 }
 
-// DecryptPasswd
-func (l BaseLogic) DecryptPasswd(c *gin.Context, req any) (data any, rspError any) {
-	r, ok := req.(*request.DecryptPasswdReq)
-	if !ok {
-		return nil, ReqAssertErr
-	}
-	_ = c
-
-	return tools.NewParPasswd(r.Passwd), nil
-}
-
 // GetConfig 获取系统配置
 func (l BaseLogic) GetConfig(c *gin.Context, req any) (data any, rspError any) {
 	_, ok := req.(*request.BaseConfigReq)
@@ -228,3 +236,18 @@ func (l BaseLogic) GetVersion(c *gin.Context, req any) (data any, rspError any)
 
 	return version.GetVersion(), nil
 }
+
+// LdapPoolStats 获取LDAP连接池统计
+func (l BaseLogic) LdapPoolStats(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.BaseLdapPoolStatsReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	stats, ok := common.GetLdapDirectoryPoolStats(r.DirectoryId)
+	if !ok {
+		return nil, tools.NewValidatorError(fmt.Errorf("LDAP目录不存在"))
+	}
+	return stats, nil
+}
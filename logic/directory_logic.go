@@ -0,0 +1,292 @@
+package logic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+)
+
+type DirectoryLogic struct{}
+
+// List 数据列表
+func (l DirectoryLogic) List(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.DirectoryListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	directories, err := isql.Directory.List(r)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取LDAP目录列表失败: %s", err.Error()))
+	}
+	rets := make([]model.Directory, 0)
+	for _, directory := range directories {
+		rets = append(rets, *directory)
+	}
+	count, err := isql.Directory.Count()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取LDAP目录总数失败"))
+	}
+	return response.DirectoryListRsp{
+		Total:       count,
+		Directories: rets,
+	}, nil
+}
+
+// Add 添加数据, 连接并绑定管理员成功后才保存
+func (l DirectoryLogic) Add(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.DirectoryAddReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户信息失败"))
+	}
+	if isql.Directory.Exist(tools.H{"name": r.Name}) {
+		return nil, tools.NewValidatorError(fmt.Errorf("目录名称已存在,请勿重复添加"))
+	}
+
+	directory := model.Directory{
+		Name:      r.Name,
+		Url:       r.Url,
+		BaseDN:    r.BaseDN,
+		UserDN:    r.UserDN,
+		AdminDN:   r.AdminDN,
+		AdminPass: r.AdminPass,
+		StartTLS:  r.StartTLS,
+		CAFile:    r.CAFile,
+		MaxConn:   r.MaxConn,
+		Remark:    r.Remark,
+		Creator:   ctxUser.Username,
+	}
+	if rspError := checkDirectory(&directory); rspError != nil {
+		return nil, rspError
+	}
+
+	err = isql.Directory.Add(&directory)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("创建LDAP目录失败: %s", err.Error()))
+	}
+	err = common.RegisterLdapDirectory(&directory)
+	if err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("初始化LDAP目录连接池失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// Update 更新数据, 更新后重建该目录的连接池
+func (l DirectoryLogic) Update(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.DirectoryUpdateReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	oldData := new(model.Directory)
+	err := isql.Directory.Find(tools.H{"id": r.ID}, oldData)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("LDAP目录不存在"))
+	}
+	if r.Name != oldData.Name && isql.Directory.Exist(tools.H{"name": r.Name}) {
+		return nil, tools.NewValidatorError(fmt.Errorf("目录名称已存在"))
+	}
+	// 已有用户或分组的DN由基础DN生成, 此时不能再修改
+	if r.BaseDN != oldData.BaseDN || r.UserDN != oldData.UserDN {
+		inUse, err := isql.Directory.InUse(r.ID)
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("获取目录下的用户与分组失败: %s", err.Error()))
+		}
+		if inUse {
+			return nil, tools.NewValidatorError(fmt.Errorf("目录下已有用户或分组, 不能修改基础DN与用户DN"))
+		}
+	}
+
+	directory := model.Directory{
+		Model:     oldData.Model,
+		Name:      r.Name,
+		Url:       r.Url,
+		BaseDN:    r.BaseDN,
+		UserDN:    r.UserDN,
+		AdminDN:   r.AdminDN,
+		AdminPass: r.AdminPass,
+		StartTLS:  r.StartTLS,
+		CAFile:    r.CAFile,
+		MaxConn:   r.MaxConn,
+		Remark:    r.Remark,
+		Creator:   oldData.Creator,
+	}
+	if directory.AdminPass == "" {
+		directory.AdminPass = oldData.AdminPass
+	}
+	if rspError := checkDirectory(&directory); rspError != nil {
+		return nil, rspError
+	}
+
+	err = isql.Directory.Update(&directory)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("更新LDAP目录失败: %s", err.Error()))
+	}
+	err = common.RegisterLdapDirectory(&directory)
+	if err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("初始化LDAP目录连接池失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// Delete 删除数据, 目录下还有用户或分组时不能删除
+func (l DirectoryLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.DirectoryDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	for _, id := range r.DirectoryIds {
+		if !isql.Directory.Exist(tools.H{"id": id}) {
+			return nil, tools.NewMySqlError(fmt.Errorf("LDAP目录不存在"))
+		}
+		inUse, err := isql.Directory.InUse(id)
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("获取目录下的用户与分组失败: %s", err.Error()))
+		}
+		if inUse {
+			return nil, tools.NewValidatorError(fmt.Errorf("目录%d下还有用户或分组, 不能删除", id))
+		}
+	}
+	err := isql.Directory.Delete(r.DirectoryIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("删除LDAP目录失败: %s", err.Error()))
+	}
+	for _, id := range r.DirectoryIds {
+		common.RemoveLdapDirectory(id)
+	}
+	return nil, nil
+}
+
+// checkDirectory 校验目录配置: 用户DN需位于基础DN之下, 且能够连接并绑定管理员
+func checkDirectory(directory *model.Directory) any {
+	if !strings.HasSuffix(strings.ToLower(directory.UserDN), strings.ToLower(directory.BaseDN)) {
+		return tools.NewValidatorError(fmt.Errorf("用户DN需位于基础DN之下"))
+	}
+	if strings.EqualFold(directory.BaseDN, config.Conf.Ldap.BaseDN) {
+		return tools.NewValidatorError(fmt.Errorf("基础DN与默认目录相同"))
+	}
+	if err := common.TestLdapDirectory(directory); err != nil {
+		return tools.NewLdapError(fmt.Errorf("连接LDAP目录失败: %s", err.Error()))
+	}
+	return nil
+}
+
+// directoryScope 当前登录用户可管理的目录, 返回nil表示不限制
+// 超级管理员与未限制目录的角色不受限制, 其余角色可管理的目录取并集
+func directoryScope(c *gin.Context) ([]uint, error) {
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, err
+	}
+	scope := make([]uint, 0)
+	for _, role := range ctxUser.Roles {
+		if role.Sort == 1 || role.DirectoryIds == "" {
+			return nil, nil
+		}
+		scope = append(scope, tools.StringToSlice(role.DirectoryIds, ",")...)
+	}
+	return scope, nil
+}
+
+// checkDirectoryScope 校验当前登录用户是否可以管理指定的目录
+func checkDirectoryScope(c *gin.Context, directoryIds ...uint) any {
+	scope, err := directoryScope(c)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("获取当前登陆用户可管理的目录失败"))
+	}
+	if scope == nil {
+		return nil
+	}
+	for _, id := range directoryIds {
+		if !funk.Contains(scope, id) {
+			return tools.NewValidatorError(fmt.Errorf("没有权限管理LDAP目录%d", id))
+		}
+	}
+	return nil
+}
+
+// checkUserScope 校验当前登录用户是否可以管理指定用户所在的目录
+func checkUserScope(c *gin.Context, userIds ...uint) any {
+	if len(userIds) == 0 {
+		return nil
+	}
+	users, err := isql.User.GetUserByIds(userIds)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
+	}
+	for _, user := range users {
+		if rspError := checkDirectoryScope(c, user.DirectoryID); rspError != nil {
+			return rspError
+		}
+	}
+	return nil
+}
+
+// checkGroupScope 校验当前登录用户是否可以管理指定分组所在的目录
+func checkGroupScope(c *gin.Context, groups ...*model.Group) any {
+	for _, group := range groups {
+		if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+			return rspError
+		}
+	}
+	return nil
+}
+
+// roleDirectoryIds 校验角色可管理的目录, 受限的用户只能分配自己可管理的目录
+func roleDirectoryIds(c *gin.Context, directoryIds []uint) (string, any) {
+	for _, id := range directoryIds {
+		if !common.LdapDirectoryExist(id) {
+			return "", tools.NewValidatorError(fmt.Errorf("LDAP目录%d不存在", id))
+		}
+	}
+	scope, err := directoryScope(c)
+	if err != nil {
+		return "", tools.NewMySqlError(fmt.Errorf("获取当前登陆用户可管理的目录失败"))
+	}
+	if scope != nil && len(directoryIds) == 0 {
+		return "", tools.NewValidatorError(fmt.Errorf("不能创建不限制目录的角色"))
+	}
+	if rspError := checkDirectoryScope(c, directoryIds...); rspError != nil {
+		return "", rspError
+	}
+	return tools.SliceToString(directoryIds, ","), nil
+}
+
+// checkGroupsDirectory 用户只能加入所属目录中的分组
+func checkGroupsDirectory(directoryId uint, groups []*model.Group) any {
+	for _, group := range groups {
+		if group.DirectoryID != directoryId {
+			return tools.NewValidatorError(fmt.Errorf("分组[%s]与用户不在同一个LDAP目录", group.GroupName))
+		}
+	}
+	return nil
+}
+
+// checkUsersDirectory 分组只能添加所属目录中的用户
+func checkUsersDirectory(group *model.Group, users []model.User) any {
+	for _, user := range users {
+		if user.DirectoryID != group.DirectoryID {
+			return tools.NewValidatorError(fmt.Errorf("用户[%s]与分组不在同一个LDAP目录", user.Username))
+		}
+	}
+	return nil
+}
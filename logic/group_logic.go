@@ -10,6 +10,7 @@ import (
 	"github.com/eryajf/go-ldap-admin/model"
 	"github.com/eryajf/go-ldap-admin/model/request"
 	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
 	"github.com/eryajf/go-ldap-admin/public/tools"
 	"github.com/eryajf/go-ldap-admin/service/ildap"
 	"github.com/eryajf/go-ldap-admin/service/isql"
@@ -41,11 +42,22 @@ func (l GroupLogic) Add(c *gin.Context, req any) (data any, rspError any) {
 		Creator:   ctxUser.Username,
 		Source:    "platform", //默认是平台添加
 	}
+	if group.GroupType == "cn" {
+		group.GroupSchema = r.GroupSchema
+		// AD只有group一种分组objectClass
+		if group.GroupSchema == "" || common.IsActiveDirectory() {
+			group.GroupSchema = common.DefaultGroupSchema()
+		}
+	}
 
 	if r.ParentId == 0 {
+		if !common.LdapDirectoryExist(r.DirectoryId) {
+			return nil, tools.NewValidatorError(fmt.Errorf("LDAP目录不存在"))
+		}
 		group.SourceDeptId = "platform_0"
 		group.SourceDeptParentId = "platform_0"
-		group.GroupDN = fmt.Sprintf("%s=%s,%s", r.GroupType, r.GroupName, config.Conf.Ldap.BaseDN)
+		group.DirectoryID = r.DirectoryId
+		group.GroupDN = fmt.Sprintf("%s=%s,%s", r.GroupType, r.GroupName, common.LdapDirectoryConf(r.DirectoryId).BaseDN)
 	} else {
 		parentGroup := new(model.Group)
 		err := isql.Group.Find(tools.H{"id": r.ParentId}, parentGroup)
@@ -54,14 +66,23 @@ func (l GroupLogic) Add(c *gin.Context, req any) (data any, rspError any) {
 		}
 		group.SourceDeptId = "platform_0"
 		group.SourceDeptParentId = fmt.Sprintf("%s_%d", parentGroup.Source, r.ParentId)
+		group.DirectoryID = parentGroup.DirectoryID
 		group.GroupDN = fmt.Sprintf("%s=%s,%s", r.GroupType, r.GroupName, parentGroup.GroupDN)
 	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
 	// 根据 group_dn 判断分组是否已存在
 	if isql.Group.Exist(tools.H{"group_dn": group.GroupDN}) {
 		return nil, tools.NewValidatorError(fmt.Errorf("该分组对应DN已存在"))
 	}
 
+	err = allocPosixGroup(&group)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("分配gidNumber失败: %s", err.Error()))
+	}
+
 	// 先在ldap中创建组
 	err = ildap.Group.Add(&group)
 	if err != nil {
@@ -95,7 +116,13 @@ func (l GroupLogic) List(c *gin.Context, req any) (data any, rspError any) {
 	if !ok {
 		return nil, ReqAssertErr
 	}
-	_ = c
+
+	// 只返回当前登录用户可管理的目录中的分组
+	scope, err := directoryScope(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户可管理的目录失败"))
+	}
+	r.DirectoryIds = scope
 
 	// 获取数据列表
 	groups, err := isql.Group.List(r)
@@ -124,10 +151,16 @@ func (l GroupLogic) GetTree(c *gin.Context, req any) (data any, rspError any) {
 	if !ok {
 		return nil, ReqAssertErr
 	}
-	_ = c
+
+	// 只返回当前登录用户可管理的目录中的分组
+	scope, err := directoryScope(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户可管理的目录失败"))
+	}
+	r.DirectoryIds = scope
 
 	var groups []*model.Group
-	groups, err := isql.Group.ListTree(r)
+	groups, err = isql.Group.ListTree(r)
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "获取资源列表失败: "+err.Error()))
 	}
@@ -161,13 +194,17 @@ func (l GroupLogic) Update(c *gin.Context, req any) (data any, rspError any) {
 	if err != nil {
 		return nil, tools.NewMySqlError(err)
 	}
+	if rspError := checkDirectoryScope(c, oldGroup.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
 	newGroup := model.Group{
-		Model:     oldGroup.Model,
-		GroupName: r.GroupName,
-		Remark:    r.Remark,
-		Creator:   ctxUser.Username,
-		GroupType: oldGroup.GroupType,
+		Model:       oldGroup.Model,
+		GroupName:   r.GroupName,
+		Remark:      r.Remark,
+		Creator:     ctxUser.Username,
+		GroupType:   oldGroup.GroupType,
+		DirectoryID: oldGroup.DirectoryID,
 	}
 
 	//若配置了不允许修改分组名称，则不更新分组名称
@@ -206,6 +243,12 @@ func (l GroupLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
 		return nil, tools.NewMySqlError(fmt.Errorf("获取分组列表失败: %s", err.Error()))
 	}
 
+	for _, group := range groups {
+		if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+			return nil, rspError
+		}
+	}
+
 	for _, group := range groups {
 		// 判断存在子分组，不允许删除
 		filter := tools.H{"parent_id": int(group.ID)}
@@ -220,6 +263,12 @@ func (l GroupLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
 		}
 	}
 
+	// 清理分组之间的嵌套关系
+	err = removeNestedGroups(groups)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("清理分组嵌套关系失败: %s", err.Error()))
+	}
+
 	// 从MySQL中删除
 	err = isql.Group.Delete(groups)
 	if err != nil {
@@ -257,6 +306,12 @@ func (l GroupLogic) AddUser(c *gin.Context, req any) (data any, rspError any) {
 	if group.GroupDN[:3] == "ou=" {
 		return nil, tools.NewMySqlError(fmt.Errorf("ou类型的分组不能添加用户"))
 	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
+	if rspError := checkUsersDirectory(group, users); rspError != nil {
+		return nil, rspError
+	}
 
 	// 先添加到MySQL
 	err = isql.Group.AddUserToGroup(group, users)
@@ -272,6 +327,10 @@ func (l GroupLogic) AddUser(c *gin.Context, req any) (data any, rspError any) {
 		}
 	}
 
+	if err := materializeNestedGroups(group.ID); err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("展开嵌套分组成员失败: %s", err.Error()))
+	}
+
 	for _, user := range users {
 		oldData := new(model.User)
 		err = isql.User.Find(tools.H{"id": user.ID}, oldData)
@@ -327,6 +386,9 @@ func (l GroupLogic) RemoveUser(c *gin.Context, req any) (data any, rspError any)
 	if group.GroupDN[:3] == "ou=" {
 		return nil, tools.NewMySqlError(fmt.Errorf("ou类型的分组内没有用户"))
 	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
 	// 先操作ldap
 	for _, user := range users {
@@ -341,6 +403,9 @@ func (l GroupLogic) RemoveUser(c *gin.Context, req any) (data any, rspError any)
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("将用户从MySQL移除失败: %s", err.Error()))
 	}
+	if err := materializeNestedGroups(group.ID); err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("展开嵌套分组成员失败: %s", err.Error()))
+	}
 
 	for _, user := range users {
 		oldData := new(model.User)
@@ -382,8 +447,6 @@ func (l GroupLogic) UserInGroup(c *gin.Context, req any) (data any, rspError any
 	if !ok {
 		return nil, ReqAssertErr
 	}
-	_ = c
-
 	filter := tools.H{"id": r.GroupID}
 
 	if !isql.Group.Exist(filter) {
@@ -395,6 +458,9 @@ func (l GroupLogic) UserInGroup(c *gin.Context, req any) (data any, rspError any
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("获取分组失败: %s", err.Error()))
 	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
 	rets := make([]response.Guser, 0)
 
@@ -427,8 +493,6 @@ func (l GroupLogic) UserNoInGroup(c *gin.Context, req any) (data any, rspError a
 	if !ok {
 		return nil, ReqAssertErr
 	}
-	_ = c
-
 	filter := tools.H{"id": r.GroupID}
 
 	if !isql.Group.Exist(filter) {
@@ -440,6 +504,9 @@ func (l GroupLogic) UserNoInGroup(c *gin.Context, req any) (data any, rspError a
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("获取分组失败: %s", err.Error()))
 	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
 	var userList []*model.User
 	userList, err = isql.User.ListAll()
@@ -449,6 +516,10 @@ func (l GroupLogic) UserNoInGroup(c *gin.Context, req any) (data any, rspError a
 
 	rets := make([]response.Guser, 0)
 	for _, user := range userList {
+		// 分组只能添加所属目录中的用户
+		if user.DirectoryID != group.DirectoryID {
+			continue
+		}
 		in := true
 		for _, groupUser := range group.Users {
 			if user.Username == groupUser.Username {
@@ -479,3 +550,38 @@ func (l GroupLogic) UserNoInGroup(c *gin.Context, req any) (data any, rspError a
 		UserList:    rets,
 	}, nil
 }
+
+// ConvertSchema 将分组转换为指定的objectClass(groupOfUniqueNames、groupOfNames)
+func (l GroupLogic) ConvertSchema(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.GroupConvertSchemaReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if common.IsActiveDirectory() {
+		return nil, tools.NewValidatorError(fmt.Errorf("Active Directory的分组不支持转换objectClass"))
+	}
+
+	groups, err := isql.Group.GetGroupByIds(r.GroupIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组信息失败: %s", err.Error()))
+	}
+	if rspError := checkGroupScope(c, groups...); rspError != nil {
+		return nil, rspError
+	}
+	converted := make([]string, 0)
+	for _, group := range groups {
+		if group.GroupType != "cn" {
+			return tools.H{"converted": converted}, tools.NewValidatorError(fmt.Errorf("分组[%s]是OU组织单元，不能转换", group.GroupName))
+		}
+		err = ildap.Group.ConvertSchema(group.GroupDN, r.GroupSchema)
+		if err != nil {
+			return tools.H{"converted": converted}, tools.NewLdapError(fmt.Errorf("在LDAP转换分组[%s]失败: %s", group.GroupName, err.Error()))
+		}
+		err = isql.Group.UpdateGroupSchema(group.ID, r.GroupSchema)
+		if err != nil {
+			return tools.H{"converted": converted}, tools.NewMySqlError(fmt.Errorf("在MySQL更新分组[%s]失败: %s", group.GroupName, err.Error()))
+		}
+		converted = append(converted, group.GroupName)
+	}
+	return tools.H{"converted": converted}, nil
+}
@@ -0,0 +1,429 @@
+package logic
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+)
+
+// groupNestingMu 串行化嵌套关系的循环检查与写入, 避免并发添加时各自检查通过而形成循环
+var groupNestingMu sync.Mutex
+
+// groupNestingGraph 分组嵌套关系, members为分组的直接成员分组, parents为直接包含该分组的分组
+type groupNestingGraph struct {
+	members map[uint][]uint
+	parents map[uint][]uint
+}
+
+func loadGroupNestingGraph() (*groupNestingGraph, error) {
+	list, err := isql.GroupNesting.List()
+	if err != nil {
+		return nil, err
+	}
+	graph := &groupNestingGraph{members: make(map[uint][]uint), parents: make(map[uint][]uint)}
+	for _, nesting := range list {
+		graph.add(nesting.GroupID, nesting.MemberGroupID)
+	}
+	return graph, nil
+}
+
+func (g *groupNestingGraph) add(groupId, memberGroupId uint) {
+	g.members[groupId] = append(g.members[groupId], memberGroupId)
+	g.parents[memberGroupId] = append(g.parents[memberGroupId], groupId)
+}
+
+// descendants 分组直接或间接包含的全部分组
+func (g *groupNestingGraph) descendants(groupIds ...uint) []uint {
+	return walkGroups(g.members, groupIds)
+}
+
+// ancestors 直接或间接包含该分组的全部分组
+func (g *groupNestingGraph) ancestors(groupIds ...uint) []uint {
+	return walkGroups(g.parents, groupIds)
+}
+
+// walkGroups 广度优先遍历, 不包含起点本身, 已访问的分组不会重复遍历
+func walkGroups(edges map[uint][]uint, start []uint) []uint {
+	visited := make(map[uint]bool)
+	for _, id := range start {
+		visited[id] = true
+	}
+	queue := append([]uint{}, start...)
+	ret := make([]uint, 0)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[id] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			ret = append(ret, next)
+			queue = append(queue, next)
+		}
+	}
+	return ret
+}
+
+// uniqueGroupIds 去除重复的分组编号, 保持原有顺序
+func uniqueGroupIds(ids []uint) []uint {
+	seen := make(map[uint]bool)
+	ret := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
+
+// AddGroup 将分组作为成员加入分组
+func (l GroupLogic) AddGroup(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.GroupAddGroupReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	// 重复的编号只添加一次, 避免写入重复的嵌套关系
+	r.MemberGroupIds = uniqueGroupIds(r.MemberGroupIds)
+
+	group, members, rspError := getNestingGroups(r.GroupID, r.MemberGroupIds)
+	if rspError != nil {
+		return nil, rspError
+	}
+	groupNestingMu.Lock()
+	defer groupNestingMu.Unlock()
+	graph, err := loadGroupNestingGraph()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组嵌套关系失败: %s", err.Error()))
+	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
+	for _, member := range members {
+		if member.ID == group.ID {
+			return nil, tools.NewValidatorError(fmt.Errorf("分组不能包含自己"))
+		}
+		if member.DirectoryID != group.DirectoryID {
+			return nil, tools.NewValidatorError(fmt.Errorf("分组[%s]与分组[%s]不在同一个LDAP目录", member.GroupName, group.GroupName))
+		}
+		for _, id := range graph.members[group.ID] {
+			if id == member.ID {
+				return nil, tools.NewValidatorError(fmt.Errorf("分组[%s]已是分组[%s]的成员", member.GroupName, group.GroupName))
+			}
+		}
+		// 成员分组直接或间接包含了当前分组时会形成循环
+		for _, id := range graph.descendants(member.ID) {
+			if id == group.ID {
+				return nil, tools.NewValidatorError(fmt.Errorf("分组[%s]已直接或间接包含分组[%s]，不能形成循环嵌套", member.GroupName, group.GroupName))
+			}
+		}
+		graph.add(group.ID, member.ID)
+	}
+
+	// 先添加到MySQL
+	err = isql.GroupNesting.Add(group.ID, r.MemberGroupIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("添加分组成员失败: %s", err.Error()))
+	}
+	// 再往ldap添加, 成员属性中写入分组的DN
+	for _, member := range members {
+		err = ildap.Group.AddUserToGroup(group.GroupDN, member.GroupDN)
+		if err != nil {
+			return nil, tools.NewLdapError(fmt.Errorf("%s", "向LDAP添加分组成员失败"+err.Error()))
+		}
+	}
+	if err := materializeNestedGroups(group.ID); err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("展开嵌套分组成员失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// RemoveGroup 将分组从分组的成员中移除
+func (l GroupLogic) RemoveGroup(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.GroupRemoveGroupReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	r.MemberGroupIds = uniqueGroupIds(r.MemberGroupIds)
+	group, members, rspError := getNestingGroups(r.GroupID, r.MemberGroupIds)
+	if rspError != nil {
+		return nil, rspError
+	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
+	for _, member := range members {
+		err := ildap.Group.RemoveUserFromGroup(group.GroupDN, member.GroupDN)
+		if err != nil {
+			return nil, tools.NewLdapError(fmt.Errorf("%s", "在LDAP移除分组成员失败"+err.Error()))
+		}
+	}
+	err := isql.GroupNesting.Delete(group.ID, r.MemberGroupIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("移除分组成员失败: %s", err.Error()))
+	}
+	if err := materializeNestedGroups(group.ID); err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("展开嵌套分组成员失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// EffectiveMembers 分组的有效成员, 包含嵌套分组中的用户
+func (l GroupLogic) EffectiveMembers(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.GroupEffectiveMembersReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	group := new(model.Group)
+	if err := isql.Group.Find(tools.H{"id": r.GroupID}, group); err != nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("分组不存在"))
+	}
+	if rspError := checkDirectoryScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
+	graph, err := loadGroupNestingGraph()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组嵌套关系失败: %s", err.Error()))
+	}
+	groups, err := isql.Group.GetGroupByIds(append([]uint{r.GroupID}, graph.descendants(r.GroupID)...))
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组信息失败: %s", err.Error()))
+	}
+
+	users := make([]*model.User, 0)
+	seen := make(map[uint]bool)
+	nestedGroups := make([]*model.Group, 0)
+	for _, group := range groups {
+		if group.ID != r.GroupID {
+			nestedGroups = append(nestedGroups, group)
+		}
+		for _, user := range group.Users {
+			if !seen[user.ID] {
+				seen[user.ID] = true
+				users = append(users, user)
+			}
+		}
+		group.Users = nil
+	}
+	return tools.H{"users": users, "groups": nestedGroups}, nil
+}
+
+// EffectiveGroups 用户的有效分组, 包含通过嵌套关系间接所属的分组
+func (l UserLogic) EffectiveGroups(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.UserEffectiveGroupsReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkUserScope(c, r.UserID); rspError != nil {
+		return nil, rspError
+	}
+	groups, err := isql.Group.GetUserGroups(r.UserID)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户分组失败: %s", err.Error()))
+	}
+	graph, err := loadGroupNestingGraph()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组嵌套关系失败: %s", err.Error()))
+	}
+	direct := make([]uint, 0, len(groups))
+	for _, group := range groups {
+		direct = append(direct, group.ID)
+	}
+	inherited := make([]*model.Group, 0)
+	if ids := graph.ancestors(direct...); len(ids) > 0 {
+		inherited, err = isql.Group.GetGroupByIds(ids)
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("获取分组信息失败: %s", err.Error()))
+		}
+		for _, group := range inherited {
+			group.Users = nil
+		}
+	}
+	return tools.H{"direct": groups, "inherited": inherited}, nil
+}
+
+// SyncNestedMembers 按当前配置重新写入分组在LDAP中的成员, 用于切换展开配置后修正已有分组
+func (l GroupLogic) SyncNestedMembers(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.GroupSyncNestedMembersReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	graph, err := loadGroupNestingGraph()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组嵌套关系失败: %s", err.Error()))
+	}
+	ids := r.GroupIds
+	if len(ids) == 0 {
+		for id := range graph.members {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	groups, err := isql.Group.GetGroupByIds(ids)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组信息失败: %s", err.Error()))
+	}
+	if rspError := checkGroupScope(c, groups...); rspError != nil {
+		return nil, rspError
+	}
+	if err := syncGroupMembers(graph, append(ids, graph.ancestors(ids...)...)); err != nil {
+		return nil, tools.NewLdapError(err)
+	}
+	return nil, nil
+}
+
+// materializeNestedGroups 成员变化后, 开启了嵌套展开时重新写入分组及其上级分组的成员
+func materializeNestedGroups(groupIds ...uint) error {
+	if !config.Conf.Ldap.NestedGroupMaterialize || len(groupIds) == 0 {
+		return nil
+	}
+	graph, err := loadGroupNestingGraph()
+	if err != nil {
+		return err
+	}
+	return syncGroupMembers(graph, append(groupIds, graph.ancestors(groupIds...)...))
+}
+
+// syncGroupMembers 以数据库中的成员关系覆盖LDAP中分组的成员属性
+func syncGroupMembers(graph *groupNestingGraph, groupIds []uint) error {
+	groups, err := isql.Group.GetGroupByIds(groupIds)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if group.GroupType != "cn" {
+			continue
+		}
+		memberDNs, err := groupMemberDNs(graph, group)
+		if err != nil {
+			return err
+		}
+		if err := ildap.Group.SetMembers(group.GroupDN, memberDNs); err != nil {
+			return fmt.Errorf("写入分组[%s]的成员失败: %s", group.GroupName, err.Error())
+		}
+	}
+	return nil
+}
+
+// groupMemberDNs 分组在LDAP中应有的成员: 管理员、直接成员用户、成员分组, 开启展开时还包含嵌套分组中的用户
+func groupMemberDNs(graph *groupNestingGraph, group *model.Group) ([]string, error) {
+	adminDN := common.LdapDirectoryConf(group.DirectoryID).AdminDN
+	memberDNs := []string{adminDN}
+	seen := map[string]bool{strings.ToLower(adminDN): true}
+	appendDN := func(dn string) {
+		if dn != "" && !seen[strings.ToLower(dn)] {
+			seen[strings.ToLower(dn)] = true
+			memberDNs = append(memberDNs, dn)
+		}
+	}
+
+	userGroups := []*model.Group{group}
+	if memberIds := graph.members[group.ID]; len(memberIds) > 0 {
+		members, err := isql.Group.GetGroupByIds(memberIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			appendDN(member.GroupDN)
+		}
+	}
+	if config.Conf.Ldap.NestedGroupMaterialize {
+		if ids := graph.descendants(group.ID); len(ids) > 0 {
+			nested, err := isql.Group.GetGroupByIds(ids)
+			if err != nil {
+				return nil, err
+			}
+			userGroups = append(userGroups, nested...)
+		}
+	}
+	for _, g := range userGroups {
+		for _, user := range g.Users {
+			// 离职用户不在LDAP中
+			if user.Status == 1 {
+				appendDN(user.UserDN)
+			}
+		}
+	}
+	return memberDNs, nil
+}
+
+// getNestingGroups 获取分组及成员分组, 只有cn类型的分组可以互相嵌套
+func getNestingGroups(groupId uint, memberGroupIds []uint) (*model.Group, []*model.Group, any) {
+	group := new(model.Group)
+	err := isql.Group.Find(tools.H{"id": groupId}, group)
+	if err != nil {
+		return nil, nil, tools.NewMySqlError(fmt.Errorf("获取分组失败: %s", err.Error()))
+	}
+	if group.GroupType != "cn" {
+		return nil, nil, tools.NewValidatorError(fmt.Errorf("ou类型的分组不能添加成员"))
+	}
+	members, err := isql.Group.GetGroupByIds(memberGroupIds)
+	if err != nil {
+		return nil, nil, tools.NewMySqlError(fmt.Errorf("获取成员分组失败: %s", err.Error()))
+	}
+	if len(members) != len(memberGroupIds) {
+		return nil, nil, tools.NewValidatorError(fmt.Errorf("有成员分组不存在"))
+	}
+	for _, member := range members {
+		if member.GroupType != "cn" {
+			return nil, nil, tools.NewValidatorError(fmt.Errorf("ou类型的分组[%s]不能作为成员", member.GroupName))
+		}
+	}
+	return group, members, nil
+}
+
+// removeNestedGroups 删除分组前, 将其从上级分组的成员中移除并清理嵌套关系
+func removeNestedGroups(groups []*model.Group) error {
+	graph, err := loadGroupNestingGraph()
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, 0, len(groups))
+	parentIds := make([]uint, 0)
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+		for _, parentId := range graph.parents[group.ID] {
+			parent := new(model.Group)
+			if err := isql.Group.Find(tools.H{"id": parentId}, parent); err != nil {
+				continue
+			}
+			if err := ildap.Group.RemoveUserFromGroup(parent.GroupDN, group.GroupDN); err != nil {
+				common.Log.Warnf("从分组[%s]移除成员分组[%s]失败: %v", parent.GroupName, group.GroupName, err)
+			}
+			parentIds = append(parentIds, parentId)
+		}
+	}
+	if err := isql.GroupNesting.DeleteByGroups(ids); err != nil {
+		return err
+	}
+	// 被删除的分组不再参与展开
+	remaining := make([]uint, 0, len(parentIds))
+	for _, id := range parentIds {
+		if !funk.Contains(ids, id) {
+			remaining = append(remaining, id)
+		}
+	}
+	return materializeNestedGroups(remaining...)
+}
+
+func groupIds(groups []*model.Group) []uint {
+	ids := make([]uint, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.ID)
+	}
+	return ids
+}
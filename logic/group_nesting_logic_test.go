@@ -0,0 +1,136 @@
+package logic
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+func TestGroupNestingGraph(t *testing.T) {
+	graph := &groupNestingGraph{members: make(map[uint][]uint), parents: make(map[uint][]uint)}
+	// 1包含2和3, 2包含4, 3也包含4
+	graph.add(1, 2)
+	graph.add(1, 3)
+	graph.add(2, 4)
+	graph.add(3, 4)
+
+	if got := graph.descendants(1); !reflect.DeepEqual(got, []uint{2, 3, 4}) {
+		t.Fatalf("1的下级分组应为[2 3 4], 实际为%v", got)
+	}
+	if got := graph.ancestors(4); !reflect.DeepEqual(got, []uint{2, 3, 1}) {
+		t.Fatalf("4的上级分组应为[2 3 1], 实际为%v", got)
+	}
+	if got := graph.descendants(4); len(got) != 0 {
+		t.Fatalf("4没有下级分组, 实际为%v", got)
+	}
+}
+
+func TestWalkGroupsCycle(t *testing.T) {
+	// 已存在的数据中即使有循环, 遍历也能结束且不包含起点
+	edges := map[uint][]uint{1: {2}, 2: {3}, 3: {1}}
+	if got := walkGroups(edges, []uint{1}); !reflect.DeepEqual(got, []uint{2, 3}) {
+		t.Fatalf("遍历结果应为[2 3], 实际为%v", got)
+	}
+}
+
+func setupNestingGroups(t *testing.T) *groupNestingGraph {
+	setupLogicTest(t)
+	config.Conf.Ldap.AdminDN = "cn=admin,dc=eryajf,dc=net"
+	zhangsan := &model.User{Username: "zhangsan", Mobile: "13800000001", Status: 1, UserDN: "uid=zhangsan,ou=people,dc=eryajf,dc=net"}
+	lisi := &model.User{Username: "lisi", Mobile: "13800000002", Status: 1, UserDN: "uid=lisi,ou=people,dc=eryajf,dc=net"}
+	wangwu := &model.User{Username: "wangwu", Mobile: "13800000003", Status: 2, UserDN: "uid=wangwu,ou=people,dc=eryajf,dc=net"}
+	groups := []*model.Group{
+		{GroupName: "all", GroupType: "cn", GroupDN: "cn=all,dc=eryajf,dc=net", Users: []*model.User{zhangsan}},
+		{GroupName: "dev", GroupType: "cn", GroupDN: "cn=dev,dc=eryajf,dc=net", Users: []*model.User{lisi}},
+		{GroupName: "ops", GroupType: "cn", GroupDN: "cn=ops,dc=eryajf,dc=net", Users: []*model.User{wangwu, zhangsan}},
+	}
+	for _, group := range groups {
+		if err := common.DB.Create(group).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// all包含dev, dev包含ops
+	if err := isql.GroupNesting.Add(groups[0].ID, []uint{groups[1].ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := isql.GroupNesting.Add(groups[1].ID, []uint{groups[2].ID}); err != nil {
+		t.Fatal(err)
+	}
+	graph, err := loadGroupNestingGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+func nestingGroupMemberDNs(t *testing.T, graph *groupNestingGraph, name string) []string {
+	group := new(model.Group)
+	if err := common.DB.Preload("Users").Where("group_name = ?", name).First(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	memberDNs, err := groupMemberDNs(graph, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return memberDNs
+}
+
+func TestGroupMemberDNs(t *testing.T) {
+	graph := setupNestingGroups(t)
+	config.Conf.Ldap.NestedGroupMaterialize = false
+
+	want := []string{"cn=admin,dc=eryajf,dc=net", "cn=dev,dc=eryajf,dc=net", "uid=zhangsan,ou=people,dc=eryajf,dc=net"}
+	if got := nestingGroupMemberDNs(t, graph, "all"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("未开启展开时只包含直接成员, 应为%v, 实际为%v", want, got)
+	}
+	// 离职用户不在成员中
+	want = []string{"cn=admin,dc=eryajf,dc=net", "uid=zhangsan,ou=people,dc=eryajf,dc=net"}
+	if got := nestingGroupMemberDNs(t, graph, "ops"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ops成员应为%v, 实际为%v", want, got)
+	}
+}
+
+func TestGroupMemberDNsMaterialize(t *testing.T) {
+	graph := setupNestingGroups(t)
+	config.Conf.Ldap.NestedGroupMaterialize = true
+
+	// 展开后包含间接成员, 重复的用户只出现一次
+	want := []string{
+		"cn=admin,dc=eryajf,dc=net",
+		"cn=dev,dc=eryajf,dc=net",
+		"uid=zhangsan,ou=people,dc=eryajf,dc=net",
+		"uid=lisi,ou=people,dc=eryajf,dc=net",
+	}
+	if got := nestingGroupMemberDNs(t, graph, "all"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("all成员应为%v, 实际为%v", want, got)
+	}
+}
+
+func TestMaterializeNestedGroupsDisabled(t *testing.T) {
+	setupNestingGroups(t)
+	config.Conf.Ldap.NestedGroupMaterialize = false
+	// 未开启展开时不访问LDAP
+	if err := materializeNestedGroups(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNestingGroupsDuplicateIds(t *testing.T) {
+	setupNestingGroups(t)
+	ids := uniqueGroupIds([]uint{3, 2, 3})
+	if !reflect.DeepEqual(ids, []uint{3, 2}) {
+		t.Fatalf("去重后应为[3 2], 实际为%v", ids)
+	}
+	// 重复的编号去重后按实际存在的分组校验, 不会误报分组不存在
+	_, members, rspError := getNestingGroups(1, ids)
+	if rspError != nil {
+		t.Fatal(rspError)
+	}
+	if len(members) != 2 {
+		t.Fatalf("应获取到2个成员分组, 实际为%d个", len(members))
+	}
+}
@@ -0,0 +1,112 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/client/httpsource"
+)
+
+// HttpSourceLogic 通用HTTP身份源, 字段映射使用字段关联中 flag_group 与 flag_user 的配置
+type HttpSourceLogic struct {
+}
+
+func init() {
+	RegisterSyncProvider(HttpSource)
+}
+
+// conf 配置文件中没有该身份源时使用空配置, 不开启同步
+func (d HttpSourceLogic) conf() *config.HttpSourceConfig {
+	if config.Conf.HttpSource == nil {
+		return &config.HttpSourceConfig{}
+	}
+	return config.Conf.HttpSource
+}
+
+// Flag HTTP身份源的来源标识
+func (d HttpSourceLogic) Flag() string {
+	if flag := d.conf().Flag; flag != "" {
+		return flag
+	}
+	return "http"
+}
+
+// Name HTTP身份源的来源名称
+func (d HttpSourceLogic) Name() string {
+	if name := d.conf().Name; name != "" {
+		return name
+	}
+	return "HTTP身份源"
+}
+
+// Config HTTP身份源的同步配置, 顶级部门同步到 ou=<flag>root 之下
+func (d HttpSourceLogic) Config() *SyncConfig {
+	conf := d.conf()
+	rootDeptId := conf.RootDeptId
+	if rootDeptId == "" {
+		rootDeptId = "0"
+	}
+	return &SyncConfig{
+		RootDeptId:    fmt.Sprintf("%s_%s", d.Flag(), rootDeptId),
+		RootDeptName:  d.Flag() + "root",
+		EnableSync:    conf.EnableSync,
+		DeptSyncTime:  conf.DeptSyncTime,
+		UserSyncTime:  conf.UserSyncTime,
+		IsUpdateSyncd: conf.IsUpdateSyncd,
+		IsDeleteSyncd: conf.IsDeleteSyncd,
+		DryRun:        conf.DryRun,
+	}
+}
+
+// Schema HTTP身份源的连接配置项
+func (d HttpSourceLogic) Schema() []*model.SyncSourceField {
+	conf := d.conf()
+	pageType := ""
+	if conf.Page != nil {
+		pageType = conf.Page.Type
+	}
+	return syncSourceFields([]*model.SyncSourceField{
+		{Key: "http-source.dept-url", Name: "部门列表接口地址", Required: true},
+		{Key: "http-source.user-url", Name: "用户列表接口地址", Required: true},
+		{Key: "http-source.leaver-url", Name: "离职用户列表接口地址"},
+		{Key: "http-source.auth-header", Name: "认证请求头"},
+		{Key: "http-source.token", Name: "认证请求头的值", Secret: true},
+		{Key: "http-source.page.type", Name: "分页方式：page、offset、cursor"},
+		{Key: "http-source.dept-items-path", Name: "部门数组的JSON路径"},
+		{Key: "http-source.user-items-path", Name: "用户数组的JSON路径"},
+		{Key: "http-source.user-dept-ids-path", Name: "用户所在部门ID的JSON路径", Required: true},
+	}, conf.DeptUrl, conf.UserUrl, conf.LeaverUrl, conf.AuthHeader, conf.Token, pageType,
+		conf.DeptItemsPath, conf.UserItemsPath, conf.UserDeptIdsPath)
+}
+
+// GetDepts 获取HTTP身份源的全部部门
+func (d HttpSourceLogic) GetDepts() ([]map[string]any, error) {
+	return httpsource.GetAllDepts()
+}
+
+// EachUser 获取HTTP身份源的全部在职用户
+func (d HttpSourceLogic) EachUser(fn func(user map[string]any) error) error {
+	return eachSyncUser(httpsource.GetAllUsers, fn)
+}
+
+// GetLeavers 获取HTTP身份源的离职用户
+// 未配置离职用户接口时默认不处理离职, 开启leaver-by-missing后平台中有而接口中没有的用户视为已离职
+func (d HttpSourceLogic) GetLeavers(usernames map[string]struct{}) ([]*model.User, error) {
+	conf := d.conf()
+	if conf.LeaverUrl == "" {
+		if !conf.LeaverByMissing {
+			return nil, nil
+		}
+		return syncLeaversByMissing(d.Flag(), usernames)
+	}
+	userIds, err := httpsource.GetLeaveUserIds()
+	if err != nil {
+		return nil, err
+	}
+	field := conf.LeaverIdField
+	if field != "source_union_id" {
+		field = "source_user_id"
+	}
+	return syncLeaversByIds(d.Flag(), field, userIds)
+}
@@ -0,0 +1,186 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+	"github.com/thoas/go-funk"
+)
+
+// 按IP统计的登录失败次数, 只保存在内存中
+var (
+	ipFailureCache = cache.New(time.Hour, 10*time.Minute)
+	ipFailureMutex sync.Mutex
+)
+
+type ipFailure struct {
+	Count       int
+	LockedUntil time.Time
+}
+
+// getLoginLockConfig 获取登录失败锁定配置, 未开启时返回nil
+func getLoginLockConfig() *config.LoginLockConfig {
+	if config.Conf.LoginLock == nil || !config.Conf.LoginLock.Enable {
+		return nil
+	}
+	return config.Conf.LoginLock
+}
+
+// checkLoginLock 登录前检查账号与IP是否处于锁定期
+func checkLoginLock(username, ip string) error {
+	if getLoginLockConfig() == nil {
+		return nil
+	}
+	if v, ok := ipFailureCache.Get(ip); ok {
+		if until := v.(*ipFailure).LockedUntil; time.Now().Before(until) {
+			return fmt.Errorf("登录失败次数过多，当前IP已被锁定，请于%s后重试", until.Format("15:04:05"))
+		}
+	}
+	user := new(model.User)
+	if err := isql.User.Find(tools.H{"username": username}, user); err != nil {
+		return nil
+	}
+	if user.IsLocked() {
+		return fmt.Errorf("登录失败次数过多，账号已被锁定，请于%s后重试或联系管理员解锁", user.LockedUntil.Format("15:04:05"))
+	}
+	return nil
+}
+
+// loginFailed 记录一次登录失败, 达到阈值时锁定账号或IP
+func loginFailed(username, ip string) {
+	lockConf := getLoginLockConfig()
+	if lockConf == nil {
+		return
+	}
+	now := time.Now()
+	window := time.Duration(lockConf.FailureWindow) * time.Minute
+	lockDuration := time.Duration(lockConf.LockDuration) * time.Minute
+
+	if lockConf.IpMaxFailures > 0 {
+		ipFailureMutex.Lock()
+		failure := &ipFailure{}
+		if v, ok := ipFailureCache.Get(ip); ok {
+			failure = v.(*ipFailure)
+		}
+		failure.Count++
+		if failure.Count >= lockConf.IpMaxFailures {
+			failure.Count = 0
+			failure.LockedUntil = now.Add(lockDuration)
+			addLoginLockLog(username, ip, loginLockLogPath, fmt.Sprintf("IP[%s]连续登录失败，锁定至%s", ip, failure.LockedUntil.Format("2006-01-02 15:04:05")))
+		}
+		// 统计窗口内没有新的失败则计数过期, 锁定期内不过期
+		ipFailureCache.Set(ip, failure, window+lockDuration)
+		ipFailureMutex.Unlock()
+	}
+
+	if lockConf.UserMaxFailures <= 0 {
+		return
+	}
+	user := new(model.User)
+	if err := isql.User.Find(tools.H{"username": username}, user); err != nil {
+		return
+	}
+	failures := user.LoginFailures + 1
+	if user.LastFailedAt != nil && now.Sub(*user.LastFailedAt) > window {
+		failures = 1
+	}
+	var lockedUntil *time.Time
+	if failures >= uint(lockConf.UserMaxFailures) {
+		until := now.Add(lockDuration)
+		lockedUntil = &until
+		failures = 0
+		addLoginLockLog(username, ip, loginLockLogPath, fmt.Sprintf("账号[%s]连续登录失败，锁定至%s", username, until.Format("2006-01-02 15:04:05")))
+	}
+	if err := isql.User.UpdateLoginLock(user.ID, failures, &now, lockedUntil); err != nil {
+		common.Log.Errorf("记录用户[%s]登录失败次数失败: %v", username, err)
+	}
+}
+
+// loginSucceeded 登录成功后清除账号的失败计数, 锁定已到期的视为自动解锁
+func loginSucceeded(user *model.User, ip string) {
+	if user.LoginFailures == 0 && user.LockedUntil == nil {
+		return
+	}
+	if user.LockedUntil != nil {
+		addLoginLockLog(user.Username, ip, loginLockLogPath, fmt.Sprintf("账号[%s]锁定已到期，自动解锁", user.Username))
+	}
+	if err := isql.User.Unlock([]uint{user.ID}); err != nil {
+		common.Log.Errorf("清除用户[%s]登录失败次数失败: %v", user.Username, err)
+	}
+}
+
+// loginLockLogPath 登录过程中的锁定与自动解锁事件记录为登录接口
+const loginLockLogPath = "/base/login"
+
+// addLoginLockLog 将锁定与解锁事件写入操作日志
+func addLoginLockLog(username, ip, path, remark string) {
+	common.Log.Warn(remark)
+	err := isql.OperationLog.Add(&model.OperationLog{
+		Username:  username,
+		Ip:        ip,
+		Method:    "POST",
+		Path:      path,
+		Remark:    operationLogRemark(remark),
+		Status:    200,
+		StartTime: fmt.Sprintf("%v", time.Now()),
+	})
+	if err != nil {
+		common.Log.Errorf("记录登录锁定日志失败: %v", err)
+	}
+}
+
+// Unlock 管理员解除用户的登录失败锁定
+func (l UserLogic) Unlock(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.UserUnlockReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	// 根据用户ID获取用户角色排序最小值
+	roleMinSortList, err := isql.User.GetUserMinRoleSortsByIds(r.UserIds)
+	if err != nil || len(roleMinSortList) == 0 {
+		return nil, tools.NewValidatorError(fmt.Errorf("根据用户ID获取用户角色排序最小值失败"))
+	}
+	// 获取当前登陆用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := isql.User.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("获取当前登陆用户角色排序最小值失败"))
+	}
+	// 不能解锁比自己(登陆用户)角色排序低(等级高)的用户
+	for _, sort := range roleMinSortList {
+		if int(minSort) > sort {
+			return nil, tools.NewValidatorError(fmt.Errorf("用户不能解锁比自己角色等级高的用户"))
+		}
+	}
+
+	users, err := isql.User.GetUserByIds(r.UserIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
+	}
+	if len(users) == 0 {
+		return nil, tools.NewValidatorError(errors.New("用户不存在"))
+	}
+	for _, user := range users {
+		if rspError := checkDirectoryScope(c, user.DirectoryID); rspError != nil {
+			return nil, rspError
+		}
+	}
+	err = isql.User.Unlock(r.UserIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("解锁用户失败: %s", err.Error()))
+	}
+	usernames := funk.Map(users, func(u model.User) string { return u.Username }).([]string)
+	addLoginLockLog(ctxUser.Username, c.ClientIP(), operationLogPath(c), fmt.Sprintf("解锁用户: %s", strings.Join(usernames, ",")))
+	return nil, nil
+}
@@ -0,0 +1,80 @@
+package logic
+
+import (
+	"errors"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+// 后台登录的密码校验方式
+const (
+	LoginModeDB     = "db"      // 校验数据库中的密码
+	LoginModeLdap   = "ldap"    // 以用户DN绑定LDAP校验
+	LoginModeLdapDB = "ldap-db" // 优先LDAP校验，失败时回退数据库校验
+)
+
+// CommonLogin 按配置的登录方式校验用户名与密码, 连续失败达到阈值时锁定账号或IP
+func CommonLogin(username, password, ip string) (*model.User, error) {
+	if err := checkLoginLock(username, ip); err != nil {
+		return nil, err
+	}
+	user, err := commonLogin(username, password)
+	if err != nil {
+		if errors.Is(err, isql.ErrWrongPasswd) || errors.Is(err, isql.ErrUserNotExist) || errors.Is(err, ildap.ErrInvalidCredentials) {
+			loginFailed(username, ip)
+		}
+		return nil, err
+	}
+	// 需要二次验证的用户在第二步验证通过后才清除失败计数, 避免只凭密码重置计数后无限尝试验证码
+	if !mfaEnrolled(user) && !mfaRequired(user) {
+		loginSucceeded(user, ip)
+	}
+	return user, nil
+}
+
+func commonLogin(username, password string) (*model.User, error) {
+	u := &model.User{
+		Username: username,
+		Password: password,
+	}
+
+	switch config.Conf.Ldap.LoginMode {
+	case LoginModeLdap:
+		return ldapLogin(u)
+	case LoginModeLdapDB:
+		user, err := ldapLogin(u)
+		// 账号锁定与密码过期是LDAP给出的明确结论，不再回退
+		if err == nil || errors.Is(err, ildap.ErrAccountLocked) || errors.Is(err, ildap.ErrPasswordExpired) {
+			return user, err
+		}
+		common.Log.Warnf("用户[%s]LDAP认证失败，回退到数据库校验: %v", username, err)
+		return isql.User.Login(u)
+	default:
+		return isql.User.Login(u)
+	}
+}
+
+// ldapLogin 以用户DN绑定LDAP校验密码
+func ldapLogin(u *model.User) (*model.User, error) {
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"username": u.Username}, user)
+	if err != nil {
+		return nil, isql.ErrUserNotExist
+	}
+	if user.Status != 1 {
+		return nil, errors.New("用户被禁用")
+	}
+
+	err = ildap.User.Auth(user.UserDN, u.Password)
+	if err != nil {
+		return nil, err
+	}
+	// 绑定成功说明密码正确，顺带将旧的可逆加密密码升级为哈希
+	isql.User.RehashLegacyPasswd(user, u.Password)
+	return user, nil
+}
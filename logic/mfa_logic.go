@@ -0,0 +1,382 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+	"github.com/thoas/go-funk"
+)
+
+type MfaLogic struct{}
+
+const (
+	MfaStatusEnabled uint = 1 // 已启用
+	MfaStatusPending uint = 2 // 已生成密钥, 待验证码确认
+
+	mfaIssuer            = "go-ldap-admin"
+	mfaRecoveryCodeCount = 10
+	mfaMaxAttempts       = 5
+)
+
+// ErrMfaRequired 密码校验通过但需要继续输入二次验证码
+var ErrMfaRequired = errors.New("请输入二次验证码")
+
+// 登录第二步使用的临时凭据, 5分钟内有效
+var mfaTicketCache = cache.New(5*time.Minute, 10*time.Minute)
+
+type mfaTicket struct {
+	UserID   uint
+	Attempts int
+}
+
+// mfaEnrolled 用户是否已开启二次验证
+func mfaEnrolled(user *model.User) bool {
+	mfa := new(model.UserMfa)
+	return isql.UserMfa.Find(tools.H{"user_id": user.ID}, mfa) == nil && mfa.Status == MfaStatusEnabled
+}
+
+// MfaChallenge 密码校验通过后判断用户是否需要二次验证, 需要时返回登录第二步的凭据
+func MfaChallenge(user *model.User) (tools.H, bool) {
+	enrolled := mfaEnrolled(user)
+	if !enrolled && !mfaRequired(user) {
+		return nil, false
+	}
+
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	ticket := hex.EncodeToString(buf)
+	mfaTicketCache.SetDefault(ticket, &mfaTicket{UserID: user.ID})
+
+	return tools.H{
+		"mfaTicket":   ticket,
+		"mfaEnrolled": enrolled,
+	}, true
+}
+
+// MfaLogin 登录第二步, 校验二次验证码或恢复码
+// 用户尚未绑定时(角色强制要求), 校验通过即完成绑定并返回恢复码
+// 验证码错误与密码错误一样计入账号与IP的登录失败次数, 验证通过后才清除失败计数
+func MfaLogin(ticket, code, ip string) (*model.User, []string, error) {
+	v, ok := mfaTicketCache.Get(ticket)
+	if !ok {
+		return nil, nil, errors.New("二次验证已过期，请重新登录")
+	}
+	t := v.(*mfaTicket)
+
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": t.UserID}, user)
+	if err != nil {
+		return nil, nil, errors.New("用户不存在")
+	}
+	if user.Status != 1 {
+		return nil, nil, errors.New("用户被禁用")
+	}
+	if err := checkLoginLock(user.Username, ip); err != nil {
+		mfaTicketCache.Delete(ticket)
+		return nil, nil, err
+	}
+
+	mfa := new(model.UserMfa)
+	err = isql.UserMfa.Find(tools.H{"user_id": user.ID}, mfa)
+	if err != nil {
+		return nil, nil, errors.New("请先绑定二次验证")
+	}
+
+	var recoveryCodes []string
+	if mfa.Status == MfaStatusEnabled {
+		ok = verifyMfaCode(mfa, code)
+	} else {
+		ok = validateMfaTotp(mfa, code)
+		if ok {
+			recoveryCodes, err = enableMfa(mfa)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if !ok {
+		loginFailed(user.Username, ip)
+		t.Attempts++
+		if t.Attempts >= mfaMaxAttempts {
+			mfaTicketCache.Delete(ticket)
+			return nil, nil, errors.New("二次验证码错误次数过多，请重新登录")
+		}
+		return nil, nil, errors.New("二次验证码错误")
+	}
+	mfaTicketCache.Delete(ticket)
+	loginSucceeded(user, ip)
+	return user, recoveryCodes, nil
+}
+
+// SetupByTicket 登录过程中为强制二次验证的用户生成绑定密钥
+func (l MfaLogic) SetupByTicket(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.BaseMfaSetupReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	v, ok := mfaTicketCache.Get(r.MfaTicket)
+	if !ok {
+		return nil, tools.NewValidatorError(fmt.Errorf("二次验证已过期，请重新登录"))
+	}
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": v.(*mfaTicket).UserID}, user)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
+	}
+	return setupMfa(user)
+}
+
+// Info 获取当前用户的二次验证状态
+func (l MfaLogic) Info(c *gin.Context, req any) (data any, rspError any) {
+	_, ok := req.(*request.MfaInfoReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	user, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户信息失败"))
+	}
+	return tools.H{
+		"enabled":  isql.UserMfa.Exist(tools.H{"user_id": user.ID, "status": MfaStatusEnabled}),
+		"required": mfaRequired(&user),
+	}, nil
+}
+
+// Setup 当前用户生成二次验证密钥
+func (l MfaLogic) Setup(c *gin.Context, req any) (data any, rspError any) {
+	_, ok := req.(*request.MfaSetupReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	user, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户信息失败"))
+	}
+	return setupMfa(&user)
+}
+
+// Enable 当前用户使用验证码确认绑定, 返回恢复码
+func (l MfaLogic) Enable(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.MfaEnableReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	user, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户信息失败"))
+	}
+	mfa := new(model.UserMfa)
+	err = isql.UserMfa.Find(tools.H{"user_id": user.ID}, mfa)
+	if err != nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("请先生成二次验证密钥"))
+	}
+	if mfa.Status == MfaStatusEnabled {
+		return nil, tools.NewValidatorError(fmt.Errorf("已启用二次验证"))
+	}
+	if !validateMfaTotp(mfa, r.Code) {
+		return nil, tools.NewValidatorError(fmt.Errorf("二次验证码错误"))
+	}
+	recoveryCodes, err := enableMfa(mfa)
+	if err != nil {
+		return nil, tools.NewMySqlError(err)
+	}
+	return tools.H{"recoveryCodes": recoveryCodes}, nil
+}
+
+// Disable 当前用户关闭二次验证
+func (l MfaLogic) Disable(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.MfaDisableReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	user, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户信息失败"))
+	}
+	if mfaRequired(&user) {
+		return nil, tools.NewValidatorError(fmt.Errorf("所属角色要求必须开启二次验证，无法关闭"))
+	}
+	mfa := new(model.UserMfa)
+	err = isql.UserMfa.Find(tools.H{"user_id": user.ID, "status": MfaStatusEnabled}, mfa)
+	if err != nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("未启用二次验证"))
+	}
+	if !verifyMfaCode(mfa, r.Code) {
+		return nil, tools.NewValidatorError(fmt.Errorf("二次验证码错误"))
+	}
+	err = isql.UserMfa.Delete([]uint{user.ID})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("关闭二次验证失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// Reset 管理员重置用户的二次验证, 用户下次登录时需重新绑定
+func (l MfaLogic) Reset(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.MfaResetReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	// 根据用户ID获取用户角色排序最小值
+	roleMinSortList, err := isql.User.GetUserMinRoleSortsByIds(r.UserIds)
+	if err != nil || len(roleMinSortList) == 0 {
+		return nil, tools.NewValidatorError(fmt.Errorf("根据用户ID获取用户角色排序最小值失败"))
+	}
+	// 获取当前登陆用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := isql.User.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("获取当前登陆用户角色排序最小值失败"))
+	}
+	// 不能重置比自己(登陆用户)角色排序低(等级高)的用户
+	for _, sort := range roleMinSortList {
+		if int(minSort) > sort {
+			return nil, tools.NewValidatorError(fmt.Errorf("用户不能重置比自己角色等级高的用户"))
+		}
+	}
+
+	users, err := isql.User.GetUserByIds(r.UserIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
+	}
+	for _, user := range users {
+		if rspError := checkDirectoryScope(c, user.DirectoryID); rspError != nil {
+			return nil, rspError
+		}
+	}
+	err = isql.UserMfa.Delete(r.UserIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("重置二次验证失败: %s", err.Error()))
+	}
+	usernames := funk.Map(users, func(u model.User) string { return u.Username }).([]string)
+	common.Log.Infof("用户 %s 重置了以下用户的二次验证: %s", ctxUser.Username, strings.Join(usernames, ","))
+	// 操作日志中间件只记录请求路径, 这里单独记录被重置的用户
+	err = isql.OperationLog.Add(&model.OperationLog{
+		Username:  ctxUser.Username,
+		Ip:        c.ClientIP(),
+		Method:    c.Request.Method,
+		Path:      operationLogPath(c),
+		Remark:    operationLogRemark(fmt.Sprintf("重置二次验证: %s", strings.Join(usernames, ","))),
+		Status:    200,
+		StartTime: fmt.Sprintf("%v", time.Now()),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		common.Log.Errorf("记录重置二次验证日志失败: %v", err)
+	}
+	return nil, nil
+}
+
+// mfaRequired 用户所属角色中是否有强制要求二次验证的
+func mfaRequired(user *model.User) bool {
+	for _, role := range user.Roles {
+		if role.Status == 1 && role.MfaRequired == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// setupMfa 生成新的TOTP密钥, 待用户使用验证码确认后生效
+func setupMfa(user *model.User) (data any, rspError any) {
+	if isql.UserMfa.Exist(tools.H{"user_id": user.ID, "status": MfaStatusEnabled}) {
+		return nil, tools.NewValidatorError(fmt.Errorf("已启用二次验证，如需重新绑定请先关闭或联系管理员重置"))
+	}
+	secret := tools.GenTotpSecret()
+	encrypted, err := tools.RSAEncrypt([]byte(secret), config.Conf.System.RSAPublicBytes)
+	if err != nil {
+		return nil, tools.NewOperationError(fmt.Errorf("加密二次验证密钥失败: %s", err.Error()))
+	}
+	err = isql.UserMfa.Save(&model.UserMfa{
+		UserID: user.ID,
+		Secret: string(encrypted),
+		Status: MfaStatusPending,
+	})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("保存二次验证密钥失败: %s", err.Error()))
+	}
+	return tools.H{
+		"secret":     secret,
+		"otpauthUrl": tools.TotpURI(mfaIssuer, user.Username, secret),
+	}, nil
+}
+
+// enableMfa 启用二次验证并生成恢复码, 恢复码只保存哈希
+func enableMfa(mfa *model.UserMfa) ([]string, error) {
+	recoveryCodes := tools.GenRecoveryCodes(mfaRecoveryCodeCount)
+	hashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hashes = append(hashes, tools.GenPasswd(code))
+	}
+	mfa.RecoveryCodes = strings.Join(hashes, ",")
+	mfa.Status = MfaStatusEnabled
+	err := isql.UserMfa.Save(mfa)
+	if err != nil {
+		return nil, fmt.Errorf("启用二次验证失败: %s", err.Error())
+	}
+	return recoveryCodes, nil
+}
+
+// validateMfaTotp 校验TOTP验证码, 同一验证码只能使用一次(RFC 6238 5.2节)
+func validateMfaTotp(mfa *model.UserMfa, code string) bool {
+	secret, err := tools.RSADecrypt([]byte(mfa.Secret), config.Conf.System.RSAPrivateBytes)
+	if err != nil {
+		common.Log.Errorf("解密用户[%d]的二次验证密钥失败: %v", mfa.UserID, err)
+		return false
+	}
+	step, ok := tools.MatchTotp(string(secret), code, time.Now())
+	if !ok || step <= mfa.LastStep {
+		return false
+	}
+	claimed, err := isql.UserMfa.ClaimStep(mfa.UserID, step)
+	if err != nil {
+		common.Log.Errorf("记录用户[%d]的二次验证时间步失败: %v", mfa.UserID, err)
+		return false
+	}
+	if claimed {
+		mfa.LastStep = step
+	}
+	return claimed
+}
+
+// verifyMfaCode 校验TOTP验证码, 不通过时尝试作为恢复码校验, 恢复码使用后即失效
+func verifyMfaCode(mfa *model.UserMfa, code string) bool {
+	if validateMfaTotp(mfa, code) {
+		return true
+	}
+	code = strings.ToLower(strings.TrimSpace(code))
+	if mfa.RecoveryCodes == "" || len(code) < 10 {
+		return false
+	}
+	hashes := strings.Split(mfa.RecoveryCodes, ",")
+	for i, hash := range hashes {
+		if tools.ComparePasswd(hash, code) == nil {
+			remain := append(hashes[:i:i], hashes[i+1:]...)
+			err := isql.UserMfa.UpdateRecoveryCodes(mfa.UserID, strings.Join(remain, ","))
+			if err != nil {
+				common.Log.Errorf("更新用户[%d]的恢复码失败: %v", mfa.UserID, err)
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// setupTestMfa 生成加密密钥并保存一个已启用的二次验证, 返回TOTP密钥
+func setupTestMfa(t *testing.T) (*model.UserMfa, string) {
+	setupLogicTest(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Conf.System = &config.SystemConfig{
+		RSAPublicBytes:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}),
+		RSAPrivateBytes: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}
+	secret := tools.GenTotpSecret()
+	encrypted, err := tools.RSAEncrypt([]byte(secret), config.Conf.System.RSAPublicBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa := &model.UserMfa{UserID: 1, Secret: string(encrypted), Status: MfaStatusEnabled}
+	if err := common.DB.Create(mfa).Error; err != nil {
+		t.Fatal(err)
+	}
+	return mfa, secret
+}
+
+func TestValidateMfaTotpReplay(t *testing.T) {
+	mfa, secret := setupTestMfa(t)
+	now := time.Now()
+	code, err := tools.TotpCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validateMfaTotp(mfa, code) {
+		t.Fatal("验证码应通过校验")
+	}
+	if validateMfaTotp(mfa, code) {
+		t.Fatal("同一验证码不能重复使用")
+	}
+
+	// 其他请求读取到的旧记录同样不能重复使用
+	stale := &model.UserMfa{UserID: mfa.UserID, Secret: mfa.Secret, Status: MfaStatusEnabled}
+	if validateMfaTotp(stale, code) {
+		t.Fatal("并发请求中同一验证码只能使用一次")
+	}
+	// 已使用的时间步之前的验证码也不再接受
+	prev, err := tools.TotpCode(secret, now.Add(-30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev != code && validateMfaTotp(mfa, prev) {
+		t.Fatal("早于已使用时间步的验证码不应通过校验")
+	}
+
+	saved := new(model.UserMfa)
+	if err := common.DB.First(saved, mfa.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.LastStep != now.Unix()/30 {
+		t.Fatalf("应记录通过校验的时间步 %d, 实际为 %d", now.Unix()/30, saved.LastStep)
+	}
+}
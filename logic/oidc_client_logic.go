@@ -0,0 +1,147 @@
+package logic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+)
+
+type OidcClientLogic struct{}
+
+// List 数据列表
+func (l OidcClientLogic) List(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.OidcClientListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	clients, err := isql.OidcClient.List(r)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取OIDC应用列表失败: %s", err.Error()))
+	}
+	rets := make([]model.OidcClient, 0)
+	for _, client := range clients {
+		rets = append(rets, *client)
+	}
+	count, err := isql.OidcClient.Count()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取OIDC应用总数失败"))
+	}
+	return response.OidcClientListRsp{
+		Total:   count,
+		Clients: rets,
+	}, nil
+}
+
+// Add 添加数据, client_secret仅在此时返回一次
+func (l OidcClientLogic) Add(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.OidcClientAddReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户信息失败"))
+	}
+
+	secret := oidcRandom()
+	client := model.OidcClient{
+		Name:         r.Name,
+		ClientID:     oidcRandom()[:32],
+		ClientSecret: tools.GenPasswd(secret),
+		RedirectURIs: strings.Join(r.RedirectURIs, "\n"),
+		Remark:       r.Remark,
+		Status:       r.Status,
+		Creator:      ctxUser.Username,
+	}
+	err = isql.OidcClient.Add(&client)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("创建OIDC应用失败: %s", err.Error()))
+	}
+	return response.OidcClientSecretRsp{
+		ClientID:     client.ClientID,
+		ClientSecret: secret,
+	}, nil
+}
+
+// Update 更新数据
+func (l OidcClientLogic) Update(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.OidcClientUpdateReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	oldData := new(model.OidcClient)
+	err := isql.OidcClient.Find(tools.H{"id": r.ID}, oldData)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("OIDC应用不存在"))
+	}
+	client := model.OidcClient{
+		Model:        oldData.Model,
+		Name:         r.Name,
+		RedirectURIs: strings.Join(r.RedirectURIs, "\n"),
+		Remark:       r.Remark,
+		Status:       r.Status,
+	}
+	err = isql.OidcClient.Update(&client)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("更新OIDC应用失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// ResetSecret 重置应用密钥, 新的client_secret仅在此时返回一次
+func (l OidcClientLogic) ResetSecret(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.OidcClientResetSecretReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	oldData := new(model.OidcClient)
+	err := isql.OidcClient.Find(tools.H{"id": r.ID}, oldData)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("OIDC应用不存在"))
+	}
+	secret := oidcRandom()
+	err = isql.OidcClient.Update(&model.OidcClient{
+		Model:        oldData.Model,
+		ClientSecret: tools.GenPasswd(secret),
+	})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("重置OIDC应用密钥失败: %s", err.Error()))
+	}
+	return response.OidcClientSecretRsp{
+		ClientID:     oldData.ClientID,
+		ClientSecret: secret,
+	}, nil
+}
+
+// Delete 删除数据
+func (l OidcClientLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.OidcClientDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	for _, id := range r.ClientIds {
+		if !isql.OidcClient.Exist(tools.H{"id": id}) {
+			return nil, tools.NewMySqlError(fmt.Errorf("OIDC应用不存在"))
+		}
+	}
+	err := isql.OidcClient.Delete(r.ClientIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("删除OIDC应用失败: %s", err.Error()))
+	}
+	return nil, nil
+}
@@ -0,0 +1,473 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/patrickmn/go-cache"
+	"github.com/thoas/go-funk"
+)
+
+// 支持的scope
+var oidcScopes = []string{"openid", "profile", "email", "phone", "groups"}
+
+// OidcError 按OAuth2规范返回给应用的错误
+type OidcError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OidcError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newOidcError(code, description string) *OidcError {
+	return &OidcError{Code: code, Description: description}
+}
+
+// oidcCode 授权码关联的信息
+type oidcCode struct {
+	ClientID            string
+	RedirectURI         string
+	UserID              uint
+	Scope               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	AuthTime            int64
+}
+
+var (
+	oidcCodeCache = cache.New(5*time.Minute, 10*time.Minute)
+	oidcCodeLock  sync.Mutex
+
+	oidcKeyOnce sync.Once
+	oidcKey     *rsa.PrivateKey
+	oidcKid     string
+	oidcKeyErr  error
+)
+
+// OidcEnabled 是否启用内置OIDC身份提供者
+func OidcEnabled() bool {
+	return config.Conf.Oidc != nil && config.Conf.Oidc.Enable
+}
+
+func oidcIssuer() string {
+	return strings.TrimRight(config.Conf.Oidc.Issuer, "/")
+}
+
+func oidcTokenTTL() time.Duration {
+	if config.Conf.Oidc.TokenTTL <= 0 {
+		return time.Hour
+	}
+	return time.Duration(config.Conf.Oidc.TokenTTL) * time.Second
+}
+
+func oidcCodeTTL() time.Duration {
+	if config.Conf.Oidc.CodeTTL <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(config.Conf.Oidc.CodeTTL) * time.Second
+}
+
+// oidcSigningKey 加载签名私钥, 文件不存在时生成并保存
+func oidcSigningKey() (*rsa.PrivateKey, string, error) {
+	oidcKeyOnce.Do(func() {
+		file := config.Conf.Oidc.SigningKeyFile
+		if file == "" {
+			oidcKeyErr = errors.New("未配置OIDC签名私钥文件")
+			return
+		}
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			common.Log.Infof("OIDC签名私钥文件 %s 不存在，自动生成", file)
+			oidcKey, err = rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				oidcKeyErr = fmt.Errorf("生成OIDC签名私钥失败: %v", err)
+				return
+			}
+			data = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(oidcKey)})
+			if err = os.MkdirAll(filepath.Dir(file), 0700); err == nil {
+				err = os.WriteFile(file, data, 0600)
+			}
+			if err != nil {
+				oidcKeyErr = fmt.Errorf("保存OIDC签名私钥失败: %v", err)
+				return
+			}
+		} else if err != nil {
+			oidcKeyErr = fmt.Errorf("读取OIDC签名私钥失败: %v", err)
+			return
+		} else {
+			oidcKey, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				oidcKeyErr = fmt.Errorf("解析OIDC签名私钥失败: %v", err)
+				return
+			}
+		}
+		der, _ := x509.MarshalPKIXPublicKey(&oidcKey.PublicKey)
+		sum := sha256.Sum256(der)
+		oidcKid = base64.RawURLEncoding.EncodeToString(sum[:])[:16]
+	})
+	return oidcKey, oidcKid, oidcKeyErr
+}
+
+// OidcDiscovery 发现文档
+func OidcDiscovery() tools.H {
+	issuer := oidcIssuer()
+	return tools.H{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      oidcScopes,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":      []string{"plain", "S256"},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "preferred_username", "nickname", "picture", "email", "phone_number", "groups",
+		},
+	}
+}
+
+// OidcJWKS 公钥集合, 供应用校验id_token签名
+func OidcJWKS() (tools.H, error) {
+	key, kid, err := oidcSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	return tools.H{
+		"keys": []tools.H{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}},
+	}, nil
+}
+
+// OidcCheckAuthorize 校验授权请求
+// 返回普通error时说明client_id或redirect_uri不可信, 不能重定向回应用, 只能直接展示错误
+func OidcCheckAuthorize(r *request.OidcAuthorizeReq) (*model.OidcClient, error) {
+	client := new(model.OidcClient)
+	err := isql.OidcClient.Find(tools.H{"client_id": r.ClientID, "status": 1}, client)
+	if err != nil {
+		return nil, errors.New("应用不存在或已被禁用")
+	}
+	if !oidcRedirectURIAllowed(client, r.RedirectURI) {
+		return nil, errors.New("回调地址与应用登记的不一致")
+	}
+	if r.ResponseType != "code" {
+		return client, newOidcError("unsupported_response_type", "仅支持授权码模式")
+	}
+	scopes := strings.Fields(r.Scope)
+	if !funk.ContainsString(scopes, "openid") {
+		return client, newOidcError("invalid_scope", "scope必须包含openid")
+	}
+	switch r.CodeChallengeMethod {
+	case "", "plain", "S256":
+	default:
+		return client, newOidcError("invalid_request", "不支持的code_challenge_method")
+	}
+	return client, nil
+}
+
+// OidcErrorRedirect 将错误通过重定向返回给应用
+func OidcErrorRedirect(r *request.OidcAuthorizeReq, e *OidcError) string {
+	q := url.Values{}
+	q.Set("error", e.Code)
+	q.Set("error_description", e.Description)
+	if r.State != "" {
+		q.Set("state", r.State)
+	}
+	return appendQuery(r.RedirectURI, q)
+}
+
+// OidcIssueCode 用户登录成功后签发授权码, 返回重定向到应用的地址
+func OidcIssueCode(r *request.OidcAuthorizeReq, user *model.User, authTime int64) string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Fields(r.Scope) {
+		if funk.ContainsString(oidcScopes, scope) && !funk.ContainsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	method := r.CodeChallengeMethod
+	if r.CodeChallenge != "" && method == "" {
+		method = "plain"
+	}
+	code := oidcRandom()
+	oidcCodeCache.Set(code, &oidcCode{
+		ClientID:            r.ClientID,
+		RedirectURI:         r.RedirectURI,
+		UserID:              user.ID,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               r.Nonce,
+		CodeChallenge:       r.CodeChallenge,
+		CodeChallengeMethod: method,
+		AuthTime:            authTime,
+	}, oidcCodeTTL())
+
+	q := url.Values{}
+	q.Set("code", code)
+	if r.State != "" {
+		q.Set("state", r.State)
+	}
+	return appendQuery(r.RedirectURI, q)
+}
+
+// OidcExchangeCode 使用授权码换取令牌
+func OidcExchangeCode(r *request.OidcTokenReq) (tools.H, *OidcError) {
+	client := new(model.OidcClient)
+	err := isql.OidcClient.Find(tools.H{"client_id": r.ClientID, "status": 1}, client)
+	if err != nil || tools.ComparePasswd(client.ClientSecret, r.ClientSecret) != nil {
+		return nil, newOidcError("invalid_client", "应用认证失败")
+	}
+	if r.GrantType != "authorization_code" {
+		return nil, newOidcError("unsupported_grant_type", "仅支持authorization_code")
+	}
+
+	// 授权码只能使用一次
+	oidcCodeLock.Lock()
+	v, ok := oidcCodeCache.Get(r.Code)
+	oidcCodeCache.Delete(r.Code)
+	oidcCodeLock.Unlock()
+	if !ok {
+		return nil, newOidcError("invalid_grant", "授权码无效或已过期")
+	}
+	code := v.(*oidcCode)
+	if code.ClientID != client.ClientID || code.RedirectURI != r.RedirectURI {
+		return nil, newOidcError("invalid_grant", "授权码与应用或回调地址不匹配")
+	}
+	if !oidcVerifyPKCE(code, r.CodeVerifier) {
+		return nil, newOidcError("invalid_grant", "code_verifier校验失败")
+	}
+
+	user := new(model.User)
+	err = isql.User.Find(tools.H{"id": code.UserID}, user)
+	if err != nil || user.Status != 1 {
+		return nil, newOidcError("invalid_grant", "用户不存在或已被禁用")
+	}
+
+	key, kid, err := oidcSigningKey()
+	if err != nil {
+		common.Log.Errorf("OIDC签发令牌失败: %v", err)
+		return nil, newOidcError("server_error", "签名密钥不可用")
+	}
+	now := time.Now()
+	exp := now.Add(oidcTokenTTL())
+	scopes := strings.Fields(code.Scope)
+
+	idClaims := oidcUserClaims(user, scopes)
+	idClaims["iss"] = oidcIssuer()
+	idClaims["aud"] = client.ClientID
+	idClaims["iat"] = now.Unix()
+	idClaims["exp"] = exp.Unix()
+	idClaims["auth_time"] = code.AuthTime
+	if code.Nonce != "" {
+		idClaims["nonce"] = code.Nonce
+	}
+	idToken, err := oidcSign(key, kid, idClaims)
+	if err != nil {
+		return nil, newOidcError("server_error", err.Error())
+	}
+
+	accessToken, err := oidcSign(key, kid, jwt.MapClaims{
+		"iss":       oidcIssuer(),
+		"sub":       strconv.Itoa(int(user.ID)),
+		"aud":       client.ClientID,
+		"iat":       now.Unix(),
+		"exp":       exp.Unix(),
+		"scope":     code.Scope,
+		"token_use": "access",
+	})
+	if err != nil {
+		return nil, newOidcError("server_error", err.Error())
+	}
+
+	return tools.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(oidcTokenTTL().Seconds()),
+		"id_token":     idToken,
+		"scope":        code.Scope,
+	}, nil
+}
+
+// OidcUserInfo 根据access_token返回用户信息
+func OidcUserInfo(accessToken string) (tools.H, *OidcError) {
+	claims, err := oidcParse(accessToken, "access")
+	if err != nil {
+		return nil, newOidcError("invalid_token", err.Error())
+	}
+	sub, _ := claims["sub"].(string)
+	userId, _ := strconv.Atoi(sub)
+	user := new(model.User)
+	err = isql.User.Find(tools.H{"id": userId}, user)
+	if err != nil || user.Status != 1 {
+		return nil, newOidcError("invalid_token", "用户不存在或已被禁用")
+	}
+	scope, _ := claims["scope"].(string)
+	return oidcUserClaims(user, strings.Fields(scope)), nil
+}
+
+// OidcSessionToken 授权页登录成功后签发的会话令牌, 保存在cookie中, 有效期内再次授权无需重复登录
+func OidcSessionToken(user *model.User) (string, time.Time, error) {
+	key, kid, err := oidcSigningKey()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	exp := now.Add(oidcTokenTTL())
+	token, err := oidcSign(key, kid, jwt.MapClaims{
+		"iss":       oidcIssuer(),
+		"sub":       strconv.Itoa(int(user.ID)),
+		"iat":       now.Unix(),
+		"exp":       exp.Unix(),
+		"token_use": "session",
+	})
+	return token, exp, err
+}
+
+// OidcSessionUser 根据会话令牌获取已登录的用户及登录时间
+func OidcSessionUser(session string) (*model.User, int64, error) {
+	claims, err := oidcParse(session, "session")
+	if err != nil {
+		return nil, 0, err
+	}
+	sub, _ := claims["sub"].(string)
+	userId, _ := strconv.Atoi(sub)
+	user := new(model.User)
+	err = isql.User.Find(tools.H{"id": userId}, user)
+	if err != nil {
+		return nil, 0, errors.New("用户不存在")
+	}
+	if user.Status != 1 {
+		return nil, 0, errors.New("用户被禁用")
+	}
+	iat, _ := claims["iat"].(float64)
+	return user, int64(iat), nil
+}
+
+// oidcUserClaims 根据scope组装用户信息
+func oidcUserClaims(user *model.User, scopes []string) tools.H {
+	claims := tools.H{
+		"sub": strconv.Itoa(int(user.ID)),
+	}
+	if funk.ContainsString(scopes, "profile") {
+		claims["name"] = user.Nickname
+		claims["preferred_username"] = user.Username
+		claims["nickname"] = user.GivenName
+		if user.Avatar != "" {
+			claims["picture"] = user.Avatar
+		}
+	}
+	if funk.ContainsString(scopes, "email") {
+		claims["email"] = user.Mail
+	}
+	if funk.ContainsString(scopes, "phone") {
+		claims["phone_number"] = user.Mobile
+	}
+	if funk.ContainsString(scopes, "groups") {
+		groupNames := make([]string, 0)
+		groups, err := isql.Group.GetUserGroups(user.ID)
+		if err != nil {
+			common.Log.Errorf("获取用户[%s]所在分组失败: %v", user.Username, err)
+		}
+		for _, group := range groups {
+			groupNames = append(groupNames, group.GroupName)
+		}
+		claims["groups"] = groupNames
+	}
+	return claims
+}
+
+func oidcSign(key *rsa.PrivateKey, kid string, claims map[string]any) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims(claims))
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+func oidcParse(tokenString, tokenUse string) (jwt.MapClaims, error) {
+	key, _, err := oidcSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
+	token, err := parser.Parse(tokenString, func(t *jwt.Token) (any, error) {
+		return &key.PublicKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("令牌无效或已过期")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["token_use"] != tokenUse || claims["iss"] != oidcIssuer() {
+		return nil, errors.New("令牌无效")
+	}
+	return claims, nil
+}
+
+func oidcVerifyPKCE(code *oidcCode, verifier string) bool {
+	switch code.CodeChallengeMethod {
+	case "":
+		return true
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(code.CodeChallenge)) == 1
+	default:
+		return verifier != "" && subtle.ConstantTimeCompare([]byte(verifier), []byte(code.CodeChallenge)) == 1
+	}
+}
+
+func oidcRedirectURIAllowed(client *model.OidcClient, redirectURI string) bool {
+	if redirectURI == "" {
+		return false
+	}
+	for _, uri := range strings.Split(client.RedirectURIs, "\n") {
+		if strings.TrimSpace(uri) == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+func oidcRandom() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func appendQuery(rawURL string, q url.Values) string {
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + q.Encode()
+	}
+	return rawURL + "?" + q.Encode()
+}
@@ -1,11 +1,12 @@
 package logic
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/eryajf/go-ldap-admin/config"
 	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
 	"github.com/eryajf/go-ldap-admin/public/client/openldap"
 	"github.com/eryajf/go-ldap-admin/public/common"
 	"github.com/eryajf/go-ldap-admin/public/tools"
@@ -16,68 +17,128 @@ import (
 type OpenLdapLogic struct {
 }
 
+func init() {
+	RegisterSyncProvider(OpenLdap)
+}
+
 // 通过ldap获取部门信息
 func (d *OpenLdapLogic) SyncOpenLdapDepts(c *gin.Context, req any) (data any, rspError any) {
-	// 1.获取所有部门
-	depts, err := openldap.GetAllDepts()
-	if err != nil {
-		errMsg := fmt.Sprintf("获取OpenLDAP部门列表失败：%s", err.Error())
-		common.Log.Errorf("SyncOpenLdapDepts: %s", errMsg)
-		return nil, tools.NewOperationError(errors.New(errMsg))
+	r, ok := req.(*request.SyncOpenLdapDeptsReq)
+	if !ok {
+		return nil, ReqAssertErr
 	}
-	if len(depts) == 0 {
-		errMsg := "获取到的部门数量为0"
-		common.Log.Errorf("SyncOpenLdapDepts: %s", errMsg)
-		return nil, tools.NewOperationError(errors.New(errMsg))
+	return syncDepts(c, d, r.DryRun)
+}
+
+// 根据现有数据库同步到的部门信息，开启用户同步
+func (d *OpenLdapLogic) SyncOpenLdapUsers(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncOpenLdapUserReq)
+	if !ok {
+		return nil, ReqAssertErr
 	}
-	groups := make([]*model.Group, 0)
+	return syncUsers(c, d, r.DryRun)
+}
+
+// Flag OpenLDAP的来源标识
+func (d OpenLdapLogic) Flag() string {
+	return "openldap"
+}
+
+// Name OpenLDAP的来源名称
+func (d OpenLdapLogic) Name() string {
+	return "OpenLDAP"
+}
+
+// Config OpenLDAP只将ldap中已有的数据导入平台, 不做更新与删除, 也不定时同步
+func (d OpenLdapLogic) Config() *SyncConfig {
+	return &SyncConfig{RootDeptId: "0"}
+}
+
+// Schema OpenLDAP使用平台自身的ldap连接配置
+func (d OpenLdapLogic) Schema() []*model.SyncSourceField {
+	conf := config.Conf.Ldap
+	return syncSourceFields([]*model.SyncSourceField{
+		{Key: "ldap.url", Name: "ldap地址", Required: true},
+		{Key: "ldap.base-dn", Name: "ldap的基础dn", Required: true},
+		{Key: "ldap.user-dn", Name: "用户所在的dn", Required: true},
+		{Key: "ldap.member-of", Name: "是否通过memberOf获取用户所在的分组"},
+	}, conf.Url, conf.BaseDN, conf.UserDN, conf.MemberOf)
+}
+
+// GetDepts 获取ldap中的全部分组
+func (d OpenLdapLogic) GetDepts() ([]map[string]any, error) {
+	var ret []map[string]any
+	err := openldap.EachDept(func(dept *openldap.Dept) error {
+		ret = append(ret, map[string]any{
+			"dn":       dept.DN,
+			"id":       dept.Id,
+			"name":     dept.Name,
+			"remark":   dept.Remark,
+			"parentid": dept.ParentId,
+			"schema":   dept.Schema,
+		})
+		return nil
+	})
+	return ret, err
+}
+
+// EachUser 分页读取ldap用户, 每读取到一个用户就交给fn处理, 不需要一次性加载全部用户
+func (d OpenLdapLogic) EachUser(fn func(user map[string]any) error) error {
+	return openldap.EachUser(func(staff *openldap.User) error {
+		return fn(map[string]any{
+			"name":           staff.Name,
+			"dn":             staff.DN,
+			"department_ids": staff.DepartmentIds,
+			"fields":         staff.Fields,
+		})
+	})
+}
+
+// GetLeavers ldap中不存在离职用户
+func (d OpenLdapLogic) GetLeavers(usernames map[string]struct{}) ([]*model.User, error) {
+	return nil, nil
+}
+
+// ConvertDepts ldap中的分组自带dn, 直接转换, 不使用字段关联
+func (d OpenLdapLogic) ConvertDepts(depts []map[string]any) ([]*model.Group, error) {
+	groups := make([]*model.Group, 0, len(depts))
 	for _, dept := range depts {
 		groups = append(groups, &model.Group{
-			GroupName:          dept.Name,
-			Remark:             dept.Remark,
-			SourceDeptId:       dept.Id,
-			SourceDeptParentId: dept.ParentId,
-			GroupDN:            dept.DN,
+			GroupName:          dept["name"].(string),
+			Remark:             dept["remark"].(string),
+			SourceDeptId:       dept["id"].(string),
+			SourceDeptParentId: dept["parentid"].(string),
+			GroupDN:            dept["dn"].(string),
+			GroupSchema:        dept["schema"].(string),
 		})
 	}
-	// 2.将远程数据转换成树
-	deptTree := GroupListToTree("0", groups)
+	return groups, nil
+}
 
-	// 3.根据树进行创建
-	err = d.addDepts(deptTree.Children)
+// ConvertUser 按配置的用户schema导入映射填充用户字段
+func (d OpenLdapLogic) ConvertUser(staff map[string]any) (*model.User, error) {
+	name := staff["name"].(string)
+	groupIds, err := isql.Group.DeptIdsToGroupIds(staff["department_ids"].([]string))
 	if err != nil {
-		errMsg := fmt.Sprintf("创建OpenLDAP部门失败：%s", err.Error())
-		common.Log.Errorf("SyncOpenLdapDepts: %s", errMsg)
-		return nil, err
+		return nil, tools.NewMySqlError(fmt.Errorf("将用户[%s]的部门ids转换为内部部门id失败：%s", name, err.Error()))
 	}
-
-	common.Log.Infof("SyncOpenLdapDepts: OpenLDAP部门同步成功")
-	return nil, err
-}
-
-// 添加部门
-func (d OpenLdapLogic) addDepts(depts []*model.Group) error {
-	for _, dept := range depts {
-		err := d.AddDepts(dept)
-		if err != nil {
-			errMsg := fmt.Sprintf("DsyncOpenLdapDepts添加部门[%s]失败: %s", dept.GroupName, err.Error())
-			common.Log.Errorf("%s", errMsg)
-			return tools.NewOperationError(errors.New(errMsg))
-		}
-		if len(dept.Children) != 0 {
-			err = d.addDepts(dept.Children)
-			if err != nil {
-				errMsg := fmt.Sprintf("DsyncOpenLdapDepts添加子部门失败: %s", err.Error())
-				common.Log.Errorf("%s", errMsg)
-				return tools.NewOperationError(errors.New(errMsg))
-			}
-		}
+	user := &model.User{
+		Username:      name,
+		Creator:       "system",
+		Source:        d.Flag(),
+		DepartmentId:  tools.SliceToString(groupIds, ","),
+		SourceUserId:  name,
+		SourceUnionId: name,
+		UserDN:        staff["dn"].(string),
 	}
-	return nil
+	for field, value := range staff["fields"].(map[string]string) {
+		common.SetLdapUserField(user, field, value)
+	}
+	return user, nil
 }
 
-// AddGroup 添加部门数据
-func (d OpenLdapLogic) AddDepts(group *model.Group) error {
+// AddGroup 将ldap中的分组导入平台
+func (d OpenLdapLogic) AddGroup(group *model.Group) error {
 	// 判断部门名称是否存在,此处使用ldap中的唯一值dn,以免出现数据同步不全的问题
 	if !isql.Group.Exist(tools.H{"group_dn": group.GroupDN}) {
 		// 此时的 group 已经附带了Build后动态关联好的字段，接下来将一些确定性的其他字段值添加上，就可以创建这个分组了
@@ -88,7 +149,7 @@ func (d OpenLdapLogic) AddDepts(group *model.Group) error {
 			return err
 		}
 		group.ParentId = parentid
-		group.Source = "openldap"
+		group.Source = d.Flag()
 		err = isql.Group.Add(group)
 		if err != nil {
 			return err
@@ -98,7 +159,7 @@ func (d OpenLdapLogic) AddDepts(group *model.Group) error {
 
 }
 
-// AddGroup 添加部门数据
+// getParentGroupID 获取分组在平台中的父级分组
 func (d OpenLdapLogic) getParentGroupID(group *model.Group) (id uint, err error) {
 	switch group.SourceDeptParentId {
 	case "dingtalkroot":
@@ -116,71 +177,16 @@ func (d OpenLdapLogic) getParentGroupID(group *model.Group) (id uint, err error)
 	return parentGroup.ID, nil
 }
 
-// 根据现有数据库同步到的部门信息，开启用户同步
-func (d OpenLdapLogic) SyncOpenLdapUsers(c *gin.Context, req any) (data any, rspError any) {
-	// 1.获取ldap用户列表
-	staffs, err := openldap.GetAllUsers()
-	if err != nil {
-		errMsg := fmt.Sprintf("获取OpenLDAP用户列表失败：%s", err.Error())
-		common.Log.Errorf("SyncOpenLdapUsers: %s", errMsg)
-		return nil, tools.NewOperationError(errors.New(errMsg))
-	}
-	if len(staffs) == 0 {
-		errMsg := "获取到的用户数量为0"
-		common.Log.Errorf("SyncOpenLdapUsers: %s", errMsg)
-		return nil, tools.NewOperationError(errors.New(errMsg))
-	}
-	// 2.遍历用户，开始写入
-	for i, staff := range staffs {
-		groupIds, err := isql.Group.DeptIdsToGroupIds(staff.DepartmentIds)
-		if err != nil {
-			errMsg := fmt.Sprintf("将用户[%s]的部门ids转换为内部部门id失败：%s", staff.Name, err.Error())
-			common.Log.Errorf("SyncOpenLdapUsers: %s", errMsg)
-			return nil, tools.NewMySqlError(errors.New(errMsg))
-		}
+// AddUser 将ldap中的用户导入平台
+func (d OpenLdapLogic) AddUser(user *model.User) error {
+	// 根据 user_dn 查询用户,不存在则创建
+	if !isql.User.Exist(tools.H{"user_dn": user.UserDN}) {
 		// 根据角色id获取角色
 		roles, err := isql.Role.GetRolesByIds([]uint{2})
 		if err != nil {
-			errMsg := fmt.Sprintf("获取用户[%s]的角色信息失败：%s", staff.Name, err.Error())
-			common.Log.Errorf("SyncOpenLdapUsers: %s", errMsg)
-			return nil, tools.NewValidatorError(errors.New(errMsg))
-		}
-		// 入库
-		err = d.AddUsers(&model.User{
-			Username:      staff.Name,
-			Nickname:      staff.DisplayName,
-			GivenName:     staff.GivenName,
-			Mail:          staff.Mail,
-			JobNumber:     staff.EmployeeNumber,
-			Mobile:        staff.Mobile,
-			PostalAddress: staff.PostalAddress,
-			Departments:   staff.BusinessCategory,
-			Position:      staff.DepartmentNumber,
-			Introduction:  staff.CN,
-			Creator:       "system",
-			Source:        "openldap",
-			DepartmentId:  tools.SliceToString(groupIds, ","),
-			SourceUserId:  staff.Name,
-			SourceUnionId: staff.Name,
-			Roles:         roles,
-			UserDN:        staff.DN,
-		})
-		if err != nil {
-			errMsg := fmt.Sprintf("写入用户[%s]失败：%s", staff.Name, err.Error())
-			common.Log.Errorf("SyncOpenLdapUsers: %s", errMsg)
-			return nil, tools.NewOperationError(errors.New(errMsg))
+			return tools.NewValidatorError(fmt.Errorf("获取用户的角色信息失败：%s", err.Error()))
 		}
-		common.Log.Infof("SyncOpenLdapUsers: 成功同步用户[%s] (%d/%d)", staff.Name, i+1, len(staffs))
-	}
-
-	common.Log.Infof("SyncOpenLdapUsers: OpenLDAP用户同步完成，共同步%d个用户", len(staffs))
-	return nil, nil
-}
-
-// AddUser 添加用户数据
-func (d OpenLdapLogic) AddUsers(user *model.User) error {
-	// 根据 user_dn 查询用户,不存在则创建
-	if !isql.User.Exist(tools.H{"user_dn": user.UserDN}) {
+		user.Roles = roles
 		if user.Departments == "" {
 			user.Departments = "默认:研发中心"
 		}
@@ -200,7 +206,7 @@ func (d OpenLdapLogic) AddUsers(user *model.User) error {
 			user.JobNumber = "未启用"
 		}
 		// 先将用户添加到MySQL
-		err := isql.User.Add(user)
+		err = isql.User.Add(user)
 		if err != nil {
 			return tools.NewMySqlError(fmt.Errorf("%s", "向MySQL创建用户失败："+err.Error()))
 		}
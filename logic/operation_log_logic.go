@@ -2,7 +2,9 @@ package logic
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/eryajf/go-ldap-admin/config"
 	"github.com/eryajf/go-ldap-admin/model"
 	"github.com/eryajf/go-ldap-admin/model/request"
 	"github.com/eryajf/go-ldap-admin/model/response"
@@ -85,3 +87,17 @@ func (l OperationLogLogic) Clean(c *gin.Context, req any) (data any, rspError an
 	}
 	return "操作日志清空完成", nil
 }
+
+// operationLogRemark 操作日志的备注最长100个字符, 超出时截断
+func operationLogRemark(remark string) string {
+	runes := []rune(remark)
+	if len(runes) <= 100 {
+		return remark
+	}
+	return string(runes[:97]) + "..."
+}
+
+// operationLogPath 与操作日志中间件相同, 记录去掉前缀的路由路径
+func operationLogPath(c *gin.Context) string {
+	return strings.TrimPrefix(c.FullPath(), "/"+config.Conf.System.UrlPathPrefix)
+}
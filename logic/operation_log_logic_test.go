@@ -0,0 +1,18 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestOperationLogRemark(t *testing.T) {
+	if got := operationLogRemark("解锁用户: zhangsan"); got != "解锁用户: zhangsan" {
+		t.Fatalf("未超长的备注不应截断, 实际为%s", got)
+	}
+	long := "解锁用户: " + strings.Repeat("zhangsan,", 20)
+	got := operationLogRemark(long)
+	if utf8.RuneCountInString(got) != 100 || !strings.HasPrefix(long, strings.TrimSuffix(got, "...")) {
+		t.Fatalf("超长的备注应截断为100个字符, 实际为%d个字符: %s", utf8.RuneCountInString(got), got)
+	}
+}
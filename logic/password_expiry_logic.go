@@ -0,0 +1,127 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+// ErrPwdExpired 密码校验通过但已过期, 需要先修改密码
+var ErrPwdExpired = errors.New("密码已过期，请修改密码后重新登录")
+
+// 修改过期密码使用的临时凭据, 10分钟内有效
+var pwdExpiredTicketCache = cache.New(10*time.Minute, 20*time.Minute)
+
+// PwdExpireAt 返回用户密码的过期时间, 未开启密码有效期时返回零值
+func PwdExpireAt(user *model.User) time.Time {
+	policy := tools.GetPasswordPolicy()
+	if policy.MaxAge <= 0 || user.PwdChangedAt == nil {
+		return time.Time{}
+	}
+	return user.PwdChangedAt.AddDate(0, 0, policy.MaxAge)
+}
+
+// PwdExpired 判断用户密码是否已过期
+func PwdExpired(user *model.User) bool {
+	expireAt := PwdExpireAt(user)
+	return !expireAt.IsZero() && time.Now().After(expireAt)
+}
+
+// PwdExpiredChallenge 密码已过期时返回修改密码使用的凭据
+func PwdExpiredChallenge(user *model.User) tools.H {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	ticket := hex.EncodeToString(buf)
+	pwdExpiredTicketCache.SetDefault(ticket, user.ID)
+	return tools.H{
+		"pwdTicket": ticket,
+	}
+}
+
+// LdapPwdExpiredChallenge LDAP绑定时提示密码已过期(ppolicy), 此时密码本身是正确的
+// 开启了二次验证的用户无法在此完成第二步校验, 需通过邮箱重置密码
+func LdapPwdExpiredChallenge(username string) (tools.H, bool) {
+	user := new(model.User)
+	if err := isql.User.Find(tools.H{"username": username}, user); err != nil || user.Status != 1 {
+		return nil, false
+	}
+	if isql.UserMfa.Exist(tools.H{"user_id": user.ID, "status": MfaStatusEnabled}) || mfaRequired(user) {
+		return nil, false
+	}
+	return PwdExpiredChallenge(user), true
+}
+
+// ChangeExpiredPwd 登录时密码已过期, 凭登录返回的凭据修改密码
+func (l BaseLogic) ChangeExpiredPwd(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.BaseChangeExpiredPwdReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	v, ok := pwdExpiredTicketCache.Get(r.PwdTicket)
+	if !ok {
+		return nil, tools.NewValidatorError(fmt.Errorf("修改密码凭据已过期，请重新登录"))
+	}
+	decodeNewPassword, err := tools.RSADecrypt([]byte(r.NewPassword), config.Conf.System.RSAPrivateBytes)
+	if err != nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("新密码解析失败"))
+	}
+	newPassword := string(decodeNewPassword)
+
+	user := new(model.User)
+	err = isql.User.Find(tools.H{"id": v.(uint)}, user)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
+	}
+	if data, err := checkPasswordPolicy(user, newPassword); err != nil {
+		return data, err
+	}
+
+	err = ildap.User.ChangePwd(user.UserDN, "", newPassword)
+	if err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("%s", "在LDAP更新密码失败"+err.Error()))
+	}
+	err = isql.User.ChangePwd(user.Username, tools.GenPasswd(newPassword))
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("%s", "在MySQL更新密码失败: "+err.Error()))
+	}
+	pwdExpiredTicketCache.Delete(r.PwdTicket)
+	return nil, nil
+}
+
+// NotifyPwdExpiring 提醒密码即将在expire-warn-days天内过期的用户
+func NotifyPwdExpiring() {
+	policy := tools.GetPasswordPolicy()
+	if policy.MaxAge <= 0 || policy.ExpireWarnDays <= 0 {
+		return
+	}
+	now := time.Now()
+	users, err := isql.User.ListPwdChangedBetween(now.AddDate(0, 0, -policy.MaxAge), now.AddDate(0, 0, policy.ExpireWarnDays-policy.MaxAge))
+	if err != nil {
+		common.Log.Errorf("获取密码即将过期的用户失败: %v", err)
+		return
+	}
+	for _, user := range users {
+		expireAt := PwdExpireAt(&user)
+		common.Log.Infof("用户[%s]的密码将于%s过期", user.Username, expireAt.Format("2006-01-02 15:04:05"))
+		if user.Mail == "" {
+			continue
+		}
+		if err := tools.SendPasswordExpiringNotification(user.Nickname, user.Mail, expireAt); err != nil {
+			common.Log.Warnf("发送密码过期提醒邮件失败，用户: %s, 邮箱: %s, 错误: %v", user.Username, user.Mail, err)
+		}
+	}
+}
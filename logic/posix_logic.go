@@ -0,0 +1,195 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+)
+
+// allocPosixUser 为用户分配uidNumber与主组gidNumber, 未开启POSIX或已分配时不处理
+func allocPosixUser(user *model.User) error {
+	if !ildap.PosixEnabled() {
+		return nil
+	}
+	conf := config.Conf.Ldap.Posix
+	if user.UidNumber == 0 {
+		uidNumber, err := isql.PosixId.Allocate(model.PosixIdKindUid, conf.UidMin, conf.UidMax, posixIdUsed(model.PosixIdKindUid))
+		if err != nil {
+			return err
+		}
+		user.UidNumber = uidNumber
+	}
+	if user.GidNumber > 0 {
+		return nil
+	}
+	gidNumber := conf.UserGidNumber
+	if gidNumber == 0 {
+		// 用户私有组优先使用与uidNumber同号的gidNumber, 已被分组占用时从gid范围中另行分配
+		claimed, err := claimPosixId(model.PosixIdKindGid, user.UidNumber)
+		if err != nil {
+			return err
+		}
+		gidNumber = user.UidNumber
+		if !claimed {
+			gidNumber, err = isql.PosixId.Allocate(model.PosixIdKindGid, conf.GidMin, conf.GidMax, posixIdUsed(model.PosixIdKindGid))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	user.GidNumber = gidNumber
+	return nil
+}
+
+// allocPosixGroup 为cn类型分组分配gidNumber, ou不是分组, 不需要分配
+func allocPosixGroup(group *model.Group) error {
+	if !ildap.PosixEnabled() || group.GroupType != "cn" || group.GidNumber > 0 {
+		return nil
+	}
+	conf := config.Conf.Ldap.Posix
+	gidNumber, err := isql.PosixId.Allocate(model.PosixIdKindGid, conf.GidMin, conf.GidMax, posixIdUsed(model.PosixIdKindGid))
+	if err != nil {
+		return err
+	}
+	group.GidNumber = gidNumber
+	return nil
+}
+
+// posixIdUsed 分配编号时跳过LDAP中已被使用的编号
+func posixIdUsed(kind string) func(number uint) (bool, error) {
+	return func(number uint) (bool, error) {
+		return ildap.PosixIdUsed(kind, number)
+	}
+}
+
+// claimPosixId 登记指定的编号, 已登记或已被LDAP中的条目使用时返回false
+func claimPosixId(kind string, number uint) (bool, error) {
+	used, err := ildap.PosixIdUsed(kind, number)
+	if err != nil || used {
+		return false, err
+	}
+	return isql.PosixId.Claim(kind, number)
+}
+
+// adoptPosixIds 回填时沿用LDAP条目中已有的uidNumber与gidNumber, 并登记为已分配
+// 已有编号决定了主机上文件的属主, 不能替换; 编号已登记(如多个用户共用同一主组)时同样沿用
+func adoptPosixIds(dn string) (uidNumber, gidNumber uint, err error) {
+	uidNumber, gidNumber, err = ildap.PosixIds(dn)
+	if err != nil {
+		return 0, 0, err
+	}
+	if uidNumber > 0 {
+		if _, err := isql.PosixId.Claim(model.PosixIdKindUid, uidNumber); err != nil {
+			return 0, 0, err
+		}
+	}
+	if gidNumber > 0 {
+		if _, err := isql.PosixId.Claim(model.PosixIdKindGid, gidNumber); err != nil {
+			return 0, 0, err
+		}
+	}
+	return uidNumber, gidNumber, nil
+}
+
+// PosixBackfill 为已有用户分配uidNumber并在LDAP中追加posixAccount
+func (l UserLogic) PosixBackfill(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.UserPosixBackfillReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if !ildap.PosixEnabled() {
+		return nil, tools.NewValidatorError(fmt.Errorf("未开启POSIX账号"))
+	}
+	users, err := isql.User.ListPosixPending(r.UserIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取待回填的用户失败: %s", err.Error()))
+	}
+	for _, user := range users {
+		if rspError := checkDirectoryScope(c, user.DirectoryID); rspError != nil {
+			return nil, rspError
+		}
+	}
+
+	failed := make([]string, 0)
+	for _, user := range users {
+		// 离职用户不在LDAP中, 没有可沿用的编号
+		if user.Status == 1 {
+			uidNumber, gidNumber, err := adoptPosixIds(user.UserDN)
+			if err != nil {
+				common.Log.Errorf("PosixBackfill: 读取用户[%s]已有的uidNumber失败: %v", user.Username, err)
+				failed = append(failed, user.Username)
+				continue
+			}
+			user.UidNumber, user.GidNumber = uidNumber, gidNumber
+		}
+		if err := allocPosixUser(user); err != nil {
+			return tools.H{"count": len(users) - len(failed), "failed": failed}, tools.NewMySqlError(fmt.Errorf("为用户[%s]分配uidNumber失败: %s", user.Username, err.Error()))
+		}
+		// 离职用户不在LDAP中, 只记录编号, 恢复在职时随用户条目一起写入
+		if user.Status == 1 {
+			if err := ildap.User.EnablePosix(user); err != nil {
+				common.Log.Errorf("PosixBackfill: 在LDAP回填用户[%s]失败: %v", user.Username, err)
+				failed = append(failed, user.Username)
+				continue
+			}
+		}
+		if err := isql.User.UpdatePosix(user.ID, user.UidNumber, user.GidNumber); err != nil {
+			return tools.H{"count": len(users) - len(failed), "failed": failed}, tools.NewMySqlError(fmt.Errorf("更新用户[%s]的uidNumber失败: %s", user.Username, err.Error()))
+		}
+	}
+	return tools.H{"count": len(users) - len(failed), "failed": failed}, nil
+}
+
+// PosixBackfill 为已有cn类型分组分配gidNumber并在LDAP中追加posixGroup
+func (l GroupLogic) PosixBackfill(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.GroupPosixBackfillReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if !ildap.PosixEnabled() {
+		return nil, tools.NewValidatorError(fmt.Errorf("未开启POSIX账号"))
+	}
+	groups, err := isql.Group.ListPosixPending(r.GroupIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取待回填的分组失败: %s", err.Error()))
+	}
+	if rspError := checkGroupScope(c, groups...); rspError != nil {
+		return nil, rspError
+	}
+
+	failed := make([]string, 0)
+	for _, group := range groups {
+		_, gidNumber, err := adoptPosixIds(group.GroupDN)
+		if err != nil {
+			common.Log.Errorf("PosixBackfill: 读取分组[%s]已有的gidNumber失败: %v", group.GroupName, err)
+			failed = append(failed, group.GroupName)
+			continue
+		}
+		group.GidNumber = gidNumber
+		if err := allocPosixGroup(group); err != nil {
+			return tools.H{"count": len(groups) - len(failed), "failed": failed}, tools.NewMySqlError(fmt.Errorf("为分组[%s]分配gidNumber失败: %s", group.GroupName, err.Error()))
+		}
+		memberUids := make([]string, 0, len(group.Users))
+		for _, user := range group.Users {
+			if user.UserDN != config.Conf.Ldap.AdminDN && user.Status == 1 {
+				memberUids = append(memberUids, user.Username)
+			}
+		}
+		if err := ildap.Group.EnablePosix(group, memberUids); err != nil {
+			common.Log.Errorf("PosixBackfill: 在LDAP回填分组[%s]失败: %v", group.GroupName, err)
+			failed = append(failed, group.GroupName)
+			continue
+		}
+		if err := isql.Group.UpdateGidNumber(group.ID, group.GidNumber); err != nil {
+			return tools.H{"count": len(groups) - len(failed), "failed": failed}, tools.NewMySqlError(fmt.Errorf("更新分组[%s]的gidNumber失败: %s", group.GroupName, err.Error()))
+		}
+	}
+	return tools.H{"count": len(groups) - len(failed), "failed": failed}, nil
+}
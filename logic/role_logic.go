@@ -42,12 +42,17 @@ func (l RoleLogic) Add(c *gin.Context, req any) (data any, rspError any) {
 	}
 
 	role := model.Role{
-		Name:    r.Name,
-		Keyword: r.Keyword,
-		Remark:  r.Remark,
-		Status:  r.Status,
-		Sort:    r.Sort,
-		Creator: ctxUser.Username,
+		Name:        r.Name,
+		Keyword:     r.Keyword,
+		Remark:      r.Remark,
+		Status:      r.Status,
+		Sort:        r.Sort,
+		Creator:     ctxUser.Username,
+		MfaRequired: r.MfaRequired,
+	}
+	role.DirectoryIds, rspError = roleDirectoryIds(c, r.DirectoryIds)
+	if rspError != nil {
+		return nil, rspError
 	}
 
 	// 创建角色
@@ -132,13 +137,18 @@ func (l RoleLogic) Update(c *gin.Context, req any) (data any, rspError any) {
 		return nil, tools.NewMySqlError(err)
 	}
 	role := model.Role{
-		Model:   oldData.Model,
-		Name:    r.Name,
-		Keyword: r.Keyword,
-		Remark:  r.Remark,
-		Status:  r.Status,
-		Sort:    r.Sort,
-		Creator: ctxUser.Username,
+		Model:       oldData.Model,
+		Name:        r.Name,
+		Keyword:     r.Keyword,
+		Remark:      r.Remark,
+		Status:      r.Status,
+		Sort:        r.Sort,
+		Creator:     ctxUser.Username,
+		MfaRequired: r.MfaRequired,
+	}
+	role.DirectoryIds, rspError = roleDirectoryIds(c, r.DirectoryIds)
+	if rspError != nil {
+		return nil, rspError
 	}
 
 	// 更新角色
@@ -0,0 +1,149 @@
+package logic
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/gin-gonic/gin"
+)
+
+// Bulk 批量操作, 按顺序执行每一项操作, 后面的操作可以通过bulkId引用前面创建的资源
+func (l ScimLogic) Bulk(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimBulkReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if len(r.Operations) > scimMaxOperations {
+		return nil, scimError(http.StatusRequestEntityTooLarge, tools.ScimTooMany, "批量操作不能超过%d项", scimMaxOperations)
+	}
+
+	rsp := &response.ScimBulkRsp{
+		Schemas:    []string{model.ScimBulkResponseSchema},
+		Operations: make([]*response.ScimBulkResult, 0, len(r.Operations)),
+	}
+	// bulkId与创建之后的资源编号
+	ids := make(map[string]string)
+	failed := 0
+	for _, op := range r.Operations {
+		if r.FailOnErrors > 0 && failed >= r.FailOnErrors {
+			break
+		}
+		result := l.bulkOperation(c, op, ids)
+		if status, _ := strconv.Atoi(result.Status); status >= http.StatusBadRequest {
+			failed++
+		}
+		rsp.Operations = append(rsp.Operations, result)
+	}
+	return rsp, nil
+}
+
+// bulkOperation 执行批量请求中的一项操作
+func (l ScimLogic) bulkOperation(c *gin.Context, op *model.ScimBulkOperation, ids map[string]string) *response.ScimBulkResult {
+	method := strings.ToUpper(op.Method)
+	result := &response.ScimBulkResult{Method: method, BulkId: op.BulkId, Version: op.Version}
+	fail := func(rspError any) *response.ScimBulkResult {
+		_, rsp := ScimErrorOf(rspError)
+		result.Status = rsp.Status
+		result.Response = rsp
+		return result
+	}
+
+	// 替换引用的bulkId
+	path := op.Path
+	body, err := json.Marshal(op.Data)
+	if err != nil {
+		return fail(scimError(http.StatusBadRequest, tools.ScimInvalidSyntax, "data不合法: %s", err.Error()))
+	}
+	for bulkId, id := range ids {
+		path = strings.ReplaceAll(path, "bulkId:"+bulkId, id)
+		body = bytes.ReplaceAll(body, []byte(`"bulkId:`+bulkId+`"`), []byte(`"`+id+`"`))
+	}
+	if strings.Contains(path, "bulkId:") || bytes.Contains(body, []byte(`"bulkId:`)) {
+		return fail(scimError(http.StatusConflict, tools.ScimInvalidValue, "引用的bulkId不存在或对应的操作未成功"))
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	endpoint, id := parts[0], ""
+	if len(parts) == 2 {
+		id = parts[1]
+	}
+	if len(parts) > 2 || (endpoint != "Users" && endpoint != "Groups") || (method == http.MethodPost) != (id == "") {
+		return fail(scimError(http.StatusBadRequest, tools.ScimInvalidPath, "不支持的操作 %s %s", method, op.Path))
+	}
+	if method == http.MethodPost && op.BulkId == "" {
+		return fail(scimError(http.StatusBadRequest, tools.ScimInvalidSyntax, "POST操作必须指定bulkId"))
+	}
+	routePath := "/scim/v2/" + endpoint
+	if id != "" {
+		routePath += "/:id"
+	}
+	if !scimAllowed(c, method, routePath) {
+		return fail(scimError(http.StatusForbidden, "", "没有 %s %s 接口的权限", method, routePath))
+	}
+
+	var data, rspError any
+	switch endpoint + " " + method {
+	case "Users POST", "Users PUT":
+		r := &request.ScimUserReq{ID: id}
+		if err := json.Unmarshal(body, &r.User); err != nil {
+			return fail(scimError(http.StatusBadRequest, tools.ScimInvalidSyntax, "data不合法: %s", err.Error()))
+		}
+		if method == http.MethodPost {
+			data, rspError = l.UserAdd(c, r)
+		} else {
+			data, rspError = l.UserReplace(c, r)
+		}
+	case "Groups POST", "Groups PUT":
+		r := &request.ScimGroupReq{ID: id}
+		if err := json.Unmarshal(body, &r.Group); err != nil {
+			return fail(scimError(http.StatusBadRequest, tools.ScimInvalidSyntax, "data不合法: %s", err.Error()))
+		}
+		if method == http.MethodPost {
+			data, rspError = l.GroupAdd(c, r)
+		} else {
+			data, rspError = l.GroupReplace(c, r)
+		}
+	case "Users PATCH", "Groups PATCH":
+		r := &request.ScimPatchReq{ID: id}
+		if err := json.Unmarshal(body, r); err != nil {
+			return fail(scimError(http.StatusBadRequest, tools.ScimInvalidSyntax, "data不合法: %s", err.Error()))
+		}
+		if endpoint == "Users" {
+			data, rspError = l.UserPatch(c, r)
+		} else {
+			data, rspError = l.GroupPatch(c, r)
+		}
+	case "Users DELETE":
+		_, rspError = l.UserDelete(c, &request.ScimDeleteReq{ID: id})
+	case "Groups DELETE":
+		_, rspError = l.GroupDelete(c, &request.ScimDeleteReq{ID: id})
+	default:
+		return fail(scimError(http.StatusMethodNotAllowed, "", "不支持的方法%s", method))
+	}
+	if rspError != nil {
+		return fail(rspError)
+	}
+
+	result.Status = strconv.Itoa(http.StatusOK)
+	switch res := data.(type) {
+	case *model.ScimUser:
+		id, result.Location = res.ID, res.Meta.Location
+	case *model.ScimGroup:
+		id, result.Location = res.ID, res.Meta.Location
+	}
+	switch method {
+	case http.MethodPost:
+		result.Status = strconv.Itoa(http.StatusCreated)
+		ids[op.BulkId] = id
+	case http.MethodDelete:
+		result.Status = strconv.Itoa(http.StatusNoContent)
+		result.Location = scimBaseURL(c) + "/" + endpoint + "/" + id
+	}
+	return result
+}
@@ -0,0 +1,1166 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+	"gorm.io/gorm"
+)
+
+// ScimLogic SCIM 2.0服务端, 将身份提供者推送的用户与分组写入平台与ldap
+type ScimLogic struct{}
+
+const (
+	// scimSource 通过SCIM创建的用户与分组的来源
+	scimSource = "scim"
+	// scimRootDeptId SCIM分组所在根部门的部门编号
+	scimRootDeptId = "scim_root"
+	// 列表每页默认与最多返回的数量
+	scimDefaultCount = 100
+	scimMaxResults   = 1000
+	// 批量请求最多的操作数量与请求体大小
+	scimMaxOperations  = 1000
+	ScimMaxPayloadSize = 1 << 20
+	// scimAdminId 创建分组时默认加入的管理员, 保证ldap中的分组至少有一个成员, 不作为SCIM分组成员
+	scimAdminId = 1
+)
+
+// ScimEnabled 是否启用SCIM服务端
+func ScimEnabled() bool {
+	return config.Conf.Scim != nil && config.Conf.Scim.Enable
+}
+
+// scimError 构造SCIM错误响应
+func scimError(status int, scimType string, format string, args ...any) *response.ScimErrorRsp {
+	return &response.ScimErrorRsp{
+		Schemas:  []string{model.ScimErrorSchema},
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+		Status:   strconv.Itoa(status),
+	}
+}
+
+// ScimErrorOf 将逻辑层返回的错误转换为SCIM错误响应与http状态码
+func ScimErrorOf(rspError any) (int, *response.ScimErrorRsp) {
+	rsp, ok := rspError.(*response.ScimErrorRsp)
+	if !ok {
+		// RspError不会展开内部的错误, 需要对原始错误做判断
+		cause, _ := rspError.(error)
+		var scimErr *tools.ScimError
+		var tooLarge *http.MaxBytesError
+		err := tools.ReloadErr(rspError)
+		switch {
+		case errors.As(cause, &tooLarge):
+			rsp = scimError(http.StatusRequestEntityTooLarge, "", "请求体不能超过%d字节", tooLarge.Limit)
+		case errors.As(cause, &scimErr):
+			rsp = scimError(http.StatusBadRequest, scimErr.ScimType, "%s", scimErr.Error())
+		case err.Code() == tools.ValidatorErr:
+			rsp = scimError(http.StatusBadRequest, "", "%s", err.Error())
+		default:
+			rsp = scimError(http.StatusInternalServerError, "", "%s", err.Error())
+		}
+	}
+	status, _ := strconv.Atoi(rsp.Status)
+	return status, rsp
+}
+
+// scimBaseURL SCIM接口的外部访问地址
+func scimBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	base := scheme + "://" + c.Request.Host
+	if prefix := strings.Trim(config.Conf.System.UrlPathPrefix, "/"); prefix != "" {
+		base += "/" + prefix
+	}
+	return base + "/scim/v2"
+}
+
+func scimLocation(c *gin.Context, endpoint string, id uint) string {
+	return fmt.Sprintf("%s/%s/%d", scimBaseURL(c), endpoint, id)
+}
+
+func scimMeta(c *gin.Context, resourceType, endpoint string, m gorm.Model) *model.ScimMeta {
+	return &model.ScimMeta{
+		ResourceType: resourceType,
+		Created:      m.CreatedAt.UTC().Format(time.RFC3339),
+		LastModified: m.UpdatedAt.UTC().Format(time.RFC3339),
+		Location:     scimLocation(c, endpoint, m.ID),
+	}
+}
+
+// scimMap 将SCIM资源转换为map, 用于过滤与PATCH
+func scimMap(v any) map[string]any {
+	m := make(map[string]any)
+	data, err := json.Marshal(v)
+	if err == nil {
+		_ = json.Unmarshal(data, &m)
+	}
+	return m
+}
+
+// scimFromMap 将PATCH之后的map转换回SCIM资源
+func scimFromMap(m map[string]any, v any) any {
+	// 部分身份提供者会以字符串形式传递active, 如"False"
+	for k, val := range m {
+		if s, ok := val.(string); ok && strings.EqualFold(k, "active") {
+			if b, err := strconv.ParseBool(s); err == nil {
+				m[k] = b
+			}
+		}
+	}
+	data, err := json.Marshal(m)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return scimError(http.StatusBadRequest, tools.ScimInvalidValue, "PATCH之后的资源不合法: %s", err.Error())
+	}
+	return nil
+}
+
+// scimPatch 依次执行PATCH请求中的操作
+func scimPatch(res map[string]any, r *request.ScimPatchReq) any {
+	if len(r.Operations) == 0 {
+		return scimError(http.StatusBadRequest, tools.ScimInvalidValue, "PATCH请求中没有操作")
+	}
+	for _, op := range r.Operations {
+		if err := tools.ScimPatch(res, op.Op, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scimParseFilter 解析列表查询的过滤条件, 为空时返回nil
+func scimParseFilter(filter string) (tools.ScimFilter, any) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+	f, err := tools.ParseScimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// scimList 对过滤之后的资源分页, 返回当前页的起止下标
+func scimList(r *request.ScimListReq, total int) (rsp *response.ScimListRsp, start, end int) {
+	startIndex := max(r.StartIndex, 1)
+	count := r.Count
+	if count <= 0 {
+		count = scimDefaultCount
+	}
+	count = min(count, scimMaxResults)
+	start = min(startIndex-1, total)
+	end = min(start+count, total)
+	return &response.ScimListRsp{
+		Schemas:      []string{model.ScimListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: end - start,
+		Resources:    make([]any, 0, end-start),
+	}, start, end
+}
+
+// scimExcluded 列表查询是否排除了指定属性
+func scimExcluded(r *request.ScimListReq, attr string) bool {
+	for _, v := range strings.Split(r.ExcludedAttributes, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), attr) {
+			return true
+		}
+	}
+	return false
+}
+
+// scimResourceId 解析资源编号, 不合法时视为资源不存在
+func scimResourceId(id, kind string) (uint, any) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || n == 0 {
+		return 0, scimError(http.StatusNotFound, "", "%s%s不存在", kind, id)
+	}
+	return uint(n), nil
+}
+
+// scimCheckScope 当前登录用户是否可以管理资源所在的目录
+func scimCheckScope(c *gin.Context, directoryId uint) any {
+	if rspError := checkDirectoryScope(c, directoryId); rspError != nil {
+		return scimError(http.StatusForbidden, "", "%s", tools.ReloadErr(rspError).Error())
+	}
+	return nil
+}
+
+// scimAllowed 批量请求中的每一项操作同样需要当前用户与访问令牌拥有对应接口的权限
+func scimAllowed(c *gin.Context, method, path string) bool {
+	user, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return false
+	}
+	var subs []string
+	for _, role := range user.Roles {
+		if role.Status == 1 {
+			subs = append(subs, role.Keyword)
+		}
+	}
+	if !common.CasbinCheck(subs, path, method) {
+		return false
+	}
+	if token, ok := c.Get("accessToken"); ok {
+		return AccessTokenAllowed(token.(*model.AccessToken), method, path)
+	}
+	return true
+}
+
+// ServiceProviderConfig 服务端支持的功能
+func (l ScimLogic) ServiceProviderConfig(c *gin.Context, req any) (data any, rspError any) {
+	return tools.H{
+		"schemas":        []string{model.ScimServiceProviderConfigSchema},
+		"patch":          tools.H{"supported": true},
+		"bulk":           tools.H{"supported": true, "maxOperations": scimMaxOperations, "maxPayloadSize": ScimMaxPayloadSize},
+		"filter":         tools.H{"supported": true, "maxResults": scimMaxResults},
+		"changePassword": tools.H{"supported": true},
+		"sort":           tools.H{"supported": false},
+		"etag":           tools.H{"supported": false},
+		"authenticationSchemes": []tools.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "使用平台的个人访问令牌认证",
+			"primary":     true,
+		}},
+		"meta": tools.H{
+			"resourceType": "ServiceProviderConfig",
+			"location":     scimBaseURL(c) + "/ServiceProviderConfig",
+		},
+	}, nil
+}
+
+// ResourceTypes 支持的资源类型, 指定ID时返回单个资源类型
+func (l ScimLogic) ResourceTypes(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimInfoReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	types := []tools.H{
+		{
+			"id":          "User",
+			"name":        "User",
+			"endpoint":    "/Users",
+			"description": "用户",
+			"schema":      model.ScimUserSchema,
+			"schemaExtensions": []tools.H{
+				{"schema": model.ScimEnterpriseUserSchema, "required": false},
+			},
+		},
+		{
+			"id":          "Group",
+			"name":        "Group",
+			"endpoint":    "/Groups",
+			"description": "分组",
+			"schema":      model.ScimGroupSchema,
+		},
+	}
+	for _, t := range types {
+		t["schemas"] = []string{model.ScimResourceTypeSchema}
+		t["meta"] = tools.H{"resourceType": "ResourceType", "location": scimBaseURL(c) + "/ResourceTypes/" + t["id"].(string)}
+	}
+	return scimDiscovery(r.ID, "资源类型", types)
+}
+
+// Schemas 支持的schema及其属性定义, 指定ID时返回单个schema
+func (l ScimLogic) Schemas(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimInfoReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	multiValue := func(name string) tools.H {
+		return scimAttr(name, "complex", true, false, "readWrite",
+			scimAttr("value", "string", false, false, "readWrite"),
+			scimAttr("type", "string", false, false, "readWrite"),
+			scimAttr("primary", "boolean", false, false, "readWrite"),
+		)
+	}
+	member := func(name string, mutability string) tools.H {
+		return scimAttr(name, "complex", true, false, mutability,
+			scimAttr("value", "string", false, false, "immutable"),
+			scimAttr("$ref", "reference", false, false, "immutable"),
+			scimAttr("display", "string", false, false, "readOnly"),
+			scimAttr("type", "string", false, false, "immutable"),
+		)
+	}
+	userName := scimAttr("userName", "string", false, true, "readWrite")
+	userName["uniqueness"] = "server"
+	password := scimAttr("password", "string", false, false, "writeOnly")
+	password["returned"] = "never"
+
+	schemas := []tools.H{
+		{
+			"id":          model.ScimUserSchema,
+			"name":        "User",
+			"description": "用户",
+			"attributes": []tools.H{
+				userName,
+				scimAttr("name", "complex", false, false, "readWrite",
+					scimAttr("formatted", "string", false, false, "readWrite"),
+					scimAttr("familyName", "string", false, false, "readWrite"),
+					scimAttr("givenName", "string", false, false, "readWrite"),
+				),
+				scimAttr("displayName", "string", false, false, "readWrite"),
+				scimAttr("title", "string", false, false, "readWrite"),
+				scimAttr("active", "boolean", false, false, "readWrite"),
+				password,
+				multiValue("emails"),
+				multiValue("phoneNumbers"),
+				multiValue("photos"),
+				scimAttr("addresses", "complex", true, false, "readWrite",
+					scimAttr("formatted", "string", false, false, "readWrite"),
+					scimAttr("streetAddress", "string", false, false, "readWrite"),
+					scimAttr("locality", "string", false, false, "readWrite"),
+					scimAttr("region", "string", false, false, "readWrite"),
+					scimAttr("postalCode", "string", false, false, "readWrite"),
+					scimAttr("country", "string", false, false, "readWrite"),
+					scimAttr("type", "string", false, false, "readWrite"),
+					scimAttr("primary", "boolean", false, false, "readWrite"),
+				),
+				member("groups", "readOnly"),
+			},
+		},
+		{
+			"id":          model.ScimGroupSchema,
+			"name":        "Group",
+			"description": "分组",
+			"attributes": []tools.H{
+				scimAttr("displayName", "string", false, true, "readWrite"),
+				member("members", "readWrite"),
+			},
+		},
+		{
+			"id":          model.ScimEnterpriseUserSchema,
+			"name":        "EnterpriseUser",
+			"description": "企业用户",
+			"attributes": []tools.H{
+				scimAttr("employeeNumber", "string", false, false, "readWrite"),
+				scimAttr("department", "string", false, false, "readOnly"),
+			},
+		},
+	}
+	for _, s := range schemas {
+		s["schemas"] = []string{model.ScimSchemaSchema}
+		s["meta"] = tools.H{"resourceType": "Schema", "location": scimBaseURL(c) + "/Schemas/" + s["id"].(string)}
+	}
+	return scimDiscovery(r.ID, "schema", schemas)
+}
+
+// scimAttr schema中的属性定义
+func scimAttr(name, typ string, multiValued, required bool, mutability string, subAttributes ...tools.H) tools.H {
+	attr := tools.H{
+		"name":        name,
+		"type":        typ,
+		"multiValued": multiValued,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    "default",
+		"uniqueness":  "none",
+	}
+	if len(subAttributes) > 0 {
+		attr["subAttributes"] = subAttributes
+	}
+	return attr
+}
+
+// scimDiscovery 返回全部资源列表, 指定ID时返回单个资源
+func scimDiscovery(id, kind string, resources []tools.H) (any, any) {
+	if id != "" {
+		for _, res := range resources {
+			if strings.EqualFold(res["id"].(string), id) {
+				return res, nil
+			}
+		}
+		return nil, scimError(http.StatusNotFound, "", "%s%s不存在", kind, id)
+	}
+	rsp := &response.ScimListRsp{
+		Schemas:      []string{model.ScimListResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+	}
+	for _, res := range resources {
+		rsp.Resources = append(rsp.Resources, res)
+	}
+	return rsp, nil
+}
+
+// scimUserOf 将平台用户转换为SCIM用户, groups为用户所在的分组
+func scimUserOf(c *gin.Context, user *model.User, groups []*model.Group) *model.ScimUser {
+	active := user.Status == 1
+	su := &model.ScimUser{
+		Schemas:     []string{model.ScimUserSchema, model.ScimEnterpriseUserSchema},
+		ID:          strconv.Itoa(int(user.ID)),
+		UserName:    user.Username,
+		Name:        &model.ScimName{Formatted: user.Nickname, GivenName: user.GivenName},
+		DisplayName: user.Nickname,
+		Title:       user.Position,
+		Active:      &active,
+		Emails:      []*model.ScimMultiValue{{Value: user.Mail, Type: "work", Primary: true}},
+		PhoneNumbers: []*model.ScimMultiValue{
+			{Value: user.Mobile, Type: "mobile", Primary: true},
+		},
+		Addresses:  []*model.ScimAddress{{Formatted: user.PostalAddress, Type: "work", Primary: true}},
+		Enterprise: &model.ScimEnterpriseUser{EmployeeNumber: user.JobNumber, Department: user.Departments},
+		Meta:       scimMeta(c, "User", "Users", user.Model),
+	}
+	if user.Source == scimSource {
+		su.ExternalId = user.SourceUserId
+	}
+	if user.Avatar != "" {
+		su.Photos = []*model.ScimMultiValue{{Value: user.Avatar, Type: "photo", Primary: true}}
+	}
+	for _, group := range groups {
+		if group.GroupType != "cn" {
+			continue
+		}
+		su.Groups = append(su.Groups, &model.ScimMember{
+			Value:   strconv.Itoa(int(group.ID)),
+			Ref:     scimLocation(c, "Groups", group.ID),
+			Display: group.GroupName,
+			Type:    "direct",
+		})
+	}
+	return su
+}
+
+// scimPrimary 多值属性中的主要值, 没有标记主要值时取第一项
+func scimPrimary(values []*model.ScimMultiValue) string {
+	for _, v := range values {
+		if v != nil && v.Primary {
+			return v.Value
+		}
+	}
+	for _, v := range values {
+		if v != nil {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+// scimAddress 用户的主要地址
+func scimAddress(addresses []*model.ScimAddress) string {
+	var addr *model.ScimAddress
+	for _, v := range addresses {
+		if v != nil && (addr == nil || v.Primary) {
+			addr = v
+		}
+	}
+	if addr == nil {
+		return ""
+	}
+	if addr.Formatted != "" {
+		return addr.Formatted
+	}
+	var parts []string
+	for _, v := range []string{addr.Country, addr.Region, addr.Locality, addr.StreetAddress, addr.PostalCode} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// scimApplyUser 将SCIM用户的属性写入平台用户, 未提供的属性保持不变
+func scimApplyUser(user *model.User, su *model.ScimUser) {
+	if su.UserName != "" {
+		user.Username = su.UserName
+	}
+	switch {
+	case su.DisplayName != "":
+		user.Nickname = su.DisplayName
+	case su.Name != nil && su.Name.Formatted != "":
+		user.Nickname = su.Name.Formatted
+	case su.Name != nil && su.Name.FamilyName+su.Name.GivenName != "":
+		user.Nickname = su.Name.FamilyName + su.Name.GivenName
+	}
+	if su.Name != nil && su.Name.GivenName != "" {
+		user.GivenName = su.Name.GivenName
+	}
+	if v := scimPrimary(su.Emails); v != "" {
+		user.Mail = v
+	}
+	if v := scimPrimary(su.PhoneNumbers); v != "" {
+		user.Mobile = v
+	}
+	if v := scimPrimary(su.Photos); v != "" {
+		user.Avatar = v
+	}
+	if v := scimAddress(su.Addresses); v != "" {
+		user.PostalAddress = v
+	}
+	if su.Title != "" {
+		user.Position = su.Title
+	}
+	if su.Enterprise != nil && su.Enterprise.EmployeeNumber != "" {
+		user.JobNumber = su.Enterprise.EmployeeNumber
+	}
+	if su.ExternalId != "" && user.Source == scimSource {
+		user.SourceUserId = su.ExternalId
+		user.SourceUnionId = su.ExternalId
+	}
+}
+
+// scimCheckUnique 用户名、手机号与邮箱不能与其他用户重复
+func scimCheckUnique(user *model.User) any {
+	fields := []struct{ column, value string }{
+		{"username", user.Username},
+		{"mobile", user.Mobile},
+		{"mail", user.Mail},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		other := new(model.User)
+		err := isql.User.Find(tools.H{field.column: field.value}, other)
+		if err == nil && other.ID != user.ID {
+			return scimError(http.StatusConflict, tools.ScimUniqueness, "%s已被用户%s使用", field.value, other.Username)
+		}
+	}
+	return nil
+}
+
+// scimPassword 新用户的密码, 未指定时使用配置的初始密码, 初始密码不满足密码策略时随机生成
+func scimPassword(user *model.User, passwd string) (string, any) {
+	if passwd != "" {
+		if _, rspError := checkPasswordPolicy(user, passwd); rspError != nil {
+			return "", rspError
+		}
+		return passwd, nil
+	}
+	passwd = config.Conf.Ldap.UserInitPassword
+	if violations := tools.CheckPasswordPolicy(passwd, user.Username, user.Nickname); len(violations) > 0 {
+		return randomPolicyPasswd(user)
+	}
+	return passwd, nil
+}
+
+// scimFindUser 根据编号获取用户
+func scimFindUser(c *gin.Context, id string) (*model.User, any) {
+	uid, rspError := scimResourceId(id, "用户")
+	if rspError != nil {
+		return nil, rspError
+	}
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": uid}, user)
+	if err != nil {
+		return nil, scimError(http.StatusNotFound, "", "用户%s不存在", id)
+	}
+	if rspError := scimCheckScope(c, user.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
+	return user, nil
+}
+
+// scimUserInfo 获取用户及其所在分组
+func scimUserInfo(c *gin.Context, id uint) (any, any) {
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": id}, user)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("在MySQL查询用户失败: %s", err.Error()))
+	}
+	groups, err := isql.Group.GetUserGroups(user.ID)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户所在的分组失败: %s", err.Error()))
+	}
+	return scimUserOf(c, user, groups), nil
+}
+
+// UserList 用户列表
+func (l ScimLogic) UserList(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	filter, rspError := scimParseFilter(r.Filter)
+	if rspError != nil {
+		return nil, rspError
+	}
+	scope, err := directoryScope(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户可管理的目录失败"))
+	}
+	users, err := isql.User.ListAll()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户列表失败: %s", err.Error()))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	matched := make([]*model.User, 0, len(users))
+	for _, user := range users {
+		if scope != nil && !funk.Contains(scope, user.DirectoryID) {
+			continue
+		}
+		if filter != nil && !filter.Match(scimMap(scimUserOf(c, user, nil))) {
+			continue
+		}
+		matched = append(matched, user)
+	}
+
+	rsp, start, end := scimList(r, len(matched))
+	for _, user := range matched[start:end] {
+		var groups []*model.Group
+		if !scimExcluded(r, "groups") {
+			groups, err = isql.Group.GetUserGroups(user.ID)
+			if err != nil {
+				return nil, tools.NewMySqlError(fmt.Errorf("获取用户所在的分组失败: %s", err.Error()))
+			}
+		}
+		rsp.Resources = append(rsp.Resources, scimUserOf(c, user, groups))
+	}
+	return rsp, nil
+}
+
+// UserInfo 获取用户
+func (l ScimLogic) UserInfo(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimInfoReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	user, rspError := scimFindUser(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	return scimUserInfo(c, user.ID)
+}
+
+// UserAdd 创建用户
+func (l ScimLogic) UserAdd(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimUserReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	su := &r.User
+	if su.UserName == "" {
+		return nil, scimError(http.StatusBadRequest, tools.ScimInvalidValue, "userName不能为空")
+	}
+	if rspError := scimCheckScope(c, 0); rspError != nil {
+		return nil, rspError
+	}
+	if rspError := scimCheckManageable(c, 0, su.Active != nil && !*su.Active); rspError != nil {
+		return nil, rspError
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+
+	user := &model.User{
+		Creator: ctxUser.Username,
+		Source:  scimSource,
+	}
+	scimApplyUser(user, su)
+	user.UserDN = common.LdapUserDN(user.Username)
+	if rspError := scimCheckUnique(user); rspError != nil {
+		return nil, rspError
+	}
+	// 默认添加为普通用户角色
+	roles, err := isql.Role.GetRolesByIds([]uint{2})
+	if err != nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("根据角色ID获取角色信息失败: %s", err.Error()))
+	}
+	user.Roles = roles
+	user.Password, rspError = scimPassword(user, su.Password)
+	if rspError != nil {
+		return nil, rspError
+	}
+
+	err = CommonAddUser(user, nil)
+	if err != nil {
+		return nil, tools.NewOperationError(fmt.Errorf("添加用户失败: %s", err.Error()))
+	}
+	if su.Active != nil && !*su.Active {
+		if err := disableSyncUser(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return scimUserInfo(c, user.ID)
+}
+
+// UserReplace 替换用户
+func (l ScimLogic) UserReplace(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimUserReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	user, rspError := scimFindUser(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	return scimSaveUser(c, user, &r.User)
+}
+
+// UserPatch 修改用户
+func (l ScimLogic) UserPatch(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimPatchReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	user, rspError := scimFindUser(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	res := scimMap(scimUserOf(c, user, nil))
+	if rspError := scimPatch(res, r); rspError != nil {
+		return nil, rspError
+	}
+	su := new(model.ScimUser)
+	if rspError := scimFromMap(res, su); rspError != nil {
+		return nil, rspError
+	}
+	return scimSaveUser(c, user, su)
+}
+
+// scimCheckManageable 与用户管理一致, 非管理员只能修改角色等级比自己低的用户, 修改在职状态需要管理员
+// userId为0表示新建用户, 只校验在职状态
+func scimCheckManageable(c *gin.Context, userId uint, changeStatus bool) any {
+	minSort, _, err := isql.User.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		return tools.NewValidatorError(fmt.Errorf("获取当前登陆用户角色排序最小值失败"))
+	}
+	if minSort == 1 {
+		return nil
+	}
+	if changeStatus {
+		return scimError(http.StatusForbidden, "", "只有管理员才能更改用户状态")
+	}
+	if userId == 0 {
+		return nil
+	}
+	minRoleSorts, err := isql.User.GetUserMinRoleSortsByIds([]uint{userId})
+	if err != nil || len(minRoleSorts) == 0 {
+		return tools.NewValidatorError(fmt.Errorf("根据用户ID获取用户角色排序最小值失败"))
+	}
+	if int(minSort) >= minRoleSorts[0] {
+		return scimError(http.StatusForbidden, "", "不能修改比自己角色等级高的或者相同等级的用户")
+	}
+	return nil
+}
+
+// scimSaveUser 保存用户的属性、密码与在职状态
+func scimSaveUser(c *gin.Context, oldUser *model.User, su *model.ScimUser) (any, any) {
+	newUser := *oldUser
+	scimApplyUser(&newUser, su)
+	if !config.Conf.Ldap.UserNameModify {
+		newUser.Username = oldUser.Username
+	}
+	status := oldUser.Status
+	if su.Active != nil {
+		status = 2
+		if *su.Active {
+			status = 1
+		}
+	}
+	// 权限与参数在修改任何数据之前校验, 避免部分写入
+	if rspError := scimCheckManageable(c, oldUser.ID, status != oldUser.Status); rspError != nil {
+		return nil, rspError
+	}
+	if rspError := scimCheckUnique(&newUser); rspError != nil {
+		return nil, rspError
+	}
+	if su.Password != "" {
+		if _, rspError := checkPasswordPolicy(&newUser, su.Password); rspError != nil {
+			return nil, rspError
+		}
+	}
+
+	// 离职用户在ldap中可能已被删除, 需要先恢复为在职再更新ldap中的属性
+	// 同时指定了密码时直接使用该密码, 不再生成随机密码并发送重置邮件
+	if oldUser.Status == 2 && status == 1 {
+		restored := *oldUser
+		if rspError := changeUserStatus(&restored, 1, su.Password); rspError != nil {
+			return nil, rspError
+		}
+		newUser.Status = 1
+	}
+	// 密码单独修改, 不随用户信息更新
+	newUser.Password = ""
+	if newUser.Status == 1 {
+		err := CommonUpdateUser(oldUser, &newUser, tools.StringToSlice(oldUser.DepartmentId, ","))
+		if err != nil {
+			return nil, tools.NewOperationError(fmt.Errorf("更新用户失败: %s", err.Error()))
+		}
+	} else {
+		err := isql.User.Update(&newUser)
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("在MySQL更新用户失败: %s", err.Error()))
+		}
+	}
+
+	if su.Password != "" {
+		if rspError := scimChangePwd(oldUser.ID, su.Password); rspError != nil {
+			return nil, rspError
+		}
+	}
+	if oldUser.Status == 1 && status == 2 {
+		// 用户名可能已修改, 重新获取最新的DN
+		disabled := new(model.User)
+		if err := isql.User.Find(tools.H{"id": oldUser.ID}, disabled); err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("在MySQL查询用户失败: %s", err.Error()))
+		}
+		if rspError := changeUserStatus(disabled, 2, ""); rspError != nil {
+			return nil, rspError
+		}
+	}
+	return scimUserInfo(c, oldUser.ID)
+}
+
+// scimChangePwd 修改用户密码
+func scimChangePwd(id uint, passwd string) any {
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": id}, user)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("在MySQL查询用户失败: %s", err.Error()))
+	}
+	if _, rspError := checkPasswordPolicy(user, passwd); rspError != nil {
+		return rspError
+	}
+	// 离职用户在ldap中可能已不存在, 只修改MySQL中的密码
+	if user.Status == 1 {
+		err = ildap.User.ChangePwd(user.UserDN, "", passwd)
+		if err != nil {
+			return tools.NewLdapError(fmt.Errorf("在LDAP更新密码失败: %s", err.Error()))
+		}
+	}
+	err = isql.User.ChangePwd(user.Username, tools.GenPasswd(passwd))
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("在MySQL更新密码失败: %s", err.Error()))
+	}
+	RevokeUserSessions(user.ID)
+	return nil
+}
+
+// UserDelete 删除用户
+func (l ScimLogic) UserDelete(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	user, rspError := scimFindUser(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	return User.Delete(c, &request.UserDeleteReq{UserIds: []uint{user.ID}})
+}
+
+// scimGroupOf 将平台分组转换为SCIM分组
+func scimGroupOf(c *gin.Context, group *model.Group) *model.ScimGroup {
+	sg := &model.ScimGroup{
+		Schemas:     []string{model.ScimGroupSchema},
+		ID:          strconv.Itoa(int(group.ID)),
+		DisplayName: group.GroupName,
+		Members:     make([]*model.ScimMember, 0, len(group.Users)),
+		Meta:        scimMeta(c, "Group", "Groups", group.Model),
+	}
+	if group.Source == scimSource {
+		sg.ExternalId = strings.TrimPrefix(group.SourceDeptId, scimSource+"_")
+	}
+	for _, user := range group.Users {
+		if user.ID == scimAdminId {
+			continue
+		}
+		sg.Members = append(sg.Members, &model.ScimMember{
+			Value:   strconv.Itoa(int(user.ID)),
+			Ref:     scimLocation(c, "Users", user.ID),
+			Display: user.Username,
+			Type:    "User",
+		})
+	}
+	return sg
+}
+
+// scimFindGroup 根据编号获取分组, 只有cn类型的分组可以作为SCIM分组
+func scimFindGroup(c *gin.Context, id string) (*model.Group, any) {
+	gid, rspError := scimResourceId(id, "分组")
+	if rspError != nil {
+		return nil, rspError
+	}
+	group := new(model.Group)
+	err := isql.Group.Find(tools.H{"id": gid}, group)
+	if err != nil || group.GroupType != "cn" {
+		return nil, scimError(http.StatusNotFound, "", "分组%s不存在", id)
+	}
+	if rspError := scimCheckScope(c, group.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
+	return group, nil
+}
+
+// scimGroupInfo 获取分组及其成员
+func scimGroupInfo(c *gin.Context, id uint) (any, any) {
+	group := new(model.Group)
+	err := isql.Group.Find(tools.H{"id": id}, group)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组失败: %s", err.Error()))
+	}
+	return scimGroupOf(c, group), nil
+}
+
+// scimRootGroup SCIM分组所在的根部门, 不存在时创建
+func scimRootGroup() (*model.Group, any) {
+	name := config.Conf.Scim.RootGroupName
+	if name == "" {
+		name = scimSource + "root"
+	}
+	err := ensureRootGroup(scimSource, "SCIM", scimRootDeptId, name)
+	if err != nil {
+		return nil, err
+	}
+	root := new(model.Group)
+	err = isql.Group.Find(tools.H{"source_dept_id": scimRootDeptId}, root)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取SCIM根部门失败: %s", err.Error()))
+	}
+	return root, nil
+}
+
+// GroupList 分组列表
+func (l ScimLogic) GroupList(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	filter, rspError := scimParseFilter(r.Filter)
+	if rspError != nil {
+		return nil, rspError
+	}
+	scope, err := directoryScope(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户可管理的目录失败"))
+	}
+	all, err := isql.Group.ListAll()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组列表失败: %s", err.Error()))
+	}
+	var ids []uint
+	for _, group := range all {
+		if group.GroupType == "cn" && (scope == nil || funk.Contains(scope, group.DirectoryID)) {
+			ids = append(ids, group.ID)
+		}
+	}
+	groups := make([]*model.Group, 0)
+	if len(ids) > 0 {
+		groups, err = isql.Group.GetGroupByIds(ids)
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("获取分组成员失败: %s", err.Error()))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+
+	matched := make([]*model.ScimGroup, 0, len(groups))
+	for _, group := range groups {
+		sg := scimGroupOf(c, group)
+		if filter != nil && !filter.Match(scimMap(sg)) {
+			continue
+		}
+		matched = append(matched, sg)
+	}
+
+	rsp, start, end := scimList(r, len(matched))
+	for _, sg := range matched[start:end] {
+		if scimExcluded(r, "members") {
+			sg.Members = nil
+		}
+		rsp.Resources = append(rsp.Resources, sg)
+	}
+	return rsp, nil
+}
+
+// GroupInfo 获取分组
+func (l ScimLogic) GroupInfo(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimInfoReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	group, rspError := scimFindGroup(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	return scimGroupOf(c, group), nil
+}
+
+// GroupAdd 创建分组, 分组创建在SCIM根部门之下
+func (l ScimLogic) GroupAdd(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimGroupReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	sg := &r.Group
+	if sg.DisplayName == "" {
+		return nil, scimError(http.StatusBadRequest, tools.ScimInvalidValue, "displayName不能为空")
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	root, rspError := scimRootGroup()
+	if rspError != nil {
+		return nil, rspError
+	}
+	if rspError := scimCheckScope(c, root.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
+
+	group := &model.Group{
+		GroupName:          sg.DisplayName,
+		Remark:             sg.DisplayName,
+		Creator:            ctxUser.Username,
+		GroupType:          "cn",
+		ParentId:           root.ID,
+		SourceDeptId:       fmt.Sprintf("%s_%s", scimSource, sg.ExternalId),
+		Source:             scimSource,
+		SourceDeptParentId: root.SourceDeptId,
+		GroupDN:            fmt.Sprintf("cn=%s,%s", sg.DisplayName, root.GroupDN),
+		DirectoryID:        root.DirectoryID,
+	}
+	if isql.Group.Exist(tools.H{"group_dn": group.GroupDN}) {
+		return nil, scimError(http.StatusConflict, tools.ScimUniqueness, "分组%s已存在", sg.DisplayName)
+	}
+	err = CommonAddGroup(group)
+	if err != nil {
+		return nil, tools.NewOperationError(fmt.Errorf("添加分组失败: %s", err.Error()))
+	}
+	if rspError := scimSetMembers(c, group.ID, sg.Members); rspError != nil {
+		return nil, rspError
+	}
+	return scimGroupInfo(c, group.ID)
+}
+
+// GroupReplace 替换分组
+func (l ScimLogic) GroupReplace(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimGroupReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	group, rspError := scimFindGroup(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	return scimSaveGroup(c, group, &r.Group)
+}
+
+// GroupPatch 修改分组, 常用于添加与移除成员
+func (l ScimLogic) GroupPatch(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimPatchReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	group, rspError := scimFindGroup(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	res := scimMap(scimGroupOf(c, group))
+	if rspError := scimPatch(res, r); rspError != nil {
+		return nil, rspError
+	}
+	sg := new(model.ScimGroup)
+	if rspError := scimFromMap(res, sg); rspError != nil {
+		return nil, rspError
+	}
+	return scimSaveGroup(c, group, sg)
+}
+
+// scimSaveGroup 保存分组名称、外部编号与成员
+func scimSaveGroup(c *gin.Context, group *model.Group, sg *model.ScimGroup) (any, any) {
+	if sg.DisplayName != "" && sg.DisplayName != group.GroupName {
+		_, rspError := Group.Update(c, &request.GroupUpdateReq{ID: group.ID, GroupName: sg.DisplayName, Remark: group.Remark})
+		if rspError != nil {
+			return nil, rspError
+		}
+	}
+	if externalId := fmt.Sprintf("%s_%s", scimSource, sg.ExternalId); group.Source == scimSource && sg.ExternalId != "" && externalId != group.SourceDeptId {
+		err := isql.Group.UpdateSyncFields(group.ID, tools.H{"source_dept_id": externalId})
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("更新分组的外部编号失败: %s", err.Error()))
+		}
+	}
+	if rspError := scimSetMembers(c, group.ID, sg.Members); rspError != nil {
+		return nil, rspError
+	}
+	return scimGroupInfo(c, group.ID)
+}
+
+// scimSetMembers 将分组成员调整为members, 复用分组添加与移除用户的逻辑维护MySQL与ldap
+func scimSetMembers(c *gin.Context, groupId uint, members []*model.ScimMember) any {
+	group := new(model.Group)
+	err := isql.Group.Find(tools.H{"id": groupId}, group)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("获取分组失败: %s", err.Error()))
+	}
+	want := make(map[uint]bool)
+	for _, member := range members {
+		if member == nil {
+			continue
+		}
+		id, err := strconv.ParseUint(member.Value, 10, 64)
+		if err != nil || !isql.User.Exist(tools.H{"id": id}) {
+			return scimError(http.StatusBadRequest, tools.ScimInvalidValue, "成员%s不存在", member.Value)
+		}
+		want[uint(id)] = true
+	}
+	have := make(map[uint]bool)
+	var removeIds []uint
+	for _, user := range group.Users {
+		have[user.ID] = true
+		if !want[user.ID] && user.ID != scimAdminId {
+			removeIds = append(removeIds, user.ID)
+		}
+	}
+	var addIds []uint
+	for id := range want {
+		if !have[id] {
+			addIds = append(addIds, id)
+		}
+	}
+	sort.Slice(addIds, func(i, j int) bool { return addIds[i] < addIds[j] })
+
+	if len(addIds) > 0 {
+		_, rspError := Group.AddUser(c, &request.GroupAddUserReq{GroupID: groupId, UserIds: addIds})
+		if rspError != nil {
+			return rspError
+		}
+	}
+	if len(removeIds) > 0 {
+		_, rspError := Group.RemoveUser(c, &request.GroupRemoveUserReq{GroupID: groupId, UserIds: removeIds})
+		if rspError != nil {
+			return rspError
+		}
+	}
+	return nil
+}
+
+// GroupDelete 删除分组
+func (l ScimLogic) GroupDelete(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.ScimDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	group, rspError := scimFindGroup(c, r.ID)
+	if rspError != nil {
+		return nil, rspError
+	}
+	return Group.Delete(c, &request.GroupDeleteReq{GroupIds: []uint{group.ID}})
+}
@@ -0,0 +1,50 @@
+package logic
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestScimErrorOf(t *testing.T) {
+	_, filterErr := tools.ParseScimFilter(`userName eq`)
+	if filterErr == nil {
+		t.Fatal("过滤条件不完整时应解析失败")
+	}
+	code, rsp := ScimErrorOf(filterErr)
+	if code != http.StatusBadRequest || rsp.ScimType != tools.ScimInvalidFilter {
+		t.Fatalf("过滤条件错误应返回400 invalidFilter, 实际为%d %q", code, rsp.ScimType)
+	}
+	code, _ = ScimErrorOf(tools.NewMySqlError(io.EOF))
+	if code != http.StatusInternalServerError {
+		t.Fatalf("数据库错误应返回500, 实际为%d", code)
+	}
+}
+
+func TestScimBulkPayloadTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"Operations":[{"method":"POST","path":"/Users","data":{"userName":"` + strings.Repeat("a", ScimMaxPayloadSize) + `"}}]}`
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	// 分块传输的请求没有Content-Length, 只能在读取时限制
+	c.Request = httptest.NewRequest(http.MethodPost, "/scim/v2/Bulk", io.MultiReader(bytes.NewBufferString(body)))
+	c.Request.ContentLength = -1
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ScimMaxPayloadSize)
+
+	err := c.ShouldBindWith(new(request.ScimBulkReq), binding.JSON)
+	if err == nil {
+		t.Fatal("请求体超过限制时应解析失败")
+	}
+	code, rsp := ScimErrorOf(&tools.ScimError{ScimType: tools.ScimInvalidSyntax, Err: err})
+	if code != http.StatusRequestEntityTooLarge || rsp.Status != "413" {
+		t.Fatalf("请求体超过限制时应返回413, 实际为%d", code)
+	}
+}
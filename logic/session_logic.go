@@ -0,0 +1,215 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+type SessionLogic struct{}
+
+// 有效会话缓存, 避免每个请求都查询数据库, 吊销时同步删除
+var sessionCache = cache.New(time.Minute, 5*time.Minute)
+
+// NewSessionID 生成令牌ID(jti)
+func NewSessionID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// SessionCreate 登录成功后登记会话
+func SessionCreate(sessionId string, user *model.User, ip, userAgent string, expires time.Time) error {
+	now := time.Now()
+	return isql.UserSession.Add(&model.UserSession{
+		SessionID:    sessionId,
+		UserID:       user.ID,
+		Username:     user.Username,
+		Ip:           ip,
+		UserAgent:    userAgent,
+		ExpiresAt:    expires,
+		LastActiveAt: now,
+	})
+}
+
+// SessionActive 判断会话是否有效(未被吊销)
+func SessionActive(sessionId string) bool {
+	if sessionId == "" {
+		return false
+	}
+	if _, ok := sessionCache.Get(sessionId); ok {
+		return true
+	}
+	if !isql.UserSession.Exist(tools.H{"session_id": sessionId}) {
+		return false
+	}
+	sessionCache.SetDefault(sessionId, struct{}{})
+	// 缓存失效时顺带更新活跃时间, 不必每个请求都写库
+	_ = isql.UserSession.Touch(sessionId, tools.H{"last_active_at": time.Now()})
+	return true
+}
+
+// SessionRefresh 刷新令牌后更新会话的过期时间
+func SessionRefresh(sessionId string, expires time.Time) {
+	err := isql.UserSession.Touch(sessionId, tools.H{"expires_at": expires, "last_active_at": time.Now()})
+	if err != nil {
+		common.Log.Warnf("更新会话[%s]过期时间失败: %v", sessionId, err)
+	}
+}
+
+// RevokeSession 吊销单个会话
+func RevokeSession(sessionId string) error {
+	return revokeSessions(tools.H{"session_id": sessionId})
+}
+
+// RevokeUserSessions 吊销用户的全部会话, 用于禁用、删除用户及重置密码
+func RevokeUserSessions(userIds ...uint) {
+	if err := revokeSessions(tools.H{"user_id": userIds}); err != nil {
+		common.Log.Errorf("吊销用户%v的会话失败: %v", userIds, err)
+	}
+}
+
+func revokeSessions(filter tools.H) error {
+	sessionIds, err := isql.UserSession.Delete(filter)
+	for _, sessionId := range sessionIds {
+		sessionCache.Delete(sessionId)
+	}
+	return err
+}
+
+// CleanSessions 清理超过最大刷新时间的会话
+func CleanSessions() {
+	before := time.Now().Add(-time.Hour * time.Duration(config.Conf.Jwt.MaxRefresh))
+	if err := isql.UserSession.Clean(before); err != nil {
+		common.Log.Errorf("清理过期会话失败: %v", err)
+	}
+}
+
+// Mine 获取当前用户的会话列表
+func (l SessionLogic) Mine(c *gin.Context, req any) (data any, rspError any) {
+	_, ok := req.(*request.SessionMineReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	return listSessions(c, ctxUser.ID)
+}
+
+// List 管理员获取指定用户的会话列表
+func (l SessionLogic) List(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SessionListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSessionManageable(c, []uint{r.UserID}); rspError != nil {
+		return nil, rspError
+	}
+	return listSessions(c, r.UserID)
+}
+
+// RevokeMine 吊销当前用户自己的会话
+func (l SessionLogic) RevokeMine(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SessionRevokeReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	err = revokeSessions(tools.H{"id": r.Ids, "user_id": ctxUser.ID})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("吊销会话失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// Revoke 管理员吊销指定会话
+func (l SessionLogic) Revoke(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SessionRevokeReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	sessions, err := isql.UserSession.GetByIds(r.Ids)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取会话信息失败: %s", err.Error()))
+	}
+	if len(sessions) == 0 {
+		return nil, tools.NewValidatorError(fmt.Errorf("会话不存在"))
+	}
+	userIds := make([]uint, 0, len(sessions))
+	for _, session := range sessions {
+		userIds = append(userIds, session.UserID)
+	}
+	if rspError := checkSessionManageable(c, userIds); rspError != nil {
+		return nil, rspError
+	}
+	err = revokeSessions(tools.H{"id": r.Ids})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("吊销会话失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// RevokeUser 管理员吊销用户的全部会话
+func (l SessionLogic) RevokeUser(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SessionRevokeUserReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSessionManageable(c, r.UserIds); rspError != nil {
+		return nil, rspError
+	}
+	err := revokeSessions(tools.H{"user_id": r.UserIds})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("吊销会话失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// listSessions 获取用户的会话列表, 并标记发起请求的会话
+func listSessions(c *gin.Context, userId uint) (any, any) {
+	sessions, err := isql.UserSession.List(userId)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取会话列表失败: %s", err.Error()))
+	}
+	current := c.GetString("sessionId")
+	for _, session := range sessions {
+		session.Current = session.SessionID == current
+	}
+	return sessions, nil
+}
+
+// checkSessionManageable 只能查看与吊销可管理目录中、角色等级不高于自己(登陆用户)的用户的会话
+func checkSessionManageable(c *gin.Context, userIds []uint) any {
+	if rspError := checkUserScope(c, userIds...); rspError != nil {
+		return rspError
+	}
+	roleMinSortList, err := isql.User.GetUserMinRoleSortsByIds(userIds)
+	if err != nil || len(roleMinSortList) == 0 {
+		return tools.NewValidatorError(fmt.Errorf("根据用户ID获取用户角色排序最小值失败"))
+	}
+	minSort, _, err := isql.User.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		return tools.NewValidatorError(fmt.Errorf("获取当前登陆用户角色排序最小值失败"))
+	}
+	for _, sort := range roleMinSortList {
+		if int(minSort) > sort {
+			return tools.NewValidatorError(fmt.Errorf("用户不能管理比自己角色等级高的用户的会话"))
+		}
+	}
+	return nil
+}
@@ -24,7 +24,10 @@ func (d *SqlLogic) SyncSqlUsers(c *gin.Context, req any) (data any, rspError any
 		common.Log.Errorf("SyncSqlUsers: %s", errMsg)
 		return nil, ReqAssertErr
 	}
-	_ = c
+	src := d.syncSource()
+	rec := startSyncRun(c, src, model.SyncTargetUser, r.DryRun)
+	defer rec.done(&rspError)
+
 	// 1.获取所有用户
 	for _, id := range r.UserIds {
 		filter := tools.H{"id": int(id)}
@@ -40,41 +43,74 @@ func (d *SqlLogic) SyncSqlUsers(c *gin.Context, req any) (data any, rspError any
 		common.Log.Errorf("SyncSqlUsers: %s", errMsg)
 		return nil, tools.NewMySqlError(errors.New(errMsg))
 	}
-	// 2.再将用户添加到ldap
-	for i, user := range users {
-		err = ildap.User.Add(&user)
-		if err != nil {
-			errMsg := fmt.Sprintf("向LDAP同步用户[%s]失败：%s", user.Username, err.Error())
-			common.Log.Errorf("SyncSqlUsers: %s", errMsg)
-			return nil, tools.NewLdapError(errors.New(errMsg))
-		}
-		// 获取用户将要添加的分组
-		groups, err := isql.Group.GetGroupByIds(tools.StringToSlice(user.DepartmentId, ","))
-		if err != nil {
-			errMsg := fmt.Sprintf("用户[%s]根据部门ID获取部门信息失败: %s", user.Username, err.Error())
-			common.Log.Errorf("SyncSqlUsers: %s", errMsg)
-			return nil, tools.NewMySqlError(errors.New(errMsg))
-		}
-		for _, group := range groups {
-			//根据选择的部门，添加到部门内
-			err = ildap.Group.AddUserToGroup(group.GroupDN, user.UserDN)
-			if err != nil {
-				errMsg := fmt.Sprintf("向Ldap添加用户[%s]到分组[%s]失败：%s", user.Username, group.GroupName, err.Error())
-				common.Log.Errorf("SyncSqlUsers: %s", errMsg)
-				return nil, tools.NewMySqlError(errors.New(errMsg))
-			}
-		}
-		err = isql.User.ChangeSyncState(int(user.ID), 1)
-		if err != nil {
-			errMsg := fmt.Sprintf("用户[%s]同步完毕之后更新状态失败：%s", user.Username, err.Error())
-			common.Log.Errorf("SyncSqlUsers: %s", errMsg)
-			return nil, tools.NewLdapError(errors.New(errMsg))
-		}
-		common.Log.Infof("SyncSqlUsers: 成功同步用户[%s] (%d/%d)", user.Username, i+1, len(users))
+	// 2.生成同步计划, 预演时只返回计划, 否则将用户添加到ldap
+	planner := &syncPlanner{src: src}
+	for _, user := range users {
+		planner.items = append(planner.items, &model.SyncPlanItem{
+			Kind:    "user",
+			Action:  model.SyncActionCreate,
+			Name:    user.Username,
+			DN:      user.UserDN,
+			LocalId: user.ID,
+			User:    &model.User{Model: user.Model, Username: user.Username, UserDN: user.UserDN},
+		})
+	}
+	data, err = planner.run(c, rec, model.SyncTargetUser, r.DryRun)
+	if err != nil {
+		return nil, err
 	}
 
 	common.Log.Infof("SyncSqlUsers: SQL用户同步成功，共同步%d个用户", len(users))
-	return nil, nil
+	return data, nil
+}
+
+// syncSource SQL的同步来源配置, 将平台中的分组与用户写入ldap
+func (d SqlLogic) syncSource() *syncSource {
+	return &syncSource{
+		Flag:     "sql",
+		Name:     "SQL",
+		AddGroup: d.syncGroup,
+		AddUser:  d.syncUser,
+	}
+}
+
+// syncUser 将平台中的一个用户写入ldap
+func (d SqlLogic) syncUser(u *model.User) error {
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": u.ID}, user)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
+	}
+	// 数据库中只保存了密码哈希，同步到ldap时为用户生成新的随机密码
+	newPassword := tools.GenerateRandomPassword()
+	err = ildap.User.Add(user, newPassword)
+	if err != nil {
+		return tools.NewLdapError(fmt.Errorf("向LDAP同步用户失败：%s", err.Error()))
+	}
+	err = isql.User.ChangePwd(user.Username, tools.GenPasswd(newPassword))
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("更新用户的密码失败：%s", err.Error()))
+	}
+	if err := tools.SendPasswordResetNotification(user.Username, user.Nickname, user.Mail, newPassword); err != nil {
+		common.Log.Warnf("SyncSqlUsers: 发送密码重置通知邮件失败，用户: %s, 邮箱: %s, 错误: %v", user.Username, user.Mail, err)
+	}
+	// 获取用户将要添加的分组
+	groups, err := isql.Group.GetGroupByIds(tools.StringToSlice(user.DepartmentId, ","))
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("根据部门ID获取部门信息失败: %s", err.Error()))
+	}
+	for _, group := range groups {
+		//根据选择的部门，添加到部门内
+		err = ildap.Group.AddUserToGroup(group.GroupDN, user.UserDN)
+		if err != nil {
+			return tools.NewMySqlError(fmt.Errorf("向Ldap添加用户到分组[%s]失败：%s", group.GroupName, err.Error()))
+		}
+	}
+	err = isql.User.ChangeSyncState(int(user.ID), 1)
+	if err != nil {
+		return tools.NewLdapError(fmt.Errorf("同步完毕之后更新状态失败：%s", err.Error()))
+	}
+	return nil
 }
 
 // SyncSqlGroups 同步sql中的分组信息到ldap
@@ -85,7 +121,10 @@ func (d *SqlLogic) SyncSqlGroups(c *gin.Context, req any) (data any, rspError an
 		common.Log.Errorf("SyncSqlGroups: %s", errMsg)
 		return nil, ReqAssertErr
 	}
-	_ = c
+	src := d.syncSource()
+	rec := startSyncRun(c, src, model.SyncTargetDept, r.DryRun)
+	defer rec.done(&rspError)
+
 	// 1.获取所有分组
 	for _, id := range r.GroupIds {
 		filter := tools.H{"id": int(id)}
@@ -101,37 +140,52 @@ func (d *SqlLogic) SyncSqlGroups(c *gin.Context, req any) (data any, rspError an
 		common.Log.Errorf("SyncSqlGroups: %s", errMsg)
 		return nil, tools.NewMySqlError(errors.New(errMsg))
 	}
-	// 2.再将分组添加到ldap
+	// 2.生成同步计划, 预演时只返回计划, 否则将分组添加到ldap
+	planner := &syncPlanner{src: src}
 	for _, group := range groups {
-		err = ildap.Group.Add(group)
-		if err != nil {
-			errMsg := fmt.Sprintf("向LDAP同步分组[%s]失败：%s", group.GroupName, err.Error())
-			common.Log.Errorf("SyncSqlGroups: %s", errMsg)
-			return nil, tools.NewLdapError(errors.New(errMsg))
-		}
-		if len(group.Users) > 0 {
-			for _, user := range group.Users {
-				if user.UserDN == config.Conf.Ldap.AdminDN {
-					continue
-				}
-				err = ildap.Group.AddUserToGroup(group.GroupDN, user.UserDN)
-				if err != nil {
-					errMsg := fmt.Sprintf("同步分组[%s]之后处理分组内的用户[%s]失败：%s", group.GroupName, user.Username, err.Error())
-					common.Log.Errorf("SyncSqlGroups: %s", errMsg)
-					return nil, tools.NewLdapError(errors.New(errMsg))
-				}
-			}
+		planner.items = append(planner.items, &model.SyncPlanItem{
+			Kind:    "group",
+			Action:  model.SyncActionCreate,
+			Name:    group.GroupName,
+			DN:      group.GroupDN,
+			LocalId: group.ID,
+			Group:   &model.Group{Model: group.Model, GroupName: group.GroupName, GroupDN: group.GroupDN},
+		})
+	}
+	data, err = planner.run(c, rec, model.SyncTargetDept, r.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	common.Log.Infof("SyncSqlGroups: SQL分组同步成功，共同步%d个分组", len(groups))
+	return data, nil
+}
+
+// syncGroup 将平台中的一个分组及其成员写入ldap
+func (d SqlLogic) syncGroup(g *model.Group) error {
+	group := new(model.Group)
+	err := isql.Group.Find(tools.H{"id": g.ID}, group)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("获取分组信息失败: %s", err.Error()))
+	}
+	err = ildap.Group.Add(group)
+	if err != nil {
+		return tools.NewLdapError(fmt.Errorf("向LDAP同步分组失败：%s", err.Error()))
+	}
+	for _, user := range group.Users {
+		if user.UserDN == config.Conf.Ldap.AdminDN {
+			continue
 		}
-		err = isql.Group.ChangeSyncState(int(group.ID), 1)
+		err = ildap.Group.AddUserToGroup(group.GroupDN, user.UserDN)
 		if err != nil {
-			errMsg := fmt.Sprintf("分组[%s]同步完毕之后更新状态失败：%s", group.GroupName, err.Error())
-			common.Log.Errorf("SyncSqlGroups: %s", errMsg)
-			return nil, tools.NewLdapError(errors.New(errMsg))
+			return tools.NewLdapError(fmt.Errorf("同步分组之后处理分组内的用户[%s]失败：%s", user.Username, err.Error()))
 		}
 	}
-
-	common.Log.Infof("SyncSqlGroups: SQL分组同步成功，共同步%d个分组", len(groups))
-	return nil, nil
+	err = isql.Group.ChangeSyncState(int(group.ID), 1)
+	if err != nil {
+		return tools.NewLdapError(fmt.Errorf("同步完毕之后更新状态失败：%s", err.Error()))
+	}
+	return nil
 }
 
 // SearchGroupDiff 检索未同步到ldap中的分组
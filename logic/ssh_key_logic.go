@@ -0,0 +1,285 @@
+package logic
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh"
+)
+
+type SshKeyLogic struct{}
+
+// RSA公钥的最小长度
+const sshKeyMinRsaBits = 2048
+
+// Mine 获取当前用户的SSH公钥列表
+func (l SshKeyLogic) Mine(c *gin.Context, req any) (data any, rspError any) {
+	_, ok := req.(*request.SshKeyMineReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	return listSshKeys(ctxUser.ID)
+}
+
+// List 管理员获取指定用户的SSH公钥列表
+func (l SshKeyLogic) List(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SshKeyListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSshKeyManageable(c, []uint{r.UserID}); rspError != nil {
+		return nil, rspError
+	}
+	return listSshKeys(r.UserID)
+}
+
+// AddMine 当前用户添加SSH公钥
+func (l SshKeyLogic) AddMine(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SshKeyAddMineReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	return addSshKey(&ctxUser, r.Title, r.Key, r.ExpireDays)
+}
+
+// Add 管理员为用户添加SSH公钥
+func (l SshKeyLogic) Add(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SshKeyAddReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSshKeyManageable(c, []uint{r.UserID}); rspError != nil {
+		return nil, rspError
+	}
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": r.UserID}, user)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
+	}
+	return addSshKey(user, r.Title, r.Key, r.ExpireDays)
+}
+
+// DeleteMine 当前用户删除自己的SSH公钥
+func (l SshKeyLogic) DeleteMine(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SshKeyDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	ctxUser, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
+	}
+	err = isql.SshKey.Delete(tools.H{"id": r.Ids, "user_id": ctxUser.ID})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("删除SSH公钥失败: %s", err.Error()))
+	}
+	if err := syncSshKeys(&ctxUser); err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("同步SSH公钥到LDAP失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// Delete 管理员删除SSH公钥
+func (l SshKeyLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SshKeyDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	keys, err := isql.SshKey.ListByIds(r.Ids)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取SSH公钥失败: %s", err.Error()))
+	}
+	if len(keys) == 0 {
+		return nil, tools.NewValidatorError(fmt.Errorf("SSH公钥不存在"))
+	}
+	userIds := sshKeyUserIds(keys)
+	if rspError := checkSshKeyManageable(c, userIds); rspError != nil {
+		return nil, rspError
+	}
+	err = isql.SshKey.Delete(tools.H{"id": r.Ids})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("删除SSH公钥失败: %s", err.Error()))
+	}
+	if err := syncUsersSshKeys(userIds); err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("同步SSH公钥到LDAP失败: %s", err.Error()))
+	}
+	return nil, nil
+}
+
+// CleanExpiredSshKeys 删除已过期的SSH公钥, 并同步到LDAP
+func CleanExpiredSshKeys() {
+	keys, err := isql.SshKey.ListExpired(time.Now())
+	if err != nil {
+		common.Log.Errorf("获取已过期的SSH公钥失败: %v", err)
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	if err := isql.SshKey.Delete(tools.H{"id": ids}); err != nil {
+		common.Log.Errorf("删除已过期的SSH公钥失败: %v", err)
+		return
+	}
+	if err := syncUsersSshKeys(sshKeyUserIds(keys)); err != nil {
+		common.Log.Errorf("同步已过期的SSH公钥到LDAP失败: %v", err)
+	}
+	common.Log.Infof("CleanExpiredSshKeys: 删除了%d个已过期的SSH公钥", len(keys))
+}
+
+// ParseSshKey 校验authorized_keys格式的公钥, 返回规范化后的公钥、类型、指纹与注释
+func ParseSshKey(line string) (key, keyType, fingerprint, comment string, err error) {
+	pub, comment, options, rest, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(line)))
+	if err != nil {
+		return "", "", "", "", errors.New("SSH公钥格式错误")
+	}
+	if len(options) > 0 {
+		return "", "", "", "", errors.New("SSH公钥不能包含选项")
+	}
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return "", "", "", "", errors.New("每次只能添加一个SSH公钥")
+	}
+	switch pub.Type() {
+	case ssh.KeyAlgoDSA:
+		return "", "", "", "", errors.New("不支持DSA类型的SSH公钥")
+	case ssh.KeyAlgoRSA:
+		if cryptoKey, ok := pub.(ssh.CryptoPublicKey); ok {
+			if rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey); ok && rsaKey.N.BitLen() < sshKeyMinRsaBits {
+				return "", "", "", "", fmt.Errorf("RSA公钥长度不能小于%d位", sshKeyMinRsaBits)
+			}
+		}
+	}
+	key = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" {
+		key += " " + comment
+	}
+	return key, pub.Type(), ssh.FingerprintSHA256(pub), comment, nil
+}
+
+// addSshKey 校验并保存用户的SSH公钥, 同一公钥只能属于一个用户
+func addSshKey(user *model.User, title, line string, expireDays uint) (any, any) {
+	key, keyType, fingerprint, comment, err := ParseSshKey(line)
+	if err != nil {
+		return nil, tools.NewValidatorError(err)
+	}
+	if isql.SshKey.Exist(tools.H{"fingerprint": fingerprint}) {
+		return nil, tools.NewValidatorError(fmt.Errorf("该SSH公钥已被添加"))
+	}
+	if title == "" {
+		title = comment
+	}
+	sshKey := &model.SshKey{
+		UserID:      user.ID,
+		Title:       title,
+		KeyType:     keyType,
+		Key:         key,
+		Fingerprint: fingerprint,
+	}
+	if expireDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, int(expireDays))
+		sshKey.ExpiresAt = &expiresAt
+	}
+	err = isql.SshKey.Add(sshKey)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("添加SSH公钥失败: %s", err.Error()))
+	}
+	if err := syncSshKeys(user); err != nil {
+		// 写入LDAP失败时删除刚添加的公钥, 保持数据库与LDAP一致
+		if delErr := isql.SshKey.Delete(tools.H{"id": sshKey.ID}); delErr != nil {
+			common.Log.Errorf("回滚SSH公钥[%d]失败: %v", sshKey.ID, delErr)
+		}
+		return nil, tools.NewLdapError(fmt.Errorf("同步SSH公钥到LDAP失败: %s", err.Error()))
+	}
+	return sshKey, nil
+}
+
+// syncSshKeys 将用户在数据库中的SSH公钥覆盖写入LDAP, 离职用户不在LDAP中, 不需要同步
+func syncSshKeys(user *model.User) error {
+	if user.Status != 1 {
+		return nil
+	}
+	keys, err := isql.SshKey.List(user.ID)
+	if err != nil {
+		return err
+	}
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, key.Key)
+	}
+	return ildap.User.SetSshKeys(user.UserDN, lines)
+}
+
+func syncUsersSshKeys(userIds []uint) error {
+	users, err := isql.User.GetUserByIds(userIds)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := syncSshKeys(&user); err != nil {
+			return fmt.Errorf("用户[%s]: %s", user.Username, err.Error())
+		}
+	}
+	return nil
+}
+
+func listSshKeys(userId uint) (any, any) {
+	keys, err := isql.SshKey.List(userId)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取SSH公钥列表失败: %s", err.Error()))
+	}
+	return keys, nil
+}
+
+func sshKeyUserIds(keys []*model.SshKey) []uint {
+	seen := make(map[uint]bool)
+	userIds := make([]uint, 0)
+	for _, key := range keys {
+		if !seen[key.UserID] {
+			seen[key.UserID] = true
+			userIds = append(userIds, key.UserID)
+		}
+	}
+	return userIds
+}
+
+// checkSshKeyManageable 不能管理比自己(登陆用户)角色等级高的用户以及不可管理目录中用户的SSH公钥
+func checkSshKeyManageable(c *gin.Context, userIds []uint) any {
+	if rspError := checkUserScope(c, userIds...); rspError != nil {
+		return rspError
+	}
+	roleMinSortList, err := isql.User.GetUserMinRoleSortsByIds(userIds)
+	if err != nil || len(roleMinSortList) == 0 {
+		return tools.NewValidatorError(fmt.Errorf("根据用户ID获取用户角色排序最小值失败"))
+	}
+	minSort, _, err := isql.User.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		return tools.NewValidatorError(fmt.Errorf("获取当前登陆用户角色排序最小值失败"))
+	}
+	for _, sort := range roleMinSortList {
+		if int(minSort) > sort {
+			return tools.NewValidatorError(fmt.Errorf("用户不能管理比自己角色等级高的用户的SSH公钥"))
+		}
+	}
+	return nil
+}
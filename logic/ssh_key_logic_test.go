@@ -0,0 +1,72 @@
+package logic
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func authorizedKey(t *testing.T, pub any) string {
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+}
+
+func TestParseSshKey(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := authorizedKey(t, edPub)
+
+	key, keyType, fingerprint, comment, err := ParseSshKey("  " + line + " zhangsan@laptop\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != line+" zhangsan@laptop" {
+		t.Fatalf("公钥应去除首尾空白并保留注释, 实际为%s", key)
+	}
+	if keyType != ssh.KeyAlgoED25519 || comment != "zhangsan@laptop" || !strings.HasPrefix(fingerprint, "SHA256:") {
+		t.Fatalf("解析结果有误: %s %s %s", keyType, fingerprint, comment)
+	}
+
+	// 同一公钥不论注释如何, 指纹相同
+	_, _, other, _, err := ParseSshKey(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != fingerprint {
+		t.Fatal("同一公钥的指纹应相同")
+	}
+}
+
+func TestParseSshKeyInvalid(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := authorizedKey(t, edPub)
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"格式错误":  "ssh-ed25519 not-base64",
+		"空内容":   "",
+		"包含选项":  `command="/bin/true" ` + line,
+		"多个公钥":  line + "\n" + line,
+		"RSA过短": authorizedKey(t, &weak.PublicKey),
+	}
+	for name, input := range cases {
+		if _, _, _, _, err := ParseSshKey(input); err == nil {
+			t.Fatalf("%s时应校验失败", name)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/client/dingtalk"
+	"github.com/eryajf/go-ldap-admin/public/client/feishu"
+	"github.com/eryajf/go-ldap-admin/public/client/wechat"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+type SsoLogic struct{}
+
+const (
+	SsoProviderDingTalk = "dingtalk"
+	SsoProviderFeiShu   = "feishu"
+	SsoProviderWeCom    = "wecom"
+)
+
+// ssoProvider 第三方扫码登录方式
+type ssoProvider struct {
+	enabled         func() bool
+	flag            func() string
+	redirectURI     func() string
+	oauthURL        func(redirectURI, state string) string
+	getUserIdByCode func(code string) (string, error)
+}
+
+var (
+	ssoProviderNames = []string{SsoProviderDingTalk, SsoProviderFeiShu, SsoProviderWeCom}
+	ssoProviders     = map[string]ssoProvider{
+		SsoProviderDingTalk: {
+			enabled:         func() bool { return config.Conf.DingTalk.EnableSso },
+			flag:            func() string { return config.Conf.DingTalk.Flag },
+			redirectURI:     func() string { return config.Conf.DingTalk.SsoRedirectUri },
+			oauthURL:        dingtalk.OAuthURL,
+			getUserIdByCode: dingtalk.GetUserIdByCode,
+		},
+		SsoProviderFeiShu: {
+			enabled:         func() bool { return config.Conf.FeiShu.EnableSso },
+			flag:            func() string { return config.Conf.FeiShu.Flag },
+			redirectURI:     func() string { return config.Conf.FeiShu.SsoRedirectUri },
+			oauthURL:        feishu.OAuthURL,
+			getUserIdByCode: feishu.GetUserIdByCode,
+		},
+		SsoProviderWeCom: {
+			enabled:         func() bool { return config.Conf.WeCom.EnableSso },
+			flag:            func() string { return config.Conf.WeCom.Flag },
+			redirectURI:     func() string { return config.Conf.WeCom.SsoRedirectUri },
+			oauthURL:        wechat.OAuthURL,
+			getUserIdByCode: wechat.GetUserIdByCode,
+		},
+	}
+
+	// 登录授权的state, 防止登录CSRF, 10分钟内有效且只能使用一次
+	ssoStateCache = cache.New(10*time.Minute, 20*time.Minute)
+)
+
+// SsoStateCookie 保存登录授权state的cookie, 登录时校验回调中的state与发起授权的浏览器一致
+const SsoStateCookie = "sso_state"
+
+// ssoStateCookiePath state只在登录接口中使用
+func ssoStateCookiePath() string {
+	return "/" + config.Conf.System.UrlPathPrefix + "/base"
+}
+
+// SetSsoStateCookie 设置或清除(state为空)登录授权state的cookie
+func SetSsoStateCookie(c *gin.Context, state string) {
+	maxAge := int((10 * time.Minute).Seconds())
+	if state == "" {
+		maxAge = -1
+	}
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SsoStateCookie, state, maxAge, ssoStateCookiePath(), "", secure, true)
+}
+
+// Providers 获取已开启的扫码登录方式
+func (l SsoLogic) Providers(c *gin.Context, req any) (data any, rspError any) {
+	_, ok := req.(*request.SsoProvidersReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	providers := make([]string, 0)
+	for _, name := range ssoProviderNames {
+		if ssoProviders[name].enabled() {
+			providers = append(providers, name)
+		}
+	}
+	return providers, nil
+}
+
+// Authorize 生成第三方登录授权地址
+func (l SsoLogic) Authorize(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SsoAuthorizeReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	provider, ok := ssoProviders[r.Provider]
+	if !ok || !provider.enabled() {
+		return nil, tools.NewValidatorError(fmt.Errorf("未开启该登录方式"))
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	state := hex.EncodeToString(buf)
+	ssoStateCache.SetDefault(state, r.Provider)
+	SetSsoStateCookie(c, state)
+
+	return tools.H{
+		"url":   provider.oauthURL(provider.redirectURI(), state),
+		"state": state,
+	}, nil
+}
+
+// SsoLogin 使用第三方登录授权码登录, 根据第三方userid匹配平台用户
+// cookieState为发起授权时写入浏览器cookie的state, 必须与回调中的state一致
+func SsoLogin(providerName, code, state, cookieState string) (*model.User, error) {
+	provider, ok := ssoProviders[providerName]
+	if !ok || !provider.enabled() {
+		return nil, errors.New("未开启该登录方式")
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		return nil, errors.New("登录请求与当前浏览器不匹配，请重新扫码")
+	}
+	v, ok := ssoStateCache.Get(state)
+	ssoStateCache.Delete(state)
+	if !ok || v.(string) != providerName {
+		return nil, errors.New("登录请求已过期，请重新扫码")
+	}
+	if code == "" {
+		return nil, errors.New("缺少登录授权码")
+	}
+
+	userId, err := provider.getUserIdByCode(code)
+	if err != nil {
+		// 错误中的请求地址已去掉查询参数, 不会包含应用密钥
+		common.Log.Errorf("SsoLogin: %s 获取用户信息失败: %v", providerName, err)
+		return nil, errors.New("获取第三方用户信息失败")
+	}
+
+	user := new(model.User)
+	err = isql.User.Find(tools.H{"source_user_id": fmt.Sprintf("%s_%s", provider.flag(), userId)}, user)
+	if err != nil {
+		return nil, errors.New("用户不存在，请先同步该用户到平台")
+	}
+	if user.Status != 1 {
+		return nil, errors.New("用户被禁用")
+	}
+	return user, nil
+}
@@ -0,0 +1,706 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+)
+
+type SyncLogic struct{}
+
+// syncSource 生成与执行同步计划时需要的来源配置
+type syncSource struct {
+	Flag          string                   // 来源标识, 同时作为分组与用户的Source
+	Name          string                   // 来源名称, 用于日志
+	RootDeptId    string                   // 来源根部门在平台中的sourceDeptId
+	IsUpdateSyncd bool                     // 是否更新、移动已同步的分组与用户
+	IsDeleteSyncd bool                     // 是否删除来源中已不存在的分组
+	AddGroup      func(*model.Group) error // 新建分组
+	AddUser       func(*model.User) error  // 新建用户, 开启同步更新时也负责更新已存在的用户
+}
+
+// syncSourceOf 根据来源标识获取同步来源, 用于执行之前保存的同步计划
+func syncSourceOf(flag string) *syncSource {
+	if p := syncProviderOf(flag); p != nil {
+		return newSyncSource(p)
+	}
+	if src := Sql.syncSource(); src.Flag == flag {
+		return src
+	}
+	return nil
+}
+
+// syncUserFields 同步时对比的用户字段
+var syncUserFields = []struct {
+	name string
+	get  func(u *model.User) string
+}{
+	{"nickname", func(u *model.User) string { return u.Nickname }},
+	{"givenName", func(u *model.User) string { return u.GivenName }},
+	{"mail", func(u *model.User) string { return u.Mail }},
+	{"jobNumber", func(u *model.User) string { return u.JobNumber }},
+	{"mobile", func(u *model.User) string { return u.Mobile }},
+	{"avatar", func(u *model.User) string { return u.Avatar }},
+	{"postalAddress", func(u *model.User) string { return u.PostalAddress }},
+	{"position", func(u *model.User) string { return u.Position }},
+	{"introduction", func(u *model.User) string { return u.Introduction }},
+}
+
+// syncPlanner 对比来源数据与平台中的数据, 生成同步计划
+type syncPlanner struct {
+	src        *syncSource
+	groupNames map[uint]string
+	items      []*model.SyncPlanItem
+	root       *model.Group // 预演时尚未创建的根部门
+}
+
+func newSyncPlanner(src *syncSource) (*syncPlanner, error) {
+	groups, err := isql.Group.ListAll()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取分组列表失败：%s", err.Error()))
+	}
+	groupNames := make(map[uint]string, len(groups))
+	for _, g := range groups {
+		groupNames[g.ID] = g.GroupName
+	}
+	return &syncPlanner{src: src, groupNames: groupNames}, nil
+}
+
+// planRoot 根部门尚不存在时作为计划的第一项, 执行计划时才创建
+func (p *syncPlanner) planRoot(root *model.Group) {
+	if root == nil || isql.Group.Exist(tools.H{"source_dept_id": root.SourceDeptId}) {
+		return
+	}
+	p.root = root
+	p.addGroupItem(model.SyncActionCreate, root, root.GroupDN, 0, nil)
+}
+
+// planDepts 按部门树的顺序生成分组的同步计划, 上级分组总是先于下级分组处理
+func (p *syncPlanner) planDepts(tree []*model.Group) error {
+	groups, err := isql.Group.ListAll()
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("获取分组列表失败：%s", err.Error()))
+	}
+	return p.planDeptTree(tree, groups)
+}
+
+// planDeptTree 对比部门树与平台中已有的分组
+func (p *syncPlanner) planDeptTree(tree []*model.Group, groups []*model.Group) error {
+	var err error
+	bySourceId := make(map[string]*model.Group)
+	byDN := make(map[string]*model.Group)
+	// 部门编号对应的计划执行之后的分组dn
+	dns := make(map[string]string)
+	for _, g := range groups {
+		byDN[g.GroupDN] = g
+		if g.SourceDeptId != "" {
+			bySourceId[g.SourceDeptId] = g
+			dns[g.SourceDeptId] = g.GroupDN
+		}
+	}
+	if p.root != nil {
+		byDN[p.root.GroupDN] = p.root
+		dns[p.root.SourceDeptId] = p.root.GroupDN
+	}
+
+	seen := make(map[string]bool)
+	var walk func(depts []*model.Group) error
+	walk = func(depts []*model.Group) error {
+		for _, dept := range depts {
+			seen[dept.SourceDeptId] = true
+			err := p.planDept(dept, bySourceId, byDN, dns)
+			if err != nil {
+				return err
+			}
+			if len(dept.Children) != 0 {
+				if err = walk(dept.Children); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err = walk(tree); err != nil {
+		return err
+	}
+
+	if !p.src.IsDeleteSyncd {
+		return nil
+	}
+	// 来源中已不存在的分组, 先删除下级分组
+	var removed []*model.Group
+	for _, g := range groups {
+		if g.Source != p.src.Flag || g.GroupType != "cn" || g.SourceDeptId == "" || g.SourceDeptId == p.src.RootDeptId {
+			continue
+		}
+		if !seen[g.SourceDeptId] {
+			removed = append(removed, g)
+		}
+	}
+	sort.SliceStable(removed, func(i, j int) bool {
+		return strings.Count(removed[i].GroupDN, ",") > strings.Count(removed[j].GroupDN, ",")
+	})
+	for _, g := range removed {
+		p.items = append(p.items, &model.SyncPlanItem{
+			Kind:     "group",
+			Action:   model.SyncActionDelete,
+			Name:     g.GroupName,
+			SourceId: g.SourceDeptId,
+			DN:       g.GroupDN,
+			LocalId:  g.ID,
+		})
+	}
+	return nil
+}
+
+func (p *syncPlanner) planDept(dept *model.Group, bySourceId, byDN map[string]*model.Group, dns map[string]string) error {
+	// 来源自带dn时(如OpenLDAP)只以dn判断分组是否已存在
+	if dept.GroupDN != "" {
+		if byDN[dept.GroupDN] == nil {
+			p.addGroupItem(model.SyncActionCreate, dept, dept.GroupDN, 0, nil)
+			byDN[dept.GroupDN] = dept
+		}
+		return nil
+	}
+
+	parentDN, ok := dns[dept.SourceDeptParentId]
+	if !ok {
+		return tools.NewMySqlError(fmt.Errorf("查询部门[%s]的父级部门失败", dept.GroupName))
+	}
+	old := bySourceId[dept.SourceDeptId]
+	if old == nil {
+		dn := fmt.Sprintf("cn=%s,%s", dept.GroupName, parentDN)
+		dns[dept.SourceDeptId] = dn
+		if byDN[dn] == nil {
+			p.addGroupItem(model.SyncActionCreate, dept, dn, 0, nil)
+			byDN[dn] = dept
+		}
+		return nil
+	}
+	// 根部门等OU只做新建, 不随来源变化
+	if !p.src.IsUpdateSyncd || old.GroupType != "cn" {
+		return nil
+	}
+
+	var diffs []*model.SyncFieldDiff
+	name := old.GroupName
+	if config.Conf.Ldap.GroupNameModify && dept.GroupName != "" && dept.GroupName != old.GroupName {
+		diffs = append(diffs, &model.SyncFieldDiff{Field: "groupName", Old: old.GroupName, New: dept.GroupName})
+		name = dept.GroupName
+	}
+	if dept.Remark != "" && dept.Remark != old.Remark {
+		diffs = append(diffs, &model.SyncFieldDiff{Field: "remark", Old: old.Remark, New: dept.Remark})
+	}
+	dn := fmt.Sprintf("cn=%s,%s", name, parentDN)
+	dns[dept.SourceDeptId] = dn
+	if dn != old.GroupDN {
+		diffs = append(diffs, &model.SyncFieldDiff{Field: "groupDn", Old: old.GroupDN, New: dn})
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	action := model.SyncActionUpdate
+	if dept.SourceDeptParentId != old.SourceDeptParentId {
+		action = model.SyncActionMove
+	}
+	dept.GroupName = name
+	p.addGroupItem(action, dept, dn, old.ID, diffs)
+	return nil
+}
+
+func (p *syncPlanner) addGroupItem(action string, dept *model.Group, dn string, localId uint, diffs []*model.SyncFieldDiff) {
+	group := *dept
+	group.Children = nil
+	group.GroupDN = dn
+	p.items = append(p.items, &model.SyncPlanItem{
+		Kind:     "group",
+		Action:   action,
+		Name:     dept.GroupName,
+		SourceId: dept.SourceDeptId,
+		DN:       dn,
+		LocalId:  localId,
+		Diffs:    diffs,
+		Group:    &group,
+	})
+}
+
+// planUser 对比来源用户与平台中的用户, 无需变更时不生成计划
+func (p *syncPlanner) planUser(user *model.User) error {
+	dn := user.UserDN
+	if dn == "" {
+		dn = common.LdapUserDN(user.Username)
+	}
+	item := &model.SyncPlanItem{
+		Kind:     "user",
+		Action:   model.SyncActionCreate,
+		Name:     user.Username,
+		SourceId: user.SourceUserId,
+		DN:       dn,
+		User:     user,
+	}
+	if !isql.User.Exist(tools.H{"user_dn": dn}) {
+		p.items = append(p.items, item)
+		return nil
+	}
+	if !p.src.IsUpdateSyncd {
+		return nil
+	}
+
+	old := new(model.User)
+	err := isql.User.Find(tools.H{"user_dn": dn}, old)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("查询用户[%s]失败：%s", user.Username, err.Error()))
+	}
+	p.planUserDiff(item, user, old)
+	return nil
+}
+
+// planUserDiff 对比来源用户与平台中已存在的用户, 有变化时加入计划
+func (p *syncPlanner) planUserDiff(item *model.SyncPlanItem, user, old *model.User) {
+	// 来源中为空的字段沿用平台中的值, 不算作变化
+	for _, field := range syncUserFields {
+		newValue, oldValue := field.get(user), field.get(old)
+		if newValue != "" && newValue != oldValue {
+			item.Diffs = append(item.Diffs, &model.SyncFieldDiff{Field: field.name, Old: oldValue, New: newValue})
+		}
+	}
+	item.Action = model.SyncActionUpdate
+	oldDeptIds := tools.StringToSlice(old.DepartmentId, ",")
+	newDeptIds := tools.StringToSlice(user.DepartmentId, ",")
+	if add, remove := tools.ArrUintCmp(oldDeptIds, newDeptIds); len(add) > 0 || len(remove) > 0 {
+		item.Action = model.SyncActionMove
+		item.Diffs = append(item.Diffs, &model.SyncFieldDiff{Field: "departments", Old: old.Departments, New: p.deptNames(newDeptIds)})
+	}
+	if len(item.Diffs) == 0 {
+		return
+	}
+	item.LocalId = old.ID
+	p.items = append(p.items, item)
+}
+
+// planLeavers 来源中已离职的用户需要禁用
+func (p *syncPlanner) planLeavers(users []*model.User) {
+	for _, user := range users {
+		p.items = append(p.items, &model.SyncPlanItem{
+			Kind:     "user",
+			Action:   model.SyncActionDisable,
+			Name:     user.Username,
+			SourceId: user.SourceUserId,
+			DN:       user.UserDN,
+			LocalId:  user.ID,
+		})
+	}
+}
+
+func (p *syncPlanner) deptNames(ids []uint) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if name, ok := p.groupNames[id]; ok {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// summary 统计各动作的数量
+func (p *syncPlanner) summary() map[string]int {
+	summary := map[string]int{
+		model.SyncActionCreate:  0,
+		model.SyncActionUpdate:  0,
+		model.SyncActionMove:    0,
+		model.SyncActionDisable: 0,
+		model.SyncActionDelete:  0,
+	}
+	for _, item := range p.items {
+		summary[item.Action]++
+	}
+	return summary
+}
+
+// run 预演时保存并返回同步计划, 否则直接按计划执行并返回同步记录
+func (p *syncPlanner) run(c *gin.Context, rec *syncRecorder, target string, dryRun bool) (any, error) {
+	if !dryRun {
+		err := applySyncPlan(p.src, p.items, rec, false)
+		if err != nil {
+			return nil, err
+		}
+		return rec.run, nil
+	}
+	summary, err := json.Marshal(p.summary())
+	if err != nil {
+		return nil, tools.NewOperationError(err)
+	}
+	items, err := json.Marshal(p.items)
+	if err != nil {
+		return nil, tools.NewOperationError(err)
+	}
+	plan := &model.SyncPlan{
+		Source:  p.src.Flag,
+		Target:  target,
+		Status:  1,
+		Creator: syncOperator(c),
+		Summary: summary,
+		Items:   items,
+	}
+	err = isql.SyncPlan.Add(plan)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("保存同步计划失败：%s", err.Error()))
+	}
+	common.Log.Infof("%s同步预演完成, 生成计划[%d]: %s", p.src.Name, plan.ID, summary)
+	return plan, nil
+}
+
+// syncOperator 触发同步的用户, 定时任务为system
+func syncOperator(c *gin.Context) string {
+	if c == nil {
+		return "system"
+	}
+	user, err := isql.User.GetCurrentLoginUser(c)
+	if err != nil {
+		return "system"
+	}
+	return user.Username
+}
+
+// applySyncPlan 依次执行同步计划中的操作, 单项失败时记录结果并继续执行后续操作
+// recheck为true时先与平台中当前的数据重新对比, 计划生成后已变化的项不再执行
+func applySyncPlan(src *syncSource, items []*model.SyncPlanItem, rec *syncRecorder, recheck bool) error {
+	failed := 0
+	for i, item := range items {
+		var err error
+		if recheck {
+			err = checkSyncItem(item)
+		}
+		if err == nil {
+			err = applySyncItem(src, item)
+		}
+		rec.record(item, err)
+		if err != nil {
+			failed++
+			item.Status, item.Error = 2, err.Error()
+			common.Log.Errorf("%s同步: %s[%s] %s失败：%s (%d/%d)", src.Name, item.Kind, item.Name, item.Action, err.Error(), i+1, len(items))
+			continue
+		}
+		item.Status, item.Error = 1, ""
+		common.Log.Infof("%s同步: 成功%s %s[%s] (%d/%d)", src.Name, item.Action, item.Kind, item.Name, i+1, len(items))
+	}
+	if failed > 0 {
+		errMsg := fmt.Sprintf("同步完成, 其中%d项失败, 详见同步记录[%d]", failed, rec.run.ID)
+		return tools.NewOperationError(errors.New(errMsg))
+	}
+	return nil
+}
+
+// checkSyncItem 计划生成后平台中的数据已变化时返回错误, 需要重新预演生成计划
+func checkSyncItem(item *model.SyncPlanItem) error {
+	stale := func(format string, args ...any) error {
+		return tools.NewValidatorError(fmt.Errorf("计划生成后"+format+", 请重新预演", args...))
+	}
+	switch item.Kind {
+	case "group":
+		if item.Action == model.SyncActionCreate {
+			if item.SourceId != "" && isql.Group.Exist(tools.H{"source_dept_id": item.SourceId}) || isql.Group.Exist(tools.H{"group_dn": item.DN}) {
+				return stale("分组已被创建")
+			}
+			return nil
+		}
+		group := new(model.Group)
+		if err := isql.Group.Find(tools.H{"id": item.LocalId}, group); err != nil {
+			return stale("分组已被删除")
+		}
+		if item.Action == model.SyncActionDelete {
+			if group.GroupDN != item.DN {
+				return stale("分组已被修改")
+			}
+			return nil
+		}
+		current := map[string]string{"groupName": group.GroupName, "remark": group.Remark, "groupDn": group.GroupDN}
+		for _, diff := range item.Diffs {
+			if current[diff.Field] != diff.Old {
+				return stale("分组的%s已被修改", diff.Field)
+			}
+		}
+	case "user":
+		if item.Action == model.SyncActionCreate {
+			if isql.User.Exist(tools.H{"user_dn": item.DN}) {
+				return stale("用户已被创建")
+			}
+			return nil
+		}
+		user := new(model.User)
+		if err := isql.User.Find(tools.H{"id": item.LocalId}, user); err != nil {
+			return stale("用户已被删除")
+		}
+		if item.Action == model.SyncActionDisable {
+			if user.Status != 1 {
+				return stale("用户已离职")
+			}
+			return nil
+		}
+		current := map[string]string{"departments": user.Departments}
+		for _, field := range syncUserFields {
+			current[field.name] = field.get(user)
+		}
+		for _, diff := range item.Diffs {
+			if current[diff.Field] != diff.Old {
+				return stale("用户的%s已被修改", diff.Field)
+			}
+		}
+	}
+	return nil
+}
+
+func applySyncItem(src *syncSource, item *model.SyncPlanItem) error {
+	switch item.Kind {
+	case "group":
+		switch item.Action {
+		case model.SyncActionCreate:
+			// 预演时计划中的根部门直接创建在ldap基础dn之下
+			if item.Group.SourceDeptId == src.RootDeptId && item.Group.GroupType == "ou" {
+				return addRootGroup(item.Group)
+			}
+			return src.AddGroup(item.Group)
+		case model.SyncActionUpdate, model.SyncActionMove:
+			return updateSyncGroup(item.LocalId, item.Group)
+		case model.SyncActionDelete:
+			return deleteSyncGroup(item.LocalId)
+		}
+	case "user":
+		switch item.Action {
+		case model.SyncActionCreate, model.SyncActionUpdate, model.SyncActionMove:
+			return src.AddUser(item.User)
+		case model.SyncActionDisable:
+			return disableSyncUser(item.LocalId)
+		}
+	}
+	return fmt.Errorf("不支持的同步操作")
+}
+
+// updateSyncGroup 按来源的变化更新分组的名称、说明与上级分组
+func updateSyncGroup(id uint, group *model.Group) error {
+	oldGroup := new(model.Group)
+	err := isql.Group.Find(tools.H{"id": id}, oldGroup)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("查询分组失败：%s", err.Error()))
+	}
+	parentGroup := new(model.Group)
+	err = isql.Group.Find(tools.H{"source_dept_id": group.SourceDeptParentId}, parentGroup)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("查询父级部门失败：%s", err.Error()))
+	}
+	remark := oldGroup.Remark
+	if group.Remark != "" {
+		remark = group.Remark
+	}
+	// 名称变化通过修改dn完成, 这里只更新分组说明
+	err = ildap.Group.Update(oldGroup, &model.Group{GroupName: oldGroup.GroupName, Remark: remark})
+	if err != nil {
+		return tools.NewLdapError(fmt.Errorf("向LDAP更新分组失败：%s", err.Error()))
+	}
+	if group.GroupDN != oldGroup.GroupDN {
+		err = ildap.Group.Move(oldGroup.GroupDN, group.GroupDN)
+		if err != nil {
+			return tools.NewLdapError(fmt.Errorf("向LDAP移动分组失败：%s", err.Error()))
+		}
+	}
+	err = isql.Group.UpdateSyncFields(oldGroup.ID, tools.H{
+		"group_name":            group.GroupName,
+		"remark":                remark,
+		"group_dn":              group.GroupDN,
+		"parent_id":             parentGroup.ID,
+		"source_dept_parent_id": group.SourceDeptParentId,
+	})
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("向MySQL更新分组失败：%s", err.Error()))
+	}
+	if group.GroupDN != oldGroup.GroupDN {
+		err = isql.Group.ReplaceDNSuffix(oldGroup.GroupDN, group.GroupDN)
+		if err != nil {
+			return tools.NewMySqlError(fmt.Errorf("向MySQL更新下级分组dn失败：%s", err.Error()))
+		}
+		// 上级分组展开后的成员中包含该分组的dn, 需要重新写入
+		if err := materializeNestedGroups(oldGroup.ID); err != nil {
+			return tools.NewLdapError(fmt.Errorf("展开嵌套分组成员失败：%s", err.Error()))
+		}
+	}
+	return nil
+}
+
+// deleteSyncGroup 删除来源中已不存在的分组, 仍有下级分组时不删除
+func deleteSyncGroup(id uint) error {
+	group := new(model.Group)
+	err := isql.Group.Find(tools.H{"id": id}, group)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("查询分组失败：%s", err.Error()))
+	}
+	if isql.Group.Exist(tools.H{"parent_id": int(group.ID)}) {
+		return tools.NewValidatorError(fmt.Errorf("存在子分组，不能删除"))
+	}
+	err = ildap.Group.Delete(group.GroupDN)
+	if err != nil {
+		return tools.NewLdapError(fmt.Errorf("向LDAP删除分组失败：%s", err.Error()))
+	}
+	groups := []*model.Group{group}
+	err = removeNestedGroups(groups)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("清理分组嵌套关系失败：%s", err.Error()))
+	}
+	err = isql.Group.Delete(groups)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("向MySQL删除分组失败：%s", err.Error()))
+	}
+	return nil
+}
+
+// disableSyncUser 禁用来源中已离职的用户
+func disableSyncUser(id uint) error {
+	user := new(model.User)
+	err := isql.User.Find(tools.H{"id": id}, user)
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("在MySQL查询离职用户失败：%s", err.Error()))
+	}
+	// 与手动离职相同: 在ldap禁用用户(AD禁用账号, OpenLDAP删除条目)、更新状态、重新展开分组成员并使token失效
+	if rspError := changeUserStatus(user, 2, ""); rspError != nil {
+		return tools.ReloadErr(rspError)
+	}
+	return nil
+}
+
+// PlanList 同步计划列表
+func (l SyncLogic) PlanList(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncPlanListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+
+	plans, err := isql.SyncPlan.List(r)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取同步计划列表失败：%s", err.Error()))
+	}
+	count, err := isql.SyncPlan.ListCount(r)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取同步计划总数失败：%s", err.Error()))
+	}
+	return response.SyncPlanListRsp{Total: count, Plans: plans}, nil
+}
+
+// PlanInfo 同步计划详情
+func (l SyncLogic) PlanInfo(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncPlanInfoReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+
+	plan := new(model.SyncPlan)
+	err := isql.SyncPlan.Find(tools.H{"id": r.ID}, plan)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("同步计划不存在"))
+	}
+	return plan, nil
+}
+
+// PlanApply 执行审核过的同步计划, 执行失败时可以再次执行, 只重试失败的项
+func (l SyncLogic) PlanApply(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncPlanApplyReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+
+	plan := new(model.SyncPlan)
+	err := isql.SyncPlan.Find(tools.H{"id": r.ID}, plan)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("同步计划不存在"))
+	}
+	src := syncSourceOf(plan.Source)
+	if src == nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("不支持的同步来源：%s", plan.Source))
+	}
+	var items []*model.SyncPlanItem
+	err = json.Unmarshal(plan.Items, &items)
+	if err != nil {
+		return nil, tools.NewOperationError(fmt.Errorf("解析同步计划失败：%s", err.Error()))
+	}
+
+	// 执行失败的计划只重新执行未成功的项
+	pending := make([]*model.SyncPlanItem, 0, len(items))
+	for _, item := range items {
+		if item.Status != 1 {
+			pending = append(pending, item)
+		}
+	}
+
+	claimed, err := isql.SyncPlan.Claim(plan.ID)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("更新同步计划状态失败：%s", err.Error()))
+	}
+	if !claimed {
+		return nil, tools.NewValidatorError(fmt.Errorf("同步计划已执行过"))
+	}
+	rec := startSyncRun(c, src, plan.Target, false)
+	rec.run.PlanID = plan.ID
+	defer rec.done(&rspError)
+	// 执行中panic时计划保持可重新执行
+	defer func() {
+		if p := recover(); p != nil {
+			if result, err := json.Marshal(items); err == nil {
+				_ = isql.SyncPlan.SaveResult(plan.ID, 3, result)
+			}
+			panic(p)
+		}
+	}()
+
+	applyErr := applySyncPlan(src, pending, rec, true)
+	status := uint(2)
+	if applyErr != nil {
+		status = 3
+	}
+	result, err := json.Marshal(items)
+	if err == nil {
+		err = isql.SyncPlan.SaveResult(plan.ID, status, result)
+	}
+	if err != nil {
+		common.Log.Errorf("保存同步计划[%d]的执行结果失败: %v", plan.ID, err)
+	}
+	if applyErr != nil {
+		return nil, applyErr
+	}
+	common.Log.Infof("%s同步: 计划[%d]执行完成", src.Name, plan.ID)
+	return rec.run, nil
+}
+
+// PlanDelete 删除同步计划
+func (l SyncLogic) PlanDelete(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncPlanDeleteReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+
+	err := isql.SyncPlan.Delete(r.PlanIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("删除同步计划失败：%s", err.Error()))
+	}
+	return nil, nil
+}
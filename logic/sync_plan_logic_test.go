@@ -0,0 +1,268 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupLogicTest 使用内存中的sqlite作为平台数据库, 每个测试独立一份
+func setupLogicTest(t *testing.T) {
+	common.Log = zap.NewNop().Sugar()
+	config.Conf.Ldap = &config.LdapConfig{
+		BaseDN: "dc=eryajf,dc=net",
+		UserDN: "ou=people,dc=eryajf,dc=net",
+	}
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	err = db.AutoMigrate(&model.Group{}, &model.User{}, &model.FieldRelation{}, &model.SyncPlan{}, &model.SyncRun{}, &model.SyncRunItem{}, &model.GroupNesting{}, &model.UserMfa{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.DB = db
+}
+
+func testSyncSource() *syncSource {
+	return &syncSource{
+		Flag:          "http",
+		Name:          "HTTP",
+		RootDeptId:    "root",
+		IsUpdateSyncd: true,
+		IsDeleteSyncd: true,
+	}
+}
+
+func TestPlanDeptTree(t *testing.T) {
+	config.Conf.Ldap = &config.LdapConfig{BaseDN: "dc=eryajf,dc=net"}
+	groups := []*model.Group{
+		{Model: gorm.Model{ID: 1}, GroupName: "http", GroupType: "ou", Source: "http", SourceDeptId: "root", GroupDN: "ou=http,dc=eryajf,dc=net"},
+		{Model: gorm.Model{ID: 2}, GroupName: "dev", Remark: "研发", GroupType: "cn", Source: "http", SourceDeptId: "d1", SourceDeptParentId: "root", GroupDN: "cn=dev,ou=http,dc=eryajf,dc=net"},
+		{Model: gorm.Model{ID: 3}, GroupName: "ops", Remark: "运维", GroupType: "cn", Source: "http", SourceDeptId: "d2", SourceDeptParentId: "root", GroupDN: "cn=ops,ou=http,dc=eryajf,dc=net"},
+		{Model: gorm.Model{ID: 4}, GroupName: "qa", Remark: "测试", GroupType: "cn", Source: "http", SourceDeptId: "d3", SourceDeptParentId: "root", GroupDN: "cn=qa,ou=http,dc=eryajf,dc=net"},
+		{Model: gorm.Model{ID: 5}, GroupName: "local", GroupType: "cn", Source: "platform", GroupDN: "cn=local,dc=eryajf,dc=net"},
+	}
+	// 部门树为根部门之下的部门
+	tree := []*model.Group{
+		{GroupName: "dev", Remark: "研发中心", SourceDeptId: "d1", SourceDeptParentId: "root", Children: []*model.Group{
+			{GroupName: "ops", Remark: "运维", SourceDeptId: "d2", SourceDeptParentId: "d1"},
+		}},
+		{GroupName: "sre", Remark: "稳定性", SourceDeptId: "d4", SourceDeptParentId: "root"},
+	}
+
+	p := &syncPlanner{src: testSyncSource()}
+	if err := p.planDeptTree(tree, groups); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		action, name, dn string
+		localId          uint
+	}{
+		{model.SyncActionUpdate, "dev", "cn=dev,ou=http,dc=eryajf,dc=net", 2},
+		{model.SyncActionMove, "ops", "cn=ops,cn=dev,ou=http,dc=eryajf,dc=net", 3},
+		{model.SyncActionCreate, "sre", "cn=sre,ou=http,dc=eryajf,dc=net", 0},
+		{model.SyncActionDelete, "qa", "cn=qa,ou=http,dc=eryajf,dc=net", 4},
+	}
+	if len(p.items) != len(want) {
+		t.Fatalf("计划应有%d项, 实际为%d项", len(want), len(p.items))
+	}
+	for i, w := range want {
+		item := p.items[i]
+		if item.Kind != "group" || item.Action != w.action || item.Name != w.name || item.DN != w.dn || item.LocalId != w.localId {
+			t.Fatalf("第%d项应为%s %s(%s), 实际为%s %s(%s)", i+1, w.action, w.name, w.dn, item.Action, item.Name, item.DN)
+		}
+	}
+	if diffs := p.items[0].Diffs; len(diffs) != 1 || diffs[0].Field != "remark" || diffs[0].New != "研发中心" {
+		t.Fatalf("更新分组应只有说明变化, 实际为%+v", diffs)
+	}
+	if diffs := p.items[1].Diffs; len(diffs) != 1 || diffs[0].Field != "groupDn" {
+		t.Fatalf("移动分组应只有dn变化, 实际为%+v", diffs)
+	}
+
+	// 未开启删除时不生成删除计划
+	src := testSyncSource()
+	src.IsDeleteSyncd = false
+	p = &syncPlanner{src: src}
+	if err := p.planDeptTree(tree, groups); err != nil {
+		t.Fatal(err)
+	}
+	if summary := p.summary(); summary[model.SyncActionDelete] != 0 {
+		t.Fatalf("未开启删除时不应删除分组, 实际为%d", summary[model.SyncActionDelete])
+	}
+}
+
+func TestPlanDeptTreeMissingParent(t *testing.T) {
+	config.Conf.Ldap = &config.LdapConfig{BaseDN: "dc=eryajf,dc=net"}
+	tree := []*model.Group{{GroupName: "dev", SourceDeptId: "d1", SourceDeptParentId: "unknown"}}
+	p := &syncPlanner{src: testSyncSource()}
+	if err := p.planDeptTree(tree, nil); err == nil {
+		t.Fatal("父级部门不存在时应报错")
+	}
+}
+
+func TestPlanUser(t *testing.T) {
+	setupLogicTest(t)
+	old := &model.User{
+		Username:     "zhangsan",
+		Nickname:     "张三",
+		Mail:         "zhangsan@eryajf.net",
+		Mobile:       "13800000000",
+		DepartmentId: "2",
+		Departments:  "dev",
+		UserDN:       "uid=zhangsan,ou=people,dc=eryajf,dc=net",
+		Status:       1,
+	}
+	if err := common.DB.Create(old).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := &syncPlanner{src: testSyncSource(), groupNames: map[uint]string{2: "dev", 3: "ops"}}
+
+	// 新用户
+	if err := p.planUser(&model.User{Username: "lisi", UserDN: "uid=lisi,ou=people,dc=eryajf,dc=net"}); err != nil {
+		t.Fatal(err)
+	}
+	// 来源中为空的字段不算作变化
+	if err := p.planUser(&model.User{Username: "zhangsan", Nickname: "张三", DepartmentId: "2", UserDN: old.UserDN}); err != nil {
+		t.Fatal(err)
+	}
+	// 字段变化
+	if err := p.planUser(&model.User{Username: "zhangsan", Mail: "zs@eryajf.net", DepartmentId: "2", UserDN: old.UserDN}); err != nil {
+		t.Fatal(err)
+	}
+	// 部门变化
+	if err := p.planUser(&model.User{Username: "zhangsan", DepartmentId: "3", UserDN: old.UserDN}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.items) != 3 {
+		t.Fatalf("计划应有3项, 实际为%d项", len(p.items))
+	}
+	if item := p.items[0]; item.Action != model.SyncActionCreate || item.Name != "lisi" || item.LocalId != 0 {
+		t.Fatalf("第1项应为新建lisi, 实际为%s %s", item.Action, item.Name)
+	}
+	if item := p.items[1]; item.Action != model.SyncActionUpdate || item.LocalId != old.ID || len(item.Diffs) != 1 || item.Diffs[0].Field != "mail" {
+		t.Fatalf("第2项应为更新邮箱, 实际为%s %+v", item.Action, item.Diffs)
+	}
+	item := p.items[2]
+	if item.Action != model.SyncActionMove || len(item.Diffs) != 1 || item.Diffs[0].Old != "dev" || item.Diffs[0].New != "ops" {
+		t.Fatalf("第3项应为移动部门, 实际为%s %+v", item.Action, item.Diffs)
+	}
+
+	// 未开启同步更新时只新建用户
+	src := testSyncSource()
+	src.IsUpdateSyncd = false
+	p = &syncPlanner{src: src}
+	if err := p.planUser(&model.User{Username: "zhangsan", Mail: "zs@eryajf.net", UserDN: old.UserDN}); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.items) != 0 {
+		t.Fatalf("未开启同步更新时不应更新用户, 实际为%d项", len(p.items))
+	}
+}
+
+func TestPlanLeavers(t *testing.T) {
+	p := &syncPlanner{src: testSyncSource()}
+	p.planLeavers([]*model.User{
+		{Model: gorm.Model{ID: 7}, Username: "wangwu", SourceUserId: "u7", UserDN: "uid=wangwu,ou=people,dc=eryajf,dc=net"},
+	})
+	if len(p.items) != 1 {
+		t.Fatalf("计划应有1项, 实际为%d项", len(p.items))
+	}
+	item := p.items[0]
+	if item.Kind != "user" || item.Action != model.SyncActionDisable || item.LocalId != 7 || item.SourceId != "u7" {
+		t.Fatalf("离职用户应生成禁用计划, 实际为%+v", item)
+	}
+	if summary := p.summary(); summary[model.SyncActionDisable] != 1 || summary[model.SyncActionCreate] != 0 {
+		t.Fatalf("计划统计有误: %v", summary)
+	}
+}
+
+func TestCheckSyncItem(t *testing.T) {
+	setupLogicTest(t)
+	group := &model.Group{GroupName: "dev", Remark: "研发", GroupType: "cn", SourceDeptId: "d1", GroupDN: "cn=dev,ou=http,dc=eryajf,dc=net"}
+	user := &model.User{Username: "zhangsan", Mail: "zhangsan@eryajf.net", Departments: "dev", UserDN: "uid=zhangsan,ou=people,dc=eryajf,dc=net", Status: 1}
+	if err := common.DB.Create(group).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := common.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	update := &model.SyncPlanItem{Kind: "group", Action: model.SyncActionUpdate, LocalId: group.ID, DN: group.GroupDN,
+		Diffs: []*model.SyncFieldDiff{{Field: "remark", Old: "研发", New: "研发中心"}}}
+	if err := checkSyncItem(update); err != nil {
+		t.Fatalf("数据未变化时不应跳过: %v", err)
+	}
+	cases := []struct {
+		name string
+		item *model.SyncPlanItem
+	}{
+		{"分组已存在", &model.SyncPlanItem{Kind: "group", Action: model.SyncActionCreate, SourceId: "d1", DN: "cn=dev2,ou=http,dc=eryajf,dc=net"}},
+		{"分组已删除", &model.SyncPlanItem{Kind: "group", Action: model.SyncActionDelete, LocalId: group.ID + 1}},
+		{"用户已存在", &model.SyncPlanItem{Kind: "user", Action: model.SyncActionCreate, DN: user.UserDN}},
+		{"用户字段已修改", &model.SyncPlanItem{Kind: "user", Action: model.SyncActionUpdate, LocalId: user.ID,
+			Diffs: []*model.SyncFieldDiff{{Field: "mail", Old: "old@eryajf.net", New: "new@eryajf.net"}}}},
+	}
+	for _, tc := range cases {
+		if err := checkSyncItem(tc.item); err == nil {
+			t.Fatalf("%s时应跳过该项", tc.name)
+		}
+	}
+
+	// 计划生成后分组说明被修改
+	if err := common.DB.Model(group).Update("remark", "研发部").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSyncItem(update); err == nil {
+		t.Fatal("分组说明已变化时应跳过该项")
+	}
+	// 计划生成后用户已离职
+	if err := common.DB.Model(user).Update("status", 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSyncItem(&model.SyncPlanItem{Kind: "user", Action: model.SyncActionDisable, LocalId: user.ID}); err == nil {
+		t.Fatal("用户已离职时应跳过该项")
+	}
+}
+
+func TestApplySyncPlanResult(t *testing.T) {
+	setupLogicTest(t)
+	src := testSyncSource()
+	src.AddUser = func(u *model.User) error {
+		if u.Username == "bad" {
+			return errors.New("ldap unavailable")
+		}
+		return nil
+	}
+	items := []*model.SyncPlanItem{
+		{Kind: "user", Action: model.SyncActionCreate, Name: "good", DN: "uid=good,ou=people,dc=eryajf,dc=net", User: &model.User{Username: "good"}},
+		{Kind: "user", Action: model.SyncActionCreate, Name: "bad", DN: "uid=bad,ou=people,dc=eryajf,dc=net", User: &model.User{Username: "bad"}},
+	}
+	rec := &syncRecorder{run: new(model.SyncRun), summary: make(map[string]*syncCount)}
+	if err := applySyncPlan(src, items, rec, true); err == nil {
+		t.Fatal("有失败的项时应返回错误")
+	}
+	if items[0].Status != 1 || items[0].Error != "" {
+		t.Fatalf("成功的项应标记为成功, 实际为%d %s", items[0].Status, items[0].Error)
+	}
+	if items[1].Status != 2 || items[1].Error == "" {
+		t.Fatalf("失败的项应记录失败原因, 实际为%d %s", items[1].Status, items[1].Error)
+	}
+}
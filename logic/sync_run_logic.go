@@ -0,0 +1,171 @@
+package logic
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+)
+
+// syncCount 单个动作的执行数量
+type syncCount struct {
+	Success int `json:"success"`
+	Failed  int `json:"failed"`
+}
+
+// syncRecorder 记录一次同步的执行过程与每一项的结果
+type syncRecorder struct {
+	run     *model.SyncRun
+	items   []*model.SyncRunItem
+	summary map[string]*syncCount
+}
+
+// startSyncRun 开始记录一次同步, 预演不会写入任何数据, 不做记录
+func startSyncRun(c *gin.Context, src *syncSource, target string, dryRun bool) *syncRecorder {
+	if dryRun {
+		return nil
+	}
+	trigger := model.SyncTriggerManual
+	if c == nil {
+		trigger = model.SyncTriggerCron
+	}
+	run := &model.SyncRun{
+		Source:    src.Flag,
+		Target:    target,
+		Trigger:   trigger,
+		Operator:  syncOperator(c),
+		Status:    1,
+		StartedAt: time.Now(),
+	}
+	err := isql.SyncRun.Add(run)
+	if err != nil {
+		common.Log.Errorf("%s同步: 保存同步记录失败: %v", src.Name, err)
+	}
+	return &syncRecorder{run: run, summary: make(map[string]*syncCount)}
+}
+
+// record 记录单项的执行结果
+func (r *syncRecorder) record(item *model.SyncPlanItem, err error) {
+	if r == nil {
+		return
+	}
+	count, ok := r.summary[item.Action]
+	if !ok {
+		count = new(syncCount)
+		r.summary[item.Action] = count
+	}
+	result := &model.SyncRunItem{
+		Kind:   item.Kind,
+		Action: item.Action,
+		Name:   item.Name,
+		DN:     item.DN,
+		Status: 1,
+	}
+	if err != nil {
+		result.Status = 2
+		result.Error = err.Error()
+		r.run.Failed++
+		count.Failed++
+	} else {
+		count.Success++
+	}
+	r.items = append(r.items, result)
+}
+
+// succeeded 成功执行的数量
+func (r *syncRecorder) succeeded() int {
+	total := 0
+	for _, count := range r.summary {
+		total += count.Success
+	}
+	return total
+}
+
+// finish 保存同步结果, rspError为中止同步的错误
+func (r *syncRecorder) finish(rspError any) {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.run.EndedAt = &now
+	if rspError != nil && r.run.Failed == 0 {
+		r.run.Error = tools.ReloadErr(rspError).Error()
+	}
+	switch {
+	case rspError == nil && r.run.Failed == 0:
+		r.run.Status = 2
+	case r.succeeded() == 0:
+		r.run.Status = 4
+	default:
+		r.run.Status = 3
+	}
+	summary, err := json.Marshal(r.summary)
+	if err == nil {
+		r.run.Summary = summary
+	}
+	if r.run.ID == 0 {
+		return
+	}
+	for _, item := range r.items {
+		item.RunID = r.run.ID
+	}
+	if err := isql.SyncRun.AddItems(r.items); err != nil {
+		common.Log.Errorf("保存同步记录[%d]的执行结果失败: %v", r.run.ID, err)
+	}
+	if err := isql.SyncRun.Update(r.run); err != nil {
+		common.Log.Errorf("更新同步记录[%d]失败: %v", r.run.ID, err)
+	}
+}
+
+// done 在同步函数中直接defer, 发生panic时将同步记录标记为中断后继续panic
+func (r *syncRecorder) done(rspError *any) {
+	if p := recover(); p != nil {
+		r.finish(tools.NewOperationError(fmt.Errorf("同步异常中断: %v", p)))
+		panic(p)
+	}
+	r.finish(*rspError)
+}
+
+// RunList 同步记录列表
+func (l SyncLogic) RunList(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncRunListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+
+	runs, err := isql.SyncRun.List(r)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取同步记录列表失败：%s", err.Error()))
+	}
+	count, err := isql.SyncRun.ListCount(r)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取同步记录总数失败：%s", err.Error()))
+	}
+	return response.SyncRunListRsp{Total: count, Runs: runs}, nil
+}
+
+// RunItems 同步记录中每一项的执行结果
+func (l SyncLogic) RunItems(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncRunItemsReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+
+	items, count, err := isql.SyncRun.ListItems(r)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取同步执行结果失败：%s", err.Error()))
+	}
+	return response.SyncRunItemsRsp{Total: count, Items: items}, nil
+}
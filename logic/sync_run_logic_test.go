@@ -0,0 +1,161 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+)
+
+func testSyncItem(action, name string) *model.SyncPlanItem {
+	return &model.SyncPlanItem{Kind: "user", Action: action, Name: name, DN: "uid=" + name + ",ou=people,dc=eryajf,dc=net"}
+}
+
+// findSyncRun 从数据库读取保存后的同步记录
+func findSyncRun(t *testing.T, id uint) *model.SyncRun {
+	run := new(model.SyncRun)
+	if err := isql.SyncRun.Find(tools.H{"id": id}, run); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func TestSyncRecorderFinish(t *testing.T) {
+	setupLogicTest(t)
+	src := testSyncSource()
+	failed := errors.New("ldap unavailable")
+
+	cases := []struct {
+		name    string
+		results []error
+		abort   any
+		status  uint
+		failed  int
+		error   bool
+	}{
+		{name: "全部成功", results: []error{nil, nil}, status: 2},
+		{name: "部分失败", results: []error{nil, failed}, status: 3, failed: 1},
+		{name: "全部失败", results: []error{failed, failed}, status: 4, failed: 2},
+		{name: "开始前中止", abort: tools.NewOperationError(errors.New("获取部门列表失败")), status: 4, error: true},
+		{name: "执行后中止", results: []error{nil}, abort: tools.NewOperationError(errors.New("获取离职用户失败")), status: 3, error: true},
+	}
+	for _, tc := range cases {
+		rec := startSyncRun(nil, src, model.SyncTargetUser, false)
+		if rec.run.ID == 0 || rec.run.Status != 1 || rec.run.Trigger != model.SyncTriggerCron {
+			t.Fatalf("%s: 开始时应保存执行中的定时同步记录, 实际为%+v", tc.name, rec.run)
+		}
+		for i, err := range tc.results {
+			rec.record(testSyncItem(model.SyncActionCreate, string(rune('a'+i))), err)
+		}
+		rec.finish(tc.abort)
+
+		run := findSyncRun(t, rec.run.ID)
+		if run.Status != tc.status || run.Failed != tc.failed || run.EndedAt == nil {
+			t.Fatalf("%s: 状态应为%d、失败%d项, 实际为%d、%d项", tc.name, tc.status, tc.failed, run.Status, run.Failed)
+		}
+		if (run.Error != "") != tc.error {
+			t.Fatalf("%s: 中止原因记录有误: %q", tc.name, run.Error)
+		}
+		var count int64
+		common.DB.Model(&model.SyncRunItem{}).Where("run_id = ?", run.ID).Count(&count)
+		if int(count) != len(tc.results) {
+			t.Fatalf("%s: 应保存%d项执行结果, 实际为%d项", tc.name, len(tc.results), count)
+		}
+	}
+}
+
+func TestSyncRecorderSummary(t *testing.T) {
+	setupLogicTest(t)
+	failed := errors.New("ldap unavailable")
+	rec := startSyncRun(nil, testSyncSource(), model.SyncTargetUser, false)
+	rec.record(testSyncItem(model.SyncActionCreate, "a"), nil)
+	rec.record(testSyncItem(model.SyncActionCreate, "b"), failed)
+	rec.record(testSyncItem(model.SyncActionUpdate, "c"), nil)
+	rec.record(testSyncItem(model.SyncActionUpdate, "d"), nil)
+	rec.record(testSyncItem(model.SyncActionDisable, "e"), failed)
+	if got := rec.succeeded(); got != 3 {
+		t.Fatalf("成功数量应为3, 实际为%d", got)
+	}
+	rec.finish(nil)
+
+	var summary map[string]syncCount
+	if err := json.Unmarshal(findSyncRun(t, rec.run.ID).Summary, &summary); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]syncCount{
+		model.SyncActionCreate:  {Success: 1, Failed: 1},
+		model.SyncActionUpdate:  {Success: 2},
+		model.SyncActionDisable: {Failed: 1},
+	}
+	if len(summary) != len(want) {
+		t.Fatalf("汇总应为%v, 实际为%v", want, summary)
+	}
+	for action, count := range want {
+		if summary[action] != count {
+			t.Fatalf("%s的汇总应为%+v, 实际为%+v", action, count, summary[action])
+		}
+	}
+}
+
+func TestSyncRecorderDryRun(t *testing.T) {
+	setupLogicTest(t)
+	// 预演不做记录, 空记录器的方法可以直接调用
+	rec := startSyncRun(nil, testSyncSource(), model.SyncTargetUser, true)
+	if rec != nil {
+		t.Fatal("预演不应创建同步记录")
+	}
+	rec.record(testSyncItem(model.SyncActionCreate, "a"), nil)
+	rec.finish(nil)
+	var count int64
+	common.DB.Model(&model.SyncRun{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("预演不应保存同步记录, 实际保存%d条", count)
+	}
+}
+
+func TestSyncRecorderDonePanic(t *testing.T) {
+	setupLogicTest(t)
+	rec := startSyncRun(nil, testSyncSource(), model.SyncTargetUser, false)
+	apply := func() (data any, rspError any) {
+		defer rec.done(&rspError)
+		rec.record(testSyncItem(model.SyncActionCreate, "a"), nil)
+		panic("nil map")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic应继续向上抛出")
+			}
+		}()
+		_, _ = apply()
+	}()
+
+	run := findSyncRun(t, rec.run.ID)
+	if run.Status != 3 || run.Error == "" || run.EndedAt == nil {
+		t.Fatalf("panic后同步记录应标记为中断, 实际为%d %q", run.Status, run.Error)
+	}
+}
+
+func TestFailStaleSyncRuns(t *testing.T) {
+	setupLogicTest(t)
+	stale := startSyncRun(nil, testSyncSource(), model.SyncTargetUser, false)
+	done := startSyncRun(nil, testSyncSource(), model.SyncTargetDept, false)
+	done.finish(nil)
+
+	count, err := isql.SyncRun.FailStale()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("应有1条中断的同步记录, 实际为%d条", count)
+	}
+	if run := findSyncRun(t, stale.run.ID); run.Status != 4 || run.Error == "" || run.EndedAt == nil {
+		t.Fatalf("执行中的同步记录应标记为失败, 实际为%d %q", run.Status, run.Error)
+	}
+	if run := findSyncRun(t, done.run.ID); run.Status != 2 {
+		t.Fatalf("已结束的同步记录不受影响, 实际状态为%d", run.Status)
+	}
+}
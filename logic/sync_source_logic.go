@@ -0,0 +1,530 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/model/response"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/gin-gonic/gin"
+)
+
+// SyncProvider 身份源, 实现之后通过RegisterSyncProvider注册, 即可使用统一的同步接口与定时任务
+type SyncProvider interface {
+	// Flag 来源标识, 同时作为分组与用户的Source以及字段关联的前缀
+	Flag() string
+	// Name 来源名称, 用于日志与错误信息
+	Name() string
+	// Config 同步相关的配置
+	Config() *SyncConfig
+	// Schema 来源的连接配置项
+	Schema() []*model.SyncSourceField
+	// GetDepts 获取来源中的全部部门
+	GetDepts() ([]map[string]any, error)
+	// EachUser 依次获取来源中的在职用户, fn返回错误时停止获取
+	EachUser(fn func(user map[string]any) error) error
+	// GetLeavers 找出平台中仍在职、来源中已离职的用户, usernames为本次获取到的在职用户名
+	GetLeavers(usernames map[string]struct{}) ([]*model.User, error)
+}
+
+// SyncConverter 不使用字段关联, 自行将来源数据转换为分组与用户的身份源
+type SyncConverter interface {
+	ConvertDepts(depts []map[string]any) ([]*model.Group, error)
+	// ConvertUser 返回nil时跳过该用户
+	ConvertUser(user map[string]any) (*model.User, error)
+}
+
+// SyncWriter 自行写入分组与用户的身份源, 未实现时同时写入平台与ldap
+type SyncWriter interface {
+	AddGroup(group *model.Group) error
+	AddUser(user *model.User) error
+}
+
+// SyncConfig 身份源的同步配置
+type SyncConfig struct {
+	RootDeptId    string // 来源根部门在平台中的sourceDeptId
+	RootDeptName  string // 根部门不存在时以该名称创建ou, 为空时根部门需要预先创建
+	EnableSync    bool   // 是否开启定时同步
+	DeptSyncTime  string // 定时同步部门的cron表达式
+	UserSyncTime  string // 定时同步用户的cron表达式
+	IsUpdateSyncd bool   // 是否更新、移动已同步的分组与用户
+	IsDeleteSyncd bool   // 是否删除来源中已不存在的分组
+	DryRun        bool   // 定时同步时是否只预演
+}
+
+var syncProviders []SyncProvider
+
+// RegisterSyncProvider 注册身份源
+func RegisterSyncProvider(p SyncProvider) {
+	syncProviders = append(syncProviders, p)
+}
+
+// syncProviderOf 根据来源标识获取身份源, 标识来自配置, 需要在使用时比较
+func syncProviderOf(flag string) SyncProvider {
+	for _, p := range syncProviders {
+		if p.Flag() == flag {
+			return p
+		}
+	}
+	return nil
+}
+
+// newSyncSource 根据身份源生成同步计划的来源配置
+func newSyncSource(p SyncProvider) *syncSource {
+	cfg := p.Config()
+	src := &syncSource{
+		Flag:          p.Flag(),
+		Name:          p.Name(),
+		RootDeptId:    cfg.RootDeptId,
+		IsUpdateSyncd: cfg.IsUpdateSyncd,
+		IsDeleteSyncd: cfg.IsDeleteSyncd,
+	}
+	if w, ok := p.(SyncWriter); ok {
+		src.AddGroup = w.AddGroup
+		src.AddUser = w.AddUser
+	} else {
+		src.AddGroup = func(group *model.Group) error { return addSyncGroup(src, group) }
+		src.AddUser = func(user *model.User) error { return addSyncUser(src, user) }
+	}
+	return src
+}
+
+// syncDepts 从身份源同步部门
+func syncDepts(c *gin.Context, p SyncProvider, dryRun bool) (data any, rspError any) {
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+	src := newSyncSource(p)
+	rec := startSyncRun(c, src, model.SyncTargetDept, dryRun)
+	defer rec.done(&rspError)
+
+	// 1.获取所有部门
+	deptSource, err := p.GetDepts()
+	if err != nil {
+		errMsg := fmt.Sprintf("获取%s部门列表失败：%s", src.Name, err.Error())
+		common.Log.Errorf("syncDepts: %s", errMsg)
+		return nil, tools.NewOperationError(errors.New(errMsg))
+	}
+	var depts []*model.Group
+	if cv, ok := p.(SyncConverter); ok {
+		depts, err = cv.ConvertDepts(deptSource)
+	} else {
+		depts, err = ConvertDeptData(src.Flag, deptSource)
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("转换%s部门数据失败：%s", src.Name, err.Error())
+		common.Log.Errorf("syncDepts: %s", errMsg)
+		return nil, tools.NewOperationError(errors.New(errMsg))
+	}
+	if len(depts) == 0 {
+		errMsg := "获取到的部门数量为0"
+		common.Log.Errorf("syncDepts: %s%s", src.Name, errMsg)
+		return nil, tools.NewOperationError(errors.New(errMsg))
+	}
+
+	// 2.将远程数据转换成树, 根部门不存在时预演只将其作为计划中的第一项, 不实际创建
+	planner, err := newSyncPlanner(src)
+	if err != nil {
+		common.Log.Errorf("syncDepts: 生成%s部门同步计划失败：%s", src.Name, err.Error())
+		return nil, err
+	}
+	if dryRun {
+		planner.planRoot(syncRootGroup(p))
+	} else if err = ensureSyncRoot(p); err != nil {
+		common.Log.Errorf("syncDepts: 创建%s根部门失败：%s", src.Name, err.Error())
+		return nil, err
+	}
+	deptTree := GroupListToTree(src.RootDeptId, depts)
+
+	// 3.对比平台中的分组生成同步计划
+	err = planner.planDepts(deptTree.Children)
+	if err != nil {
+		common.Log.Errorf("syncDepts: 生成%s部门同步计划失败：%s", src.Name, err.Error())
+		return nil, err
+	}
+
+	// 4.预演时只返回计划, 否则按计划创建
+	data, err = planner.run(c, rec, model.SyncTargetDept, dryRun)
+	if err != nil {
+		common.Log.Errorf("syncDepts: 同步%s部门失败：%s", src.Name, err.Error())
+		return nil, err
+	}
+
+	common.Log.Infof("syncDepts: %s部门同步成功", src.Name)
+	return data, nil
+}
+
+// syncUsers 从身份源同步用户, 用户所在的部门需要先同步
+func syncUsers(c *gin.Context, p SyncProvider, dryRun bool) (data any, rspError any) {
+	if rspError := checkSyncScope(c); rspError != nil {
+		return nil, rspError
+	}
+	src := newSyncSource(p)
+	rec := startSyncRun(c, src, model.SyncTargetUser, dryRun)
+	defer rec.done(&rspError)
+
+	planner, err := newSyncPlanner(src)
+	if err != nil {
+		common.Log.Errorf("syncUsers: 生成%s用户同步计划失败：%s", src.Name, err.Error())
+		return nil, err
+	}
+
+	// 1.依次获取来源用户, 每获取到一个用户就与平台中的用户对比
+	cv, _ := p.(SyncConverter)
+	usernames := make(map[string]struct{})
+	var planErr any
+	err = p.EachUser(func(staff map[string]any) error {
+		var user *model.User
+		var err error
+		if cv != nil {
+			user, err = cv.ConvertUser(staff)
+		} else {
+			var users []*model.User
+			users, err = ConvertUserData(src.Flag, []map[string]any{staff})
+			if len(users) > 0 {
+				user = users[0]
+			}
+		}
+		if err != nil {
+			errMsg := fmt.Sprintf("转换%s用户数据失败：%s", src.Name, err.Error())
+			common.Log.Errorf("syncUsers: %s", errMsg)
+			planErr = tools.NewOperationError(errors.New(errMsg))
+			return err
+		}
+		if user == nil {
+			return nil
+		}
+		usernames[user.Username] = struct{}{}
+		err = planner.planUser(user)
+		if err != nil {
+			errMsg := fmt.Sprintf("对比用户[%s]失败：%s", user.Username, err.Error())
+			common.Log.Errorf("syncUsers: %s", errMsg)
+			planErr = tools.NewOperationError(errors.New(errMsg))
+			return err
+		}
+		return nil
+	})
+	if planErr != nil {
+		return nil, planErr
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("获取%s用户列表失败：%s", src.Name, err.Error())
+		common.Log.Errorf("syncUsers: %s", errMsg)
+		return nil, tools.NewOperationError(errors.New(errMsg))
+	}
+	if len(usernames) == 0 {
+		errMsg := "获取到的用户数量为0"
+		common.Log.Errorf("syncUsers: %s%s", src.Name, errMsg)
+		return nil, tools.NewOperationError(errors.New(errMsg))
+	}
+
+	// 2.找出来源中已离职的用户
+	leavers, err := p.GetLeavers(usernames)
+	if err != nil {
+		errMsg := fmt.Sprintf("获取%s离职用户列表失败：%s", src.Name, err.Error())
+		common.Log.Errorf("syncUsers: %s", errMsg)
+		return nil, tools.NewOperationError(errors.New(errMsg))
+	}
+	planner.planLeavers(leavers)
+
+	// 3.预演时只返回计划, 否则按计划写入
+	data, err = planner.run(c, rec, model.SyncTargetUser, dryRun)
+	if err != nil {
+		common.Log.Errorf("syncUsers: 同步%s用户失败：%s", src.Name, err.Error())
+		return nil, err
+	}
+
+	common.Log.Infof("syncUsers: %s用户同步完成，共获取%d个在职用户，%d个离职用户", src.Name, len(usernames), len(leavers))
+	return data, nil
+}
+
+// checkSyncScope 身份源同步的部门与用户都写入默认目录, 需要能够管理默认目录, 定时任务不校验
+func checkSyncScope(c *gin.Context) any {
+	if c == nil {
+		return nil
+	}
+	return checkDirectoryScope(c, 0)
+}
+
+// syncRootGroup 来源的根部门, 未配置根部门名称时返回nil
+func syncRootGroup(p SyncProvider) *model.Group {
+	cfg := p.Config()
+	if cfg.RootDeptName == "" {
+		return nil
+	}
+	return rootGroupOf(p.Flag(), p.Name(), cfg.RootDeptId, cfg.RootDeptName)
+}
+
+// ensureSyncRoot 来源的根部门不存在时在ldap基础dn之下创建ou
+func ensureSyncRoot(p SyncProvider) error {
+	root := syncRootGroup(p)
+	if root == nil {
+		return nil
+	}
+	return addRootGroup(root)
+}
+
+// ensureRootGroup 确保来源的根部门存在, 不存在时在ldap的基础dn下创建
+func ensureRootGroup(flag, name, rootId, rootName string) error {
+	return addRootGroup(rootGroupOf(flag, name, rootId, rootName))
+}
+
+// rootGroupOf 位于ldap基础dn之下的来源根部门
+func rootGroupOf(flag, name, rootId, rootName string) *model.Group {
+	return &model.Group{
+		GroupName:          rootName,
+		Remark:             fmt.Sprintf("%s根部门", name),
+		Creator:            "system",
+		GroupType:          "ou",
+		ParentId:           1,
+		SourceDeptId:       rootId,
+		Source:             flag,
+		SourceDeptParentId: "0",
+		GroupDN:            fmt.Sprintf("ou=%s,%s", rootName, config.Conf.Ldap.BaseDN),
+	}
+}
+
+// addRootGroup 根部门不存在时创建
+func addRootGroup(root *model.Group) error {
+	if isql.Group.Exist(tools.H{"source_dept_id": root.SourceDeptId}) {
+		return nil
+	}
+	err := CommonAddGroup(root)
+	if err != nil {
+		return tools.NewOperationError(fmt.Errorf("添加根部门: %s, 失败: %s", root.GroupName, err.Error()))
+	}
+	return nil
+}
+
+// addSyncGroup 将来源中的部门写入平台与ldap
+func addSyncGroup(src *syncSource, group *model.Group) error {
+	parentGroup := new(model.Group)
+	err := isql.Group.Find(tools.H{"source_dept_id": group.SourceDeptParentId}, parentGroup) // 查询当前分组父ID在MySQL中的数据信息
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("查询父级部门失败：%s", err.Error()))
+	}
+
+	// 此时的 group 已经附带了Build后动态关联好的字段，接下来将一些确定性的其他字段值添加上，就可以创建这个分组了
+	group.Creator = "system"
+	group.GroupType = "cn"
+	group.ParentId = parentGroup.ID
+	group.Source = src.Flag
+	group.GroupDN = fmt.Sprintf("cn=%s,%s", group.GroupName, parentGroup.GroupDN)
+
+	if !isql.Group.Exist(tools.H{"group_dn": group.GroupDN}) { // 判断当前部门是否已落库
+		err = CommonAddGroup(group)
+		if err != nil {
+			return tools.NewOperationError(fmt.Errorf("添加部门: %s, 失败: %s", group.GroupName, err.Error()))
+		}
+	}
+	return nil
+}
+
+// addSyncUser 将来源中的用户写入平台与ldap, 开启同步更新时更新已存在的用户
+func addSyncUser(src *syncSource, user *model.User) error {
+	// 根据角色id获取角色
+	roles, err := isql.Role.GetRolesByIds([]uint{2}) // 默认添加为普通用户角色
+	if err != nil {
+		return tools.NewValidatorError(fmt.Errorf("根据角色ID获取角色信息失败:%s", err.Error()))
+	}
+	user.Roles = roles
+	user.Creator = "system"
+	user.Source = src.Flag
+	user.Password = config.Conf.Ldap.UserInitPassword
+	user.UserDN = common.LdapUserDN(user.Username)
+
+	// 获取用户将要添加的分组
+	groups, err := isql.Group.GetGroupByIds(tools.StringToSlice(user.DepartmentId, ","))
+	if err != nil {
+		return tools.NewMySqlError(fmt.Errorf("%s", "根据部门ID获取部门信息失败"+err.Error()))
+	}
+	var deptTmp string
+	for _, group := range groups {
+		deptTmp = deptTmp + group.GroupName + ","
+	}
+	user.Departments = strings.TrimRight(deptTmp, ",")
+
+	// 根据 user_dn 查询用户,不存在则创建
+	if !isql.User.Exist(tools.H{"user_dn": user.UserDN}) {
+		err = CommonAddUser(user, groups)
+		if err != nil {
+			return tools.NewOperationError(fmt.Errorf("添加用户: %s, 失败: %s", user.Username, err.Error()))
+		}
+		return nil
+	}
+	if !src.IsUpdateSyncd {
+		return nil
+	}
+
+	// 先获取用户信息
+	oldData := new(model.User)
+	err = isql.User.Find(tools.H{"user_dn": user.UserDN}, oldData)
+	if err != nil {
+		return err
+	}
+	user.Model = oldData.Model
+	user.Roles = oldData.Roles
+	user.Creator = oldData.Creator
+	user.Source = oldData.Source
+	user.Password = oldData.Password
+	user.UserDN = oldData.UserDN
+
+	// 用户信息的预置处理
+	if user.Nickname == "" {
+		user.Nickname = oldData.Nickname
+	}
+	if user.GivenName == "" {
+		user.GivenName = user.Nickname
+	}
+	if user.Introduction == "" {
+		user.Introduction = user.Nickname
+	}
+	if user.Mail == "" {
+		user.Mail = oldData.Mail
+	}
+	if user.JobNumber == "" {
+		user.JobNumber = oldData.JobNumber
+	}
+	if user.Departments == "" {
+		user.Departments = oldData.Departments
+	}
+	if user.Position == "" {
+		user.Position = oldData.Position
+	}
+	if user.PostalAddress == "" {
+		user.PostalAddress = oldData.PostalAddress
+	}
+	if user.Mobile == "" {
+		user.Mobile = oldData.Mobile
+	}
+	return CommonUpdateUser(oldData, user, tools.StringToSlice(user.DepartmentId, ","))
+}
+
+// eachSyncUser 一次性获取全部用户的来源, 依次交给fn处理
+func eachSyncUser(getAll func() ([]map[string]any, error), fn func(user map[string]any) error) error {
+	users, err := getAll()
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncLeaversByIds 根据来源中离职用户的编号找出平台中仍在职的用户, field为保存编号的字段
+func syncLeaversByIds(flag, field string, ids []string) ([]*model.User, error) {
+	var leavers []*model.User
+	for _, id := range ids {
+		filter := tools.H{
+			field:    fmt.Sprintf("%s_%s", flag, id),
+			"status": 1, //只处理1在职的
+		}
+		if !isql.User.Exist(filter) {
+			continue
+		}
+		user := new(model.User)
+		err := isql.User.Find(filter, user)
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("在MySQL查询离职用户[%s]失败: %s", id, err.Error()))
+		}
+		leavers = append(leavers, user)
+	}
+	return leavers, nil
+}
+
+// syncLeaversByMissing 来源没有离职用户接口时, 平台中有而来源中没有的在职用户视为离职
+func syncLeaversByMissing(flag string, usernames map[string]struct{}) ([]*model.User, error) {
+	// 来源没有返回任何用户时多半是接口异常, 不能据此禁用全部用户
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	users, err := isql.User.ListAll()
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取MySQL用户列表失败：%s", err.Error()))
+	}
+	var leavers []*model.User
+	for _, user := range users {
+		if user.Source != flag || user.Status != 1 {
+			continue
+		}
+		if _, ok := usernames[user.Username]; !ok {
+			leavers = append(leavers, user)
+		}
+	}
+	return leavers, nil
+}
+
+// syncSourceFields 读取配置项的当前值, 密钥只返回是否已配置
+func syncSourceFields(fields []*model.SyncSourceField, values ...any) []*model.SyncSourceField {
+	for i, field := range fields {
+		if i >= len(values) {
+			break
+		}
+		field.Value = values[i]
+		if field.Secret {
+			if s, _ := values[i].(string); s != "" {
+				field.Value = "******"
+			}
+		}
+	}
+	return fields
+}
+
+// SourceList 已注册的身份源
+func (l SyncLogic) SourceList(c *gin.Context, req any) (data any, rspError any) {
+	_, ok := req.(*request.SyncSourceListReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	_ = c
+
+	sources := make([]*response.SyncSourceRsp, 0, len(syncProviders))
+	for _, p := range syncProviders {
+		cfg := p.Config()
+		sources = append(sources, &response.SyncSourceRsp{
+			Flag:         p.Flag(),
+			Name:         p.Name(),
+			EnableSync:   cfg.EnableSync,
+			DeptSyncTime: cfg.DeptSyncTime,
+			UserSyncTime: cfg.UserSyncTime,
+			DryRun:       cfg.DryRun,
+			Fields:       p.Schema(),
+		})
+	}
+	return sources, nil
+}
+
+// SourceDepts 从指定的身份源同步部门
+func (l SyncLogic) SourceDepts(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncSourceReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	p := syncProviderOf(r.Source)
+	if p == nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("不支持的同步来源：%s", r.Source))
+	}
+	return syncDepts(c, p, r.DryRun)
+}
+
+// SourceUsers 从指定的身份源同步用户
+func (l SyncLogic) SourceUsers(c *gin.Context, req any) (data any, rspError any) {
+	r, ok := req.(*request.SyncSourceReq)
+	if !ok {
+		return nil, ReqAssertErr
+	}
+	p := syncProviderOf(r.Source)
+	if p == nil {
+		return nil, tools.NewValidatorError(fmt.Errorf("不支持的同步来源：%s", r.Source))
+	}
+	return syncUsers(c, p, r.DryRun)
+}
@@ -0,0 +1,210 @@
+package logic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+)
+
+// fakeSyncProvider 内存中的身份源, 通过字段关联转换数据
+type fakeSyncProvider struct {
+	cfg     *SyncConfig
+	depts   []map[string]any
+	users   []map[string]any
+	leavers func(usernames map[string]struct{}) ([]*model.User, error)
+}
+
+func (p *fakeSyncProvider) Flag() string                     { return "fake" }
+func (p *fakeSyncProvider) Name() string                     { return "测试来源" }
+func (p *fakeSyncProvider) Config() *SyncConfig              { return p.cfg }
+func (p *fakeSyncProvider) Schema() []*model.SyncSourceField { return nil }
+func (p *fakeSyncProvider) GetDepts() ([]map[string]any, error) {
+	return p.depts, nil
+}
+func (p *fakeSyncProvider) EachUser(fn func(user map[string]any) error) error {
+	return eachSyncUser(func() ([]map[string]any, error) { return p.users, nil }, fn)
+}
+func (p *fakeSyncProvider) GetLeavers(usernames map[string]struct{}) ([]*model.User, error) {
+	if p.leavers != nil {
+		return p.leavers(usernames)
+	}
+	return syncLeaversByMissing(p.Flag(), usernames)
+}
+
+// fakeConvertProvider 自行转换数据的身份源
+type fakeConvertProvider struct {
+	fakeSyncProvider
+	convertUser func(user map[string]any) (*model.User, error)
+}
+
+func (p *fakeConvertProvider) ConvertDepts(depts []map[string]any) ([]*model.Group, error) {
+	groups := make([]*model.Group, 0, len(depts))
+	for _, dept := range depts {
+		groups = append(groups, &model.Group{
+			GroupName:          dept["name"].(string),
+			SourceDeptId:       dept["id"].(string),
+			SourceDeptParentId: dept["parent"].(string),
+		})
+	}
+	return groups, nil
+}
+
+func (p *fakeConvertProvider) ConvertUser(user map[string]any) (*model.User, error) {
+	return p.convertUser(user)
+}
+
+func testSyncConfig() *SyncConfig {
+	return &SyncConfig{RootDeptId: "fake_root", RootDeptName: "fake", IsUpdateSyncd: true}
+}
+
+// dryRunItems 预演并返回保存的计划明细
+func dryRunItems(t *testing.T, run func() (any, any)) []*model.SyncPlanItem {
+	data, rspError := run()
+	if rspError != nil {
+		t.Fatalf("预演失败: %v", rspError)
+	}
+	plan, ok := data.(*model.SyncPlan)
+	if !ok || plan.ID == 0 {
+		t.Fatalf("预演应保存同步计划, 实际为%#v", data)
+	}
+	var items []*model.SyncPlanItem
+	if err := json.Unmarshal(plan.Items, &items); err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestSyncDeptsDryRun(t *testing.T) {
+	setupLogicTest(t)
+	p := &fakeConvertProvider{fakeSyncProvider: fakeSyncProvider{
+		cfg: testSyncConfig(),
+		depts: []map[string]any{
+			{"id": "fake_d2", "name": "ops", "parent": "fake_d1"},
+			{"id": "fake_d1", "name": "dev", "parent": "fake_root"},
+		},
+	}}
+
+	items := dryRunItems(t, func() (any, any) { return syncDepts(nil, p, true) })
+	want := []string{"ou=fake,dc=eryajf,dc=net", "cn=dev,ou=fake,dc=eryajf,dc=net", "cn=ops,cn=dev,ou=fake,dc=eryajf,dc=net"}
+	if len(items) != len(want) {
+		t.Fatalf("计划应有%d项, 实际为%d项", len(want), len(items))
+	}
+	for i, dn := range want {
+		if items[i].Action != model.SyncActionCreate || items[i].DN != dn {
+			t.Fatalf("第%d项应为新建%s, 实际为%s %s", i+1, dn, items[i].Action, items[i].DN)
+		}
+	}
+	// 预演不会创建根部门
+	var count int64
+	common.DB.Model(&model.Group{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("预演不应写入分组, 实际写入%d个", count)
+	}
+}
+
+func TestSyncUsersFieldRelation(t *testing.T) {
+	setupLogicTest(t)
+	relation := &model.FieldRelation{Flag: "fake_user", Attributes: []byte(`{"username":"userid","nickname":"name","mail":"email"}`)}
+	dev := &model.Group{GroupName: "dev", GroupType: "cn", Source: "fake", SourceDeptId: "fake_d1", GroupDN: "cn=dev,ou=fake,dc=eryajf,dc=net"}
+	leaver := &model.User{Username: "lisi", Mobile: "13800000001", Source: "fake", Status: 1, UserDN: "uid=lisi,ou=people,dc=eryajf,dc=net"}
+	local := &model.User{Username: "admin", Mobile: "13800000002", Source: "platform", Status: 1, UserDN: "uid=admin,ou=people,dc=eryajf,dc=net"}
+	for _, row := range []any{relation, dev, leaver, local} {
+		if err := common.DB.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := &fakeSyncProvider{
+		cfg: testSyncConfig(),
+		users: []map[string]any{
+			{"userid": "zhangsan", "name": "张三", "email": "zhangsan@eryajf.net", "department_ids": []string{"fake_d1"}},
+			// 没有用户名的用户跳过
+			{"name": "无名", "department_ids": []string{"fake_d1"}},
+		},
+	}
+
+	items := dryRunItems(t, func() (any, any) { return syncUsers(nil, p, true) })
+	if len(items) != 2 {
+		t.Fatalf("计划应有2项, 实际为%d项", len(items))
+	}
+	user := items[0].User
+	if items[0].Action != model.SyncActionCreate || user == nil || user.Username != "zhangsan" || user.Nickname != "张三" || user.Mail != "zhangsan@eryajf.net" {
+		t.Fatalf("第1项应为按字段关联转换的新用户, 实际为%s %+v", items[0].Action, user)
+	}
+	if user.DepartmentId != "1" {
+		t.Fatalf("用户部门应转换为平台中的分组编号, 实际为%s", user.DepartmentId)
+	}
+	// 来源中没有的在职用户视为离职, 其他来源的用户不受影响
+	if items[1].Action != model.SyncActionDisable || items[1].Name != "lisi" || items[1].LocalId != leaver.ID {
+		t.Fatalf("第2项应为禁用lisi, 实际为%s %s", items[1].Action, items[1].Name)
+	}
+}
+
+func TestSyncUsersConverter(t *testing.T) {
+	setupLogicTest(t)
+	p := &fakeConvertProvider{
+		fakeSyncProvider: fakeSyncProvider{
+			cfg: testSyncConfig(),
+			users: []map[string]any{
+				{"name": "zhangsan"},
+				{"name": "robot"},
+			},
+			leavers: func(usernames map[string]struct{}) ([]*model.User, error) {
+				if _, ok := usernames["robot"]; ok {
+					t.Fatal("跳过的用户不应计入在职用户")
+				}
+				return nil, nil
+			},
+		},
+		convertUser: func(user map[string]any) (*model.User, error) {
+			// 返回nil时跳过该用户
+			if user["name"] == "robot" {
+				return nil, nil
+			}
+			name := user["name"].(string)
+			return &model.User{Username: name, UserDN: common.LdapUserDN(name)}, nil
+		},
+	}
+
+	items := dryRunItems(t, func() (any, any) { return syncUsers(nil, p, true) })
+	if len(items) != 1 || items[0].Name != "zhangsan" {
+		t.Fatalf("计划应只有zhangsan一项, 实际为%d项", len(items))
+	}
+
+	// 转换失败时中止同步
+	p.convertUser = func(user map[string]any) (*model.User, error) {
+		return nil, errors.New("缺少必填字段")
+	}
+	if _, rspError := syncUsers(nil, p, true); rspError == nil {
+		t.Fatal("转换失败时应返回错误")
+	}
+}
+
+func TestSyncUsersNoneFetched(t *testing.T) {
+	setupLogicTest(t)
+	leaver := &model.User{Username: "lisi", Source: "fake", Status: 1, UserDN: "uid=lisi,ou=people,dc=eryajf,dc=net"}
+	if err := common.DB.Create(leaver).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeConvertProvider{
+		fakeSyncProvider: fakeSyncProvider{cfg: testSyncConfig(), users: []map[string]any{{"name": "robot"}}},
+		convertUser:      func(user map[string]any) (*model.User, error) { return nil, nil },
+	}
+	// 没有获取到任何用户时多半是来源异常, 不能据此禁用全部用户
+	if _, rspError := syncUsers(nil, p, true); rspError == nil {
+		t.Fatal("没有获取到用户时应返回错误")
+	}
+	leavers, err := syncLeaversByMissing("fake", map[string]struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leavers) != 0 {
+		t.Fatalf("没有获取到用户时不应判定离职, 实际为%d个", len(leavers))
+	}
+	var count int64
+	common.DB.Model(&model.SyncPlan{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("失败的预演不应保存计划, 实际保存%d个", count)
+	}
+}
@@ -30,6 +30,12 @@ func (l UserLogic) Add(c *gin.Context, req any) (data any, rspError any) {
 	if isql.User.Exist(tools.H{"username": r.Username}) {
 		return nil, tools.NewValidatorError(fmt.Errorf("用户名已存在,请勿重复添加"))
 	}
+	if !common.LdapDirectoryExist(r.DirectoryId) {
+		return nil, tools.NewValidatorError(fmt.Errorf("LDAP目录不存在"))
+	}
+	if rspError := checkDirectoryScope(c, r.DirectoryId); rspError != nil {
+		return nil, rspError
+	}
 	if isql.User.Exist(tools.H{"mobile": r.Mobile}) {
 		return nil, tools.NewValidatorError(fmt.Errorf("手机号已存在,请勿重复添加"))
 	}
@@ -48,11 +54,20 @@ func (l UserLogic) Add(c *gin.Context, req any) (data any, rspError any) {
 			return nil, tools.NewValidatorError(fmt.Errorf("密码解密失败"))
 		}
 		r.Password = string(decodeData)
-		if len(r.Password) < 6 {
-			return nil, tools.NewValidatorError(fmt.Errorf("密码长度至少为6位"))
+		if data, err := checkPasswordPolicy(&model.User{Username: r.Username, Nickname: r.Nickname}, r.Password); err != nil {
+			return data, err
 		}
 	} else {
 		r.Password = config.Conf.Ldap.UserInitPassword
+		// 初始密码不满足密码策略时改为随机生成
+		if violations := tools.CheckPasswordPolicy(r.Password, r.Username, r.Nickname); len(violations) > 0 {
+			common.Log.Warnf("配置的用户初始密码%s, 用户[%s]改为使用随机密码", violations.Error(), r.Username)
+			passwd, rspErr := randomPolicyPasswd(&model.User{Username: r.Username, Nickname: r.Nickname})
+			if rspErr != nil {
+				return nil, rspErr
+			}
+			r.Password = passwd
+		}
 	}
 
 	// 当前登陆用户角色排序最小值（最高等级角色）以及当前登陆的用户
@@ -103,7 +118,8 @@ func (l UserLogic) Add(c *gin.Context, req any) (data any, rspError any) {
 		DepartmentId:  tools.SliceToString(r.DepartmentId, ","),
 		Source:        r.Source,
 		Roles:         roles,
-		UserDN:        fmt.Sprintf("uid=%s,%s", r.Username, config.Conf.Ldap.UserDN),
+		UserDN:        common.LdapUserDNOf(r.DirectoryId, r.Username),
+		DirectoryID:   r.DirectoryId,
 	}
 
 	if user.Source == "" {
@@ -115,6 +131,9 @@ func (l UserLogic) Add(c *gin.Context, req any) (data any, rspError any) {
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "根据部门ID获取部门信息失败"+err.Error()))
 	}
+	if rspError := checkGroupsDirectory(user.DirectoryID, groups); rspError != nil {
+		return nil, rspError
+	}
 
 	err = CommonAddUser(&user, groups)
 	if err != nil {
@@ -129,7 +148,13 @@ func (l UserLogic) List(c *gin.Context, req any) (data any, rspError any) {
 	if !ok {
 		return nil, ReqAssertErr
 	}
-	_ = c
+
+	// 只返回当前登录用户可管理的目录中的用户
+	scope, err := directoryScope(c)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户可管理的目录失败"))
+	}
+	r.DirectoryIds = scope
 
 	users, err := isql.User.List(r)
 	if err != nil {
@@ -138,6 +163,7 @@ func (l UserLogic) List(c *gin.Context, req any) (data any, rspError any) {
 
 	rets := make([]model.User, 0)
 	for _, user := range users {
+		user.Locked = user.IsLocked()
 		rets = append(rets, *user)
 	}
 	count, err := isql.User.ListCount(r)
@@ -217,6 +243,9 @@ func (l UserLogic) Update(c *gin.Context, req any) (data any, rspError any) {
 	if err != nil {
 		return nil, tools.NewMySqlError(err)
 	}
+	if rspError := checkDirectoryScope(c, oldData.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
 	// 过滤掉前端会选择到的 请选择部门信息 这个选项
 	var (
@@ -253,6 +282,14 @@ func (l UserLogic) Update(c *gin.Context, req any) (data any, rspError any) {
 		Source:        oldData.Source,
 		Roles:         roles,
 		UserDN:        oldData.UserDN,
+		DirectoryID:   oldData.DirectoryID,
+	}
+	groups, err := isql.Group.GetGroupByIds(deptids)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("%s", "根据部门ID获取部门信息失败"+err.Error()))
+	}
+	if rspError := checkGroupsDirectory(user.DirectoryID, groups); rspError != nil {
+		return nil, rspError
 	}
 
 	if err = CommonUpdateUser(oldData, &user, r.DepartmentId); err != nil {
@@ -305,6 +342,21 @@ func (l UserLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "获取用户信息失败: "+err.Error()))
 	}
+	for _, user := range users {
+		if rspError := checkDirectoryScope(c, user.DirectoryID); rspError != nil {
+			return nil, rspError
+		}
+	}
+
+	// 记录用户所在分组, 删除后重新展开嵌套分组成员
+	userGroupIds := make([]uint, 0)
+	for _, user := range users {
+		groups, err := isql.Group.GetUserGroups(user.ID)
+		if err != nil {
+			return nil, tools.NewMySqlError(fmt.Errorf("%s", "获取用户分组失败: "+err.Error()))
+		}
+		userGroupIds = append(userGroupIds, groupIds(groups)...)
+	}
 
 	// 先将用户从ldap中删除
 	for _, user := range users {
@@ -319,6 +371,24 @@ func (l UserLogic) Delete(c *gin.Context, req any) (data any, rspError any) {
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "在MySQL删除用户失败: "+err.Error()))
 	}
+	// 同时清理用户的二次验证信息
+	err = isql.UserMfa.Delete(r.UserIds)
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("%s", "删除用户二次验证信息失败: "+err.Error()))
+	}
+	RevokeUserSessions(r.UserIds...)
+	err = isql.AccessToken.Delete(tools.H{"user_id": r.UserIds})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("%s", "删除用户访问令牌失败: "+err.Error()))
+	}
+	err = isql.SshKey.Delete(tools.H{"user_id": r.UserIds})
+	if err != nil {
+		return nil, tools.NewMySqlError(fmt.Errorf("%s", "删除用户SSH公钥失败: "+err.Error()))
+	}
+	err = materializeNestedGroups(userGroupIds...)
+	if err != nil {
+		return nil, tools.NewLdapError(fmt.Errorf("%s", "展开嵌套分组成员失败: "+err.Error()))
+	}
 
 	return nil, nil
 }
@@ -347,16 +417,15 @@ func (l UserLogic) ChangePwd(c *gin.Context, req any) (data any, rspError any) {
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("获取当前登陆用户失败"))
 	}
-	// 获取用户的真实正确密码
-	// correctPasswd := user.Password
 	// 判断前端请求的密码是否等于真实密码
-	// err = tools.ComparePasswd(correctPasswd, r.OldPassword)
-	// if err != nil {
-	// 	return nil, tools.NewValidatorError(fmt.Errorf("原密码错误"))
-	// }
-	if tools.NewParPasswd(user.Password) != r.OldPassword {
+	err = tools.ComparePasswd(user.Password, r.OldPassword)
+	if err != nil {
 		return nil, tools.NewValidatorError(fmt.Errorf("原密码错误"))
 	}
+	// 校验新密码是否满足密码策略
+	if data, err := checkPasswordPolicy(&user, r.NewPassword); err != nil {
+		return data, err
+	}
 	// ldap更新密码时可以直接指定用户DN和新密码即可更改成功
 	err = ildap.User.ChangePwd(user.UserDN, "", r.NewPassword)
 	if err != nil {
@@ -364,7 +433,7 @@ func (l UserLogic) ChangePwd(c *gin.Context, req any) (data any, rspError any) {
 	}
 
 	// 更新密码
-	err = isql.User.ChangePwd(user.Username, tools.NewGenPasswd(r.NewPassword))
+	err = isql.User.ChangePwd(user.Username, tools.GenPasswd(r.NewPassword))
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "在MySQL更新密码失败: "+err.Error()))
 	}
@@ -391,9 +460,15 @@ func (l UserLogic) ResetPassword(c *gin.Context, req any) (data any, rspError an
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("获取用户信息失败: %s", err.Error()))
 	}
+	if rspError := checkDirectoryScope(c, user.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
-	// 生成随机密码
-	newPassword := tools.GenerateRandomPassword()
+	// 生成符合密码策略的随机密码
+	newPassword, rspErr := randomPolicyPasswd(user)
+	if rspErr != nil {
+		return nil, rspErr
+	}
 
 	// 在LDAP中更新密码
 	err = ildap.User.ChangePwd(user.UserDN, "", newPassword)
@@ -402,10 +477,12 @@ func (l UserLogic) ResetPassword(c *gin.Context, req any) (data any, rspError an
 	}
 
 	// 在MySQL中更新密码
-	err = isql.User.ChangePwd(user.Username, tools.NewGenPasswd(newPassword))
+	err = isql.User.ChangePwd(user.Username, tools.GenPasswd(newPassword))
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("在MySQL更新密码失败: %s", err.Error()))
 	}
+	// 重置密码后用户需使用新密码重新登录
+	RevokeUserSessions(user.ID)
 
 	// 发送密码重置通知邮件
 	if err := tools.SendPasswordResetNotification(user.Username, user.Nickname, user.Mail, newPassword); err != nil {
@@ -432,6 +509,9 @@ func (l UserLogic) ChangeUserStatus(c *gin.Context, req any) (data any, rspError
 	if err != nil {
 		return nil, tools.NewMySqlError(fmt.Errorf("%s", "在MySQL查询用户失败: "+err.Error()))
 	}
+	if rspError := checkDirectoryScope(c, user.DirectoryID); rspError != nil {
+		return nil, rspError
+	}
 
 	if r.Status == 1 && r.Status == user.Status {
 		return nil, tools.NewValidatorError(fmt.Errorf("用户已经是在职状态"))
@@ -451,22 +531,69 @@ func (l UserLogic) ChangeUserStatus(c *gin.Context, req any) (data any, rspError
 		return nil, tools.NewValidatorError(fmt.Errorf("只有管理员才能更改用户状态"))
 	}
 
-	if r.Status == 2 {
-		err = ildap.User.Delete(user.UserDN)
+	if rspError := changeUserStatus(user, r.Status, ""); rspError != nil {
+		return nil, rspError
+	}
+	return nil, nil
+}
+
+// changeUserStatus 修改用户在职状态, 恢复在职时passwd为空则生成随机密码并通过邮件通知用户
+func changeUserStatus(user *model.User, status uint, passwd string) any {
+	var err error
+	if status == 2 {
+		err = ildap.User.Disable(user.UserDN)
 		if err != nil {
-			return nil, tools.NewLdapError(fmt.Errorf("%s", "在LDAP删除用户失败"+err.Error()))
+			return tools.NewLdapError(fmt.Errorf("%s", "在LDAP禁用用户失败"+err.Error()))
+		}
+	} else if common.IsActiveDirectory() {
+		// AD中离职用户只是被禁用, 账号与密码仍然保留, 直接启用即可
+		err = ildap.User.Enable(user.UserDN)
+		if err != nil {
+			return tools.NewLdapError(fmt.Errorf("%s", "在LDAP启用用户失败"+err.Error()))
 		}
 	} else {
-		err = ildap.User.Add(user)
+		// 数据库中只保存了密码哈希，未指定密码时为重新添加到ldap的用户生成新的随机密码
+		newPassword := passwd
+		if newPassword == "" {
+			newPassword = tools.GenerateRandomPassword()
+		}
+		err = ildap.User.Add(user, newPassword)
+		if err != nil {
+			return tools.NewLdapError(fmt.Errorf("%s", "在LDAP添加用户失败"+err.Error()))
+		}
+		err = isql.User.ChangePwd(user.Username, tools.GenPasswd(newPassword))
 		if err != nil {
-			return nil, tools.NewLdapError(fmt.Errorf("%s", "在LDAP添加用户失败"+err.Error()))
+			return tools.NewMySqlError(fmt.Errorf("%s", "在MySQL更新密码失败: "+err.Error()))
+		}
+		if passwd == "" {
+			if err := tools.SendPasswordResetNotification(user.Username, user.Nickname, user.Mail, newPassword); err != nil {
+				common.Log.Warnf("发送密码重置通知邮件失败，用户: %s, 邮箱: %s, 错误: %v", user.Username, user.Mail, err)
+			}
 		}
 	}
-	err = isql.User.ChangeStatus(int(r.ID), int(r.Status))
+	err = isql.User.ChangeStatus(int(user.ID), int(status))
 	if err != nil {
-		return nil, tools.NewMySqlError(fmt.Errorf("%s", "在MySQL更新用户状态失败: "+err.Error()))
+		return tools.NewMySqlError(fmt.Errorf("%s", "在MySQL更新用户状态失败: "+err.Error()))
 	}
-	return nil, nil
+	// 离职用户不在分组展开后的成员中, 恢复在职时重新写入
+	groups, err := isql.Group.GetUserGroups(user.ID)
+	if err == nil {
+		err = materializeNestedGroups(groupIds(groups)...)
+	}
+	if err != nil {
+		return tools.NewLdapError(fmt.Errorf("%s", "展开嵌套分组成员失败: "+err.Error()))
+	}
+	// 离职用户已签发的token立即失效
+	if status == 2 {
+		RevokeUserSessions(user.ID)
+	} else {
+		// 重新添加到ldap的用户条目不包含SSH公钥, 需要重新写入
+		user.Status = status
+		if err := syncSshKeys(user); err != nil {
+			common.Log.Warnf("恢复用户[%s]的SSH公钥到LDAP失败: %v", user.Username, err)
+		}
+	}
+	return nil
 }
 
 // GetUserInfo 获取用户信息
@@ -485,3 +612,30 @@ func (l UserLogic) GetUserInfo(c *gin.Context, req any) (data any, rspError any)
 	}
 	return user, nil
 }
+
+// checkPasswordPolicy 校验密码是否满足密码策略, 不满足时将违反的策略项作为data一并返回
+func checkPasswordPolicy(user *model.User, passwd string) (data any, rspError any) {
+	violations := tools.CheckPasswordPolicy(passwd, user.Username, user.Nickname)
+	if n := tools.GetPasswordPolicy().HistoryCount; n > 0 && user.ID > 0 {
+		// 旧数据可能没有历史记录, 当前密码也一并比较
+		if isql.PasswordHistory.Used(user.ID, passwd, n) ||
+			(user.Password != "" && tools.ComparePasswd(user.Password, passwd) == nil) {
+			violations = append(violations, tools.PasswordViolation{Rule: "history", Message: fmt.Sprintf("不能与最近%d次使用过的密码相同", n)})
+		}
+	}
+	if len(violations) > 0 {
+		return tools.H{"violations": violations}, tools.NewValidatorError(violations)
+	}
+	return nil, nil
+}
+
+// randomPolicyPasswd 为用户生成满足密码策略的随机密码
+func randomPolicyPasswd(user *model.User) (string, any) {
+	for i := 0; i < 10; i++ {
+		passwd := tools.GenerateRandomPassword()
+		if _, err := checkPasswordPolicy(user, passwd); err == nil {
+			return passwd, nil
+		}
+	}
+	return "", tools.NewOperationError(fmt.Errorf("无法生成满足密码策略的随机密码, 请检查密码策略配置"))
+}
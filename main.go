@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -32,6 +33,8 @@ import (
 // @in header
 // @name Authorization
 func main() {
+	migratePasswords := flag.Bool("migrate-passwords", false, "将数据库中旧的可逆加密密码迁移为bcrypt哈希后退出")
+	flag.Parse()
 
 	// 加载配置文件到全局配置结构体
 	config.InitConfig()
@@ -42,9 +45,22 @@ func main() {
 	// 初始化数据库(mysql)
 	common.InitDB()
 
+	// 离线迁移旧密码，完成后直接退出
+	if *migratePasswords {
+		migrated, failed, err := isql.User.MigrateLegacyPasswd()
+		if err != nil {
+			common.Log.Fatalf("迁移用户密码失败: %v", err)
+		}
+		common.Log.Infof("迁移用户密码完成，成功: %d，失败: %d", migrated, failed)
+		return
+	}
+
 	// 初始化ldap连接
 	common.InitLDAP()
 
+	// 初始化其他LDAP目录的连接池
+	common.InitLdapDirectories()
+
 	// 初始化casbin策略管理器
 	common.InitCasbinEnforcer()
 
@@ -54,6 +70,13 @@ func main() {
 	// 初始化mysql数据
 	common.InitData()
 
+	// 上次退出时未结束的同步记录标记为中断
+	if stale, err := isql.SyncRun.FailStale(); err != nil {
+		common.Log.Errorf("更新中断的同步记录失败: %v", err)
+	} else if stale > 0 {
+		common.Log.Warnf("%d条同步记录因服务退出而中断", stale)
+	}
+
 	// 操作日志中间件处理日志时没有将日志发送到rabbitmq或者kafka中, 而是发送到了channel中
 	// 这里开启3个goroutine处理channel将日志记录到数据库
 	for i := 0; i < 3; i++ {
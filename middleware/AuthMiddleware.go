@@ -1,13 +1,17 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/logic"
 	"github.com/eryajf/go-ldap-admin/model"
 	"github.com/eryajf/go-ldap-admin/public/common"
 	"github.com/eryajf/go-ldap-admin/public/tools"
-	"github.com/eryajf/go-ldap-admin/service/isql"
+	"github.com/eryajf/go-ldap-admin/service/ildap"
 
 	"time"
 
@@ -18,6 +22,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// 需要继续输入二次验证码时的响应码
+	mfaRequiredCode = 202
+	// 密码已过期需要先修改密码时的响应码
+	pwdExpiredCode = 203
+)
+
+// jwt中间件实例, 登出和刷新token时用于解析请求中的token
+var jwtMiddleware *jwt.GinJWTMiddleware
+
 // 初始化jwt中间件
 func InitAuth() (*jwt.GinJWTMiddleware, error) {
 	authMiddleware, err := jwt.New(&jwt.GinJWTMiddleware{
@@ -37,9 +51,32 @@ func InitAuth() (*jwt.GinJWTMiddleware, error) {
 		TokenHeadName:   "Bearer",                                              // header名称
 		TimeFunc:        time.Now,
 	})
+	jwtMiddleware = authMiddleware
 	return authMiddleware, err
 }
 
+// AuthMiddleware 认证中间件, 请求携带个人访问令牌时按访问令牌认证, 否则按jwt认证
+func AuthMiddleware(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	jwtHandler := authMiddleware.MiddlewareFunc()
+	return func(c *gin.Context) {
+		token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), authMiddleware.TokenHeadName))
+		if !logic.IsAccessToken(token) {
+			jwtHandler(c)
+			return
+		}
+		user, accessToken, err := logic.AccessTokenAuth(token, c.ClientIP())
+		if err != nil {
+			response.Response(c, http.StatusUnauthorized, http.StatusUnauthorized, nil, err.Error())
+			c.Abort()
+			return
+		}
+		// 与authorizator一致, 将用户保存到context
+		c.Set("user", *user)
+		c.Set("accessToken", accessToken)
+		c.Next()
+	}
+}
+
 // 有效载荷处理
 func payloadFunc(data any) jwt.MapClaims {
 	if v, ok := data.(tools.H); ok {
@@ -49,6 +86,7 @@ func payloadFunc(data any) jwt.MapClaims {
 		return jwt.MapClaims{
 			jwt.IdentityKey: user.ID,
 			"user":          v["user"],
+			"jti":           v["sessionId"],
 		}
 	}
 	return jwt.MapClaims{}
@@ -61,6 +99,7 @@ func identityHandler(c *gin.Context) any {
 	return tools.H{
 		"IdentityKey": claims[jwt.IdentityKey],
 		"user":        claims["user"],
+		"sessionId":   claims["jti"],
 	}
 }
 
@@ -72,31 +111,91 @@ func login(c *gin.Context) (any, error) {
 		return "", err
 	}
 
-	// 密码通过RSA解密
-	decodeData, err := tools.RSADecrypt([]byte(req.Password), config.Conf.System.RSAPrivateBytes)
-	if err != nil {
-		return nil, err
+	// 登录第二步: 校验二次验证码
+	if req.MfaTicket != "" {
+		user, recoveryCodes, err := logic.MfaLogin(req.MfaTicket, req.MfaCode, c.ClientIP())
+		if err != nil {
+			return nil, err
+		}
+		// 首次绑定时返回恢复码, 由loginResponse输出
+		if len(recoveryCodes) > 0 {
+			c.Set("mfaRecoveryCodes", recoveryCodes)
+		}
+		if logic.PwdExpired(user) {
+			c.Set("pwdExpiredChallenge", logic.PwdExpiredChallenge(user))
+			return nil, logic.ErrPwdExpired
+		}
+		return loginData(c, user), nil
 	}
 
-	u := &model.User{
-		Username: req.Username,
-		Password: string(decodeData),
-	}
+	var user *model.User
+	if req.Provider != "" {
+		// 钉钉、飞书、企业微信扫码登录
+		var err error
+		cookieState, _ := c.Cookie(logic.SsoStateCookie)
+		user, err = logic.SsoLogin(req.Provider, req.Code, req.State, cookieState)
+		logic.SetSsoStateCookie(c, "")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if req.Username == "" || req.Password == "" {
+			return nil, jwt.ErrMissingLoginValues
+		}
 
-	// 密码校验
-	user, err := isql.User.Login(u)
-	if err != nil {
-		return nil, err
+		// 密码通过RSA解密
+		decodeData, err := tools.RSADecrypt([]byte(req.Password), config.Conf.System.RSAPrivateBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		// 密码校验，根据配置校验数据库密码或绑定LDAP
+		user, err = logic.CommonLogin(req.Username, string(decodeData), c.ClientIP())
+		if errors.Is(err, ildap.ErrPasswordExpired) {
+			// LDAP提示密码过期, 允许直接修改密码
+			if challenge, ok := logic.LdapPwdExpiredChallenge(req.Username); ok {
+				c.Set("pwdExpiredChallenge", challenge)
+				return nil, logic.ErrPwdExpired
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 开启或被要求开启二次验证的用户, 需继续完成登录第二步
+	if challenge, ok := logic.MfaChallenge(user); ok {
+		c.Set("mfaChallenge", challenge)
+		return nil, logic.ErrMfaRequired
 	}
-	// 将用户以json格式写入, payloadFunc/authorizator会使用到
+	// 密码已过期的用户需先修改密码, 开启二次验证的用户在完成第二步后再检查
+	if req.Provider == "" && logic.PwdExpired(user) {
+		c.Set("pwdExpiredChallenge", logic.PwdExpiredChallenge(user))
+		return nil, logic.ErrPwdExpired
+	}
+	return loginData(c, user), nil
+}
+
+// loginData 登录成功后生成会话ID, 将用户以json格式写入, payloadFunc/authorizator会使用到
+func loginData(c *gin.Context, user *model.User) tools.H {
+	sessionId := logic.NewSessionID()
+	c.Set("loginUser", user)
+	c.Set("sessionId", sessionId)
 	return tools.H{
-		"user": tools.Struct2Json(user),
-	}, nil
+		"user":      tools.Struct2Json(user),
+		"sessionId": sessionId,
+	}
 }
 
 // 用户登录校验成功处理
 func authorizator(data any, c *gin.Context) bool {
 	if v, ok := data.(tools.H); ok {
+		// 会话已被吊销(登出、禁用、删除用户或重置密码)的token不再有效
+		sessionId, _ := v["sessionId"].(string)
+		if !logic.SessionActive(sessionId) {
+			c.Set("sessionRevoked", true)
+			return false
+		}
+		c.Set("sessionId", sessionId)
 		userStr := v["user"].(string)
 		var user model.User
 		// 将用户json转为结构体
@@ -110,27 +209,68 @@ func authorizator(data any, c *gin.Context) bool {
 
 // 用户登录校验失败处理
 func unauthorized(c *gin.Context, code int, message string) {
+	// 密码校验通过, 返回二次验证凭据
+	if challenge, ok := c.Get("mfaChallenge"); ok {
+		response.Response(c, http.StatusOK, mfaRequiredCode, gin.H(challenge.(tools.H)), message)
+		return
+	}
+	// 密码已过期, 返回修改密码的凭据
+	if challenge, ok := c.Get("pwdExpiredChallenge"); ok {
+		response.Response(c, http.StatusOK, pwdExpiredCode, gin.H(challenge.(tools.H)), message)
+		return
+	}
+	if _, ok := c.Get("sessionRevoked"); ok {
+		code, message = http.StatusUnauthorized, "会话已失效，请重新登录"
+	}
 	common.Log.Debugf("JWT认证失败, 错误码: %d, 错误信息: %s", code, message)
 	response.Response(c, code, code, nil, fmt.Sprintf("JWT认证失败, 错误码: %d, 错误信息: %s", code, message))
 }
 
 // 登录成功后的响应
 func loginResponse(c *gin.Context, code int, token string, expires time.Time) {
-	response.Response(c, code, code,
-		gin.H{
-			"token":   token,
-			"expires": expires.Format("2006-01-02 15:04:05"),
-		},
-		"登录成功")
+	user := c.MustGet("loginUser").(*model.User)
+	err := logic.SessionCreate(c.GetString("sessionId"), user, c.ClientIP(), c.Request.UserAgent(), expires)
+	if err != nil {
+		common.Log.Errorf("登记用户[%s]的会话失败: %v", user.Username, err)
+		response.Response(c, http.StatusInternalServerError, http.StatusInternalServerError, nil, "登记会话失败")
+		return
+	}
+	data := gin.H{
+		"token":   token,
+		"expires": expires.Format("2006-01-02 15:04:05"),
+	}
+	// 首次绑定二次验证时返回恢复码, 仅展示这一次
+	if recoveryCodes, ok := c.Get("mfaRecoveryCodes"); ok {
+		data["recoveryCodes"] = recoveryCodes
+	}
+	response.Response(c, code, code, data, "登录成功")
 }
 
 // 登出后的响应
 func logoutResponse(c *gin.Context, code int) {
+	// 吊销当前token对应的会话
+	if claims, err := jwtMiddleware.CheckIfTokenExpire(c); err == nil {
+		if sessionId, ok := claims["jti"].(string); ok {
+			_ = logic.RevokeSession(sessionId)
+		}
+	}
 	response.Success(c, nil, "退出成功")
 }
 
 // 刷新token后的响应
 func refreshResponse(c *gin.Context, code int, token string, expires time.Time) {
+	// 新token沿用原token的会话ID, 会话被吊销后不允许再刷新
+	claims, err := jwtMiddleware.CheckIfTokenExpire(c)
+	if err != nil {
+		response.Response(c, http.StatusUnauthorized, http.StatusUnauthorized, nil, err.Error())
+		return
+	}
+	sessionId, _ := claims["jti"].(string)
+	if !logic.SessionActive(sessionId) {
+		response.Response(c, http.StatusUnauthorized, http.StatusUnauthorized, nil, "会话已失效，请重新登录")
+		return
+	}
+	logic.SessionRefresh(sessionId, expires)
 	response.Response(c, code, code,
 		gin.H{
 			"token":   token,
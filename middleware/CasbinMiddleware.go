@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"strings"
-	"sync"
 
 	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/model"
 	"github.com/eryajf/go-ldap-admin/public/common"
 	"github.com/eryajf/go-ldap-admin/public/tools"
 	"github.com/eryajf/go-ldap-admin/service/isql"
@@ -12,8 +13,6 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var checkLock sync.Mutex
-
 // Casbin中间件, 基于RBAC的权限访问控制模型
 func CasbinMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -41,28 +40,19 @@ func CasbinMiddleware() gin.HandlerFunc {
 		obj := strings.TrimPrefix(c.FullPath(), "/"+config.Conf.System.UrlPathPrefix)
 		// 获取请求方式
 		act := c.Request.Method
-		isPass := check(subs, obj, act)
+		isPass := common.CasbinCheck(subs, obj, act)
 		if !isPass {
 			tools.Response(c, 401, 401, nil, "没有权限")
 			c.Abort()
 			return
 		}
+		// 使用访问令牌时, 还需在令牌授权的接口范围内
+		if token, ok := c.Get("accessToken"); ok && !logic.AccessTokenAllowed(token.(*model.AccessToken), act, obj) {
+			tools.Response(c, 401, 401, nil, "访问令牌没有该接口的权限")
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
 }
-
-func check(subs []string, obj string, act string) bool {
-	// 同一时间只允许一个请求执行校验, 否则可能会校验失败
-	checkLock.Lock()
-	defer checkLock.Unlock()
-	isPass := false
-	for _, sub := range subs {
-		pass, _ := common.CasbinEnforcer.Enforce(sub, obj, act)
-		if pass {
-			isPass = true
-			break
-		}
-	}
-	return isPass
-}
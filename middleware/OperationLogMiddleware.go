@@ -40,6 +40,12 @@ func OperationLogMiddleware() gin.HandlerFunc {
 			username = user.Username
 		}
 
+		// 使用访问令牌时记录令牌名称
+		var tokenName string
+		if token, ok := c.Get("accessToken"); ok {
+			tokenName = token.(*model.AccessToken).Name
+		}
+
 		// 获取访问路径
 		path := strings.TrimPrefix(c.FullPath(), "/"+config.Conf.System.UrlPathPrefix)
 		// 请求方式
@@ -60,6 +66,7 @@ func OperationLogMiddleware() gin.HandlerFunc {
 			StartTime:  fmt.Sprintf("%v", startTime),
 			TimeCost:   timeCost,
 			UserAgent:  c.Request.UserAgent(),
+			TokenName:  tokenName,
 		}
 
 		// 最好是将日志发送到rabbitmq或者kafka中
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccessToken 个人访问令牌, 用于脚本等自动化场景调用接口
+type AccessToken struct {
+	gorm.Model
+	UserID     uint       `gorm:"not null;index;comment:'所属用户id'" json:"userId"`
+	Name       string     `gorm:"type:varchar(50);not null;comment:'令牌名称'" json:"name"`
+	TokenHash  string     `gorm:"type:varchar(64);not null;unique;comment:'令牌哈希(sha256)'" json:"-"`
+	Prefix     string     `gorm:"type:varchar(20);comment:'令牌前缀, 便于识别'" json:"prefix"`
+	Scopes     string     `gorm:"type:text;comment:'可访问的接口, 每行一个 METHOD:PATH'" json:"-"`
+	ExpiresAt  *time.Time `gorm:"comment:'过期时间, 为空表示永不过期'" json:"expiresAt"`
+	LastUsedAt *time.Time `gorm:"comment:'最近使用时间'" json:"lastUsedAt"`
+	LastUsedIp string     `gorm:"type:varchar(64);comment:'最近使用IP'" json:"lastUsedIp"`
+}
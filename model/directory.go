@@ -0,0 +1,19 @@
+package model
+
+import "gorm.io/gorm"
+
+// Directory 除配置文件中默认目录之外的LDAP目录, 每个目录单独维护连接池
+type Directory struct {
+	gorm.Model
+	Name      string `gorm:"type:varchar(50);not null;unique;comment:'目录名称'" json:"name"`
+	Url       string `gorm:"type:varchar(255);not null;comment:'LDAP地址'" json:"url"`
+	BaseDN    string `gorm:"type:varchar(255);not null;comment:'基础DN'" json:"baseDn"`
+	UserDN    string `gorm:"type:varchar(255);not null;comment:'用户所在DN'" json:"userDn"`
+	AdminDN   string `gorm:"type:varchar(255);not null;comment:'管理员DN'" json:"adminDn"`
+	AdminPass string `gorm:"type:text;not null;comment:'管理员密码(RSA加密)'" json:"-"`
+	StartTLS  uint   `gorm:"type:tinyint(1);default:2;comment:'是否开启StartTLS:1是, 2否'" json:"startTls"`
+	CAFile    string `gorm:"type:varchar(255);comment:'CA证书文件路径'" json:"caFile"`
+	MaxConn   int    `gorm:"default:0;comment:'最大连接数, 0表示与默认目录一致'" json:"maxConn"`
+	Remark    string `gorm:"type:varchar(128);comment:'备注'" json:"remark"`
+	Creator   string `gorm:"type:varchar(20);comment:'创建人'" json:"creator"`
+}
@@ -17,8 +17,11 @@ type Group struct {
 	SourceDeptParentId string   `gorm:"type:varchar(100);comment:'父部门编号'" json:"sourceDeptParentId"`
 	SourceUserNum      int      `gorm:"default:0;comment:'部门下的用户数量，从第三方获取的数据'" json:"source_user_num"`
 	Children           []*Group `gorm:"-" json:"children"`
-	GroupDN            string   `gorm:"type:varchar(255);not null;comment:'分组dn'" json:"groupDn"`             // 分组在ldap的dn
-	SyncState          uint     `gorm:"type:tinyint(1);default:1;comment:'同步状态:1已同步, 2未同步'" json:"syncState"` // 数据到ldap的同步状态
+	GroupDN            string   `gorm:"type:varchar(255);not null;comment:'分组dn'" json:"groupDn"`                                    // 分组在ldap的dn
+	SyncState          uint     `gorm:"type:tinyint(1);default:1;comment:'同步状态:1已同步, 2未同步'" json:"syncState"`                        // 数据到ldap的同步状态
+	GidNumber          uint     `gorm:"default:0;comment:'POSIX gidNumber'" json:"gidNumber"`                                        // POSIX gidNumber，0表示未分配
+	GroupSchema        string   `gorm:"type:varchar(30);comment:'分组objectClass：groupOfUniqueNames、groupOfNames'" json:"groupSchema"` // 为空表示groupOfUniqueNames
+	DirectoryID        uint     `gorm:"default:0;index;comment:'所属LDAP目录编号(0为配置文件中的默认目录)'" json:"directoryId"`                       // 所属LDAP目录
 }
 
 func (g *Group) SetGroupName(groupName string) {
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// GroupNesting 分组之间的成员关系, MemberGroupID作为成员加入GroupID
+type GroupNesting struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	GroupID       uint      `gorm:"not null;uniqueIndex:idx_group_nesting;comment:'分组id'" json:"groupId"`
+	MemberGroupID uint      `gorm:"not null;uniqueIndex:idx_group_nesting;index;comment:'作为成员的分组id'" json:"memberGroupId"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
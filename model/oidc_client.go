@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+// OidcClient 接入内置OIDC身份提供者的应用
+type OidcClient struct {
+	gorm.Model
+	Name         string `gorm:"type:varchar(50);not null;comment:'应用名称'" json:"name"`
+	ClientID     string `gorm:"type:varchar(64);not null;unique;comment:'client_id'" json:"clientId"`
+	ClientSecret string `gorm:"type:varchar(255);not null;comment:'client_secret哈希'" json:"-"`
+	RedirectURIs string `gorm:"type:text;comment:'回调地址, 多个以换行分隔'" json:"redirectUris"`
+	Remark       string `gorm:"type:varchar(128);comment:'备注'" json:"remark"`
+	Status       uint   `gorm:"type:tinyint(1);default:1;comment:'状态:1启用, 2禁用'" json:"status"`
+	Creator      string `gorm:"type:varchar(20);comment:'创建人'" json:"creator"`
+}
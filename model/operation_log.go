@@ -16,4 +16,5 @@ type OperationLog struct {
 	StartTime  string `gorm:"type:varchar(2048);comment:'发起时间'" json:"startTime"`
 	TimeCost   int64  `gorm:"type:int(6);comment:'请求耗时(ms)'" json:"timeCost"`
 	UserAgent  string `gorm:"type:varchar(2048);comment:'浏览器标识'" json:"userAgent"`
+	TokenName  string `gorm:"type:varchar(50);comment:'使用的访问令牌名称'" json:"tokenName"`
 }
@@ -0,0 +1,10 @@
+package model
+
+import "gorm.io/gorm"
+
+// PasswordHistory 用户历史密码, 用于校验不能重复使用最近的密码
+type PasswordHistory struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index;comment:'用户id'" json:"userId"`
+	Password string `gorm:"size:255;not null;comment:'密码哈希'" json:"-"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// POSIX编号的类型
+const (
+	PosixIdKindUid = "uid"
+	PosixIdKindGid = "gid"
+)
+
+// PosixId 已分配的uidNumber/gidNumber, 依靠唯一索引保证并发分配时不会重复
+// 用户或分组删除后编号不回收, 避免新账号继承旧账号在主机上遗留文件的属主
+type PosixId struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Kind      string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_posix_kind_number;comment:'编号类型:uid、gid'" json:"kind"`
+	Number    uint      `gorm:"not null;uniqueIndex:idx_posix_kind_number;comment:'编号'" json:"number"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,17 @@
+package request
+
+// AccessTokenListReq 获取访问令牌列表结构体
+type AccessTokenListReq struct {
+}
+
+// AccessTokenAddReq 创建访问令牌结构体
+type AccessTokenAddReq struct {
+	Name       string `json:"name" validate:"required,min=1,max=50"`
+	ApiIds     []uint `json:"apiIds" validate:"required"`
+	ExpireDays uint   `json:"expireDays" validate:"max=3650"` // 有效天数, 0表示永不过期
+}
+
+// AccessTokenDeleteReq 删除访问令牌结构体
+type AccessTokenDeleteReq struct {
+	Ids []uint `json:"ids" validate:"required"`
+}
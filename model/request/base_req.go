@@ -11,6 +11,12 @@ type BaseChangePwdReq struct {
 	Code string `json:"code" validate:"required,len=6"`
 }
 
+// BaseChangeExpiredPwdReq 登录时修改已过期密码的结构体
+type BaseChangeExpiredPwdReq struct {
+	PwdTicket   string `json:"pwdTicket" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
 // BaseDashboardReq  系统首页展示数据结构体
 type BaseDashboardReq struct {
 }
@@ -20,11 +26,6 @@ type EncryptPasswdReq struct {
 	Passwd string `json:"passwd" form:"passwd" validate:"required"`
 }
 
-// DecryptPasswdReq
-type DecryptPasswdReq struct {
-	Passwd string `json:"passwd" form:"passwd" validate:"required"`
-}
-
 // BaseConfigReq 获取系统配置结构体
 type BaseConfigReq struct {
 }
@@ -32,3 +33,17 @@ type BaseConfigReq struct {
 // BaseVersionReq 获取版本信息结构体
 type BaseVersionReq struct {
 }
+
+// BaseLdapPoolStatsReq 获取LDAP连接池统计结构体
+type BaseLdapPoolStatsReq struct {
+	DirectoryId uint `json:"directoryId" form:"directoryId"` // 0为默认目录
+}
+
+// SsoProvidersReq 获取已开启的扫码登录方式结构体
+type SsoProvidersReq struct {
+}
+
+// SsoAuthorizeReq 获取第三方登录授权地址结构体
+type SsoAuthorizeReq struct {
+	Provider string `json:"provider" form:"provider" validate:"required,oneof=dingtalk feishu wecom"`
+}
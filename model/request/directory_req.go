@@ -0,0 +1,42 @@
+package request
+
+// DirectoryListReq 获取LDAP目录列表结构体
+type DirectoryListReq struct {
+	Name     string `json:"name" form:"name"`
+	PageNum  int    `json:"pageNum" form:"pageNum"`
+	PageSize int    `json:"pageSize" form:"pageSize"`
+}
+
+// DirectoryAddReq 添加LDAP目录结构体
+type DirectoryAddReq struct {
+	Name      string `json:"name" validate:"required,min=1,max=50"`
+	Url       string `json:"url" validate:"required,min=1,max=255"`
+	BaseDN    string `json:"baseDn" validate:"required,min=1,max=255"`
+	UserDN    string `json:"userDn" validate:"required,min=1,max=255"`
+	AdminDN   string `json:"adminDn" validate:"required,min=1,max=255"`
+	AdminPass string `json:"adminPass" validate:"required"` // RSA加密后的管理员密码
+	StartTLS  uint   `json:"startTls" validate:"omitempty,oneof=1 2"`
+	CAFile    string `json:"caFile" validate:"min=0,max=255"`
+	MaxConn   int    `json:"maxConn" validate:"gte=0"`
+	Remark    string `json:"remark" validate:"min=0,max=128"`
+}
+
+// DirectoryUpdateReq 更新LDAP目录结构体
+type DirectoryUpdateReq struct {
+	ID        uint   `json:"id" validate:"required"`
+	Name      string `json:"name" validate:"required,min=1,max=50"`
+	Url       string `json:"url" validate:"required,min=1,max=255"`
+	BaseDN    string `json:"baseDn" validate:"required,min=1,max=255"`
+	UserDN    string `json:"userDn" validate:"required,min=1,max=255"`
+	AdminDN   string `json:"adminDn" validate:"required,min=1,max=255"`
+	AdminPass string `json:"adminPass"` // RSA加密后的管理员密码, 为空时不修改
+	StartTLS  uint   `json:"startTls" validate:"omitempty,oneof=1 2"`
+	CAFile    string `json:"caFile" validate:"min=0,max=255"`
+	MaxConn   int    `json:"maxConn" validate:"gte=0"`
+	Remark    string `json:"remark" validate:"min=0,max=128"`
+}
+
+// DirectoryDeleteReq 删除LDAP目录结构体
+type DirectoryDeleteReq struct {
+	DirectoryIds []uint `json:"directoryIds" validate:"required"`
+}
@@ -7,6 +7,10 @@ type GroupListReq struct {
 	PageNum   int    `json:"pageNum" form:"pageNum"`
 	PageSize  int    `json:"pageSize" form:"pageSize"`
 	SyncState uint   `json:"syncState" form:"syncState"`
+	// 所属LDAP目录
+	DirectoryId *uint `json:"directoryId" form:"directoryId"`
+	// 当前登录用户可管理的目录, 由服务端填充, nil表示不限制
+	DirectoryIds []uint `json:"-" form:"-"`
 }
 
 // GroupListAllReq 获取资源列表结构体，不分页
@@ -26,6 +30,10 @@ type GroupAddReq struct {
 	//父级Id 大于等于0 必填
 	ParentId uint   `json:"parentId" validate:"omitempty,min=0"`
 	Remark   string `json:"remark" validate:"min=0,max=128"` // 分组的中文描述
+	// 分组的objectClass, 为空时使用配置文件中的group-schema
+	GroupSchema string `json:"groupSchema" validate:"omitempty,oneof=groupOfUniqueNames groupOfNames"`
+	// 所属LDAP目录, 0为默认目录, 有父级分组时与父级分组一致
+	DirectoryId uint `json:"directoryId"`
 }
 
 // DingTalkGroupAddReq 添加钉钉资源结构体
@@ -98,21 +106,59 @@ type UserNoInGroupReq struct {
 
 // SyncDingTalkDeptsReq 同步钉钉部门信息
 type SyncDingTalkDeptsReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // SyncWeComDeptsReq 同步企业微信部门信息
 type SyncWeComDeptsReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // SyncFeiShuDeptsReq 同步飞书部门信息
 type SyncFeiShuDeptsReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // SyncOpenLdapDeptsReq 同步原ldap部门信息
 type SyncOpenLdapDeptsReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // SyncOpenLdapDeptsReq 同步原ldap部门信息
 type SyncSqlGrooupsReq struct {
 	GroupIds []uint `json:"groupIds" validate:"required"`
+	DryRun   bool   `json:"dryRun"` // 只生成同步计划, 不执行
+}
+
+// GroupPosixBackfillReq 回填分组gidNumber结构体, 不传分组ID时回填全部未分配的分组
+type GroupPosixBackfillReq struct {
+	GroupIds []uint `json:"groupIds"`
+}
+
+// GroupConvertSchemaReq 转换分组objectClass结构体
+type GroupConvertSchemaReq struct {
+	GroupIds    []uint `json:"groupIds" validate:"required"`
+	GroupSchema string `json:"groupSchema" validate:"required,oneof=groupOfUniqueNames groupOfNames"`
+}
+
+// GroupAddGroupReq 添加成员分组结构体
+type GroupAddGroupReq struct {
+	GroupID        uint   `json:"groupId" validate:"required"`
+	MemberGroupIds []uint `json:"memberGroupIds" validate:"required"`
+}
+
+// GroupRemoveGroupReq 移除成员分组结构体
+type GroupRemoveGroupReq struct {
+	GroupID        uint   `json:"groupId" validate:"required"`
+	MemberGroupIds []uint `json:"memberGroupIds" validate:"required"`
+}
+
+// GroupEffectiveMembersReq 获取分组有效成员结构体
+type GroupEffectiveMembersReq struct {
+	GroupID uint `json:"groupId" form:"groupId" validate:"required"`
+}
+
+// GroupSyncNestedMembersReq 重新写入分组成员结构体, 不传分组ID时处理全部包含成员分组的分组
+type GroupSyncNestedMembersReq struct {
+	GroupIds []uint `json:"groupIds"`
 }
@@ -0,0 +1,29 @@
+package request
+
+// BaseMfaSetupReq 登录过程中生成二次验证密钥结构体
+type BaseMfaSetupReq struct {
+	MfaTicket string `json:"mfaTicket" validate:"required"`
+}
+
+// MfaInfoReq 获取二次验证状态结构体
+type MfaInfoReq struct {
+}
+
+// MfaSetupReq 生成二次验证密钥结构体
+type MfaSetupReq struct {
+}
+
+// MfaEnableReq 启用二次验证结构体
+type MfaEnableReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MfaDisableReq 关闭二次验证结构体
+type MfaDisableReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MfaResetReq 重置用户二次验证结构体
+type MfaResetReq struct {
+	UserIds []uint `json:"userIds" validate:"required"`
+}
@@ -0,0 +1,63 @@
+package request
+
+// OidcClientListReq 获取OIDC应用列表结构体
+type OidcClientListReq struct {
+	Name     string `json:"name" form:"name"`
+	ClientID string `json:"clientId" form:"clientId"`
+	PageNum  int    `json:"pageNum" form:"pageNum"`
+	PageSize int    `json:"pageSize" form:"pageSize"`
+}
+
+// OidcClientAddReq 添加OIDC应用结构体
+type OidcClientAddReq struct {
+	Name         string   `json:"name" validate:"required,min=1,max=50"`
+	RedirectURIs []string `json:"redirectUris" validate:"required,min=1,dive,url"`
+	Remark       string   `json:"remark" validate:"min=0,max=128"`
+	Status       uint     `json:"status" validate:"oneof=1 2"`
+}
+
+// OidcClientUpdateReq 更新OIDC应用结构体
+type OidcClientUpdateReq struct {
+	ID           uint     `json:"id" validate:"required"`
+	Name         string   `json:"name" validate:"required,min=1,max=50"`
+	RedirectURIs []string `json:"redirectUris" validate:"required,min=1,dive,url"`
+	Remark       string   `json:"remark" validate:"min=0,max=128"`
+	Status       uint     `json:"status" validate:"oneof=1 2"`
+}
+
+// OidcClientDeleteReq 删除OIDC应用结构体
+type OidcClientDeleteReq struct {
+	ClientIds []uint `json:"clientIds" validate:"required"`
+}
+
+// OidcClientResetSecretReq 重置OIDC应用密钥结构体
+type OidcClientResetSecretReq struct {
+	ID uint `json:"id" validate:"required"`
+}
+
+// OidcAuthorizeReq 授权端点请求参数
+type OidcAuthorizeReq struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	// 以下为授权页登录表单提交的字段
+	Username  string `form:"username"`
+	Password  string `form:"password"`
+	MfaTicket string `form:"mfa_ticket"`
+	MfaCode   string `form:"mfa_code"`
+}
+
+// OidcTokenReq 令牌端点请求参数
+type OidcTokenReq struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+}
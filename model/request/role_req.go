@@ -2,11 +2,14 @@ package request
 
 // RoleAddReq 添加资源结构体
 type RoleAddReq struct {
-	Name    string `json:"name" validate:"required,min=1,max=20"`
-	Keyword string `json:"keyword" validate:"required,min=1,max=20"`
-	Remark  string `json:"remark" validate:"min=0,max=100"`
-	Status  uint   `json:"status" validate:"oneof=1 2"`
-	Sort    uint   `json:"sort" validate:"gte=1,lte=999"`
+	Name        string `json:"name" validate:"required,min=1,max=20"`
+	Keyword     string `json:"keyword" validate:"required,min=1,max=20"`
+	Remark      string `json:"remark" validate:"min=0,max=100"`
+	Status      uint   `json:"status" validate:"oneof=1 2"`
+	Sort        uint   `json:"sort" validate:"gte=1,lte=999"`
+	MfaRequired uint   `json:"mfaRequired" validate:"omitempty,oneof=1 2"`
+	// 可管理的LDAP目录, 为空表示不限制
+	DirectoryIds []uint `json:"directoryIds"`
 }
 
 // RoleListReq 列表结构体
@@ -20,12 +23,15 @@ type RoleListReq struct {
 
 // RoleUpdateReq 更新资源结构体
 type RoleUpdateReq struct {
-	ID      uint   `json:"id" validate:"required"`
-	Name    string `json:"name" validate:"required,min=1,max=20"`
-	Keyword string `json:"keyword" validate:"required,min=1,max=20"`
-	Remark  string `json:"remark" validate:"min=0,max=100"`
-	Status  uint   `json:"status" validate:"oneof=1 2"`
-	Sort    uint   `json:"sort" validate:"gte=1,lte=999"`
+	ID          uint   `json:"id" validate:"required"`
+	Name        string `json:"name" validate:"required,min=1,max=20"`
+	Keyword     string `json:"keyword" validate:"required,min=1,max=20"`
+	Remark      string `json:"remark" validate:"min=0,max=100"`
+	Status      uint   `json:"status" validate:"oneof=1 2"`
+	Sort        uint   `json:"sort" validate:"gte=1,lte=999"`
+	MfaRequired uint   `json:"mfaRequired" validate:"omitempty,oneof=1 2"`
+	// 可管理的LDAP目录, 为空表示不限制
+	DirectoryIds []uint `json:"directoryIds"`
 }
 
 // RoleDeleteReq 删除资源结构体
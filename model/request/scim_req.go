@@ -0,0 +1,48 @@
+package request
+
+import "github.com/eryajf/go-ldap-admin/model"
+
+// ScimListReq 查询SCIM资源列表结构体, startIndex从1开始
+type ScimListReq struct {
+	Filter     string `json:"filter" form:"filter"`
+	StartIndex int    `json:"startIndex" form:"startIndex"`
+	Count      int    `json:"count" form:"count"`
+	// 不需要返回的属性, 多个用逗号分隔, 如分组成员较多时可以排除members
+	ExcludedAttributes string `json:"excludedAttributes" form:"excludedAttributes"`
+}
+
+// ScimInfoReq 获取单个SCIM资源结构体
+type ScimInfoReq struct {
+	ID string `json:"id" uri:"id"`
+}
+
+// ScimUserReq 创建或替换SCIM用户结构体, 创建时ID为空
+type ScimUserReq struct {
+	ID   string `json:"-" uri:"id"`
+	User model.ScimUser
+}
+
+// ScimGroupReq 创建或替换SCIM分组结构体, 创建时ID为空
+type ScimGroupReq struct {
+	ID    string `json:"-" uri:"id"`
+	Group model.ScimGroup
+}
+
+// ScimPatchReq 修改SCIM资源结构体
+type ScimPatchReq struct {
+	ID         string               `json:"-" uri:"id"`
+	Schemas    []string             `json:"schemas"`
+	Operations []*model.ScimPatchOp `json:"Operations"`
+}
+
+// ScimDeleteReq 删除SCIM资源结构体
+type ScimDeleteReq struct {
+	ID string `json:"id" uri:"id"`
+}
+
+// ScimBulkReq SCIM批量操作结构体
+type ScimBulkReq struct {
+	Schemas      []string                   `json:"schemas"`
+	FailOnErrors int                        `json:"failOnErrors"` // 失败达到该数量后停止执行, 0表示不停止
+	Operations   []*model.ScimBulkOperation `json:"Operations"`
+}
@@ -0,0 +1,20 @@
+package request
+
+// SessionMineReq 获取当前用户会话列表结构体
+type SessionMineReq struct {
+}
+
+// SessionListReq 获取指定用户会话列表结构体
+type SessionListReq struct {
+	UserID uint `json:"userId" form:"userId" validate:"required"`
+}
+
+// SessionRevokeReq 吊销会话结构体
+type SessionRevokeReq struct {
+	Ids []uint `json:"ids" validate:"required"`
+}
+
+// SessionRevokeUserReq 吊销用户全部会话结构体
+type SessionRevokeUserReq struct {
+	UserIds []uint `json:"userIds" validate:"required"`
+}
@@ -0,0 +1,30 @@
+package request
+
+// SshKeyMineReq 获取当前用户SSH公钥列表结构体
+type SshKeyMineReq struct {
+}
+
+// SshKeyListReq 获取指定用户SSH公钥列表结构体
+type SshKeyListReq struct {
+	UserID uint `json:"userId" form:"userId" validate:"required"`
+}
+
+// SshKeyAddMineReq 当前用户添加SSH公钥结构体
+type SshKeyAddMineReq struct {
+	Title      string `json:"title" validate:"max=100"`
+	Key        string `json:"key" validate:"required"`
+	ExpireDays uint   `json:"expireDays" validate:"max=3650"` // 有效天数, 0表示永不过期
+}
+
+// SshKeyAddReq 管理员为用户添加SSH公钥结构体
+type SshKeyAddReq struct {
+	UserID     uint   `json:"userId" validate:"required"`
+	Title      string `json:"title" validate:"max=100"`
+	Key        string `json:"key" validate:"required"`
+	ExpireDays uint   `json:"expireDays" validate:"max=3650"` // 有效天数, 0表示永不过期
+}
+
+// SshKeyDeleteReq 删除SSH公钥结构体
+type SshKeyDeleteReq struct {
+	Ids []uint `json:"ids" validate:"required"`
+}
@@ -0,0 +1,53 @@
+package request
+
+// SyncPlanListReq 获取同步计划列表结构体
+type SyncPlanListReq struct {
+	Source   string `json:"source" form:"source"`
+	Target   string `json:"target" form:"target"`
+	Status   uint   `json:"status" form:"status"`
+	PageNum  int    `json:"pageNum" form:"pageNum"`
+	PageSize int    `json:"pageSize" form:"pageSize"`
+}
+
+// SyncPlanInfoReq 获取同步计划详情结构体
+type SyncPlanInfoReq struct {
+	ID uint `json:"id" form:"id" validate:"required"`
+}
+
+// SyncPlanApplyReq 执行同步计划结构体
+type SyncPlanApplyReq struct {
+	ID uint `json:"id" validate:"required"`
+}
+
+// SyncPlanDeleteReq 删除同步计划结构体
+type SyncPlanDeleteReq struct {
+	PlanIds []uint `json:"planIds" validate:"required"`
+}
+
+// SyncRunListReq 获取同步记录列表结构体
+type SyncRunListReq struct {
+	Source   string `json:"source" form:"source"`
+	Target   string `json:"target" form:"target"`
+	Trigger  string `json:"trigger" form:"trigger"`
+	Status   uint   `json:"status" form:"status"`
+	PageNum  int    `json:"pageNum" form:"pageNum"`
+	PageSize int    `json:"pageSize" form:"pageSize"`
+}
+
+// SyncRunItemsReq 获取同步记录单项结果结构体
+type SyncRunItemsReq struct {
+	RunID    uint `json:"runId" form:"runId" validate:"required"`
+	Status   uint `json:"status" form:"status"`
+	PageNum  int  `json:"pageNum" form:"pageNum"`
+	PageSize int  `json:"pageSize" form:"pageSize"`
+}
+
+// SyncSourceListReq 获取身份源列表结构体
+type SyncSourceListReq struct {
+}
+
+// SyncSourceReq 从身份源同步部门或用户结构体
+type SyncSourceReq struct {
+	Source string `json:"source" validate:"required"`
+	DryRun bool   `json:"dryRun"`
+}
@@ -18,6 +18,7 @@ type UserAddReq struct {
 	DepartmentId  []uint `json:"departmentId" validate:"required"`
 	Source        string `json:"source" validate:"min=0,max=50"`
 	RoleIds       []uint `json:"roleIds" validate:"required"`
+	DirectoryId   uint   `json:"directoryId"` // 所属LDAP目录, 0为默认目录
 }
 
 // DingUserAddReq 钉钉用户创建资源结构体
@@ -105,27 +106,47 @@ type UserChangeUserStatusReq struct {
 	Status uint `json:"status" validate:"oneof=1 2"`
 }
 
+// UserUnlockReq 解除用户登录锁定结构体
+type UserUnlockReq struct {
+	UserIds []uint `json:"userIds" validate:"required"`
+}
+
+// UserPosixBackfillReq 回填用户uidNumber结构体, 不传用户ID时回填全部未分配的用户
+type UserPosixBackfillReq struct {
+	UserIds []uint `json:"userIds"`
+}
+
+// UserEffectiveGroupsReq 获取用户有效分组结构体
+type UserEffectiveGroupsReq struct {
+	UserID uint `json:"userId" form:"userId" validate:"required"`
+}
+
 // UserGetUserInfoReq 获取用户信息结构体
 type UserGetUserInfoReq struct {
 }
 
 // SyncDingUserReq 同步钉钉用户信息
 type SyncDingUserReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // SyncWeComUserReq 同步企业微信用户信息
 type SyncWeComUserReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // SyncFeiShuUserReq 同步飞书用户信息
 type SyncFeiShuUserReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // SyncOpenLdapUserReq 同步ldap用户信息
 type SyncOpenLdapUserReq struct {
+	DryRun bool `json:"dryRun"` // 只生成同步计划, 不执行
 }
 type SyncSqlUserReq struct {
 	UserIds []uint `json:"userIds" validate:"required"`
+	DryRun  bool   `json:"dryRun"` // 只生成同步计划, 不执行
 }
 
 // UserListReq 获取用户列表结构体
@@ -137,12 +158,20 @@ type UserListReq struct {
 	DepartmentId []uint `json:"departmentId" form:"departmentId"`
 	Status       uint   `json:"status" form:"status" `
 	SyncState    uint   `json:"syncState" form:"syncState" `
+	DirectoryId  *uint  `json:"directoryId" form:"directoryId"`
 	PageNum      int    `json:"pageNum" form:"pageNum"`
 	PageSize     int    `json:"pageSize" form:"pageSize"`
+	// 当前登录用户可管理的目录, 由服务端填充, nil表示不限制
+	DirectoryIds []uint `json:"-" form:"-"`
 }
 
 // RegisterAndLoginReq 用户登录结构体
 type RegisterAndLoginReq struct {
-	Username string `form:"username" json:"username" binding:"required"`
-	Password string `form:"password" json:"password" binding:"required"`
+	Username  string `form:"username" json:"username"`
+	Password  string `form:"password" json:"password"`
+	MfaTicket string `form:"mfaTicket" json:"mfaTicket"` // 登录第二步: 第一步返回的二次验证凭据
+	MfaCode   string `form:"mfaCode" json:"mfaCode"`     // 登录第二步: 二次验证码或恢复码
+	Provider  string `form:"provider" json:"provider"`   // 第三方扫码登录: dingtalk、feishu、wecom
+	Code      string `form:"code" json:"code"`           // 第三方扫码登录: 回调返回的授权码
+	State     string `form:"state" json:"state"`         // 第三方扫码登录: 回调返回的state
 }
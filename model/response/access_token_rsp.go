@@ -0,0 +1,10 @@
+package response
+
+import "github.com/eryajf/go-ldap-admin/model"
+
+// AccessTokenRsp 访问令牌信息, Token明文仅在创建时返回一次
+type AccessTokenRsp struct {
+	model.AccessToken
+	Scopes []string `json:"scopes"`
+	Token  string   `json:"token,omitempty"`
+}
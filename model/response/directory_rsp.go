@@ -0,0 +1,8 @@
+package response
+
+import "github.com/eryajf/go-ldap-admin/model"
+
+type DirectoryListRsp struct {
+	Total       int64             `json:"total"`
+	Directories []model.Directory `json:"directories"`
+}
@@ -0,0 +1,14 @@
+package response
+
+import "github.com/eryajf/go-ldap-admin/model"
+
+type OidcClientListRsp struct {
+	Total   int64              `json:"total"`
+	Clients []model.OidcClient `json:"clients"`
+}
+
+// OidcClientSecretRsp 创建应用或重置密钥后返回, client_secret仅展示这一次
+type OidcClientSecretRsp struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
@@ -0,0 +1,34 @@
+package response
+
+// ScimListRsp SCIM资源列表
+type ScimListRsp struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// ScimErrorRsp SCIM错误响应, status为字符串形式的http状态码
+type ScimErrorRsp struct {
+	Schemas  []string `json:"schemas"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+	Status   string   `json:"status"`
+}
+
+// ScimBulkRsp SCIM批量操作结果
+type ScimBulkRsp struct {
+	Schemas    []string          `json:"schemas"`
+	Operations []*ScimBulkResult `json:"Operations"`
+}
+
+// ScimBulkResult 批量操作中单项的执行结果
+type ScimBulkResult struct {
+	Method   string `json:"method"`
+	BulkId   string `json:"bulkId,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Location string `json:"location,omitempty"`
+	Status   string `json:"status"`
+	Response any    `json:"response,omitempty"`
+}
@@ -0,0 +1,29 @@
+package response
+
+import "github.com/eryajf/go-ldap-admin/model"
+
+type SyncPlanListRsp struct {
+	Total int64             `json:"total"`
+	Plans []*model.SyncPlan `json:"plans"`
+}
+
+type SyncRunListRsp struct {
+	Total int64            `json:"total"`
+	Runs  []*model.SyncRun `json:"runs"`
+}
+
+type SyncRunItemsRsp struct {
+	Total int64                `json:"total"`
+	Items []*model.SyncRunItem `json:"items"`
+}
+
+// SyncSourceRsp 已注册的身份源
+type SyncSourceRsp struct {
+	Flag         string                   `json:"flag"`
+	Name         string                   `json:"name"`
+	EnableSync   bool                     `json:"enableSync"`
+	DeptSyncTime string                   `json:"deptSyncTime"`
+	UserSyncTime string                   `json:"userSyncTime"`
+	DryRun       bool                     `json:"dryRun"`
+	Fields       []*model.SyncSourceField `json:"fields"`
+}
@@ -0,0 +1,111 @@
+package model
+
+// SCIM 2.0 中使用的schema, 见RFC7643与RFC7644
+const (
+	ScimUserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimGroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimEnterpriseUserSchema        = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	ScimServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ScimResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	ScimSchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	ScimListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimPatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimBulkRequestSchema           = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	ScimBulkResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+	ScimErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ScimUser SCIM用户资源
+type ScimUser struct {
+	Schemas      []string            `json:"schemas"`
+	ID           string              `json:"id,omitempty"`
+	ExternalId   string              `json:"externalId,omitempty"` // 身份提供者中的用户编号
+	UserName     string              `json:"userName"`
+	Name         *ScimName           `json:"name,omitempty"`
+	DisplayName  string              `json:"displayName,omitempty"`
+	Title        string              `json:"title,omitempty"`
+	Active       *bool               `json:"active,omitempty"`
+	Password     string              `json:"password,omitempty"` // 只写, 不会返回
+	Emails       []*ScimMultiValue   `json:"emails,omitempty"`
+	PhoneNumbers []*ScimMultiValue   `json:"phoneNumbers,omitempty"`
+	Photos       []*ScimMultiValue   `json:"photos,omitempty"`
+	Addresses    []*ScimAddress      `json:"addresses,omitempty"`
+	Groups       []*ScimMember       `json:"groups,omitempty"` // 只读, 通过分组资源维护
+	Enterprise   *ScimEnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta         *ScimMeta           `json:"meta,omitempty"`
+}
+
+// ScimName 用户姓名
+type ScimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// ScimMultiValue 邮箱、电话等多值属性中的一项
+type ScimMultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// ScimAddress 用户地址
+type ScimAddress struct {
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"streetAddress,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postalCode,omitempty"`
+	Country       string `json:"country,omitempty"`
+	Type          string `json:"type,omitempty"`
+	Primary       bool   `json:"primary,omitempty"`
+}
+
+// ScimEnterpriseUser 企业用户扩展
+type ScimEnterpriseUser struct {
+	EmployeeNumber string `json:"employeeNumber,omitempty"`
+	Department     string `json:"department,omitempty"`
+}
+
+// ScimGroup SCIM分组资源
+type ScimGroup struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id,omitempty"`
+	ExternalId  string        `json:"externalId,omitempty"`
+	DisplayName string        `json:"displayName"`
+	Members     []*ScimMember `json:"members,omitempty"`
+	Meta        *ScimMeta     `json:"meta,omitempty"`
+}
+
+// ScimMember 分组成员或用户所在的分组
+type ScimMember struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// ScimMeta 资源的元数据
+type ScimMeta struct {
+	ResourceType string `json:"resourceType,omitempty"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+// ScimPatchOp PATCH请求中的一项操作
+type ScimPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// ScimBulkOperation 批量请求中的一项操作
+type ScimBulkOperation struct {
+	Method  string `json:"method"`
+	BulkId  string `json:"bulkId,omitempty"`
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path"`
+	Data    any    `json:"data,omitempty"`
+}
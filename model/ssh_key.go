@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SshKey 用户的SSH公钥, 同步写入LDAP条目的sshPublicKey属性, 供sshd的AuthorizedKeysCommand查询
+type SshKey struct {
+	gorm.Model
+	UserID      uint       `gorm:"not null;index;comment:'所属用户id'" json:"userId"`
+	Title       string     `gorm:"type:varchar(100);comment:'公钥名称'" json:"title"`
+	KeyType     string     `gorm:"type:varchar(50);comment:'公钥类型'" json:"keyType"`
+	Key         string     `gorm:"type:text;not null;comment:'公钥内容'" json:"key"`
+	Fingerprint string     `gorm:"type:varchar(64);not null;unique;comment:'公钥指纹(SHA256)'" json:"fingerprint"`
+	ExpiresAt   *time.Time `gorm:"comment:'过期时间, 为空表示永不过期'" json:"expiresAt"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 同步对象
+const (
+	SyncTargetDept = "dept"
+	SyncTargetUser = "user"
+)
+
+// 同步计划中的动作
+const (
+	SyncActionCreate  = "create"
+	SyncActionUpdate  = "update"
+	SyncActionMove    = "move"
+	SyncActionDisable = "disable"
+	SyncActionDelete  = "delete"
+)
+
+// SyncPlan 同步计划, 预演同步时生成, 审核之后可以按计划执行
+type SyncPlan struct {
+	gorm.Model
+	Source    string         `gorm:"type:varchar(50);comment:'同步来源'" json:"source"`
+	Target    string         `gorm:"type:varchar(20);comment:'同步对象：dept、user'" json:"target"`
+	Status    uint           `gorm:"type:tinyint(1);default:1;comment:'状态:1待执行, 2已执行, 3执行失败(可重新执行失败的项)'" json:"status"`
+	Creator   string         `gorm:"type:varchar(20);comment:'创建人'" json:"creator"`
+	Summary   datatypes.JSON `gorm:"comment:'各动作的数量'" json:"summary"`
+	Items     datatypes.JSON `gorm:"comment:'计划明细'" json:"items"`
+	AppliedAt *time.Time     `gorm:"comment:'执行时间'" json:"appliedAt"`
+}
+
+// SyncPlanItem 同步计划中的一项操作
+type SyncPlanItem struct {
+	Kind     string           `json:"kind"`             // 对象类型：group、user
+	Action   string           `json:"action"`           // 动作：create、update、move、disable、delete
+	Name     string           `json:"name"`             // 分组名或用户名
+	SourceId string           `json:"sourceId"`         // 来源中的部门或用户编号
+	DN       string           `json:"dn"`               // 执行之后在ldap中的dn
+	LocalId  uint             `json:"localId"`          // 平台中已存在的分组或用户编号, 新建时为0
+	Diffs    []*SyncFieldDiff `json:"diffs,omitempty"`  // 更新或移动时变化的字段
+	Group    *Group           `json:"group,omitempty"`  // 执行新建、更新、移动分组所需的数据
+	User     *User            `json:"user,omitempty"`   // 执行新建、更新、移动用户所需的数据
+	Status   uint             `json:"status,omitempty"` // 执行结果：1成功, 2失败, 未执行时为空
+	Error    string           `json:"error,omitempty"`  // 失败原因
+}
+
+// SyncFieldDiff 字段变化
+type SyncFieldDiff struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
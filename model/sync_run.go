@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 同步的触发方式
+const (
+	SyncTriggerManual = "manual"
+	SyncTriggerCron   = "cron"
+)
+
+// SyncRun 一次同步的执行记录
+type SyncRun struct {
+	gorm.Model
+	Source    string         `gorm:"type:varchar(50);index;comment:'同步来源'" json:"source"`
+	Target    string         `gorm:"type:varchar(20);comment:'同步对象：dept、user'" json:"target"`
+	Trigger   string         `gorm:"column:trigger_type;type:varchar(20);comment:'触发方式：manual、cron'" json:"trigger"`
+	PlanID    uint           `gorm:"default:0;comment:'按同步计划执行时的计划编号'" json:"planId"`
+	Operator  string         `gorm:"type:varchar(50);comment:'触发同步的用户'" json:"operator"`
+	Status    uint           `gorm:"type:tinyint(1);default:1;comment:'状态:1执行中, 2成功, 3部分失败, 4失败'" json:"status"`
+	StartedAt time.Time      `gorm:"comment:'开始时间'" json:"startedAt"`
+	EndedAt   *time.Time     `gorm:"comment:'结束时间'" json:"endedAt"`
+	Summary   datatypes.JSON `gorm:"comment:'各动作成功与失败的数量'" json:"summary"`
+	Failed    int            `gorm:"default:0;comment:'失败的数量'" json:"failed"`
+	Error     string         `gorm:"type:text;comment:'中止同步的错误'" json:"error"`
+}
+
+// SyncRunItem 同步中单个分组或用户的执行结果
+type SyncRunItem struct {
+	gorm.Model
+	RunID  uint   `gorm:"not null;index;comment:'同步记录编号'" json:"runId"`
+	Kind   string `gorm:"type:varchar(20);comment:'对象类型：group、user'" json:"kind"`
+	Action string `gorm:"type:varchar(20);comment:'动作'" json:"action"`
+	Name   string `gorm:"type:varchar(128);comment:'分组名或用户名'" json:"name"`
+	DN     string `gorm:"type:varchar(255);comment:'dn'" json:"dn"`
+	Status uint   `gorm:"type:tinyint(1);comment:'状态:1成功, 2失败'" json:"status"`
+	Error  string `gorm:"type:text;comment:'失败原因'" json:"error"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// SyncSourceField 身份源的配置项
+type SyncSourceField struct {
+	Key      string `json:"key"`      // 配置文件中的键, 如dingtalk.app-key
+	Name     string `json:"name"`     // 配置项说明
+	Required bool   `json:"required"` // 是否必填
+	Secret   bool   `json:"secret"`   // 是否为密钥, 密钥不返回明文
+	Value    any    `json:"value"`    // 当前的值
+}
@@ -0,0 +1,13 @@
+package model
+
+import "gorm.io/gorm"
+
+// UserMfa 用户二次验证(TOTP)信息
+type UserMfa struct {
+	gorm.Model
+	UserID        uint   `gorm:"not null;unique;comment:'用户id'" json:"userId"`
+	Secret        string `gorm:"type:varchar(512);not null;comment:'TOTP密钥(RSA加密保存)'" json:"-"`
+	RecoveryCodes string `gorm:"type:text;comment:'恢复码哈希, 逗号分隔'" json:"-"`
+	Status        uint   `gorm:"type:tinyint(1);default:2;comment:'状态:1已启用, 2待确认'" json:"status"`
+	LastStep      int64  `gorm:"default:0;comment:'最近一次通过校验的TOTP时间步, 不再接受该时间步及之前的验证码'" json:"-"`
+}
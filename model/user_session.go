@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserSession 已签发的登录令牌, 删除即视为吊销
+type UserSession struct {
+	gorm.Model
+	SessionID    string    `gorm:"type:varchar(64);not null;unique;comment:'令牌ID(jti)'" json:"sessionId"`
+	UserID       uint      `gorm:"not null;index;comment:'用户id'" json:"userId"`
+	Username     string    `gorm:"type:varchar(50);comment:'用户名'" json:"username"`
+	Ip           string    `gorm:"type:varchar(64);comment:'登录IP'" json:"ip"`
+	UserAgent    string    `gorm:"type:varchar(2048);comment:'浏览器标识'" json:"userAgent"`
+	ExpiresAt    time.Time `gorm:"comment:'令牌过期时间'" json:"expiresAt"`
+	LastActiveAt time.Time `gorm:"comment:'最近活跃时间'" json:"lastActiveAt"`
+	Current      bool      `gorm:"-" json:"current"` // 是否为发起请求的会话
+}
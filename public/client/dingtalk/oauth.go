@@ -0,0 +1,100 @@
+package dingtalk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// 钉钉开放平台地址, 测试时可替换为本地桩服务
+var (
+	loginHost = "https://login.dingtalk.com"
+	apiHost   = "https://api.dingtalk.com"
+	oapiHost  = "https://oapi.dingtalk.com"
+)
+
+// 官方文档： https://open.dingtalk.com/document/orgapp/tutorial-obtaining-user-personal-information
+// OAuthURL 生成钉钉扫码登录地址
+func OAuthURL(redirectURI, state string) string {
+	q := url.Values{}
+	q.Set("client_id", config.Conf.DingTalk.AppKey)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("response_type", "code")
+	q.Set("scope", "openid")
+	q.Set("prompt", "consent")
+	q.Set("state", state)
+	return loginHost + "/oauth2/auth?" + q.Encode()
+}
+
+// GetUserIdByCode 根据登录授权码获取用户在企业内的userid
+func GetUserIdByCode(code string) (string, error) {
+	// 1. 授权码换取用户access token
+	var tokenRsp struct {
+		AccessToken string `json:"accessToken"`
+	}
+	err := tools.HttpJSON(http.MethodPost, apiHost+"/v1.0/oauth2/userAccessToken", nil, map[string]string{
+		"clientId":     config.Conf.DingTalk.AppKey,
+		"clientSecret": config.Conf.DingTalk.AppSecret,
+		"code":         code,
+		"grantType":    "authorization_code",
+	}, &tokenRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取钉钉用户token失败: %v", err)
+	}
+	if tokenRsp.AccessToken == "" {
+		return "", errors.New("获取钉钉用户token失败")
+	}
+
+	// 2. 获取用户unionId
+	var meRsp struct {
+		UnionId string `json:"unionId"`
+	}
+	err = tools.HttpJSON(http.MethodGet, apiHost+"/v1.0/contact/users/me", map[string]string{
+		"x-acs-dingtalk-access-token": tokenRsp.AccessToken,
+	}, nil, &meRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取钉钉用户信息失败: %v", err)
+	}
+	if meRsp.UnionId == "" {
+		return "", errors.New("获取钉钉用户信息失败")
+	}
+
+	// 3. 使用企业内部应用token将unionId转为userid
+	var appTokenRsp struct {
+		ErrCode     int    `json:"errcode"`
+		ErrMsg      string `json:"errmsg"`
+		AccessToken string `json:"access_token"`
+	}
+	q := url.Values{}
+	q.Set("appkey", config.Conf.DingTalk.AppKey)
+	q.Set("appsecret", config.Conf.DingTalk.AppSecret)
+	err = tools.HttpJSON(http.MethodGet, oapiHost+"/gettoken?"+q.Encode(), nil, nil, &appTokenRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取钉钉应用token失败: %v", err)
+	}
+	if appTokenRsp.ErrCode != 0 {
+		return "", fmt.Errorf("获取钉钉应用token失败: %s", appTokenRsp.ErrMsg)
+	}
+
+	var userRsp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Result  struct {
+			UserId string `json:"userid"`
+		} `json:"result"`
+	}
+	err = tools.HttpJSON(http.MethodPost, oapiHost+"/topapi/user/getbyunionid?access_token="+url.QueryEscape(appTokenRsp.AccessToken), nil, map[string]string{
+		"unionid": meRsp.UnionId,
+	}, &userRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取钉钉用户userid失败: %v", err)
+	}
+	if userRsp.ErrCode != 0 || userRsp.Result.UserId == "" {
+		return "", fmt.Errorf("获取钉钉用户userid失败: %s", userRsp.ErrMsg)
+	}
+	return userRsp.Result.UserId, nil
+}
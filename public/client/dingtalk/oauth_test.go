@@ -0,0 +1,56 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+)
+
+func TestGetUserIdByCode(t *testing.T) {
+	config.Conf.DingTalk = &config.DingTalkConfig{AppKey: "key", AppSecret: "secret"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.0/oauth2/userAccessToken", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["code"] != "good-code" || body["clientId"] != "key" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"accessToken":"user-token"}`))
+	})
+	mux.HandleFunc("/v1.0/contact/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-acs-dingtalk-access-token") != "user-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"unionId":"union-1"}`))
+	})
+	mux.HandleFunc("/gettoken", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errcode":0,"access_token":"app-token"}`))
+	})
+	mux.HandleFunc("/topapi/user/getbyunionid", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "app-token" {
+			_, _ = w.Write([]byte(`{"errcode":40014,"errmsg":"invalid token"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errcode":0,"result":{"userid":"zhangsan"}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	apiHost, oapiHost = srv.URL, srv.URL
+
+	userId, err := GetUserIdByCode("good-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userId != "zhangsan" {
+		t.Fatalf("userid应为zhangsan, 实际为%s", userId)
+	}
+	if _, err := GetUserIdByCode("bad-code"); err == nil {
+		t.Fatal("错误的授权码不应登录成功")
+	}
+}
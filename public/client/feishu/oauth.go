@@ -0,0 +1,87 @@
+package feishu
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// 飞书开放平台地址, 测试时可替换为本地桩服务
+var apiHost = "https://open.feishu.cn"
+
+// 官方文档： https://open.feishu.cn/document/common-capabilities/sso/api/obtain-oauth-code
+// OAuthURL 生成飞书登录授权地址
+func OAuthURL(redirectURI, state string) string {
+	q := url.Values{}
+	q.Set("app_id", config.Conf.FeiShu.AppID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	return apiHost + "/open-apis/authen/v1/authorize?" + q.Encode()
+}
+
+// GetUserIdByCode 根据登录授权码获取用户的user_id
+func GetUserIdByCode(code string) (string, error) {
+	// 1. 获取应用access token
+	var appTokenRsp struct {
+		Code           int    `json:"code"`
+		Msg            string `json:"msg"`
+		AppAccessToken string `json:"app_access_token"`
+	}
+	err := tools.HttpJSON(http.MethodPost, apiHost+"/open-apis/auth/v3/app_access_token/internal", nil, map[string]string{
+		"app_id":     config.Conf.FeiShu.AppID,
+		"app_secret": config.Conf.FeiShu.AppSecret,
+	}, &appTokenRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取飞书应用token失败: %v", err)
+	}
+	if appTokenRsp.Code != 0 {
+		return "", fmt.Errorf("获取飞书应用token失败: %s", appTokenRsp.Msg)
+	}
+
+	// 2. 授权码换取用户access token
+	var tokenRsp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	err = tools.HttpJSON(http.MethodPost, apiHost+"/open-apis/authen/v1/oidc/access_token", map[string]string{
+		"Authorization": "Bearer " + appTokenRsp.AppAccessToken,
+	}, map[string]string{
+		"grant_type": "authorization_code",
+		"code":       code,
+	}, &tokenRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取飞书用户token失败: %v", err)
+	}
+	if tokenRsp.Code != 0 || tokenRsp.Data.AccessToken == "" {
+		return "", fmt.Errorf("获取飞书用户token失败: %s", tokenRsp.Msg)
+	}
+
+	// 3. 获取用户信息
+	var userRsp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			UserId string `json:"user_id"`
+		} `json:"data"`
+	}
+	err = tools.HttpJSON(http.MethodGet, apiHost+"/open-apis/authen/v1/user_info", map[string]string{
+		"Authorization": "Bearer " + tokenRsp.Data.AccessToken,
+	}, nil, &userRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取飞书用户信息失败: %v", err)
+	}
+	if userRsp.Code != 0 {
+		return "", fmt.Errorf("获取飞书用户信息失败: %s", userRsp.Msg)
+	}
+	if userRsp.Data.UserId == "" {
+		return "", errors.New("获取飞书用户user_id失败，请确认应用已开通获取用户user_id权限")
+	}
+	return userRsp.Data.UserId, nil
+}
@@ -0,0 +1,49 @@
+package feishu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+)
+
+func TestGetUserIdByCode(t *testing.T) {
+	config.Conf.FeiShu = &config.FeiShuConfig{AppID: "app", AppSecret: "secret"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/app_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"app_access_token":"app-token"}`))
+	})
+	mux.HandleFunc("/open-apis/authen/v1/oidc/access_token", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Authorization") != "Bearer app-token" || body["code"] != "good-code" {
+			_, _ = w.Write([]byte(`{"code":20003,"msg":"invalid code"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"access_token":"user-token"}}`))
+	})
+	mux.HandleFunc("/open-apis/authen/v1/user_info", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer user-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"user_id":"ou_lisi"}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	apiHost = srv.URL
+
+	userId, err := GetUserIdByCode("good-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userId != "ou_lisi" {
+		t.Fatalf("user_id应为ou_lisi, 实际为%s", userId)
+	}
+	if _, err := GetUserIdByCode("bad-code"); err == nil {
+		t.Fatal("错误的授权码不应登录成功")
+	}
+}
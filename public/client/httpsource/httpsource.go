@@ -0,0 +1,202 @@
+package httpsource
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+	"github.com/tidwall/gjson"
+)
+
+// 分页方式
+const (
+	PageTypePage   = "page"
+	PageTypeOffset = "offset"
+	PageTypeCursor = "cursor"
+)
+
+// maxPages 单个接口最多读取的页数, 避免接口分页参数配置错误时无限请求
+const maxPages = 10000
+
+// getConf 配置文件中没有http-source时不能同步
+func getConf() (*config.HttpSourceConfig, error) {
+	conf := config.Conf.HttpSource
+	if conf == nil {
+		return nil, fmt.Errorf("未配置通用HTTP身份源(http-source)")
+	}
+	return conf, nil
+}
+
+// GetAllDepts 获取所有部门
+func GetAllDepts() ([]map[string]any, error) {
+	conf, err := getConf()
+	if err != nil {
+		return nil, err
+	}
+	if conf.DeptUrl == "" {
+		return nil, fmt.Errorf("未配置部门列表接口地址")
+	}
+	var ret []map[string]any
+	err = fetchAll(conf, conf.DeptUrl, conf.DeptItemsPath, func(item gjson.Result) {
+		ret = append(ret, itemMap(item))
+	})
+	return ret, err
+}
+
+// GetAllUsers 获取所有员工信息, 所在部门的ID写入department_ids
+func GetAllUsers() ([]map[string]any, error) {
+	conf, err := getConf()
+	if err != nil {
+		return nil, err
+	}
+	if conf.UserUrl == "" {
+		return nil, fmt.Errorf("未配置用户列表接口地址")
+	}
+	var ret []map[string]any
+	err = fetchAll(conf, conf.UserUrl, conf.UserItemsPath, func(item gjson.Result) {
+		ele := itemMap(item)
+		// 部门ids
+		var sourceDeptIds []string
+		deptIds := item.Get(conf.UserDeptIdsPath)
+		if deptIds.IsArray() {
+			for _, deptId := range deptIds.Array() {
+				sourceDeptIds = append(sourceDeptIds, fmt.Sprintf("%s_%s", conf.Flag, deptId.String()))
+			}
+		} else if deptIds.Exists() {
+			sourceDeptIds = append(sourceDeptIds, fmt.Sprintf("%s_%s", conf.Flag, deptIds.String()))
+		}
+		ele["department_ids"] = sourceDeptIds
+		ret = append(ret, ele)
+	})
+	return ret, err
+}
+
+// GetLeaveUserIds 获取离职人员ID列表
+func GetLeaveUserIds() ([]string, error) {
+	conf, err := getConf()
+	if err != nil {
+		return nil, err
+	}
+	if conf.LeaverUrl == "" {
+		return nil, fmt.Errorf("未配置离职用户列表接口地址")
+	}
+	var ids []string
+	err = fetchAll(conf, conf.LeaverUrl, conf.LeaverItemsPath, func(item gjson.Result) {
+		if id := item.Get(conf.LeaverIdPath).String(); id != "" {
+			ids = append(ids, id)
+		}
+	})
+	return ids, err
+}
+
+// fetchAll 按配置的分页方式依次请求接口, 对每一页中itemsPath下的每一项调用fn
+func fetchAll(conf *config.HttpSourceConfig, rawURL, itemsPath string, fn func(item gjson.Result)) error {
+	page := conf.Page
+	if page == nil {
+		page = &config.HttpSourcePageConfig{}
+	}
+	size := page.Size
+	if size <= 0 {
+		size = 100
+	}
+	pageNum := page.StartPage
+	if page.Type == PageTypePage && pageNum <= 0 {
+		pageNum = 1
+	}
+	if page.Type == PageTypeOffset {
+		pageNum = 0
+	}
+	cursor := ""
+
+	for i := 0; i < maxPages; i++ {
+		q := url.Values{}
+		switch page.Type {
+		case PageTypePage, PageTypeOffset:
+			q.Set(paramName(page.PageParam, page.Type), strconv.Itoa(pageNum))
+			q.Set(paramName(page.SizeParam, "size"), strconv.Itoa(size))
+		case PageTypeCursor:
+			if cursor != "" {
+				q.Set(paramName(page.CursorParam, "cursor"), cursor)
+			}
+			q.Set(paramName(page.SizeParam, "size"), strconv.Itoa(size))
+		}
+		body, err := get(conf, rawURL, q)
+		if err != nil {
+			return err
+		}
+
+		items := gjson.ParseBytes(body)
+		if itemsPath != "" {
+			items = items.Get(itemsPath)
+		}
+		if items.Exists() && !items.IsArray() {
+			return fmt.Errorf("接口 %s 的响应中 %s 不是数组", tools.RedactURL(rawURL), itemsPath)
+		}
+		list := items.Array()
+		for _, item := range list {
+			fn(item)
+		}
+
+		switch page.Type {
+		case PageTypePage:
+			if len(list) < size {
+				return nil
+			}
+			pageNum++
+		case PageTypeOffset:
+			if len(list) < size {
+				return nil
+			}
+			pageNum += len(list)
+		case PageTypeCursor:
+			cursor = gjson.GetBytes(body, page.CursorPath).String()
+			if cursor == "" || len(list) == 0 {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	return fmt.Errorf("接口 %s 的分页超过%d页, 请检查分页配置", tools.RedactURL(rawURL), maxPages)
+}
+
+// get 携带认证请求头请求接口, 返回原始的响应
+func get(conf *config.HttpSourceConfig, rawURL string, q url.Values) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	for k, v := range q {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+
+	header := make(map[string]string)
+	if conf.Token != "" {
+		header[paramName(conf.AuthHeader, "Authorization")] = conf.Token
+	}
+	var body json.RawMessage
+	err = tools.HttpJSON(http.MethodGet, u.String(), header, nil, &body)
+	return body, err
+}
+
+// itemMap 将接口返回的一项转换为字段关联使用的数据
+func itemMap(item gjson.Result) map[string]any {
+	ele, ok := item.Value().(map[string]any)
+	if !ok {
+		ele = map[string]any{"value": item.Value()}
+	}
+	return ele
+}
+
+func paramName(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
@@ -0,0 +1,138 @@
+package httpsource
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+)
+
+func TestGetAllDeptsByPage(t *testing.T) {
+	depts := []string{
+		`{"id":1,"name":"研发中心","parentid":0}`,
+		`{"id":2,"name":"运维","parentid":1}`,
+		`{"id":3,"name":"测试","parentid":1}`,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/depts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "hr-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("pageNo"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		start := (page - 1) * size
+		end := min(start+size, len(depts))
+		if start > len(depts) {
+			start = end
+		}
+		fmt.Fprintf(w, `{"code":0,"data":{"list":[%s]}}`, strings.Join(depts[start:end], ","))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	config.Conf.HttpSource = &config.HttpSourceConfig{
+		Flag:          "hr",
+		DeptUrl:       srv.URL + "/depts",
+		AuthHeader:    "X-Token",
+		Token:         "hr-token",
+		DeptItemsPath: "data.list",
+		Page:          &config.HttpSourcePageConfig{Type: PageTypePage, PageParam: "pageNo", SizeParam: "pageSize", Size: 2},
+	}
+	ret, err := GetAllDepts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret) != 3 {
+		t.Fatalf("应获取到3个部门, 实际为%d", len(ret))
+	}
+	if ret[1]["name"] != "运维" {
+		t.Fatalf("第2个部门应为运维, 实际为%v", ret[1]["name"])
+	}
+
+	config.Conf.HttpSource.Token = "bad-token"
+	if _, err := GetAllDepts(); err == nil {
+		t.Fatal("错误的token不应获取成功")
+	}
+}
+
+func TestGetAllUsersByCursor(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"items":[{"userid":"u1","name":"张三","depts":[1,2]}],"next":"c1"}`,
+		"c1": `{"items":[{"userid":"u2","name":"李四","depts":3}],"next":""}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pages[r.URL.Query().Get("cursor")]))
+	}))
+	defer srv.Close()
+
+	config.Conf.HttpSource = &config.HttpSourceConfig{
+		Flag:            "hr",
+		UserUrl:         srv.URL + "/users?corp=1",
+		UserItemsPath:   "items",
+		UserDeptIdsPath: "depts",
+		Page:            &config.HttpSourcePageConfig{Type: PageTypeCursor, CursorPath: "next"},
+	}
+	ret, err := GetAllUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ret) != 2 {
+		t.Fatalf("应获取到2个用户, 实际为%d", len(ret))
+	}
+	if ids := ret[0]["department_ids"].([]string); strings.Join(ids, ",") != "hr_1,hr_2" {
+		t.Fatalf("张三的部门应为hr_1,hr_2, 实际为%v", ids)
+	}
+	if ids := ret[1]["department_ids"].([]string); strings.Join(ids, ",") != "hr_3" {
+		t.Fatalf("李四的部门应为hr_3, 实际为%v", ids)
+	}
+}
+
+func TestGetLeaveUserIdsByOffset(t *testing.T) {
+	var offsets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := r.URL.Query().Get("offset")
+		offsets = append(offsets, offset)
+		switch offset {
+		case "0":
+			_, _ = w.Write([]byte(`[{"user":{"id":"u3"}},{"user":{"id":"u4"}}]`))
+		default:
+			_, _ = w.Write([]byte(`[{"user":{"id":"u5"}}]`))
+		}
+	}))
+	defer srv.Close()
+
+	config.Conf.HttpSource = &config.HttpSourceConfig{
+		Flag:         "hr",
+		LeaverUrl:    srv.URL,
+		LeaverIdPath: "user.id",
+		Page:         &config.HttpSourcePageConfig{Type: PageTypeOffset, Size: 2},
+	}
+	ids, err := GetLeaveUserIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != "u3,u4,u5" {
+		t.Fatalf("离职用户应为u3,u4,u5, 实际为%v", ids)
+	}
+	if strings.Join(offsets, ",") != "0,2" {
+		t.Fatalf("请求的偏移量应为0,2, 实际为%v", offsets)
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	config.Conf.HttpSource = nil
+	if _, err := GetAllDepts(); err == nil {
+		t.Fatal("未配置http-source时获取部门应失败")
+	}
+	if _, err := GetAllUsers(); err == nil {
+		t.Fatal("未配置http-source时获取用户应失败")
+	}
+	if _, err := GetLeaveUserIds(); err == nil {
+		t.Fatal("未配置http-source时获取离职用户应失败")
+	}
+}
@@ -15,6 +15,7 @@ type Dept struct {
 	Name     string `json:"name"`     // 部门名称拼音
 	Remark   string `json:"remark"`   // 部门中文名
 	ParentId string `json:"parentid"` // 父部门ID
+	Schema   string `json:"schema"`   // 分组的objectClass: groupOfUniqueNames、groupOfNames
 }
 
 type User struct {
@@ -32,10 +33,21 @@ type User struct {
 	GivenName        string   `json:"givenName"`        // 给定名字，如果公司有花名，可以用这个字段
 	PostalAddress    string   `json:"postalAddress"`    // 家庭住址
 	DepartmentIds    []string `json:"department_ids"`
+	// 按用户schema的导入映射读取的字段, 键为小写的用户字段名
+	Fields map[string]string `json:"fields"`
 }
 
 // GetAllDepts 获取所有部门
 func GetAllDepts() (ret []*Dept, err error) {
+	err = EachDept(func(dept *Dept) error {
+		ret = append(ret, dept)
+		return nil
+	})
+	return ret, err
+}
+
+// EachDept 分页读取所有部门, 每读取到一个部门调用一次fn, fn返回错误时停止读取
+func EachDept(fn func(dept *Dept) error) error {
 	// Construct query request
 	searchRequest := ldap.NewSearchRequest(
 		config.Conf.Ldap.BaseDN,                                     // This is basedn, we will start searching from this node.
@@ -45,48 +57,55 @@ func GetAllDepts() (ret []*Dept, err error) {
 		nil,
 	)
 
-	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
-	defer common.PutLADPConn(conn)
-	if err != nil {
-		return nil, err
-	}
-
-	// Search through ldap built-in search
-	sr, err := conn.Search(searchRequest)
-	if err != nil {
-		return ret, err
-	}
-	// Refers to the entry that returns data. If it is greater than 0, the interface returns normally.
-	if len(sr.Entries) > 0 {
-		for _, v := range sr.Entries {
-			if v.DN == config.Conf.Ldap.BaseDN || v.DN == config.Conf.Ldap.AdminDN || strings.Contains(v.DN, config.Conf.Ldap.UserDN) {
-				continue
-			}
-			var ele Dept
-			ele.DN = v.DN
-			ele.Name = strings.Split(strings.Split(v.DN, ",")[0], "=")[1]
-			ele.Id = strings.Split(strings.Split(v.DN, ",")[0], "=")[1]
-			ele.Remark = v.GetAttributeValue("description")
-			if len(strings.Split(v.DN, ","))-len(strings.Split(config.Conf.Ldap.BaseDN, ",")) == 1 {
-				ele.ParentId = "0"
-			} else {
-				ele.ParentId = strings.Split(strings.Split(v.DN, ",")[1], "=")[1]
+	return common.SearchLDAP(searchRequest, func(v *ldap.Entry) error {
+		if v.DN == config.Conf.Ldap.BaseDN || v.DN == config.Conf.Ldap.AdminDN || strings.Contains(v.DN, config.Conf.Ldap.UserDN) {
+			return nil
+		}
+		dn, err := ldap.ParseDN(v.DN)
+		if err != nil || len(dn.RDNs) == 0 {
+			return nil
+		}
+		var ele Dept
+		ele.DN = v.DN
+		ele.Name = rdnValue(dn, 0)
+		ele.Id = rdnValue(dn, 0)
+		ele.Remark = v.GetAttributeValue("description")
+		for _, objectClass := range v.GetAttributeValues("objectClass") {
+			if strings.EqualFold(objectClass, common.GroupSchemaNames) || strings.EqualFold(objectClass, common.GroupSchemaUniqueNames) {
+				ele.Schema = objectClass
 			}
-			ret = append(ret, &ele)
 		}
-	}
-	return
+		if baseDN, err := ldap.ParseDN(config.Conf.Ldap.BaseDN); err == nil && len(dn.RDNs)-len(baseDN.RDNs) == 1 {
+			ele.ParentId = "0"
+		} else {
+			ele.ParentId = rdnValue(dn, 1)
+		}
+		return fn(&ele)
+	})
 }
 
 // GetAllUsers 获取所有员工信息
 func GetAllUsers() (ret []*User, err error) {
+	err = EachUser(func(user *User) error {
+		ret = append(ret, user)
+		return nil
+	})
+	return ret, err
+}
+
+// EachUser 分页读取所有员工信息, 每读取到一个用户调用一次fn, fn返回错误时停止读取
+func EachUser(fn func(user *User) error) error {
+	// memberOf为操作属性, 需要显式指定才会返回
+	attributes := []string{}
+	if config.Conf.Ldap.MemberOf {
+		attributes = []string{"*", "memberOf"}
+	}
 	// Construct query request
 	searchRequest := ldap.NewSearchRequest(
 		config.Conf.Ldap.BaseDN,                                     // This is basedn, we will start searching from this node.
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, // Here several parameters are respectively scope, derefAliases, sizeLimit, timeLimit,  typesOnly
 		"(&(objectClass=*))", // This is Filter for LDAP query
-		[]string{},           // Here are the attributes returned by the query, provided as an array. If empty, all attributes are returned
+		attributes,           // Here are the attributes returned by the query, provided as an array. If empty, all attributes are returned
 		nil,
 	)
 
@@ -94,74 +113,102 @@ func GetAllUsers() (ret []*User, err error) {
 	conn, err := common.GetLDAPConn()
 	defer common.PutLADPConn(conn)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// Search through ldap built-in search
-	sr, err := conn.Search(searchRequest)
-	if err != nil {
-		return ret, err
-	}
-	// Refers to the entry that returns data. If it is greater than 0, the interface returns normally.
-	if len(sr.Entries) > 0 {
-		for _, v := range sr.Entries {
-			if v.DN == config.Conf.Ldap.UserDN || !strings.Contains(v.DN, config.Conf.Ldap.UserDN) {
-				continue
-			}
-			name := strings.Split(strings.Split(v.DN, ",")[0], "=")[1]
-			deptIds, err := GetUserDeptIds(v.DN)
+	return common.SearchPaged(conn, searchRequest, func(v *ldap.Entry) error {
+		if v.DN == config.Conf.Ldap.UserDN || !strings.Contains(v.DN, config.Conf.Ldap.UserDN) {
+			return nil
+		}
+		name := strings.Split(strings.Split(v.DN, ",")[0], "=")[1]
+		var deptIds []string
+		if config.Conf.Ldap.MemberOf {
+			deptIds = memberOfDeptIds(v.GetAttributeValues("memberOf"))
+		} else {
+			// 分页之间可以在同一连接上执行其他搜索, 不需要再占用一个连接
+			var err error
+			deptIds, err = userDeptIds(conn, v.DN)
 			if err != nil {
-				return ret, err
+				return err
 			}
-			ret = append(ret, &User{
-				Name:             name,
-				DN:               v.DN,
-				CN:               v.GetAttributeValue("cn"),
-				SN:               v.GetAttributeValue("sn"),
-				Mobile:           v.GetAttributeValue("mobile"),
-				BusinessCategory: v.GetAttributeValue("businessCategory"),
-				DepartmentNumber: v.GetAttributeValue("departmentNumber"),
-				Description:      v.GetAttributeValue("description"),
-				DisplayName:      v.GetAttributeValue("displayName"),
-				Mail:             v.GetAttributeValue("mail"),
-				EmployeeNumber:   v.GetAttributeValue("employeeNumber"),
-				GivenName:        v.GetAttributeValue("givenName"),
-				PostalAddress:    v.GetAttributeValue("postalAddress"),
-				DepartmentIds:    deptIds,
-			})
 		}
-	}
-	return
+		fields := make(map[string]string)
+		for field, attr := range common.LdapUserImportAttributes() {
+			fields[field] = v.GetAttributeValue(attr)
+		}
+		return fn(&User{
+			Name:             name,
+			DN:               v.DN,
+			CN:               v.GetAttributeValue("cn"),
+			SN:               v.GetAttributeValue("sn"),
+			Mobile:           v.GetAttributeValue("mobile"),
+			BusinessCategory: v.GetAttributeValue("businessCategory"),
+			DepartmentNumber: v.GetAttributeValue("departmentNumber"),
+			Description:      v.GetAttributeValue("description"),
+			DisplayName:      v.GetAttributeValue("displayName"),
+			Mail:             v.GetAttributeValue("mail"),
+			EmployeeNumber:   v.GetAttributeValue("employeeNumber"),
+			GivenName:        v.GetAttributeValue("givenName"),
+			PostalAddress:    v.GetAttributeValue("postalAddress"),
+			DepartmentIds:    deptIds,
+			Fields:           fields,
+		})
+	})
 }
 
 // GetUserDeptIds 获取用户所在的部门
 func GetUserDeptIds(udn string) (ret []string, err error) {
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConn()
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	return userDeptIds(conn, udn)
+}
+
+func userDeptIds(conn *ldap.Conn, udn string) (ret []string, err error) {
 	// Construct query request
 	searchRequest := ldap.NewSearchRequest(
 		config.Conf.Ldap.BaseDN,                                     // This is basedn, we will start searching from this node.
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, // Here several parameters are respectively scope, derefAliases, sizeLimit, timeLimit,  typesOnly
-		fmt.Sprintf("(|(Member=%s)(uniqueMember=%s))", udn, udn), // This is Filter for LDAP query
-		[]string{}, // Here are the attributes returned by the query, provided as an array. If empty, all attributes are returned
+		fmt.Sprintf("(|(member=%s)(uniqueMember=%s))", ldap.EscapeFilter(udn), ldap.EscapeFilter(udn)), // This is Filter for LDAP query
+		[]string{"1.1"}, // 只需要DN, 不返回属性
 		nil,
 	)
 
-	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
-	defer common.PutLADPConn(conn)
-	if err != nil {
-		return nil, err
+	err = common.SearchPaged(conn, searchRequest, func(v *ldap.Entry) error {
+		if id, ok := dnDeptId(v.DN); ok {
+			ret = append(ret, id)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// memberOfDeptIds 根据用户的memberOf属性获取所在的部门
+func memberOfDeptIds(groupDNs []string) (ret []string) {
+	for _, gdn := range groupDNs {
+		if id, ok := dnDeptId(gdn); ok {
+			ret = append(ret, id)
+		}
 	}
+	return ret
+}
 
-	// Search through ldap built-in search
-	sr, err := conn.Search(searchRequest)
-	if err != nil {
-		return ret, err
+// dnDeptId 分组dn中第一个RDN的值即部门编号, 值中可能包含转义的逗号与等号, 需要按DN语法解析
+func dnDeptId(gdn string) (string, bool) {
+	dn, err := ldap.ParseDN(gdn)
+	if err != nil || len(dn.RDNs) == 0 {
+		return "", false
 	}
-	// Refers to the entry that returns data. If it is greater than 0, the interface returns normally.
-	if len(sr.Entries) > 0 {
-		for _, v := range sr.Entries {
-			ret = append(ret, strings.Split(strings.Split(v.DN, ",")[0], "=")[1])
-		}
+	return rdnValue(dn, 0), true
+}
+
+// rdnValue dn中第i个RDN的值
+func rdnValue(dn *ldap.DN, i int) string {
+	if i >= len(dn.RDNs) || len(dn.RDNs[i].Attributes) == 0 {
+		return ""
 	}
-	return ret, nil
+	return dn.RDNs[i].Attributes[0].Value
 }
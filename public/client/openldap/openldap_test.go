@@ -0,0 +1,29 @@
+package openldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMemberOfDeptIds(t *testing.T) {
+	got := memberOfDeptIds([]string{
+		"cn=dev,ou=groups,dc=eryajf,dc=net",
+		`cn=R\2CD,ou=groups,dc=eryajf,dc=net`,
+		`cn=ops\,sre,ou=groups,dc=eryajf,dc=net`,
+		"CN=qa ,OU=groups,DC=eryajf,DC=net",
+		"not-a-dn",
+	})
+	want := []string{"dev", "R,D", "ops,sre", "qa"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("部门编号应为%q, 实际为%q", want, got)
+	}
+}
+
+func TestDnDeptId(t *testing.T) {
+	if _, ok := dnDeptId(""); ok {
+		t.Fatal("空dn不应解析出部门编号")
+	}
+	if id, ok := dnDeptId(`cn=a\=b,dc=eryajf,dc=net`); !ok || id != "a=b" {
+		t.Fatalf("值中的等号应保留, 实际为%q", id)
+	}
+}
@@ -0,0 +1,70 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+// 企业微信开放平台地址, 测试时可替换为本地桩服务
+var (
+	loginHost = "https://login.work.weixin.qq.com"
+	apiHost   = "https://qyapi.weixin.qq.com"
+)
+
+// 官方文档： https://developer.work.weixin.qq.com/document/path/98152
+// OAuthURL 生成企业微信扫码登录地址
+func OAuthURL(redirectURI, state string) string {
+	q := url.Values{}
+	q.Set("login_type", "CorpApp")
+	q.Set("appid", config.Conf.WeCom.CorpID)
+	q.Set("agentid", strconv.Itoa(config.Conf.WeCom.AgentID))
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	return loginHost + "/wwlogin/sso/login?" + q.Encode()
+}
+
+// 官方文档： https://developer.work.weixin.qq.com/document/path/98176
+// GetUserIdByCode 根据登录授权码获取成员userid
+func GetUserIdByCode(code string) (string, error) {
+	var tokenRsp struct {
+		ErrCode     int    `json:"errcode"`
+		ErrMsg      string `json:"errmsg"`
+		AccessToken string `json:"access_token"`
+	}
+	q := url.Values{}
+	q.Set("corpid", config.Conf.WeCom.CorpID)
+	q.Set("corpsecret", config.Conf.WeCom.CorpSecret)
+	err := tools.HttpJSON(http.MethodGet, apiHost+"/cgi-bin/gettoken?"+q.Encode(), nil, nil, &tokenRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取企业微信token失败: %v", err)
+	}
+	if tokenRsp.ErrCode != 0 {
+		return "", fmt.Errorf("获取企业微信token失败: %s", tokenRsp.ErrMsg)
+	}
+
+	var userRsp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		UserId  string `json:"userid"`
+	}
+	q = url.Values{}
+	q.Set("access_token", tokenRsp.AccessToken)
+	q.Set("code", code)
+	err = tools.HttpJSON(http.MethodGet, apiHost+"/cgi-bin/auth/getuserinfo?"+q.Encode(), nil, nil, &userRsp)
+	if err != nil {
+		return "", fmt.Errorf("获取企业微信用户信息失败: %v", err)
+	}
+	if userRsp.ErrCode != 0 {
+		return "", fmt.Errorf("获取企业微信用户信息失败: %s", userRsp.ErrMsg)
+	}
+	if userRsp.UserId == "" {
+		return "", errors.New("非企业成员，无法登录")
+	}
+	return userRsp.UserId, nil
+}
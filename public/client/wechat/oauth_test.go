@@ -0,0 +1,44 @@
+package wechat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+)
+
+func TestGetUserIdByCode(t *testing.T) {
+	config.Conf.WeCom = &config.WeComConfig{CorpID: "corp", CorpSecret: "secret"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/gettoken", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("corpsecret") != "secret" {
+			_, _ = w.Write([]byte(`{"errcode":40001,"errmsg":"invalid credential"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errcode":0,"access_token":"corp-token"}`))
+	})
+	mux.HandleFunc("/cgi-bin/auth/getuserinfo", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("access_token") != "corp-token" || q.Get("code") != "good-code" {
+			_, _ = w.Write([]byte(`{"errcode":40029,"errmsg":"invalid code"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errcode":0,"userid":"wangwu"}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	apiHost = srv.URL
+
+	userId, err := GetUserIdByCode("good-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userId != "wangwu" {
+		t.Fatalf("userid应为wangwu, 实际为%s", userId)
+	}
+	if _, err := GetUserIdByCode("bad-code"); err == nil {
+		t.Fatal("错误的授权码不应登录成功")
+	}
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
@@ -23,6 +24,24 @@ func InitCasbinEnforcer() {
 	Log.Info("初始化Casbin完成!")
 }
 
+var checkLock sync.Mutex
+
+// CasbinCheck 判断角色中是否有任意一个拥有访问该接口的权限
+func CasbinCheck(subs []string, obj string, act string) bool {
+	// 同一时间只允许一个请求执行校验, 否则可能会校验失败
+	checkLock.Lock()
+	defer checkLock.Unlock()
+	isPass := false
+	for _, sub := range subs {
+		pass, _ := CasbinEnforcer.Enforce(sub, obj, act)
+		if pass {
+			isPass = true
+			break
+		}
+	}
+	return isPass
+}
+
 var casbinModel = `
 [request_definition]
 r = sub, obj, act
@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/eryajf/go-ldap-admin/config"
 	"github.com/eryajf/go-ldap-admin/model"
@@ -35,7 +36,21 @@ func dbAutoMigrate() {
 		&model.Api{},
 		&model.OperationLog{},
 		&model.FieldRelation{},
+		&model.UserMfa{},
+		&model.OidcClient{},
+		&model.Directory{},
+		&model.PasswordHistory{},
+		&model.UserSession{},
+		&model.AccessToken{},
+		&model.PosixId{},
+		&model.SshKey{},
+		&model.GroupNesting{},
+		&model.SyncPlan{},
+		&model.SyncRun{},
+		&model.SyncRunItem{},
 	)
+	// 升级前的用户没有密码修改时间, 以升级时间作为起点计算密码有效期
+	_ = DB.Model(&model.User{}).Where("pwd_changed_at IS NULL").UpdateColumn("pwd_changed_at", time.Now()).Error
 }
 
 func ConnSqlite() *gorm.DB {
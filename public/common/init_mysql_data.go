@@ -230,7 +230,7 @@ func InitData() {
 		{
 			Model:         gorm.Model{ID: 1},
 			Username:      "admin",
-			Password:      tools.NewGenPasswd(config.Conf.Ldap.AdminPass),
+			Password:      tools.GenPasswd(config.Conf.Ldap.AdminPass),
 			Nickname:      "管理员",
 			GivenName:     "最强后台",
 			Mail:          "admin@" + config.Conf.Ldap.DefaultEmailSuffix,
@@ -278,13 +278,6 @@ func InitData() {
 			Remark:   "加密密码",
 			Creator:  "系统",
 		},
-		{
-			Method:   "GET",
-			Path:     "/base/decryptpwd",
-			Category: "base",
-			Remark:   "解密密码",
-			Creator:  "系统",
-		},
 		{
 			Method:   "GET",
 			Path:     "/base/config",
@@ -306,6 +299,34 @@ func InitData() {
 			Remark:   "获取系统首页展示数据",
 			Creator:  "系统",
 		},
+		{
+			Method:   "GET",
+			Path:     "/system/ldapPoolStats",
+			Category: "system",
+			Remark:   "获取LDAP连接池统计",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/base/mfa/setup",
+			Category: "base",
+			Remark:   "登录过程中生成二次验证密钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/base/sso/providers",
+			Category: "base",
+			Remark:   "获取已开启的扫码登录方式",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/base/sso/authorize",
+			Category: "base",
+			Remark:   "获取第三方登录授权地址",
+			Creator:  "系统",
+		},
 		{
 			Method:   "POST",
 			Path:     "/base/login",
@@ -341,6 +362,13 @@ func InitData() {
 			Remark:   "通过邮箱修改密码",
 			Creator:  "系统",
 		},
+		{
+			Method:   "POST",
+			Path:     "/base/changeExpiredPwd",
+			Category: "base",
+			Remark:   "登录时修改已过期的密码",
+			Creator:  "系统",
+		},
 		{
 			Method:   "GET",
 			Path:     "/user/info",
@@ -397,6 +425,27 @@ func InitData() {
 			Remark:   "更改用户在职状态",
 			Creator:  "系统",
 		},
+		{
+			Method:   "POST",
+			Path:     "/user/unlock",
+			Category: "user",
+			Remark:   "解除用户登录锁定",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/posixBackfill",
+			Category: "user",
+			Remark:   "回填用户的POSIX账号信息",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/effectiveGroups",
+			Category: "user",
+			Remark:   "获取用户的有效分组",
+			Creator:  "系统",
+		},
 		{
 			Method:   "POST",
 			Path:     "/user/syncDingTalkUsers",
@@ -432,6 +481,139 @@ func InitData() {
 			Remark:   "将数据库中的用户同步到Ldap",
 			Creator:  "系统",
 		},
+		{
+			Method:   "GET",
+			Path:     "/user/mfa/info",
+			Category: "user",
+			Remark:   "获取当前用户的二次验证状态",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/mfa/setup",
+			Category: "user",
+			Remark:   "生成二次验证密钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/mfa/enable",
+			Category: "user",
+			Remark:   "启用二次验证",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/mfa/disable",
+			Category: "user",
+			Remark:   "关闭二次验证",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/mfa/reset",
+			Category: "user",
+			Remark:   "重置用户二次验证",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/sshKey/mine",
+			Category: "user",
+			Remark:   "获取当前用户的SSH公钥列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/sshKey/addMine",
+			Category: "user",
+			Remark:   "当前用户添加SSH公钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/sshKey/deleteMine",
+			Category: "user",
+			Remark:   "当前用户删除SSH公钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/sshKey/list",
+			Category: "user",
+			Remark:   "获取指定用户的SSH公钥列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/sshKey/add",
+			Category: "user",
+			Remark:   "为用户添加SSH公钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/sshKey/delete",
+			Category: "user",
+			Remark:   "删除用户的SSH公钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/session/mine",
+			Category: "session",
+			Remark:   "获取当前用户的会话列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/session/revokeMine",
+			Category: "session",
+			Remark:   "吊销当前用户的会话",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/session/list",
+			Category: "session",
+			Remark:   "获取指定用户的会话列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/session/revoke",
+			Category: "session",
+			Remark:   "吊销指定会话",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/session/revokeUser",
+			Category: "session",
+			Remark:   "吊销用户的全部会话",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/accessToken/list",
+			Category: "accessToken",
+			Remark:   "获取访问令牌列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/accessToken/add",
+			Category: "accessToken",
+			Remark:   "创建访问令牌",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/accessToken/delete",
+			Category: "accessToken",
+			Remark:   "删除访问令牌",
+			Creator:  "系统",
+		},
 		{
 			Method:   "GET",
 			Path:     "/group/list",
@@ -530,6 +712,48 @@ func InitData() {
 			Remark:   "将数据库中的分组同步到Ldap",
 			Creator:  "系统",
 		},
+		{
+			Method:   "POST",
+			Path:     "/group/posixBackfill",
+			Category: "group",
+			Remark:   "回填分组的POSIX信息",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/group/convertSchema",
+			Category: "group",
+			Remark:   "转换分组的objectClass",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/group/addGroup",
+			Category: "group",
+			Remark:   "添加成员分组",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/group/removeGroup",
+			Category: "group",
+			Remark:   "移除成员分组",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/group/effectiveMembers",
+			Category: "group",
+			Remark:   "获取分组的有效成员",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/group/syncNestedMembers",
+			Category: "group",
+			Remark:   "重新写入嵌套分组的成员",
+			Creator:  "系统",
+		},
 		{
 			Method:   "GET",
 			Path:     "/role/list",
@@ -705,14 +929,266 @@ func InitData() {
 			Remark:   "清空操作日志",
 			Creator:  "系统",
 		},
+		{
+			Method:   "GET",
+			Path:     "/oidc/client/list",
+			Category: "oidc",
+			Remark:   "获取OIDC应用列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/oidc/client/add",
+			Category: "oidc",
+			Remark:   "添加OIDC应用",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/oidc/client/update",
+			Category: "oidc",
+			Remark:   "更新OIDC应用",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/oidc/client/resetSecret",
+			Category: "oidc",
+			Remark:   "重置OIDC应用密钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/oidc/client/delete",
+			Category: "oidc",
+			Remark:   "删除OIDC应用",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/directory/list",
+			Category: "directory",
+			Remark:   "获取LDAP目录列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/directory/add",
+			Category: "directory",
+			Remark:   "添加LDAP目录",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/directory/update",
+			Category: "directory",
+			Remark:   "更新LDAP目录",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/directory/delete",
+			Category: "directory",
+			Remark:   "删除LDAP目录",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/sync/plan/list",
+			Category: "sync",
+			Remark:   "获取同步计划列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/sync/plan/info",
+			Category: "sync",
+			Remark:   "获取同步计划详情",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/sync/plan/apply",
+			Category: "sync",
+			Remark:   "执行同步计划",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/sync/plan/delete",
+			Category: "sync",
+			Remark:   "删除同步计划",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/sync/run/list",
+			Category: "sync",
+			Remark:   "获取同步记录列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/sync/run/items",
+			Category: "sync",
+			Remark:   "获取同步记录的执行结果",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/sync/source/list",
+			Category: "sync",
+			Remark:   "获取身份源列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/sync/source/depts",
+			Category: "sync",
+			Remark:   "从身份源同步部门",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/sync/source/users",
+			Category: "sync",
+			Remark:   "从身份源同步用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/ServiceProviderConfig",
+			Category: "scim",
+			Remark:   "获取SCIM服务端配置",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/Schemas",
+			Category: "scim",
+			Remark:   "获取SCIM schema列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/Schemas/:id",
+			Category: "scim",
+			Remark:   "获取SCIM schema",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/ResourceTypes",
+			Category: "scim",
+			Remark:   "获取SCIM资源类型列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/ResourceTypes/:id",
+			Category: "scim",
+			Remark:   "获取SCIM资源类型",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/Users",
+			Category: "scim",
+			Remark:   "获取SCIM用户列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/scim/v2/Users",
+			Category: "scim",
+			Remark:   "创建SCIM用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/Users/:id",
+			Category: "scim",
+			Remark:   "获取SCIM用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PUT",
+			Path:     "/scim/v2/Users/:id",
+			Category: "scim",
+			Remark:   "替换SCIM用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/scim/v2/Users/:id",
+			Category: "scim",
+			Remark:   "修改SCIM用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "DELETE",
+			Path:     "/scim/v2/Users/:id",
+			Category: "scim",
+			Remark:   "删除SCIM用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/Groups",
+			Category: "scim",
+			Remark:   "获取SCIM分组列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/scim/v2/Groups",
+			Category: "scim",
+			Remark:   "创建SCIM分组",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/scim/v2/Groups/:id",
+			Category: "scim",
+			Remark:   "获取SCIM分组",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PUT",
+			Path:     "/scim/v2/Groups/:id",
+			Category: "scim",
+			Remark:   "替换SCIM分组",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/scim/v2/Groups/:id",
+			Category: "scim",
+			Remark:   "修改SCIM分组",
+			Creator:  "系统",
+		},
+		{
+			Method:   "DELETE",
+			Path:     "/scim/v2/Groups/:id",
+			Category: "scim",
+			Remark:   "删除SCIM分组",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/scim/v2/Bulk",
+			Category: "scim",
+			Remark:   "SCIM批量操作",
+			Creator:  "系统",
+		},
 	}
 
 	// 5. 将角色绑定给菜单
 	newApi := make([]model.Api, 0)
 	newRoleCasbin := make([]model.RoleCasbin, 0)
-	for i, api := range apis {
-		api.ID = uint(i + 1)
-		err := DB.First(&api, api.ID).Error
+	for _, api := range apis {
+		// 按路径与方法判断接口是否已存在，避免升级后新增接口因ID错位而未写入
+		err := DB.Where("path = ? AND method = ?", api.Path, api.Method).First(&model.Api{}).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			newApi = append(newApi, api)
 
@@ -733,6 +1209,18 @@ func InitData() {
 				"/base/dashboard",
 				"/user/info",
 				"/user/changePwd",
+				"/user/mfa/info",
+				"/user/mfa/setup",
+				"/user/mfa/enable",
+				"/user/mfa/disable",
+				"/user/sshKey/mine",
+				"/user/sshKey/addMine",
+				"/user/sshKey/deleteMine",
+				"/session/mine",
+				"/session/revokeMine",
+				"/accessToken/list",
+				"/accessToken/add",
+				"/accessToken/delete",
 				"/menu/access/tree",
 				"/log/operation/list",
 			}
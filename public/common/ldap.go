@@ -1,10 +1,8 @@
 package common
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"math/rand"
-	"net"
 	"sync"
 	"time"
 
@@ -17,6 +15,15 @@ var ldapPool *LdapConnPool
 var ldapInit = false
 var ldapInitOne sync.Once
 
+// 连接池参数未配置时的默认值
+const (
+	defaultLdapAcquireTimeout      = 10 * time.Second
+	defaultLdapHealthCheckInterval = 30 * time.Second
+	defaultLdapInitRetries         = 5
+	maxLdapInitBackoff             = 30 * time.Second
+	ldapHealthCheckTimeout         = 5 * time.Second
+)
+
 // Init 初始化连接
 func InitLDAP() {
 	if ldapInit {
@@ -27,26 +34,8 @@ func InitLDAP() {
 		ldapInit = true
 	})
 
-	// Dail有两个参数 network,  address, 返回 (*Conn,  error)
-	ldapConn, err := ldap.DialURL(config.Conf.Ldap.Url, ldap.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}))
-	if err != nil {
-		Log.Panicf("初始化ldap连接异常: %v", err)
-		panic(fmt.Errorf("初始化ldap连接异常: %v", err))
-	}
-	err = ldapConn.Bind(config.Conf.Ldap.AdminDN, config.Conf.Ldap.AdminPass)
-	if err != nil {
-		Log.Panicf("绑定admin账号异常: %v", err)
-		panic(fmt.Errorf("绑定admin账号异常: %v", err))
-	}
-
 	// 全局变量赋值
-	ldapPool = &LdapConnPool{
-		conns:    make([]*ldap.Conn, 0),
-		reqConns: make(map[uint64]chan *ldap.Conn),
-		openConn: 0,
-		maxOpen:  config.Conf.Ldap.MaxConn,
-	}
-	PutLADPConn(ldapConn)
+	ldapPool = NewLdapConnPool(config.Conf.Ldap, ldapDialer(config.Conf.Ldap))
 
 	// 隐藏密码
 	showDsn := fmt.Sprintf(
@@ -55,105 +44,347 @@ func InitLDAP() {
 		config.Conf.Ldap.Url,
 	)
 
+	// 启动时LDAP不可用不再直接退出, 按退避间隔重试, 重试结束仍失败时由后续请求重新建立连接
+	retries := config.Conf.Ldap.InitRetries
+	if retries <= 0 {
+		retries = defaultLdapInitRetries
+	}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := ldapPool.Warmup()
+		if err == nil {
+			break
+		}
+		if attempt > retries {
+			Log.Errorf("初始化ldap连接失败, 已重试%d次, 将在后续请求时重新连接: %v", retries, err)
+			return
+		}
+		Log.Warnf("初始化ldap连接失败, %s后进行第%d次重试: %v", backoff, attempt, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxLdapInitBackoff {
+			backoff = maxLdapInitBackoff
+		}
+	}
+
 	Log.Info("初始化ldap完成! dsn: ", showDsn)
 }
 
-// GetLDAPConn 获取 LDAP 连接
+// GetLDAPConn 获取 LDAP 连接, 连接数达到上限时最多等待acquire-timeout
 func GetLDAPConn() (*ldap.Conn, error) {
-	return ldapPool.GetConnection()
+	ctx, cancel := ldapAcquireContext(config.Conf.Ldap)
+	defer cancel()
+	return ldapPool.GetConnection(ctx)
+}
+
+// GetLDAPConnContext 获取 LDAP 连接, 等待时间由ctx控制
+func GetLDAPConnContext(ctx context.Context) (*ldap.Conn, error) {
+	return ldapPool.GetConnection(ctx)
 }
 
-// PutLDAPConn 放回 LDAP 连接
+// PutLDAPConn 放回 LDAP 连接, 其他目录的连接放回其所属的连接池
 func PutLADPConn(conn *ldap.Conn) {
+	if conn == nil {
+		return
+	}
+	if pool, ok := ldapConnOwners.LoadAndDelete(conn); ok {
+		pool.(*LdapConnPool).PutConnection(conn)
+		return
+	}
 	ldapPool.PutConnection(conn)
 }
 
+// GetLDAPPoolStats 获取 LDAP 连接池统计
+func GetLDAPPoolStats() LdapPoolStats {
+	return ldapPool.Stats()
+}
+
+// LdapPoolStats 连接池统计
+type LdapPoolStats struct {
+	MaxOpen             int   `json:"maxOpen"`             // 最大连接数, 0表示不限制
+	Open                int   `json:"open"`                // 当前连接数, 包含使用中与空闲的连接
+	InUse               int   `json:"inUse"`               // 使用中的连接数
+	Idle                int   `json:"idle"`                // 空闲的连接数
+	WaitCount           int64 `json:"waitCount"`           // 因连接数达到上限而等待的次数
+	WaitDurationMs      int64 `json:"waitDurationMs"`      // 累计等待时间(毫秒)
+	AcquireTimeouts     int64 `json:"acquireTimeouts"`     // 等待超时的次数
+	Dials               int64 `json:"dials"`               // 新建连接的次数
+	DialFailures        int64 `json:"dialFailures"`        // 新建连接失败的次数
+	HealthCheckFailures int64 `json:"healthCheckFailures"` // 健康检查失败后重新连接的次数
+	IdleClosed          int64 `json:"idleClosed"`          // 空闲超时关闭的连接数
+}
+
 type LdapConnPool struct {
-	mu       sync.Mutex
-	conns    []*ldap.Conn
-	reqConns map[uint64]chan *ldap.Conn
-	openConn int
-	maxOpen  int
+	mu                  sync.Mutex
+	idle                []*idleLdapConn
+	sem                 chan struct{}
+	inUse               int
+	maxOpen             int
+	idleTimeout         time.Duration
+	healthCheckInterval time.Duration
+	dial                func() (*ldap.Conn, error)
+	stats               LdapPoolStats
+	closed              chan struct{}
 }
 
-// 获取一个 ladp Conn
-func (lcp *LdapConnPool) GetConnection() (*ldap.Conn, error) {
-	lcp.mu.Lock()
-	// 判断当前连接池内是否存在连接
-	connNum := len(lcp.conns)
-	if connNum > 0 {
-		lcp.openConn++
-		conn := lcp.conns[0]
-		copy(lcp.conns, lcp.conns[1:])
-		lcp.conns = lcp.conns[:connNum-1]
+type idleLdapConn struct {
+	conn  *ldap.Conn
+	since time.Time
+}
 
-		lcp.mu.Unlock()
-		// 发现连接已经 close 重新获取连接
-		if conn.IsClosing() {
-			return initLDAPConn()
-		}
-		return conn, nil
+// NewLdapConnPool 创建连接池, dial用于新建并绑定连接
+func NewLdapConnPool(conf *config.LdapConfig, dial func() (*ldap.Conn, error)) *LdapConnPool {
+	lcp := &LdapConnPool{
+		idle:                make([]*idleLdapConn, 0),
+		maxOpen:             conf.MaxConn,
+		idleTimeout:         time.Duration(conf.IdleTimeout) * time.Second,
+		healthCheckInterval: defaultLdapHealthCheckInterval,
+		dial:                dial,
+		closed:              make(chan struct{}),
+	}
+	if conf.HealthCheckInterval > 0 {
+		lcp.healthCheckInterval = time.Duration(conf.HealthCheckInterval) * time.Second
 	}
+	if lcp.maxOpen > 0 {
+		lcp.sem = make(chan struct{}, lcp.maxOpen)
+	}
+	if lcp.idleTimeout > 0 {
+		go lcp.closeIdleLoop()
+	}
+	return lcp
+}
 
-	// 当现有连接池为空时，并且当前超过最大连接限制
-	if lcp.maxOpen != 0 && lcp.openConn > lcp.maxOpen {
-		// 创建一个等待队列
-		req := make(chan *ldap.Conn, 1)
-		reqKey := lcp.nextRequestKeyLocked()
-		lcp.reqConns[reqKey] = req
-		lcp.mu.Unlock()
+// Warmup 新建一个连接放入连接池, 用于启动时检查LDAP是否可用
+func (lcp *LdapConnPool) Warmup() error {
+	conn, err := lcp.dialConn()
+	if err != nil {
+		return err
+	}
+	lcp.mu.Lock()
+	lcp.idle = append(lcp.idle, &idleLdapConn{conn: conn, since: time.Now()})
+	lcp.mu.Unlock()
+	return nil
+}
 
-		// 等待请求归还
-		return <-req, nil
-	} else {
-		lcp.openConn++
-		lcp.mu.Unlock()
-		return initLDAPConn()
+// 获取一个 ladp Conn, 连接数达到上限时等待其他连接归还, 直到ctx结束
+func (lcp *LdapConnPool) GetConnection(ctx context.Context) (*ldap.Conn, error) {
+	if err := lcp.acquire(ctx); err != nil {
+		return nil, err
+	}
+	conn, err := lcp.takeConn(ctx)
+	if err != nil {
+		lcp.release()
+		return nil, err
 	}
+	lcp.mu.Lock()
+	lcp.inUse++
+	lcp.mu.Unlock()
+	return conn, nil
 }
 
+// 放回连接, 已关闭的连接直接丢弃, 获取连接失败时传入的nil不做处理
 func (lcp *LdapConnPool) PutConnection(conn *ldap.Conn) {
-	log.Println("放回了一个 LDAP 连接")
+	if conn == nil {
+		return
+	}
+	lcp.mu.Lock()
+	lcp.inUse--
+	if !conn.IsClosing() && !lcp.isClosed() {
+		lcp.idle = append(lcp.idle, &idleLdapConn{conn: conn, since: time.Now()})
+	} else {
+		conn.Close()
+	}
+	lcp.mu.Unlock()
+	lcp.release()
+}
+
+// Close 关闭连接池, 关闭所有空闲连接, 使用中的连接放回时直接关闭
+func (lcp *LdapConnPool) Close() {
 	lcp.mu.Lock()
 	defer lcp.mu.Unlock()
+	if lcp.isClosed() {
+		return
+	}
+	close(lcp.closed)
+	for _, ic := range lcp.idle {
+		ic.conn.Close()
+	}
+	lcp.idle = nil
+}
 
-	// 先判断是否存在等待的队列
-	if num := len(lcp.reqConns); num > 0 {
-		var req chan *ldap.Conn
-		var reqKey uint64
-		for reqKey, req = range lcp.reqConns {
+func (lcp *LdapConnPool) isClosed() bool {
+	select {
+	case <-lcp.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stats 获取连接池统计
+func (lcp *LdapConnPool) Stats() LdapPoolStats {
+	lcp.mu.Lock()
+	defer lcp.mu.Unlock()
+	stats := lcp.stats
+	stats.MaxOpen = lcp.maxOpen
+	stats.InUse = lcp.inUse
+	stats.Idle = len(lcp.idle)
+	stats.Open = stats.InUse + stats.Idle
+	return stats
+}
+
+// acquire 占用一个连接名额
+func (lcp *LdapConnPool) acquire(ctx context.Context) error {
+	if lcp.sem == nil {
+		return nil
+	}
+	select {
+	case lcp.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	start := time.Now()
+	lcp.mu.Lock()
+	lcp.stats.WaitCount++
+	lcp.mu.Unlock()
+	select {
+	case lcp.sem <- struct{}{}:
+		lcp.mu.Lock()
+		lcp.stats.WaitDurationMs += time.Since(start).Milliseconds()
+		lcp.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		lcp.mu.Lock()
+		lcp.stats.WaitDurationMs += time.Since(start).Milliseconds()
+		lcp.stats.AcquireTimeouts++
+		lcp.mu.Unlock()
+		return fmt.Errorf("获取LDAP连接超时, 连接数已达上限%d: %w", lcp.maxOpen, ctx.Err())
+	}
+}
+
+func (lcp *LdapConnPool) release() {
+	if lcp.sem != nil {
+		<-lcp.sem
+	}
+}
+
+// takeConn 优先复用最近归还的空闲连接, 超时或检测不可用的连接关闭后重新连接
+func (lcp *LdapConnPool) takeConn(ctx context.Context) (*ldap.Conn, error) {
+	for {
+		lcp.mu.Lock()
+		n := len(lcp.idle)
+		if n == 0 {
+			lcp.mu.Unlock()
 			break
 		}
-		delete(lcp.reqConns, reqKey)
-		req <- conn
-		return
-	} else {
-		lcp.openConn--
-		if !conn.IsClosing() {
-			lcp.conns = append(lcp.conns, conn)
+		ic := lcp.idle[n-1]
+		lcp.idle = lcp.idle[:n-1]
+		lcp.mu.Unlock()
+
+		idleFor := time.Since(ic.since)
+		switch {
+		case ic.conn.IsClosing():
+			continue
+		case lcp.idleTimeout > 0 && idleFor > lcp.idleTimeout:
+			ic.conn.Close()
+			lcp.mu.Lock()
+			lcp.stats.IdleClosed++
+			lcp.mu.Unlock()
+			continue
+		case idleFor > lcp.healthCheckInterval && !pingLDAPConn(ic.conn):
+			ic.conn.Close()
+			lcp.mu.Lock()
+			lcp.stats.HealthCheckFailures++
+			lcp.mu.Unlock()
+			continue
 		}
+		return ic.conn, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("获取LDAP连接超时: %w", err)
+	}
+	return lcp.dialConn()
 }
 
-// 获取下一个请求令牌
-func (lcp *LdapConnPool) nextRequestKeyLocked() uint64 {
+func (lcp *LdapConnPool) dialConn() (*ldap.Conn, error) {
+	conn, err := lcp.dial()
+	lcp.mu.Lock()
+	lcp.stats.Dials++
+	if err != nil {
+		lcp.stats.DialFailures++
+	}
+	lcp.mu.Unlock()
+	return conn, err
+}
+
+// closeIdleLoop 定期关闭空闲超时的连接, 避免长期占用服务端连接
+func (lcp *LdapConnPool) closeIdleLoop() {
+	interval := lcp.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
-		reqKey := rand.Uint64()
-		if _, ok := lcp.reqConns[reqKey]; !ok {
-			return reqKey
+		select {
+		case <-ticker.C:
+			lcp.closeExpired()
+		case <-lcp.closed:
+			return
 		}
 	}
 }
 
-// 获取 ladp 连接
-func initLDAPConn() (*ldap.Conn, error) {
-	ldap, err := ldap.DialURL(config.Conf.Ldap.Url, ldap.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}))
-	if err != nil {
-		return nil, err
+func (lcp *LdapConnPool) closeExpired() {
+	lcp.mu.Lock()
+	expired := make([]*ldap.Conn, 0)
+	kept := lcp.idle[:0]
+	for _, ic := range lcp.idle {
+		if time.Since(ic.since) > lcp.idleTimeout || ic.conn.IsClosing() {
+			expired = append(expired, ic.conn)
+			continue
+		}
+		kept = append(kept, ic)
 	}
-	err = ldap.Bind(config.Conf.Ldap.AdminDN, config.Conf.Ldap.AdminPass)
-	if err != nil {
-		return nil, err
+	lcp.idle = kept
+	lcp.stats.IdleClosed += int64(len(expired))
+	lcp.mu.Unlock()
+
+	for _, conn := range expired {
+		conn.Close()
+	}
+}
+
+// pingLDAPConn 读取RootDSE检测连接是否可用, 服务端返回的LDAP错误说明连接仍然可用
+func pingLDAPConn(conn *ldap.Conn) bool {
+	// go-ldap的SetTimeout(0)不生效, 检查结束后需要显式恢复为连接的请求超时
+	conn.SetTimeout(ldapHealthCheckTimeout)
+	defer conn.SetTimeout(ldap.DefaultTimeout)
+	_, err := conn.Search(ldap.NewSearchRequest(
+		"",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"1.1"},
+		nil,
+	))
+	return err == nil || (!ldap.IsErrorWithCode(err, ldap.ErrorNetwork) && !conn.IsClosing())
+}
+
+// ldapDialer 按目录配置获取 ladp 连接
+func ldapDialer(conf *config.LdapConfig) func() (*ldap.Conn, error) {
+	return func() (*ldap.Conn, error) {
+		conn, err := dialLDAP(conf)
+		if err != nil {
+			return nil, err
+		}
+		// 请求超时与健康检查后恢复的值保持一致
+		conn.SetTimeout(ldap.DefaultTimeout)
+		err = conn.Bind(conf.AdminDN, conf.AdminPass)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("绑定admin账号异常: %v", err)
+		}
+		return conn, err
 	}
-	return ldap, err
 }
@@ -0,0 +1,230 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+// DefaultDirectoryID 配置文件中的默认目录编号
+const DefaultDirectoryID uint = 0
+
+// ldapDirectory 数据库中维护的LDAP目录, 每个目录使用独立的连接池
+type ldapDirectory struct {
+	conf *config.LdapConfig
+	pool *LdapConnPool
+}
+
+var (
+	ldapDirMu       sync.RWMutex
+	ldapDirectories = make(map[uint]*ldapDirectory)
+	// 从其他目录取出的连接与其连接池的对应关系, 放回连接时使用
+	ldapConnOwners sync.Map
+)
+
+// InitLdapDirectories 为数据库中的LDAP目录建立连接池, 单个目录不可用时不影响启动
+func InitLdapDirectories() {
+	var directories []*model.Directory
+	if err := DB.Find(&directories).Error; err != nil {
+		Log.Errorf("获取LDAP目录列表失败: %v", err)
+		return
+	}
+	for _, d := range directories {
+		if err := RegisterLdapDirectory(d); err != nil {
+			Log.Errorf("初始化LDAP目录[%s]失败: %v", d.Name, err)
+			continue
+		}
+		if err := ldapDirectoryPool(d.ID).Warmup(); err != nil {
+			Log.Warnf("连接LDAP目录[%s]失败, 将在后续请求时重新连接: %v", d.Name, err)
+			continue
+		}
+		Log.Infof("初始化LDAP目录[%s]完成! url: %s", d.Name, d.Url)
+	}
+}
+
+// DirectoryLdapConfig 生成目录的LDAP配置, 用户schema、密码加密方式等其他配置与默认目录一致
+func DirectoryLdapConfig(d *model.Directory) (*config.LdapConfig, error) {
+	passwd, err := tools.RSADecrypt([]byte(d.AdminPass), config.Conf.System.RSAPrivateBytes)
+	if err != nil {
+		return nil, fmt.Errorf("管理员密码解密失败: %v", err)
+	}
+	conf := *config.Conf.Ldap
+	conf.Url = d.Url
+	conf.BaseDN = d.BaseDN
+	conf.UserDN = d.UserDN
+	conf.AdminDN = d.AdminDN
+	conf.AdminPass = string(passwd)
+	if d.MaxConn > 0 {
+		conf.MaxConn = d.MaxConn
+	}
+	conf.TLS = nil
+	if d.StartTLS == 1 || d.CAFile != "" {
+		conf.TLS = &config.LdapTLSConfig{StartTLS: d.StartTLS == 1, CAFile: d.CAFile}
+	}
+	return &conf, nil
+}
+
+// TestLdapDirectory 按目录配置建立一次连接并绑定管理员, 用于保存前校验配置
+func TestLdapDirectory(d *model.Directory) error {
+	conf, err := DirectoryLdapConfig(d)
+	if err != nil {
+		return err
+	}
+	conn, err := ldapDialer(conf)()
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+// RegisterLdapDirectory 注册或更新目录, 目录已存在时关闭旧的连接池
+func RegisterLdapDirectory(d *model.Directory) error {
+	if d.ID == DefaultDirectoryID {
+		return errors.New("目录编号不能为0")
+	}
+	conf, err := DirectoryLdapConfig(d)
+	if err != nil {
+		return err
+	}
+	dir := &ldapDirectory{conf: conf, pool: NewLdapConnPool(conf, ldapDialer(conf))}
+
+	ldapDirMu.Lock()
+	old := ldapDirectories[d.ID]
+	ldapDirectories[d.ID] = dir
+	ldapDirMu.Unlock()
+	if old != nil {
+		old.pool.Close()
+	}
+	return nil
+}
+
+// RemoveLdapDirectory 移除目录并关闭其连接池
+func RemoveLdapDirectory(id uint) {
+	ldapDirMu.Lock()
+	old := ldapDirectories[id]
+	delete(ldapDirectories, id)
+	ldapDirMu.Unlock()
+	if old != nil {
+		old.pool.Close()
+	}
+}
+
+// LdapDirectoryExist 判断目录是否存在, 默认目录始终存在
+func LdapDirectoryExist(id uint) bool {
+	if id == DefaultDirectoryID {
+		return true
+	}
+	ldapDirMu.RLock()
+	defer ldapDirMu.RUnlock()
+	_, ok := ldapDirectories[id]
+	return ok
+}
+
+// LdapDirectoryIds 获取全部目录的编号, 默认目录在前
+func LdapDirectoryIds() []uint {
+	ldapDirMu.RLock()
+	defer ldapDirMu.RUnlock()
+	ids := []uint{DefaultDirectoryID}
+	for id := range ldapDirectories {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids[1:], func(i, j int) bool { return ids[i+1] < ids[j+1] })
+	return ids
+}
+
+// LdapDirectoryConf 获取目录的LDAP配置, 目录不存在时返回默认目录的配置
+func LdapDirectoryConf(id uint) *config.LdapConfig {
+	ldapDirMu.RLock()
+	defer ldapDirMu.RUnlock()
+	if dir, ok := ldapDirectories[id]; ok {
+		return dir.conf
+	}
+	return config.Conf.Ldap
+}
+
+// LdapDirectoryOf 根据DN判断所属目录, 取基础DN匹配最长的目录, 都不匹配时为默认目录
+func LdapDirectoryOf(dn string) uint {
+	dn = strings.ToLower(dn)
+	id, matched := DefaultDirectoryID, 0
+	if base := strings.ToLower(config.Conf.Ldap.BaseDN); strings.HasSuffix(dn, base) {
+		matched = len(base)
+	}
+	ldapDirMu.RLock()
+	defer ldapDirMu.RUnlock()
+	for dirId, dir := range ldapDirectories {
+		base := strings.ToLower(dir.conf.BaseDN)
+		if len(base) > matched && strings.HasSuffix(dn, base) {
+			id, matched = dirId, len(base)
+		}
+	}
+	return id
+}
+
+// LdapUserDNOf 根据用户名生成指定目录中的用户DN
+func LdapUserDNOf(id uint, username string) string {
+	return fmt.Sprintf("%s=%s,%s", LdapUserRdnAttr(), username, LdapDirectoryConf(id).UserDN)
+}
+
+// GetLDAPConnOf 获取指定目录的 LDAP 连接
+func GetLDAPConnOf(id uint) (*ldap.Conn, error) {
+	pool := ldapDirectoryPool(id)
+	if pool == nil {
+		return nil, fmt.Errorf("LDAP目录%d不存在", id)
+	}
+	ctx, cancel := ldapAcquireContext(LdapDirectoryConf(id))
+	defer cancel()
+	conn, err := pool.GetConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pool != ldapPool {
+		ldapConnOwners.Store(conn, pool)
+	}
+	return conn, nil
+}
+
+// GetLDAPConnByDN 获取DN所属目录的 LDAP 连接
+func GetLDAPConnByDN(dn string) (*ldap.Conn, error) {
+	return GetLDAPConnOf(LdapDirectoryOf(dn))
+}
+
+// GetLdapDirectoryPoolStats 获取指定目录的连接池统计
+func GetLdapDirectoryPoolStats(id uint) (LdapPoolStats, bool) {
+	pool := ldapDirectoryPool(id)
+	if pool == nil {
+		return LdapPoolStats{}, false
+	}
+	return pool.Stats(), true
+}
+
+func ldapDirectoryPool(id uint) *LdapConnPool {
+	if id == DefaultDirectoryID {
+		return ldapPool
+	}
+	ldapDirMu.RLock()
+	defer ldapDirMu.RUnlock()
+	if dir, ok := ldapDirectories[id]; ok {
+		return dir.pool
+	}
+	return nil
+}
+
+// ldapAcquireContext 连接数达到上限时最多等待acquire-timeout
+func ldapAcquireContext(conf *config.LdapConfig) (context.Context, context.CancelFunc) {
+	timeout := defaultLdapAcquireTimeout
+	if conf.AcquireTimeout > 0 {
+		timeout = time.Duration(conf.AcquireTimeout) * time.Second
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
@@ -0,0 +1,225 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+)
+
+// LdapBackendAD 目录服务为Microsoft Active Directory
+const LdapBackendAD = "ad"
+
+// IsActiveDirectory 目录服务是否为Active Directory
+func IsActiveDirectory() bool {
+	return strings.EqualFold(config.Conf.Ldap.Backend, LdapBackendAD)
+}
+
+// LdapUserRdnAttr 用户DN的RDN属性, OpenLDAP为uid, AD为cn
+func LdapUserRdnAttr() string {
+	if IsActiveDirectory() {
+		return "cn"
+	}
+	return "uid"
+}
+
+// LdapUserDN 根据用户名生成用户DN
+func LdapUserDN(username string) string {
+	return fmt.Sprintf("%s=%s,%s", LdapUserRdnAttr(), username, config.Conf.Ldap.UserDN)
+}
+
+// 未配置schema时的默认映射, 与早期版本写入的属性保持一致
+var defaultLdapUserSchema = config.LdapUserSchemaConfig{
+	ObjectClasses: []string{"inetOrgPerson"},
+	Attributes: map[string][]string{
+		"username":      {"cn"},
+		"nickname":      {"sn", "displayName"},
+		"givenName":     {"givenName"},
+		"mail":          {"mail"},
+		"jobNumber":     {"employeeNumber"},
+		"mobile":        {"mobile"},
+		"postalAddress": {"postalAddress"},
+		"departments":   {"businessCategory"},
+		"position":      {"departmentNumber"},
+		"introduction":  {"description"},
+	},
+	ImportAttributes: map[string]string{
+		"nickname":      "displayName",
+		"givenName":     "givenName",
+		"mail":          "mail",
+		"jobNumber":     "employeeNumber",
+		"mobile":        "mobile",
+		"postalAddress": "postalAddress",
+		"departments":   "businessCategory",
+		"position":      "departmentNumber",
+		"introduction":  "cn",
+	},
+}
+
+// AD用户的默认映射, 用户名写入sAMAccountName, userPrincipalName单独生成
+var defaultADUserSchema = config.LdapUserSchemaConfig{
+	ObjectClasses: []string{"top", "person", "organizationalPerson", "user"},
+	Attributes: map[string][]string{
+		"username":      {"sAMAccountName"},
+		"nickname":      {"sn", "displayName"},
+		"givenName":     {"givenName"},
+		"mail":          {"mail"},
+		"jobNumber":     {"employeeNumber"},
+		"mobile":        {"mobile"},
+		"postalAddress": {"streetAddress"},
+		"departments":   {"department"},
+		"position":      {"title"},
+		"introduction":  {"description"},
+	},
+	ImportAttributes: map[string]string{
+		"nickname":      "displayName",
+		"givenName":     "givenName",
+		"mail":          "mail",
+		"jobNumber":     "employeeNumber",
+		"mobile":        "mobile",
+		"postalAddress": "streetAddress",
+		"departments":   "department",
+		"position":      "title",
+		"introduction":  "description",
+	},
+}
+
+// 可映射的用户字段, 键为小写的json字段名(配置文件中的键会被统一转为小写)
+var ldapUserFields = map[string]func(u *model.User) *string{
+	"username":      func(u *model.User) *string { return &u.Username },
+	"nickname":      func(u *model.User) *string { return &u.Nickname },
+	"givenname":     func(u *model.User) *string { return &u.GivenName },
+	"mail":          func(u *model.User) *string { return &u.Mail },
+	"jobnumber":     func(u *model.User) *string { return &u.JobNumber },
+	"mobile":        func(u *model.User) *string { return &u.Mobile },
+	"avatar":        func(u *model.User) *string { return &u.Avatar },
+	"postaladdress": func(u *model.User) *string { return &u.PostalAddress },
+	"departments":   func(u *model.User) *string { return &u.Departments },
+	"position":      func(u *model.User) *string { return &u.Position },
+	"introduction":  func(u *model.User) *string { return &u.Introduction },
+}
+
+// LdapAttr 待写入的LDAP属性
+type LdapAttr struct {
+	Name  string
+	Value string
+}
+
+// GetLdapUserSchema 获取当前生效的用户schema, 未配置的部分使用默认值
+func GetLdapUserSchema() config.LdapUserSchemaConfig {
+	schema := defaultLdapUserSchema
+	conf := config.Conf.Ldap.UserSchema
+	if IsActiveDirectory() {
+		// AD用户的objectClass固定, 只允许调整属性映射
+		schema, conf = defaultADUserSchema, nil
+		if config.Conf.Ldap.AD != nil {
+			conf = config.Conf.Ldap.AD.UserSchema
+		}
+	}
+	if conf == nil {
+		return schema
+	}
+	if len(conf.ObjectClasses) > 0 && !IsActiveDirectory() {
+		schema.ObjectClasses = conf.ObjectClasses
+	}
+	if len(conf.Attributes) > 0 {
+		schema.Attributes = conf.Attributes
+	}
+	if len(conf.ImportAttributes) > 0 {
+		schema.ImportAttributes = conf.ImportAttributes
+	}
+	schema.SkipAttributes = conf.SkipAttributes
+	return schema
+}
+
+// LdapUserAttributes 按schema映射生成用户的LDAP属性, 不包含RDN属性与跳过的属性
+func LdapUserAttributes(user *model.User) []LdapAttr {
+	schema := GetLdapUserSchema()
+	skip := map[string]bool{strings.ToLower(LdapUserRdnAttr()): true}
+	for _, attr := range schema.SkipAttributes {
+		skip[strings.ToLower(attr)] = true
+	}
+
+	// 按字段名排序, 保证每次生成的属性顺序一致
+	fields := make([]string, 0, len(schema.Attributes))
+	for field := range schema.Attributes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	attrs := make([]LdapAttr, 0)
+	for _, field := range fields {
+		getter, ok := ldapUserFields[strings.ToLower(field)]
+		if !ok {
+			Log.Warnf("LDAP用户schema中的字段[%s]不存在，已忽略", field)
+			continue
+		}
+		for _, name := range schema.Attributes[field] {
+			if name == "" || skip[strings.ToLower(name)] {
+				continue
+			}
+			attrs = append(attrs, LdapAttr{Name: name, Value: *getter(user)})
+		}
+	}
+	return attrs
+}
+
+// LdapUserImportAttributes 从LDAP同步用户时, 返回用户字段与LDAP属性的对应关系
+func LdapUserImportAttributes() map[string]string {
+	attrs := make(map[string]string)
+	for field, name := range GetLdapUserSchema().ImportAttributes {
+		if _, ok := ldapUserFields[strings.ToLower(field)]; ok && name != "" {
+			attrs[strings.ToLower(field)] = name
+		}
+	}
+	return attrs
+}
+
+// SetLdapUserField 按字段名为用户赋值, 用于从LDAP同步用户
+func SetLdapUserField(user *model.User, field, value string) {
+	if getter, ok := ldapUserFields[strings.ToLower(field)]; ok {
+		*getter(user) = value
+	}
+}
+
+// LdapUserObjectClassFilter 用于搜索用户条目的objectClass过滤条件
+func LdapUserObjectClassFilter() string {
+	if IsActiveDirectory() {
+		// AD的计算机对象同样是user类, 需要同时按objectCategory过滤
+		return "(&(objectCategory=person)(objectClass=user))"
+	}
+	objectClasses := GetLdapUserSchema().ObjectClasses
+	if len(objectClasses) == 0 {
+		return "(objectClass=inetOrgPerson)"
+	}
+	// 以最具体的结构化objectClass(通常写在第一个)作为过滤条件
+	return "(objectClass=" + objectClasses[0] + ")"
+}
+
+// 分组可用的objectClass
+const (
+	GroupSchemaUniqueNames = "groupOfUniqueNames"
+	GroupSchemaNames       = "groupOfNames"
+	GroupSchemaAD          = "group"
+)
+
+// DefaultGroupSchema 新建分组使用的objectClass
+func DefaultGroupSchema() string {
+	if IsActiveDirectory() {
+		return GroupSchemaAD
+	}
+	if config.Conf.Ldap.GroupSchema == GroupSchemaNames {
+		return GroupSchemaNames
+	}
+	return GroupSchemaUniqueNames
+}
+
+// GroupMemberAttr 分组objectClass对应的成员属性, 为空时按早期版本的groupOfUniqueNames处理
+func GroupMemberAttr(schema string) string {
+	if strings.EqualFold(schema, GroupSchemaNames) || strings.EqualFold(schema, GroupSchemaAD) {
+		return "member"
+	}
+	return "uniqueMember"
+}
@@ -0,0 +1,58 @@
+package common
+
+import (
+	"github.com/eryajf/go-ldap-admin/config"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+// 未配置page-size时每页返回的条目数
+const defaultLdapPageSize = 500
+
+// LdapPageSize 分页搜索每页返回的条目数
+func LdapPageSize() uint32 {
+	if config.Conf.Ldap.PageSize > 0 {
+		return uint32(config.Conf.Ldap.PageSize)
+	}
+	return defaultLdapPageSize
+}
+
+// SearchPaged 使用分页控制执行搜索, 每收到一页就逐条交给fn处理, 避免超出服务端sizelimit, 也不需要一次性加载全部条目
+// fn返回错误时放弃剩余的分页并返回该错误; 服务端不支持分页控制时按普通搜索处理
+func SearchPaged(conn *ldap.Conn, searchRequest *ldap.SearchRequest, fn func(entry *ldap.Entry) error) error {
+	paging := ldap.NewControlPaging(LdapPageSize())
+	controls := searchRequest.Controls
+	defer func() { searchRequest.Controls = controls }()
+	searchRequest.Controls = append(append([]ldap.Control{}, controls...), paging)
+
+	for {
+		sr, err := conn.Search(searchRequest)
+		if err != nil {
+			return err
+		}
+		for _, entry := range sr.Entries {
+			if err := fn(entry); err != nil {
+				// 页大小为0表示通知服务端释放分页状态
+				paging.PagingSize = 0
+				_, _ = conn.Search(searchRequest)
+				return err
+			}
+		}
+		result, ok := ldap.FindControl(sr.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if !ok || len(result.Cookie) == 0 {
+			return nil
+		}
+		paging.SetCookie(result.Cookie)
+	}
+}
+
+// SearchLDAP 从连接池获取连接执行分页搜索
+func SearchLDAP(searchRequest *ldap.SearchRequest, fn func(entry *ldap.Entry) error) error {
+	// 获取 LDAP 连接
+	conn, err := GetLDAPConn()
+	defer PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+	return SearchPaged(conn, searchRequest, fn)
+}
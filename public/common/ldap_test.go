@@ -0,0 +1,302 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+// fakeLdapDialer 通过内存管道建立连接, 服务端按分页控制返回entries个条目
+type fakeLdapDialer struct {
+	mu       sync.Mutex
+	servers  []net.Conn
+	entries  int
+	searches []uint32 // 每次搜索请求的页大小
+}
+
+func (d *fakeLdapDialer) dial() (*ldap.Conn, error) {
+	client, server := net.Pipe()
+	d.mu.Lock()
+	d.servers = append(d.servers, server)
+	d.mu.Unlock()
+	go func() {
+		for {
+			packet, err := ber.ReadPacket(server)
+			if err != nil {
+				return
+			}
+			if packet.Children[1].Tag != ldap.ApplicationSearchRequest {
+				continue
+			}
+			if _, err := server.Write(d.search(packet)); err != nil {
+				return
+			}
+		}
+	}()
+	conn := ldap.NewConn(client, false)
+	conn.Start()
+	return conn, nil
+}
+
+// search 按请求中的分页控制返回一页条目, cookie为下一页的起始位置
+func (d *fakeLdapDialer) search(packet *ber.Packet) []byte {
+	id := packet.Children[0]
+	var paging *ldap.ControlPaging
+	if len(packet.Children) > 2 {
+		for _, child := range packet.Children[2].Children {
+			if control, err := ldap.DecodeControl(child); err == nil {
+				if p, ok := control.(*ldap.ControlPaging); ok {
+					paging = p
+				}
+			}
+		}
+	}
+
+	start, end := 0, d.entries
+	if paging != nil {
+		d.mu.Lock()
+		d.searches = append(d.searches, paging.PagingSize)
+		d.mu.Unlock()
+		start, _ = strconv.Atoi(string(paging.Cookie))
+		if paging.PagingSize == 0 {
+			start = end
+		} else if start+int(paging.PagingSize) < end {
+			end = start + int(paging.PagingSize)
+		}
+	}
+
+	buf := make([]byte, 0)
+	for i := start; i < end; i++ {
+		entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+		entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, fmt.Sprintf("uid=user%d,dc=test", i), ""))
+		entry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, ""))
+		buf = append(buf, fakeLdapMessage(id, entry, nil).Bytes()...)
+	}
+
+	done := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultDone, nil, "")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(ldap.LDAPResultSuccess), ""))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	var controls *ber.Packet
+	if paging != nil {
+		result := ldap.NewControlPaging(paging.PagingSize)
+		if end < d.entries {
+			result.SetCookie([]byte(strconv.Itoa(end)))
+		}
+		controls = ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "")
+		controls.AppendChild(result.Encode())
+	}
+	return append(buf, fakeLdapMessage(id, done, controls).Bytes()...)
+}
+
+func fakeLdapMessage(id, op, controls *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id.Value, ""))
+	packet.AppendChild(op)
+	if controls != nil {
+		packet.AppendChild(controls)
+	}
+	return packet
+}
+
+// kill 模拟LDAP服务端重启, 断开所有已建立的连接
+func (d *fakeLdapDialer) kill() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, server := range d.servers {
+		_ = server.Close()
+	}
+	d.servers = nil
+}
+
+func TestLdapConnPoolAcquire(t *testing.T) {
+	dialer := &fakeLdapDialer{}
+	pool := NewLdapConnPool(&config.LdapConfig{MaxConn: 1}, dialer.dial)
+
+	conn, err := pool.GetConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.GetConnection(ctx); err == nil {
+		t.Fatal("连接数达到上限时应等待超时")
+	}
+	pool.PutConnection(conn)
+	// 获取连接失败时调用方同样会放回nil, 不应影响连接数
+	pool.PutConnection(nil)
+
+	reused, err := pool.GetConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused != conn {
+		t.Fatal("应复用空闲连接")
+	}
+	stats := pool.Stats()
+	if stats.Dials != 1 || stats.InUse != 1 || stats.AcquireTimeouts != 1 || stats.WaitCount != 1 {
+		t.Fatalf("连接池统计错误: %+v", stats)
+	}
+	pool.PutConnection(reused)
+}
+
+func TestLdapConnPoolRedial(t *testing.T) {
+	dialer := &fakeLdapDialer{}
+	pool := NewLdapConnPool(&config.LdapConfig{MaxConn: 2}, dialer.dial)
+	if err := pool.Warmup(); err != nil {
+		t.Fatal(err)
+	}
+
+	dialer.kill()
+	// 等待连接感知到服务端断开
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Idle > 0 && time.Now().Before(deadline) {
+		pool.mu.Lock()
+		closing := pool.idle[0].conn.IsClosing()
+		pool.mu.Unlock()
+		if closing {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := pool.GetConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.IsClosing() {
+		t.Fatal("服务端断开后应重新建立连接")
+	}
+	if stats := pool.Stats(); stats.Dials != 2 {
+		t.Fatalf("应重新建立一次连接, 实际新建连接%d次", stats.Dials)
+	}
+	pool.PutConnection(conn)
+}
+
+func TestLdapConnPoolHealthCheckAndIdleTimeout(t *testing.T) {
+	dialer := &fakeLdapDialer{}
+	pool := NewLdapConnPool(&config.LdapConfig{MaxConn: 2}, dialer.dial)
+	// 每次复用前都检测连接
+	pool.healthCheckInterval = 0
+
+	conn, err := pool.GetConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.PutConnection(conn)
+	time.Sleep(time.Millisecond)
+	reused, err := pool.GetConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused != conn || pool.Stats().HealthCheckFailures != 0 {
+		t.Fatal("检测可用的连接应被复用")
+	}
+	pool.PutConnection(reused)
+
+	pool.idleTimeout = 10 * time.Millisecond
+	time.Sleep(20 * time.Millisecond)
+	pool.closeExpired()
+	if stats := pool.Stats(); stats.Idle != 0 || stats.IdleClosed != 1 {
+		t.Fatalf("空闲超时的连接应被关闭: %+v", stats)
+	}
+	if !conn.IsClosing() {
+		t.Fatal("空闲超时的连接应被关闭")
+	}
+}
+
+func TestSearchPaged(t *testing.T) {
+	config.Conf.Ldap = &config.LdapConfig{PageSize: 3}
+	dialer := &fakeLdapDialer{entries: 7}
+	conn, err := dialer.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	searchRequest := ldap.NewSearchRequest("dc=test", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+
+	dns := make([]string, 0)
+	err = SearchPaged(conn, searchRequest, func(entry *ldap.Entry) error {
+		dns = append(dns, entry.DN)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dns) != 7 || dns[6] != "uid=user6,dc=test" {
+		t.Fatalf("应分页读取全部7个条目, 实际为%v", dns)
+	}
+	if len(dialer.searches) != 3 {
+		t.Fatalf("每页3个条目应搜索3次, 实际为%d次", len(dialer.searches))
+	}
+	if len(searchRequest.Controls) != 0 {
+		t.Fatal("分页控制不应残留在搜索请求中")
+	}
+
+	// 处理出错时停止读取, 并以页大小0通知服务端放弃分页
+	dialer.searches = nil
+	stop := errors.New("stop")
+	count := 0
+	err = SearchPaged(conn, searchRequest, func(entry *ldap.Entry) error {
+		count++
+		if count == 4 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || count != 4 {
+		t.Fatalf("处理出错时应停止读取, 实际读取%d个条目, 错误为%v", count, err)
+	}
+	if len(dialer.searches) != 3 || dialer.searches[2] != 0 {
+		t.Fatalf("应以页大小0放弃分页, 实际搜索请求为%v", dialer.searches)
+	}
+}
+
+func TestLdapDirectoryConn(t *testing.T) {
+	config.Conf.Ldap = &config.LdapConfig{BaseDN: "dc=test", UserDN: "ou=people,dc=test"}
+	defaultDialer, subDialer := &fakeLdapDialer{}, &fakeLdapDialer{}
+	ldapPool = NewLdapConnPool(config.Conf.Ldap, defaultDialer.dial)
+	subConf := &config.LdapConfig{BaseDN: "dc=sub,dc=test", UserDN: "ou=people,dc=sub,dc=test", MaxConn: 1}
+	ldapDirMu.Lock()
+	ldapDirectories[7] = &ldapDirectory{conf: subConf, pool: NewLdapConnPool(subConf, subDialer.dial)}
+	ldapDirMu.Unlock()
+	defer RemoveLdapDirectory(7)
+
+	if id := LdapDirectoryOf("uid=a,ou=people,DC=sub,dc=test"); id != 7 {
+		t.Fatalf("应匹配基础DN最长的目录, 实际为%d", id)
+	}
+	if id := LdapDirectoryOf("uid=a,ou=people,dc=test"); id != DefaultDirectoryID {
+		t.Fatalf("应属于默认目录, 实际为%d", id)
+	}
+	if dn := LdapUserDNOf(7, "a"); dn != "uid=a,ou=people,dc=sub,dc=test" {
+		t.Fatalf("用户DN应位于所属目录的用户DN下, 实际为%s", dn)
+	}
+
+	conn, err := GetLDAPConnByDN("uid=a,ou=people,dc=sub,dc=test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	PutLADPConn(conn)
+	subStats, _ := GetLdapDirectoryPoolStats(7)
+	if subStats.Dials != 1 || subStats.Idle != 1 || ldapPool.Stats().Idle != 0 {
+		t.Fatalf("连接应放回所属目录的连接池: %+v", subStats)
+	}
+
+	if _, err := GetLDAPConnOf(8); err == nil {
+		t.Fatal("不存在的目录应返回错误")
+	}
+	RemoveLdapDirectory(7)
+	if LdapDirectoryExist(7) || !conn.IsClosing() {
+		t.Fatal("移除目录后应关闭其空闲连接")
+	}
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+var ldapTLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// dialLDAP 建立LDAP连接, url为ldaps://时直接使用TLS, 开启start-tls时在ldap://连接上升级为TLS
+func dialLDAP(conf *config.LdapConfig) (*ldap.Conn, error) {
+	u, err := url.Parse(conf.Url)
+	if err != nil {
+		return nil, fmt.Errorf("LDAP地址%s格式错误: %v", conf.Url, err)
+	}
+	ldaps := strings.EqualFold(u.Scheme, "ldaps")
+	startTLS := conf.TLS != nil && conf.TLS.StartTLS
+	if ldaps && startTLS {
+		return nil, errors.New("LDAP地址为ldaps://时不能同时开启start-tls")
+	}
+
+	opts := []ldap.DialOpt{ldap.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second})}
+	var tlsConfig *tls.Config
+	if ldaps || startTLS {
+		tlsConfig, err = ldapTLSConfig(conf.TLS, u.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, ldap.DialWithTLSConfig(tlsConfig))
+	}
+
+	conn, err := ldap.DialURL(conf.Url, opts...)
+	if err != nil {
+		if ldaps {
+			return nil, describeTLSError("LDAPS", err)
+		}
+		return nil, err
+	}
+	if startTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, describeTLSError("StartTLS", err)
+		}
+	}
+	return conn, nil
+}
+
+// ldapTLSConfig 按配置生成TLS配置, 未配置时使用系统证书校验服务端, 最低TLS1.2
+func ldapTLSConfig(conf *config.LdapTLSConfig, host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: host}
+	if conf == nil {
+		return tlsConfig, nil
+	}
+	if conf.ServerName != "" {
+		tlsConfig.ServerName = conf.ServerName
+	}
+	if conf.MinVersion != "" {
+		version, ok := ldapTLSVersions[conf.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("不支持的TLS版本[%s]，可选 1.0 1.1 1.2 1.3", conf.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取LDAP CA证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("LDAP CA证书%s中没有有效的PEM证书", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, errors.New("LDAP客户端证书与私钥需要同时配置")
+		}
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载LDAP客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// describeTLSError 为常见的TLS握手失败原因给出明确提示
+func describeTLSError(mode string, err error) error {
+	cause := err
+	var ldapErr *ldap.Error
+	if errors.As(err, &ldapErr) && ldapErr.Err != nil {
+		cause = ldapErr.Err
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.As(cause, &unknownAuthority):
+		return fmt.Errorf("%s握手失败: 服务端证书不受信任，请检查ca-file配置: %v", mode, cause)
+	case errors.As(cause, &hostnameErr):
+		return fmt.Errorf("%s握手失败: 服务端证书与主机名不匹配，请检查url或server-name配置: %v", mode, cause)
+	case errors.As(cause, &invalidErr):
+		return fmt.Errorf("%s握手失败: 服务端证书无效或已过期: %v", mode, cause)
+	case errors.As(cause, &recordErr):
+		return fmt.Errorf("%s握手失败: 服务端没有使用TLS，请检查端口与url协议: %v", mode, cause)
+	}
+	return fmt.Errorf("%s连接失败: %v", mode, cause)
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
+)
+
+// newTLSServer 启动一个只完成TLS握手的服务端, 返回地址与CA证书文件
+func newTLSServer(t *testing.T) (addr, caFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap.test"},
+		DNSNames:              []string{"ldap.test"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile = filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String(), caFile
+}
+
+func TestDialLDAPS(t *testing.T) {
+	addr, caFile := newTLSServer(t)
+
+	_, err := dialLDAP(&config.LdapConfig{Url: "ldaps://" + addr})
+	if err == nil || !strings.Contains(err.Error(), "ca-file") {
+		t.Fatalf("不受信任的证书应提示检查ca-file, 实际为%v", err)
+	}
+
+	conn, err := dialLDAP(&config.LdapConfig{Url: "ldaps://" + addr, TLS: &config.LdapTLSConfig{CAFile: caFile}})
+	if err != nil {
+		t.Fatalf("配置CA证书后应连接成功: %v", err)
+	}
+	conn.Close()
+
+	_, err = dialLDAP(&config.LdapConfig{Url: "ldaps://" + addr, TLS: &config.LdapTLSConfig{CAFile: caFile, ServerName: "other.test"}})
+	if err == nil || !strings.Contains(err.Error(), "server-name") {
+		t.Fatalf("主机名不匹配时应提示检查server-name, 实际为%v", err)
+	}
+
+	_, err = dialLDAP(&config.LdapConfig{Url: "ldaps://" + addr, TLS: &config.LdapTLSConfig{StartTLS: true}})
+	if err == nil {
+		t.Fatal("ldaps://与start-tls不能同时开启")
+	}
+}
+
+func TestLdapTLSConfig(t *testing.T) {
+	tlsConfig, err := ldapTLSConfig(nil, "ldap.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.ServerName != "ldap.test" {
+		t.Fatalf("默认应为TLS1.2并使用url中的主机名, 实际为%x %s", tlsConfig.MinVersion, tlsConfig.ServerName)
+	}
+
+	tlsConfig, err = ldapTLSConfig(&config.LdapTLSConfig{MinVersion: "1.3", ServerName: "dc01.test"}, "ldap.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 || tlsConfig.ServerName != "dc01.test" {
+		t.Fatalf("应使用配置的TLS版本与主机名, 实际为%x %s", tlsConfig.MinVersion, tlsConfig.ServerName)
+	}
+
+	for _, conf := range []*config.LdapTLSConfig{
+		{MinVersion: "1.4"},
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{CertFile: "client.pem"},
+	} {
+		if _, err := ldapTLSConfig(conf, "ldap.test"); err == nil {
+			t.Fatalf("错误的配置应返回错误: %+v", conf)
+		}
+	}
+}
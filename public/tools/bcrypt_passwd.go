@@ -2,35 +2,48 @@ package tools
 
 import (
 	"crypto/rand"
+	"errors"
 	"math/big"
+	"strings"
 
 	"github.com/eryajf/go-ldap-admin/config"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // 密码加密 使用自适应hash算法, 不可逆
-// func GenPasswd(passwd string) string {
-// 	hashPasswd, _ := bcrypt.GenerateFromPassword([]byte(passwd), bcrypt.DefaultCost)
-// 	return string(hashPasswd)
-// }
+func GenPasswd(passwd string) string {
+	hashPasswd, _ := bcrypt.GenerateFromPassword([]byte(passwd), bcrypt.DefaultCost)
+	return string(hashPasswd)
+}
 
 // 通过比较两个字符串hash判断是否出自同一个明文
-// hashPasswd 需要对比的密文
+// hashPasswd 需要对比的密文, 兼容旧版本RSA加密保存的密码
 // passwd 明文
-// func ComparePasswd(hashPasswd string, passwd string) error {
-// 	// if err := bcrypt.CompareHashAndPassword([]byte(hashPasswd), []byte(passwd)); err != nil {
-// 	// 	return err
-// 	// }
+func ComparePasswd(hashPasswd string, passwd string) error {
+	if hashPasswd == "" || passwd == "" {
+		return errors.New("密码不匹配")
+	}
+	if IsLegacyPasswd(hashPasswd) {
+		if NewParPasswd(hashPasswd) != passwd {
+			return errors.New("密码不匹配")
+		}
+		return nil
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hashPasswd), []byte(passwd))
+}
 
-// 	return nil
-// }
+// IsLegacyPasswd 判断数据库中的密码是否为旧版本RSA可逆加密保存的
+func IsLegacyPasswd(hashPasswd string) bool {
+	return hashPasswd != "" && !strings.HasPrefix(hashPasswd, "$2")
+}
 
-// 密码加密
+// 密码加密, 仅用于接口传输密码, 不可用于保存密码
 func NewGenPasswd(passwd string) string {
 	pass, _ := RSAEncrypt([]byte(passwd), config.Conf.System.RSAPublicBytes)
 	return string(pass)
 }
 
-// 密码解密
+// 密码解密, 仅用于迁移旧版本RSA加密保存的密码
 func NewParPasswd(passwd string) string {
 	pass, _ := RSADecrypt([]byte(passwd), config.Conf.System.RSAPrivateBytes)
 	return string(pass)
@@ -39,17 +52,46 @@ func NewParPasswd(passwd string) string {
 const (
 	passwordLength = 8
 	letters        = "abcdefghijklmnopqrstu@vwxyzABCDEFGHIJKL#MNOP*QRSTUVWXYZ0123456789"
-	lettersLength  = len(letters)
 )
 
-// 生成随机密码
+// 生成随机密码, 生成的密码满足当前的密码策略
 func GenerateRandomPassword() string {
-	password := make([]byte, passwordLength)
+	length := passwordLength
+	if policy := GetPasswordPolicy(); policy.MinLength > length {
+		length = policy.MinLength
+	}
 
-	for i := range password {
-		index, _ := rand.Int(rand.Reader, big.NewInt(int64(lettersLength)))
-		password[i] = letters[index.Int64()]
+	var password string
+	for i := 0; i < 10; i++ {
+		password = randomPassword(length)
+		if len(CheckPasswordPolicy(password)) == 0 {
+			break
+		}
 	}
+	return password
+}
 
+// randomPassword 生成包含大小写字母、数字和特殊字符的随机密码
+func randomPassword(length int) string {
+	password := make([]byte, length)
+	// 保证每类字符至少出现一次
+	classes := []string{"abcdefghijklmnopqrstuvwxyz", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "0123456789", "@#*"}
+	for i := range password {
+		set := letters
+		if i < len(classes) {
+			set = classes[i]
+		}
+		password[i] = set[randInt(len(set))]
+	}
+	// 打乱顺序
+	for i := len(password) - 1; i > 0; i-- {
+		j := randInt(i + 1)
+		password[i], password[j] = password[j], password[i]
+	}
 	return string(password)
 }
+
+func randInt(n int) int {
+	index, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	return int(index.Int64())
+}
@@ -112,3 +112,22 @@ func SendPasswordResetNotification(username, nickname, mail, newPassword string)
     </div>`, nickname, username, newPassword)
 	return email([]string{mail}, subject, body)
 }
+
+// SendPasswordExpiringNotification 发送密码即将过期提醒邮件
+func SendPasswordExpiringNotification(nickname, mail string, expireAt time.Time) error {
+	subject := "LDAP密码即将过期提醒"
+	// 邮件正文
+	body := fmt.Sprintf(`<div>
+        <div>
+            尊敬的%s，您好！
+        </div>
+        <div style="padding: 8px 40px 8px 50px;">
+            <p>您的LDAP账户密码将于 %s 过期。</p>
+            <p style="color: #ff6600;">请在过期前登录并修改密码，过期后登录时需要先修改密码。</p>
+        </div>
+        <div>
+            <p>此邮箱为系统邮箱，请勿回复。</p>
+        </div>
+    </div>`, nickname, expireAt.Format("2006-01-02 15:04"))
+	return email([]string{mail}, subject, body)
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// RedactURL 去掉地址中的查询参数, 获取token等接口会在查询参数中携带应用密钥, 不能写入错误信息与日志
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<无效的地址>"
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.User = nil
+	return u.String()
+}
+
+// redactURLError net/http返回的url.Error中包含完整的请求地址, 替换为去掉查询参数后的地址
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &url.Error{Op: urlErr.Op, URL: RedactURL(urlErr.URL), Err: urlErr.Err}
+	}
+	return err
+}
+
+// HttpJSON 发送JSON请求并将响应解析到result
+// body为nil时不发送请求体, header为额外的请求头, 返回的错误中不包含查询参数
+func HttpJSON(method, rawURL string, header map[string]string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return redactURLError(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return redactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("请求 %s 失败, 状态码: %d, 响应: %s", RedactURL(rawURL), resp.StatusCode, string(data))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
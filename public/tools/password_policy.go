@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/eryajf/go-ldap-admin/config"
+)
+
+// PasswordViolation 密码不满足的策略项
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordViolations 不满足的策略项列表
+type PasswordViolations []PasswordViolation
+
+func (v PasswordViolations) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, violation := range v {
+		msgs = append(msgs, violation.Message)
+	}
+	return "密码不符合安全策略: " + strings.Join(msgs, "; ")
+}
+
+// 未配置密码策略时沿用原有的最小长度要求
+var defaultPasswordPolicy = config.PasswordPolicyConfig{MinLength: 6}
+
+// GetPasswordPolicy 获取当前生效的密码策略
+func GetPasswordPolicy() config.PasswordPolicyConfig {
+	if config.Conf.PasswordPolicy == nil {
+		return defaultPasswordPolicy
+	}
+	return *config.Conf.PasswordPolicy
+}
+
+// CheckPasswordPolicy 校验密码长度、字符类型、用户信息与弱密码字典
+// userInfo 为用户名、中文名等不允许出现在密码中的信息, 历史密码由调用方结合数据库校验
+func CheckPasswordPolicy(passwd string, userInfo ...string) PasswordViolations {
+	policy := GetPasswordPolicy()
+	violations := make(PasswordViolations, 0)
+
+	if len([]rune(passwd)) < policy.MinLength {
+		violations = append(violations, PasswordViolation{"min-length", fmt.Sprintf("长度至少为%d位", policy.MinLength)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range passwd {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSpecial = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{"require-upper", "必须包含大写字母"})
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{"require-lower", "必须包含小写字母"})
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{"require-digit", "必须包含数字"})
+	}
+	if policy.RequireSpecial && !hasSpecial {
+		violations = append(violations, PasswordViolation{"require-special", "必须包含特殊字符"})
+	}
+
+	if policy.DisallowUserInfo {
+		lower := strings.ToLower(passwd)
+		for _, info := range userInfo {
+			info = strings.ToLower(strings.TrimSpace(info))
+			if info != "" && strings.Contains(lower, info) {
+				violations = append(violations, PasswordViolation{"disallow-user-info", "不能包含用户名或中文名"})
+				break
+			}
+		}
+	}
+
+	if policy.DictionaryFile != "" && passwordDict.contains(policy.DictionaryFile, passwd) {
+		violations = append(violations, PasswordViolation{"dictionary", "该密码过于常见或已泄露，请更换"})
+	}
+	return violations
+}
+
+// passwordDictionary 弱密码字典, 文件变更后自动重新加载
+type passwordDictionary struct {
+	sync.Mutex
+	file    string
+	modTime time.Time
+	words   map[string]struct{}
+}
+
+var passwordDict = &passwordDictionary{}
+
+func (d *passwordDictionary) contains(file, passwd string) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	if d.file != file || !d.modTime.Equal(info.ModTime()) {
+		f, err := os.Open(file)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		words := make(map[string]struct{})
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if word != "" {
+				words[word] = struct{}{}
+			}
+		}
+		d.file, d.modTime, d.words = file, info.ModTime(), words
+	}
+	_, ok := d.words[strings.ToLower(passwd)]
+	return ok
+}
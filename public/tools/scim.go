@@ -0,0 +1,639 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SCIM错误类型, 见RFC7644 3.12
+const (
+	ScimInvalidFilter = "invalidFilter"
+	ScimInvalidPath   = "invalidPath"
+	ScimInvalidValue  = "invalidValue"
+	ScimNoTarget      = "noTarget"
+	ScimUniqueness    = "uniqueness"
+	ScimInvalidSyntax = "invalidSyntax"
+	ScimTooMany       = "tooMany"
+)
+
+// ScimError 过滤条件或PATCH操作不合法时的错误, ScimType为响应中的scimType
+type ScimError struct {
+	ScimType string
+	Err      error
+}
+
+func (e *ScimError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ScimError) Unwrap() error {
+	return e.Err
+}
+
+func newScimError(scimType string, format string, args ...any) *ScimError {
+	return &ScimError{ScimType: scimType, Err: fmt.Errorf(format, args...)}
+}
+
+// ScimFilter SCIM过滤条件, 对资源转换而来的map做匹配
+type ScimFilter interface {
+	Match(res map[string]any) bool
+}
+
+type scimOrFilter struct{ left, right ScimFilter }
+
+func (f scimOrFilter) Match(res map[string]any) bool {
+	return f.left.Match(res) || f.right.Match(res)
+}
+
+type scimAndFilter struct{ left, right ScimFilter }
+
+func (f scimAndFilter) Match(res map[string]any) bool {
+	return f.left.Match(res) && f.right.Match(res)
+}
+
+type scimNotFilter struct{ sub ScimFilter }
+
+func (f scimNotFilter) Match(res map[string]any) bool {
+	return !f.sub.Match(res)
+}
+
+// scimValuePathFilter 多值属性中有任意一项满足条件, 如 emails[type eq "work"]
+type scimValuePathFilter struct {
+	attr string
+	sub  ScimFilter
+}
+
+func (f scimValuePathFilter) Match(res map[string]any) bool {
+	container, attr := scimContainer(res, f.attr, false)
+	if container == nil {
+		return false
+	}
+	for _, item := range scimItems(scimGet(container, attr)) {
+		if m, ok := item.(map[string]any); ok && f.sub.Match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// scimCompareFilter 属性比较, 如 userName eq "zhangsan"
+type scimCompareFilter struct {
+	attr  string
+	op    string
+	value any
+}
+
+func (f scimCompareFilter) Match(res map[string]any) bool {
+	values := scimValues(res, f.attr)
+	switch f.op {
+	case "pr":
+		for _, v := range values {
+			if v != nil && v != "" {
+				return true
+			}
+		}
+		return false
+	case "ne":
+		return !scimCompareFilter{attr: f.attr, op: "eq", value: f.value}.Match(res)
+	}
+	for _, v := range values {
+		if scimCompare(v, f.op, f.value) {
+			return true
+		}
+	}
+	return f.op == "eq" && f.value == nil && len(values) == 0
+}
+
+// ParseScimFilter 解析SCIM过滤条件, 支持eq、ne、co、sw、ew、pr、gt、ge、lt、le比较, and、or、not组合以及括号与多值属性过滤
+func ParseScimFilter(filter string) (ScimFilter, error) {
+	tokens, err := scimTokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &scimParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, newScimError(ScimInvalidFilter, "过滤条件在%s处有多余的内容", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type scimToken struct {
+	text   string
+	quoted bool
+}
+
+func scimTokenize(s string) ([]scimToken, error) {
+	var tokens []scimToken
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == '(' || ch == ')' || ch == '[' || ch == ']':
+			tokens = append(tokens, scimToken{text: string(ch)})
+			i++
+		case ch == '"':
+			// 带引号的字符串按JSON字符串解析, 以支持转义字符
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, newScimError(ScimInvalidFilter, "过滤条件中的字符串没有结束引号")
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, newScimError(ScimInvalidFilter, "过滤条件中的字符串%s不合法", s[i:j+1])
+			}
+			tokens = append(tokens, scimToken{text: text, quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(s) && !strings.ContainsRune(" \t()[]\"", rune(s[j])); j++ {
+			}
+			tokens = append(tokens, scimToken{text: s[i:j]})
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, newScimError(ScimInvalidFilter, "过滤条件为空")
+	}
+	return tokens, nil
+}
+
+type scimParser struct {
+	tokens []scimToken
+	pos    int
+}
+
+func (p *scimParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return strings.ToLower(p.tokens[p.pos].text)
+}
+
+func (p *scimParser) next() (scimToken, error) {
+	if p.pos >= len(p.tokens) {
+		return scimToken{}, newScimError(ScimInvalidFilter, "过滤条件不完整")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *scimParser) expect(text string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.quoted || t.text != text {
+		return newScimError(ScimInvalidFilter, "过滤条件在%s处应为%s", t.text, text)
+	}
+	return nil
+}
+
+func (p *scimParser) parseOr() (ScimFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = scimOrFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimParser) parseAnd() (ScimFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = scimAndFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *scimParser) parseUnary() (ScimFilter, error) {
+	switch p.peek() {
+	case "not":
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		sub, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return scimNotFilter{sub: sub}, nil
+	case "(":
+		p.pos++
+		sub, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return sub, nil
+	}
+
+	attr, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if attr.quoted || strings.ContainsAny(attr.text, "()[]") {
+		return nil, newScimError(ScimInvalidFilter, "过滤条件在%s处应为属性名", attr.text)
+	}
+	if p.peek() == "[" {
+		p.pos++
+		sub, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return scimValuePathFilter{attr: attr.text, sub: sub}, nil
+	}
+
+	opToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(opToken.text)
+	switch op {
+	case "pr":
+		return scimCompareFilter{attr: attr.text, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, newScimError(ScimInvalidFilter, "不支持的比较操作符%s", opToken.text)
+	}
+	valueToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := scimLiteral(valueToken)
+	if err != nil {
+		return nil, err
+	}
+	return scimCompareFilter{attr: attr.text, op: op, value: value}, nil
+}
+
+// scimLiteral 过滤条件中的值, 可以是字符串、数字、true、false与null
+func scimLiteral(t scimToken) (any, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return nil, newScimError(ScimInvalidFilter, "过滤条件中的值%s不合法", t.text)
+	}
+	return n, nil
+}
+
+// scimCompare 比较属性值, 字符串比较不区分大小写
+func scimCompare(v any, op string, target any) bool {
+	switch t := target.(type) {
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		s, t = strings.ToLower(s), strings.ToLower(t)
+		switch op {
+		case "eq":
+			return s == t
+		case "co":
+			return strings.Contains(s, t)
+		case "sw":
+			return strings.HasPrefix(s, t)
+		case "ew":
+			return strings.HasSuffix(s, t)
+		case "gt":
+			return s > t
+		case "ge":
+			return s >= t
+		case "lt":
+			return s < t
+		case "le":
+			return s <= t
+		}
+	case float64:
+		n, ok := v.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return n == t
+		case "gt":
+			return n > t
+		case "ge":
+			return n >= t
+		case "lt":
+			return n < t
+		case "le":
+			return n <= t
+		}
+	case bool:
+		b, ok := v.(bool)
+		return ok && op == "eq" && b == t
+	case nil:
+		return op == "eq" && v == nil
+	}
+	return false
+}
+
+// scimContainer 解析带有schema前缀的属性路径, 返回属性所在的map与去掉前缀后的路径
+// 核心schema的属性位于资源顶层, 扩展schema的属性位于以schema为键的对象中
+func scimContainer(res map[string]any, path string, create bool) (map[string]any, string) {
+	if !strings.HasPrefix(strings.ToLower(path), "urn:") {
+		return res, path
+	}
+	if _, ok := scimGet(res, path).(map[string]any); ok {
+		return res, path
+	}
+	end := len(path)
+	if i := strings.Index(path, "["); i >= 0 {
+		end = i
+	}
+	i := strings.LastIndex(path[:end], ":")
+	urn, attr := path[:i], path[i+1:]
+	if strings.Contains(strings.ToLower(urn), ":core:") {
+		return res, attr
+	}
+	ext, ok := scimGet(res, urn).(map[string]any)
+	if !ok {
+		if !create {
+			return nil, attr
+		}
+		ext = make(map[string]any)
+		res[urn] = ext
+	}
+	return ext, attr
+}
+
+// scimKey 属性名不区分大小写, 返回map中已存在的同名键
+func scimKey(m map[string]any, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+func scimGet(m map[string]any, name string) any {
+	return m[scimKey(m, name)]
+}
+
+// scimItems 将单值或多值属性统一转换为列表
+func scimItems(v any) []any {
+	switch items := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return items
+	default:
+		return []any{v}
+	}
+}
+
+// scimValues 获取属性路径对应的全部值, 多值的复杂属性取其value子属性
+func scimValues(res map[string]any, path string) []any {
+	container, attr := scimContainer(res, path, false)
+	if container == nil {
+		return nil
+	}
+	values := []any{container}
+	for _, part := range strings.Split(attr, ".") {
+		var next []any
+		for _, v := range values {
+			if m, ok := v.(map[string]any); ok {
+				next = append(next, scimItems(scimGet(m, part))...)
+			}
+		}
+		values = next
+	}
+	for i, v := range values {
+		if m, ok := v.(map[string]any); ok {
+			values[i] = scimGet(m, "value")
+		}
+	}
+	return values
+}
+
+// ScimPatch 按RFC7644 3.5.2对资源转换而来的map执行一项PATCH操作
+func ScimPatch(res map[string]any, op, path string, value any) error {
+	op = strings.ToLower(op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return newScimError(ScimInvalidSyntax, "不支持的PATCH操作%s", op)
+	}
+	if path == "" {
+		if op == "remove" {
+			return newScimError(ScimNoTarget, "remove操作必须指定path")
+		}
+		values, ok := value.(map[string]any)
+		if !ok {
+			return newScimError(ScimInvalidValue, "未指定path时value必须是对象")
+		}
+		for k, v := range values {
+			// 以扩展schema为键的对象整体合并
+			if _, ok := v.(map[string]any); ok && strings.HasPrefix(strings.ToLower(k), "urn:") {
+				scimPatchAttr(res, k, op, v)
+				continue
+			}
+			container, attr := scimContainer(res, k, true)
+			scimPatchAttr(container, attr, op, v)
+		}
+		return nil
+	}
+
+	container, rest := scimContainer(res, path, op != "remove")
+	if container == nil {
+		return nil
+	}
+	attr, filter, sub := rest, "", ""
+	if i := strings.Index(rest, "["); i >= 0 {
+		j := strings.LastIndex(rest, "]")
+		if j < i {
+			return newScimError(ScimInvalidPath, "属性路径%s不合法", path)
+		}
+		attr, filter = rest[:i], rest[i+1:j]
+		switch after := rest[j+1:]; {
+		case strings.HasPrefix(after, "."):
+			sub = after[1:]
+		case after != "":
+			return newScimError(ScimInvalidPath, "属性路径%s不合法", path)
+		}
+	} else if i := strings.Index(rest, "."); i >= 0 {
+		attr, sub = rest[:i], rest[i+1:]
+	}
+	if attr == "" || strings.ContainsAny(attr, " ]") {
+		return newScimError(ScimInvalidPath, "属性路径%s不合法", path)
+	}
+
+	if filter == "" {
+		if sub == "" {
+			scimPatchAttr(container, attr, op, value)
+			return nil
+		}
+		key := scimKey(container, attr)
+		m, ok := container[key].(map[string]any)
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			m = make(map[string]any)
+			container[key] = m
+		}
+		scimPatchAttr(m, sub, op, value)
+		return nil
+	}
+
+	f, err := ParseScimFilter(filter)
+	if err != nil {
+		return newScimError(ScimInvalidPath, "属性路径%s中的过滤条件不合法: %s", path, err.Error())
+	}
+	key := scimKey(container, attr)
+	items := scimItems(container[key])
+	matched := false
+	kept := make([]any, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok || !f.Match(m) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+		switch {
+		case op == "remove" && sub == "":
+			continue
+		case sub != "":
+			scimPatchAttr(m, sub, op, value)
+		case op == "replace":
+			if v, ok := value.(map[string]any); ok {
+				item = v
+			}
+		default:
+			if v, ok := value.(map[string]any); ok {
+				for k, sv := range v {
+					m[scimKey(m, k)] = sv
+				}
+			}
+		}
+		kept = append(kept, item)
+	}
+	if !matched && op != "remove" {
+		// 没有匹配的项时, 按过滤条件中的等值条件新增一项, 如 emails[type eq "work"].value
+		cmp, ok := f.(scimCompareFilter)
+		if !ok || cmp.op != "eq" || strings.Contains(cmp.attr, ".") {
+			return newScimError(ScimNoTarget, "属性路径%s没有匹配的值", path)
+		}
+		item := map[string]any{cmp.attr: cmp.value}
+		if sub != "" {
+			item[sub] = value
+		} else if v, ok := value.(map[string]any); ok {
+			for k, sv := range v {
+				item[k] = sv
+			}
+		}
+		kept = append(kept, item)
+	}
+	container[key] = kept
+	return nil
+}
+
+// scimPatchAttr 对单个属性执行PATCH操作
+func scimPatchAttr(m map[string]any, name, op string, value any) {
+	key := scimKey(m, name)
+	old, exist := m[key]
+	switch op {
+	case "remove":
+		items, ok := old.([]any)
+		if !ok || value == nil {
+			delete(m, key)
+			return
+		}
+		// 按value子属性从多值属性中移除指定的项, 如从members中移除成员
+		var kept []any
+		for _, item := range items {
+			if !scimContains(scimItems(value), item) {
+				kept = append(kept, item)
+			}
+		}
+		m[key] = kept
+	case "add":
+		if items, ok := old.([]any); ok {
+			for _, v := range scimItems(value) {
+				if !scimContains(items, v) {
+					items = append(items, v)
+				}
+			}
+			m[key] = items
+			return
+		}
+		fallthrough
+	default:
+		oldMap, ok1 := old.(map[string]any)
+		newMap, ok2 := value.(map[string]any)
+		if exist && ok1 && ok2 {
+			for k, v := range newMap {
+				oldMap[scimKey(oldMap, k)] = v
+			}
+			return
+		}
+		m[key] = value
+	}
+}
+
+// scimContains 判断多值属性中是否已存在value子属性相同的项
+func scimContains(items []any, v any) bool {
+	target := scimItemValue(v)
+	for _, item := range items {
+		if fmt.Sprint(scimItemValue(item)) == fmt.Sprint(target) {
+			return true
+		}
+	}
+	return false
+}
+
+func scimItemValue(v any) any {
+	if m, ok := v.(map[string]any); ok {
+		return scimGet(m, "value")
+	}
+	return v
+}
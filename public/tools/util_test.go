@@ -1,16 +1,31 @@
 package tools
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/config"
 )
 
 func TestGenPass(t *testing.T) {
-	fmt.Printf("密码为：%s\n", NewGenPasswd("123456"))
-	// err := ComparePasswd("$2a$10$Fy8p0nCixgWKzLfO3SgdhOzAF7YolSt6dHj6QidDGYlzLJDpniXB6", "123456")
-	// if err != nil {
-	// 	fmt.Printf("密码错误：%s\n", err)
-	// }
+	hashed := GenPasswd("123456")
+	if IsLegacyPasswd(hashed) {
+		t.Fatalf("bcrypt哈希不应被识别为旧的加密密码: %s", hashed)
+	}
+	if err := ComparePasswd(hashed, "123456"); err != nil {
+		t.Fatalf("密码错误：%s", err)
+	}
+	if err := ComparePasswd(hashed, "654321"); err == nil {
+		t.Fatal("错误的密码不应通过校验")
+	}
+	if err := ComparePasswd("", ""); err == nil {
+		t.Fatal("空密码不应通过校验")
+	}
 }
 
 func TestArrUintCmp(t *testing.T) {
@@ -37,3 +52,142 @@ func TestEncodePass(t *testing.T) {
 		fmt.Println("its not match")
 	}
 }
+
+func TestTotp(t *testing.T) {
+	// RFC 6238 附录B中的SHA1测试向量(取后6位)
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+	}
+	for ts, want := range cases {
+		got, err := TotpCode(secret, time.Unix(ts, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("时间 %d 的验证码应为 %s, 实际为 %s", ts, want, got)
+		}
+	}
+	if !ValidateTotp(secret, "287082", time.Unix(59+30, 0)) {
+		t.Fatal("上一个时间窗口的验证码应当通过校验")
+	}
+	if ValidateTotp(secret, "287082", time.Unix(59+90, 0)) {
+		t.Fatal("超出时间窗口的验证码不应通过校验")
+	}
+	if step, ok := MatchTotp(secret, "287082", time.Unix(59+30, 0)); !ok || step != 1 {
+		t.Fatalf("验证码所在的时间步应为 1, 实际为 %d", step)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	old := config.Conf.PasswordPolicy
+	defer func() { config.Conf.PasswordPolicy = old }()
+
+	dict := filepath.Join(t.TempDir(), "dict.txt")
+	if err := os.WriteFile(dict, []byte("Password123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config.Conf.PasswordPolicy = &config.PasswordPolicyConfig{
+		MinLength:        8,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireDigit:     true,
+		RequireSpecial:   true,
+		DisallowUserInfo: true,
+		DictionaryFile:   dict,
+	}
+
+	cases := map[string][]string{
+		"Ab1!":          {"min-length"},
+		"abcdefgh1!":    {"require-upper"},
+		"Zhangsan#2024": {"disallow-user-info"},
+		"password123":   {"require-upper", "require-special", "dictionary"},
+		"Kx9#mQ2$vLp7":  nil,
+	}
+	for passwd, want := range cases {
+		var got []string
+		for _, v := range CheckPasswordPolicy(passwd, "zhangsan", "张三") {
+			got = append(got, v.Rule)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("密码 %s 违反的策略应为 %v, 实际为 %v", passwd, want, got)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if v := CheckPasswordPolicy(GenerateRandomPassword()); len(v) > 0 {
+			t.Fatalf("随机生成的密码不满足密码策略: %v", v)
+		}
+	}
+}
+
+func TestScimFilter(t *testing.T) {
+	var user map[string]any
+	_ = json.Unmarshal([]byte(`{"userName":"ZhangSan","active":true,"name":{"givenName":"三"},
+		"emails":[{"value":"zs@example.com","type":"work"},{"value":"zs@home.com","type":"home"}],
+		"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User":{"employeeNumber":"1001"}}`), &user)
+
+	cases := map[string]bool{
+		`userName eq "zhangsan"`:                     true,
+		`userName ne "zhangsan"`:                     false,
+		`userName sw "zh" and active eq true`:        true,
+		`name.givenName eq "四" or emails co "home"`:  true,
+		`emails[type eq "work" and value ew ".com"]`: true,
+		`emails[type eq "other"]`:                    false,
+		`not (title pr)`:                             true,
+		`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber eq "1001"`: true,
+	}
+	for filter, want := range cases {
+		f, err := ParseScimFilter(filter)
+		if err != nil {
+			t.Fatalf("解析过滤条件 %s 失败: %v", filter, err)
+		}
+		if got := f.Match(user); got != want {
+			t.Fatalf("过滤条件 %s 的匹配结果应为 %v, 实际为 %v", filter, want, got)
+		}
+	}
+	for _, filter := range []string{`userName eq`, `userName xx "a"`, `(userName pr`, `userName eq "a`} {
+		if _, err := ParseScimFilter(filter); err == nil {
+			t.Fatalf("过滤条件 %s 不合法, 应解析失败", filter)
+		}
+	}
+}
+
+func TestScimPatch(t *testing.T) {
+	var group map[string]any
+	_ = json.Unmarshal([]byte(`{"displayName":"dev","members":[{"value":"1"},{"value":"2"}]}`), &group)
+	ops := []struct {
+		op, path string
+		value    any
+	}{
+		{"add", "members", []any{map[string]any{"value": "3"}, map[string]any{"value": "1"}}},
+		{"remove", `members[value eq "2"]`, nil},
+		{"Replace", "", map[string]any{"displayName": "ops"}},
+		{"replace", `emails[type eq "work"].value`, "ops@example.com"},
+	}
+	for _, op := range ops {
+		if err := ScimPatch(group, op.op, op.path, op.value); err != nil {
+			t.Fatalf("执行PATCH操作 %s %s 失败: %v", op.op, op.path, err)
+		}
+	}
+	got, _ := json.Marshal(group)
+	want := `{"displayName":"ops","emails":[{"type":"work","value":"ops@example.com"}],"members":[{"value":"1"},{"value":"3"}]}`
+	if string(got) != want {
+		t.Fatalf("PATCH之后的结果应为 %s, 实际为 %s", want, got)
+	}
+	if err := ScimPatch(group, "remove", "", nil); err == nil {
+		t.Fatal("remove操作未指定path时应失败")
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	got := RedactURL("https://oapi.dingtalk.com/gettoken?appkey=k&appsecret=s")
+	if got != "https://oapi.dingtalk.com/gettoken" {
+		t.Fatalf("去掉查询参数后的地址应为 https://oapi.dingtalk.com/gettoken, 实际为 %s", got)
+	}
+	err := HttpJSON("GET", "http://127.0.0.1:1/gettoken?corpsecret=secret", nil, nil, nil)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("请求失败的错误中不应包含查询参数: %v", err)
+	}
+}
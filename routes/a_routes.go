@@ -68,6 +68,13 @@ func InitRoutes() *gin.Engine {
 	InitApiRoutes(apiGroup, authMiddleware)           // 注册接口路由, jwt认证中间件,casbin鉴权中间件
 	InitOperationLogRoutes(apiGroup, authMiddleware)  // 注册操作日志路由, jwt认证中间件,casbin鉴权中间件
 	InitFieldRelationRoutes(apiGroup, authMiddleware) // 注册操作日志路由, jwt认证中间件,casbin鉴权中间件
+	InitOidcRoutes(apiGroup, authMiddleware)          // 注册OIDC路由, 身份提供者端点无需认证, 应用管理需jwt认证中间件,casbin鉴权中间件
+	InitSessionRoutes(apiGroup, authMiddleware)       // 注册会话管理路由, jwt认证中间件,casbin鉴权中间件
+	InitAccessTokenRoutes(apiGroup, authMiddleware)   // 注册访问令牌路由, jwt认证中间件,casbin鉴权中间件
+	InitSystemRoutes(apiGroup, authMiddleware)        // 注册系统运行状态路由, jwt认证中间件,casbin鉴权中间件
+	InitDirectoryRoutes(apiGroup, authMiddleware)     // 注册LDAP目录路由, jwt认证中间件,casbin鉴权中间件
+	InitSyncRoutes(apiGroup, authMiddleware)          // 注册同步管理路由, jwt认证中间件,casbin鉴权中间件
+	InitScimRoutes(apiGroup, authMiddleware)          // 注册SCIM路由, 启用SCIM时注册, 访问令牌认证,casbin鉴权中间件
 
 	common.Log.Info("初始化路由完成！")
 	return r
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/eryajf/go-ldap-admin/controller"
+	"github.com/eryajf/go-ldap-admin/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 注册访问令牌路由
+func InitAccessTokenRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	accessToken := r.Group("/accessToken")
+	// 开启jwt认证中间件
+	accessToken.Use(middleware.AuthMiddleware(authMiddleware))
+	// 开启casbin鉴权中间件
+	accessToken.Use(middleware.CasbinMiddleware())
+	{
+		accessToken.GET("/list", controller.AccessToken.List)
+		accessToken.POST("/add", controller.AccessToken.Add)
+		accessToken.POST("/delete", controller.AccessToken.Delete)
+	}
+	return r
+}
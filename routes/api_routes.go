@@ -11,7 +11,7 @@ import (
 func InitApiRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	api := r.Group("/api")
 	// 开启jwt认证中间件
-	api.Use(authMiddleware.MiddlewareFunc())
+	api.Use(middleware.AuthMiddleware(authMiddleware))
 	// 开启casbin鉴权中间件
 	api.Use(middleware.CasbinMiddleware())
 	{
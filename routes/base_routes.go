@@ -47,16 +47,19 @@ func InitBaseRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gi
 	{
 		base.GET("ping", controller.Demo)
 		base.GET("encryptpwd", controller.Base.EncryptPasswd) // 生成加密密码
-		base.GET("decryptpwd", controller.Base.DecryptPasswd) // 密码解密为明文
 		base.GET("config", controller.Base.GetConfig)         // 获取系统配置
 		base.GET("version", controller.Base.GetVersion)       // 获取版本信息
 		// 登录登出刷新token无需鉴权
 		base.POST("/login", authMiddleware.LoginHandler)
 		base.POST("/logout", authMiddleware.LogoutHandler)
 		base.POST("/refreshToken", authMiddleware.RefreshHandler)
-		base.POST("/sendcode", controller.Base.SendCode)   // 给用户邮箱发送验证码
-		base.POST("/changePwd", controller.Base.ChangePwd) // 修改用户密码
-		base.GET("/dashboard", controller.Base.Dashboard)  // 系统首页展示数据
+		base.POST("/sendcode", controller.Base.SendCode)                 // 给用户邮箱发送验证码
+		base.POST("/changePwd", controller.Base.ChangePwd)               // 修改用户密码
+		base.POST("/changeExpiredPwd", controller.Base.ChangeExpiredPwd) // 登录时修改已过期的密码
+		base.GET("/dashboard", controller.Base.Dashboard)                // 系统首页展示数据
+		base.POST("/mfa/setup", controller.Mfa.SetupByTicket)            // 登录过程中生成二次验证密钥
+		base.GET("/sso/providers", controller.Sso.Providers)             // 获取已开启的扫码登录方式
+		base.GET("/sso/authorize", controller.Sso.Authorize)             // 获取第三方登录授权地址
 	}
 	return r
 }
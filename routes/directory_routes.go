@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/eryajf/go-ldap-admin/controller"
+	"github.com/eryajf/go-ldap-admin/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 注册LDAP目录路由
+func InitDirectoryRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	directory := r.Group("/directory")
+	// 开启jwt认证中间件
+	directory.Use(middleware.AuthMiddleware(authMiddleware))
+	// 开启casbin鉴权中间件
+	directory.Use(middleware.CasbinMiddleware())
+	{
+		directory.GET("/list", controller.Directory.List)      // LDAP目录列表
+		directory.POST("/add", controller.Directory.Add)       // 添加LDAP目录
+		directory.POST("/update", controller.Directory.Update) // 更新LDAP目录
+		directory.POST("/delete", controller.Directory.Delete) // 删除LDAP目录
+	}
+	return r
+}
@@ -11,7 +11,7 @@ import (
 func InitFieldRelationRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	filed_relation := r.Group("/fieldrelation")
 	// 开启jwt认证中间件
-	filed_relation.Use(authMiddleware.MiddlewareFunc())
+	filed_relation.Use(middleware.AuthMiddleware(authMiddleware))
 	// 开启casbin鉴权中间件
 	filed_relation.Use(middleware.CasbinMiddleware())
 	{
@@ -11,7 +11,7 @@ import (
 func InitGroupRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	group := r.Group("/group")
 	// 开启jwt认证中间件
-	group.Use(authMiddleware.MiddlewareFunc())
+	group.Use(middleware.AuthMiddleware(authMiddleware))
 	// 开启casbin鉴权中间件
 	group.Use(middleware.CasbinMiddleware())
 	{
@@ -31,6 +31,12 @@ func InitGroupRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) g
 		group.POST("/syncFeiShuDepts", controller.Group.SyncFeiShuDepts)     // 同步飞书部门到平台
 		group.POST("/syncOpenLdapDepts", controller.Group.SyncOpenLdapDepts) // 同步ldap的分组到平台InitGroupRoutes
 		group.POST("/syncSqlGroups", controller.Group.SyncSqlGroups)         // 同步Sql分组到Ldap
+		group.POST("/posixBackfill", controller.Group.PosixBackfill)         // 为已有分组回填gidNumber
+		group.POST("/convertSchema", controller.Group.ConvertSchema)         // 转换分组的objectClass
+		group.POST("/addGroup", controller.Group.AddGroup)                   // 添加成员分组
+		group.POST("/removeGroup", controller.Group.RemoveGroup)             // 移除成员分组
+		group.GET("/effectiveMembers", controller.Group.EffectiveMembers)    // 获取分组的有效成员
+		group.POST("/syncNestedMembers", controller.Group.SyncNestedMembers) // 重新写入嵌套分组的成员
 	}
 
 	return r
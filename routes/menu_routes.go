@@ -11,7 +11,7 @@ import (
 func InitMenuRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	menu := r.Group("/menu")
 	// 开启jwt认证中间件
-	menu.Use(authMiddleware.MiddlewareFunc())
+	menu.Use(middleware.AuthMiddleware(authMiddleware))
 	// 开启casbin鉴权中间件
 	menu.Use(middleware.CasbinMiddleware())
 	{
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"github.com/eryajf/go-ldap-admin/controller"
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 注册OIDC路由
+func InitOidcRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	oidc := r.Group("/oidc")
+	// 身份提供者端点, 无需jwt认证与casbin鉴权
+	if logic.OidcEnabled() {
+		oidc.GET("/.well-known/openid-configuration", controller.Oidc.Discovery) // 发现文档
+		oidc.GET("/jwks", controller.Oidc.JWKS)                                  // 签名公钥
+		oidc.GET("/authorize", controller.Oidc.Authorize)                        // 授权端点
+		oidc.POST("/authorize", controller.Oidc.Authorize)                       // 授权页登录
+		oidc.POST("/token", controller.Oidc.Token)                               // 令牌端点
+		oidc.GET("/userinfo", controller.Oidc.UserInfo)                          // 用户信息端点
+		oidc.POST("/userinfo", controller.Oidc.UserInfo)                         // 用户信息端点
+	}
+
+	client := oidc.Group("/client")
+	// 开启jwt认证中间件
+	client.Use(middleware.AuthMiddleware(authMiddleware))
+	// 开启casbin鉴权中间件
+	client.Use(middleware.CasbinMiddleware())
+	{
+		client.GET("/list", controller.Oidc.ClientList)                // OIDC应用列表
+		client.POST("/add", controller.Oidc.ClientAdd)                 // 添加OIDC应用
+		client.POST("/update", controller.Oidc.ClientUpdate)           // 更新OIDC应用
+		client.POST("/resetSecret", controller.Oidc.ClientResetSecret) // 重置OIDC应用密钥
+		client.POST("/delete", controller.Oidc.ClientDelete)           // 删除OIDC应用
+	}
+	return r
+}
@@ -11,7 +11,7 @@ import (
 func InitOperationLogRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	operation_log := r.Group("/log")
 	// 开启jwt认证中间件
-	operation_log.Use(authMiddleware.MiddlewareFunc())
+	operation_log.Use(middleware.AuthMiddleware(authMiddleware))
 	// 开启casbin鉴权中间件
 	operation_log.Use(middleware.CasbinMiddleware())
 	{
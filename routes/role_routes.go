@@ -11,7 +11,7 @@ import (
 func InitRoleRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	role := r.Group("/role")
 	// 开启jwt认证中间件
-	role.Use(authMiddleware.MiddlewareFunc())
+	role.Use(middleware.AuthMiddleware(authMiddleware))
 	// 开启casbin鉴权中间件
 	role.Use(middleware.CasbinMiddleware())
 	{
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"github.com/eryajf/go-ldap-admin/controller"
+	"github.com/eryajf/go-ldap-admin/logic"
+	"github.com/eryajf/go-ldap-admin/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 注册SCIM路由, 身份提供者使用个人访问令牌认证
+func InitScimRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	if !logic.ScimEnabled() {
+		return r
+	}
+	scim := r.Group("/scim/v2")
+	// 开启jwt认证中间件
+	scim.Use(middleware.AuthMiddleware(authMiddleware))
+	// 开启casbin鉴权中间件
+	scim.Use(middleware.CasbinMiddleware())
+	{
+		scim.GET("/ServiceProviderConfig", controller.Scim.ServiceProviderConfig) // 服务端配置
+		scim.GET("/Schemas", controller.Scim.Schemas)                             // schema列表
+		scim.GET("/Schemas/:id", controller.Scim.Schemas)                         // 获取schema
+		scim.GET("/ResourceTypes", controller.Scim.ResourceTypes)                 // 资源类型列表
+		scim.GET("/ResourceTypes/:id", controller.Scim.ResourceTypes)             // 获取资源类型
+		scim.GET("/Users", controller.Scim.UserList)                              // 用户列表
+		scim.POST("/Users", controller.Scim.UserAdd)                              // 创建用户
+		scim.GET("/Users/:id", controller.Scim.UserInfo)                          // 获取用户
+		scim.PUT("/Users/:id", controller.Scim.UserReplace)                       // 替换用户
+		scim.PATCH("/Users/:id", controller.Scim.UserPatch)                       // 修改用户
+		scim.DELETE("/Users/:id", controller.Scim.UserDelete)                     // 删除用户
+		scim.GET("/Groups", controller.Scim.GroupList)                            // 分组列表
+		scim.POST("/Groups", controller.Scim.GroupAdd)                            // 创建分组
+		scim.GET("/Groups/:id", controller.Scim.GroupInfo)                        // 获取分组
+		scim.PUT("/Groups/:id", controller.Scim.GroupReplace)                     // 替换分组
+		scim.PATCH("/Groups/:id", controller.Scim.GroupPatch)                     // 修改分组
+		scim.DELETE("/Groups/:id", controller.Scim.GroupDelete)                   // 删除分组
+		scim.POST("/Bulk", controller.Scim.Bulk)                                  // 批量操作
+	}
+	return r
+}
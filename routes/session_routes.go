@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/eryajf/go-ldap-admin/controller"
+	"github.com/eryajf/go-ldap-admin/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 注册会话管理路由
+func InitSessionRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	session := r.Group("/session")
+	// 开启jwt认证中间件
+	session.Use(middleware.AuthMiddleware(authMiddleware))
+	// 开启casbin鉴权中间件
+	session.Use(middleware.CasbinMiddleware())
+	{
+		session.GET("/mine", controller.Session.Mine)              // 当前用户的会话列表
+		session.POST("/revokeMine", controller.Session.RevokeMine) // 吊销当前用户的会话
+		session.GET("/list", controller.Session.List)              // 指定用户的会话列表
+		session.POST("/revoke", controller.Session.Revoke)         // 吊销指定会话
+		session.POST("/revokeUser", controller.Session.RevokeUser) // 吊销用户的全部会话
+	}
+	return r
+}
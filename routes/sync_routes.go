@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"github.com/eryajf/go-ldap-admin/controller"
+	"github.com/eryajf/go-ldap-admin/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 注册同步管理路由
+func InitSyncRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	sync := r.Group("/sync")
+	// 开启jwt认证中间件
+	sync.Use(middleware.AuthMiddleware(authMiddleware))
+	// 开启casbin鉴权中间件
+	sync.Use(middleware.CasbinMiddleware())
+	{
+		sync.GET("/plan/list", controller.Sync.PlanList)        // 同步计划列表
+		sync.GET("/plan/info", controller.Sync.PlanInfo)        // 同步计划详情
+		sync.POST("/plan/apply", controller.Sync.PlanApply)     // 执行同步计划
+		sync.POST("/plan/delete", controller.Sync.PlanDelete)   // 删除同步计划
+		sync.GET("/run/list", controller.Sync.RunList)          // 同步记录列表
+		sync.GET("/run/items", controller.Sync.RunItems)        // 同步记录的执行结果
+		sync.GET("/source/list", controller.Sync.SourceList)    // 身份源列表
+		sync.POST("/source/depts", controller.Sync.SourceDepts) // 从身份源同步部门
+		sync.POST("/source/users", controller.Sync.SourceUsers) // 从身份源同步用户
+	}
+	return r
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/eryajf/go-ldap-admin/controller"
+	"github.com/eryajf/go-ldap-admin/middleware"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 注册系统运行状态路由
+func InitSystemRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	system := r.Group("/system")
+	// 开启jwt认证中间件
+	system.Use(middleware.AuthMiddleware(authMiddleware))
+	// 开启casbin鉴权中间件
+	system.Use(middleware.CasbinMiddleware())
+	{
+		system.GET("/ldapPoolStats", controller.Base.LdapPoolStats) // 获取LDAP连接池统计
+	}
+	return r
+}
@@ -12,7 +12,7 @@ import (
 func InitUserRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	user := r.Group("/user")
 	// 开启jwt认证中间件
-	user.Use(authMiddleware.MiddlewareFunc())
+	user.Use(middleware.AuthMiddleware(authMiddleware))
 	// 开启casbin鉴权中间件
 	user.Use(middleware.CasbinMiddleware())
 	{
@@ -24,12 +24,28 @@ func InitUserRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gi
 		user.POST("/changePwd", controller.User.ChangePwd)               // 修改用户密码
 		user.POST("/resetPassword", controller.User.ResetPassword)       // 重置用户密码
 		user.POST("/changeUserStatus", controller.User.ChangeUserStatus) // 修改用户状态
+		user.POST("/unlock", controller.User.Unlock)                     // 解除用户登录锁定
+		user.POST("/posixBackfill", controller.User.PosixBackfill)       // 为已有用户回填uidNumber
+		user.GET("/effectiveGroups", controller.User.EffectiveGroups)    // 获取用户的有效分组
 
 		user.POST("/syncDingTalkUsers", controller.User.SyncDingTalkUsers) // 同步钉钉用户到平台
 		user.POST("/syncWeComUsers", controller.User.SyncWeComUsers)       // 同步企业微信用户到平台
 		user.POST("/syncFeiShuUsers", controller.User.SyncFeiShuUsers)     // 同步飞书用户到平台
 		user.POST("/syncOpenLdapUsers", controller.User.SyncOpenLdapUsers) // 同步Ldap用户到平台
 		user.POST("/syncSqlUsers", controller.User.SyncSqlUsers)           // 同步Sql用户到Ldap
+
+		user.GET("/mfa/info", controller.Mfa.Info)        // 获取当前用户的二次验证状态
+		user.POST("/mfa/setup", controller.Mfa.Setup)     // 生成二次验证密钥
+		user.POST("/mfa/enable", controller.Mfa.Enable)   // 启用二次验证
+		user.POST("/mfa/disable", controller.Mfa.Disable) // 关闭二次验证
+		user.POST("/mfa/reset", controller.Mfa.Reset)     // 重置用户二次验证
+
+		user.GET("/sshKey/mine", controller.SshKey.Mine)              // 获取当前用户的SSH公钥
+		user.POST("/sshKey/addMine", controller.SshKey.AddMine)       // 当前用户添加SSH公钥
+		user.POST("/sshKey/deleteMine", controller.SshKey.DeleteMine) // 当前用户删除SSH公钥
+		user.GET("/sshKey/list", controller.SshKey.List)              // 获取指定用户的SSH公钥
+		user.POST("/sshKey/add", controller.SshKey.Add)               // 为用户添加SSH公钥
+		user.POST("/sshKey/delete", controller.SshKey.Delete)         // 删除用户的SSH公钥
 	}
 	return r
 }
@@ -0,0 +1,141 @@
+package ildap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+// AD的userAccountControl标志位与分组类型
+const (
+	adUacAccountDisable = 0x0002
+	adUacNormalAccount  = 0x0200
+
+	// 全局安全组
+	adGroupTypeGlobalSecurity = "-2147483646"
+)
+
+// adUnicodePwd AD的unicodePwd为带双引号的密码的UTF-16LE编码
+func adUnicodePwd(passwd string) string {
+	units := utf16.Encode([]rune(`"` + passwd + `"`))
+	buf := make([]byte, len(units)*2)
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(buf[i*2:], unit)
+	}
+	return string(buf)
+}
+
+// adUpnSuffix userPrincipalName的后缀, 未配置时由所属目录base-dn中的dc推导
+// 配置的upn-suffix只对默认目录生效
+func adUpnSuffix(directoryId uint) string {
+	if directoryId == common.DefaultDirectoryID && config.Conf.Ldap.AD != nil && config.Conf.Ldap.AD.UpnSuffix != "" {
+		return config.Conf.Ldap.AD.UpnSuffix
+	}
+	dn, err := ldap.ParseDN(common.LdapDirectoryConf(directoryId).BaseDN)
+	if err != nil {
+		return ""
+	}
+	dcs := make([]string, 0)
+	for _, rdn := range dn.RDNs {
+		for _, attr := range rdn.Attributes {
+			if strings.EqualFold(attr.Type, "dc") {
+				dcs = append(dcs, attr.Value)
+			}
+		}
+	}
+	return strings.Join(dcs, ".")
+}
+
+// adUserAttrs AD用户的属性, sAMAccountName与userPrincipalName固定由用户名生成
+func adUserAttrs(user *model.User, keepEmpty bool) map[string][]string {
+	attrs := groupLdapAttrs(common.LdapUserAttributes(user), keepEmpty)
+	for name := range attrs {
+		if strings.EqualFold(name, "sAMAccountName") || strings.EqualFold(name, "userPrincipalName") {
+			delete(attrs, name)
+		}
+	}
+	attrs["sAMAccountName"] = []string{user.Username}
+	if suffix := adUpnSuffix(user.DirectoryID); suffix != "" {
+		attrs["userPrincipalName"] = []string{user.Username + "@" + suffix}
+	}
+	return attrs
+}
+
+// adAddUser 创建AD用户, AD要求账号先以禁用状态创建, 设置密码后再启用
+func adAddUser(conn *ldap.Conn, user *model.User, passwd string) error {
+	add := ldap.NewAddRequest(user.UserDN, nil)
+	add.Attribute("objectClass", common.GetLdapUserSchema().ObjectClasses)
+	add.Attribute("cn", []string{user.Username})
+	for name, values := range adUserAttrs(user, false) {
+		add.Attribute(name, values)
+	}
+	add.Attribute("userAccountControl", []string{strconv.Itoa(adUacNormalAccount | adUacAccountDisable)})
+	if err := conn.Add(add); err != nil {
+		return err
+	}
+
+	if err := adSetPassword(conn, user.UserDN, passwd); err != nil {
+		// 设置密码失败时删除已创建的禁用账号, 以便修正后重试
+		if delErr := conn.Del(ldap.NewDelRequest(user.UserDN, nil)); delErr != nil {
+			common.Log.Warnf("删除设置密码失败的AD用户[%s]失败: %v", user.UserDN, delErr)
+		}
+		return fmt.Errorf("设置AD用户密码失败, 请确认使用ldaps连接且密码满足域密码策略: %v", err)
+	}
+	return adSetAccountDisabled(conn, user.UserDN, false)
+}
+
+// adSetPassword 以管理员身份重置AD用户密码, 只能通过加密连接修改
+func adSetPassword(conn *ldap.Conn, udn, passwd string) error {
+	modify := ldap.NewModifyRequest(udn, nil)
+	modify.Replace("unicodePwd", []string{adUnicodePwd(passwd)})
+	return conn.Modify(modify)
+}
+
+// adSetAccountDisabled 启用或禁用AD账号, 保留userAccountControl中的其他标志位
+func adSetAccountDisabled(conn *ldap.Conn, udn string, disabled bool) error {
+	searchRequest := ldap.NewSearchRequest(
+		udn,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"userAccountControl"},
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
+	if err != nil {
+		return err
+	}
+	if len(sr.Entries) == 0 {
+		return fmt.Errorf("用户%s不存在", udn)
+	}
+	uac, _ := strconv.Atoi(sr.Entries[0].GetAttributeValue("userAccountControl"))
+	if uac == 0 {
+		uac = adUacNormalAccount
+	}
+	if disabled {
+		uac |= adUacAccountDisable
+	} else {
+		uac &^= adUacAccountDisable
+	}
+	modify := ldap.NewModifyRequest(udn, nil)
+	modify.Replace("userAccountControl", []string{strconv.Itoa(uac)})
+	return conn.Modify(modify)
+}
+
+// adAddGroupRequest 创建AD分组的请求, AD允许分组没有成员
+func adAddGroupRequest(g *model.Group) *ldap.AddRequest {
+	add := ldap.NewAddRequest(g.GroupDN, nil)
+	add.Attribute("objectClass", []string{"top", common.GroupSchemaAD})
+	add.Attribute("cn", []string{g.GroupName})
+	add.Attribute("sAMAccountName", []string{g.GroupName})
+	add.Attribute("groupType", []string{adGroupTypeGlobalSecurity})
+	add.Attribute("description", []string{g.Remark})
+	return add
+}
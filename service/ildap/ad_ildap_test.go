@@ -0,0 +1,412 @@
+package ildap
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+// fakeLdapServer 内存中的LDAP服务, 只实现测试用到的绑定、增删改与搜索
+type fakeLdapServer struct {
+	listener    net.Listener
+	mu          sync.Mutex
+	entries     map[string]*ldap.Entry
+	rejectPwd   bool
+	rejectRelax bool // 模拟不支持relax控制的服务端
+}
+
+func newFakeLdapServer(t *testing.T) *fakeLdapServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeLdapServer{listener: listener, entries: make(map[string]*ldap.Entry)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = listener.Close() })
+	return srv
+}
+
+func (s *fakeLdapServer) dial(t *testing.T) *ldap.Conn {
+	conn, err := ldap.DialURL("ldap://" + s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func (s *fakeLdapServer) entry(dn string) *ldap.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[strings.ToLower(dn)]
+}
+
+func (s *fakeLdapServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = append(responses, ldapResponse(id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess))
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(id, op)
+		case ldap.ApplicationAddRequest:
+			responses = append(responses, ldapResponse(id, ldap.ApplicationAddResponse, s.add(op)))
+		case ldap.ApplicationModifyRequest:
+			code := uint16(ldap.LDAPResultUnavailableCriticalExtension)
+			if !s.rejectRelax || len(packet.Children) < 3 {
+				code = s.modify(op)
+			}
+			responses = append(responses, ldapResponse(id, ldap.ApplicationModifyResponse, code))
+		case ldap.ApplicationDelRequest:
+			responses = append(responses, ldapResponse(id, ldap.ApplicationDelResponse, s.del(op.Data.String())))
+		default:
+			return
+		}
+		for _, response := range responses {
+			if _, err := conn.Write(response.Bytes()); err != nil && err != io.EOF {
+				return
+			}
+		}
+	}
+}
+
+func (s *fakeLdapServer) add(op *ber.Packet) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dn := op.Children[0].Data.String()
+	if _, ok := s.entries[strings.ToLower(dn)]; ok {
+		return ldap.LDAPResultEntryAlreadyExists
+	}
+	entry := &ldap.Entry{DN: dn}
+	for _, attr := range op.Children[1].Children {
+		entry.Attributes = append(entry.Attributes, ldap.NewEntryAttribute(attr.Children[0].Data.String(), berValues(attr.Children[1])))
+	}
+	s.entries[strings.ToLower(dn)] = entry
+	return ldap.LDAPResultSuccess
+}
+
+func (s *fakeLdapServer) modify(op *ber.Packet) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[strings.ToLower(op.Children[0].Data.String())]
+	if !ok {
+		return ldap.LDAPResultNoSuchObject
+	}
+	for _, change := range op.Children[1].Children {
+		operation := change.Children[0].Value.(int64)
+		name := change.Children[1].Children[0].Data.String()
+		values := berValues(change.Children[1].Children[1])
+		if strings.EqualFold(name, "unicodePwd") && s.rejectPwd {
+			return ldap.LDAPResultUnwillingToPerform
+		}
+		attr := findAttr(entry, name)
+		if attr == nil {
+			attr = ldap.NewEntryAttribute(name, nil)
+			entry.Attributes = append(entry.Attributes, attr)
+		}
+		switch operation {
+		case ldap.AddAttribute:
+			attr.Values = append(attr.Values, values...)
+		case ldap.DeleteAttribute:
+			kept := make([]string, 0)
+			for _, value := range attr.Values {
+				if len(values) > 0 && !contains(values, value) {
+					kept = append(kept, value)
+				}
+			}
+			attr.Values = kept
+		case ldap.ReplaceAttribute:
+			attr.Values = values
+		}
+	}
+	return ldap.LDAPResultSuccess
+}
+
+func (s *fakeLdapServer) del(dn string) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[strings.ToLower(dn)]; !ok {
+		return ldap.LDAPResultNoSuchObject
+	}
+	delete(s.entries, strings.ToLower(dn))
+	return ldap.LDAPResultSuccess
+}
+
+// search 基础对象搜索忽略过滤条件; 子树搜索只支持与、或、相等及存在判断的过滤条件
+func (s *fakeLdapServer) search(id int64, op *ber.Packet) []*ber.Packet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	base := strings.ToLower(op.Children[0].Data.String())
+	wanted := berValues(op.Children[7])
+	if op.Children[1].Value.(int64) != int64(ldap.ScopeBaseObject) {
+		sizeLimit := int(op.Children[3].Value.(int64))
+		responses := make([]*ber.Packet, 0)
+		for dn, entry := range s.entries {
+			if !strings.HasSuffix(dn, base) || !matchFilter(entry, op.Children[6]) {
+				continue
+			}
+			if sizeLimit > 0 && len(responses) == sizeLimit {
+				return append(responses, ldapResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded))
+			}
+			responses = append(responses, ldapMessage(id, searchEntry(entry, wanted)))
+		}
+		return append(responses, ldapResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+	}
+	entry, ok := s.entries[base]
+	if !ok {
+		return []*ber.Packet{ldapResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultNoSuchObject)}
+	}
+	return []*ber.Packet{ldapMessage(id, searchEntry(entry, wanted)), ldapResponse(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)}
+}
+
+func searchEntry(entry *ldap.Entry, wanted []string) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, ""))
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for _, attr := range entry.Attributes {
+		if len(wanted) > 0 && !contains(wanted, "*") && !contains(wanted, attr.Name) {
+			continue
+		}
+		item := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		item.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attr.Name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, value := range attr.Values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+		}
+		item.AppendChild(set)
+		attrs.AppendChild(item)
+	}
+	result.AppendChild(attrs)
+	return result
+}
+
+func matchFilter(entry *ldap.Entry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterEqualityMatch:
+		for _, value := range entry.GetEqualFoldAttributeValues(filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(entry.GetEqualFoldAttributeValues(filter.Data.String())) > 0
+	}
+	return true
+}
+
+func ldapMessage(id int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	packet.AppendChild(op)
+	return packet
+}
+
+func ldapResponse(id int64, tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return ldapMessage(id, op)
+}
+
+func berValues(packet *ber.Packet) []string {
+	values := make([]string, 0, len(packet.Children))
+	for _, child := range packet.Children {
+		values = append(values, child.Data.String())
+	}
+	return values
+}
+
+func findAttr(entry *ldap.Entry, name string) *ldap.EntryAttribute {
+	for _, attr := range entry.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return attr
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func setupADConfig() {
+	config.Conf.Ldap = &config.LdapConfig{
+		Backend: "ad",
+		BaseDN:  "dc=corp,dc=example,dc=com",
+		UserDN:  "ou=people,dc=corp,dc=example,dc=com",
+	}
+}
+
+func TestADAddUser(t *testing.T) {
+	setupADConfig()
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+
+	user := &model.User{
+		Username: "zhangsan",
+		Nickname: "张三",
+		Mail:     "zhangsan@example.com",
+		UserDN:   "cn=zhangsan,ou=people,dc=corp,dc=example,dc=com",
+	}
+	if err := adAddUser(conn, user, "P@ssw0rd!"); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := srv.entry(user.UserDN)
+	if entry == nil {
+		t.Fatal("用户条目未创建")
+	}
+	if !contains(entry.GetAttributeValues("objectClass"), "user") {
+		t.Fatalf("objectClass应包含user, 实际为%v", entry.GetAttributeValues("objectClass"))
+	}
+	if got := entry.GetAttributeValue("sAMAccountName"); got != "zhangsan" {
+		t.Fatalf("sAMAccountName应为zhangsan, 实际为%s", got)
+	}
+	if got := entry.GetAttributeValue("userPrincipalName"); got != "zhangsan@corp.example.com" {
+		t.Fatalf("userPrincipalName应由base-dn推导, 实际为%s", got)
+	}
+	if got := entry.GetAttributeValue("unicodePwd"); got != adUnicodePwd("P@ssw0rd!") {
+		t.Fatalf("unicodePwd编码错误: %q", got)
+	}
+	if got := entry.GetAttributeValue("userAccountControl"); got != strconv.Itoa(adUacNormalAccount) {
+		t.Fatalf("设置密码后账号应为启用状态, userAccountControl实际为%s", got)
+	}
+	if findAttr(entry, "userPassword") != nil {
+		t.Fatal("AD用户不应写入userPassword")
+	}
+}
+
+func TestADAddUserPasswordRejected(t *testing.T) {
+	setupADConfig()
+	srv := newFakeLdapServer(t)
+	srv.rejectPwd = true
+	conn := srv.dial(t)
+
+	user := &model.User{Username: "lisi", UserDN: "cn=lisi,ou=people,dc=corp,dc=example,dc=com"}
+	if err := adAddUser(conn, user, "weak"); err == nil {
+		t.Fatal("密码设置失败时应返回错误")
+	}
+	if srv.entry(user.UserDN) != nil {
+		t.Fatal("密码设置失败时应删除已创建的用户")
+	}
+}
+
+func TestADSetAccountDisabled(t *testing.T) {
+	setupADConfig()
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+
+	udn := "cn=wangwu,ou=people,dc=corp,dc=example,dc=com"
+	add := ldap.NewAddRequest(udn, nil)
+	add.Attribute("objectClass", []string{"user"})
+	// 0x10000: 密码永不过期, 启用禁用时应保留
+	add.Attribute("userAccountControl", []string{strconv.Itoa(adUacNormalAccount | 0x10000)})
+	if err := conn.Add(add); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := adSetAccountDisabled(conn, udn, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.entry(udn).GetAttributeValue("userAccountControl"); got != strconv.Itoa(adUacNormalAccount|0x10000|adUacAccountDisable) {
+		t.Fatalf("禁用后userAccountControl错误: %s", got)
+	}
+	if err := adSetAccountDisabled(conn, udn, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.entry(udn).GetAttributeValue("userAccountControl"); got != strconv.Itoa(adUacNormalAccount|0x10000) {
+		t.Fatalf("启用后userAccountControl错误: %s", got)
+	}
+}
+
+func TestADGroupMembership(t *testing.T) {
+	setupADConfig()
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+
+	group := &model.Group{
+		GroupName:   "ops",
+		GroupType:   "cn",
+		GroupSchema: "group",
+		GroupDN:     "cn=ops,dc=corp,dc=example,dc=com",
+		Remark:      "运维",
+	}
+	if err := conn.Add(adAddGroupRequest(group)); err != nil {
+		t.Fatal(err)
+	}
+	entry := srv.entry(group.GroupDN)
+	if got := entry.GetAttributeValue("groupType"); got != adGroupTypeGlobalSecurity {
+		t.Fatalf("groupType应为全局安全组, 实际为%s", got)
+	}
+	if findAttr(entry, "member") != nil {
+		t.Fatal("AD分组创建时不需要占位成员")
+	}
+
+	memberAttr, posix, err := groupMembership(conn, group.GroupDN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memberAttr != "member" || posix {
+		t.Fatalf("AD分组的成员属性应为member, 实际为%s", memberAttr)
+	}
+}
+
+func TestADUnicodePwd(t *testing.T) {
+	encoded := []byte(adUnicodePwd("中文"))
+	units := make([]uint16, len(encoded)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(encoded[i*2:])
+	}
+	if got := string(utf16.Decode(units)); got != `"中文"` {
+		t.Fatalf("解码后应为带引号的原密码, 实际为%s", got)
+	}
+}
@@ -2,6 +2,9 @@ package ildap
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/eryajf/go-ldap-admin/config"
 	"github.com/eryajf/go-ldap-admin/model"
@@ -17,19 +20,38 @@ func (x GroupService) Add(g *model.Group) error { //organizationalUnit
 	if g.Remark == "" {
 		g.Remark = g.GroupName
 	}
+	if g.GroupType == "cn" && strings.EqualFold(g.GroupSchema, common.GroupSchemaAD) {
+		// 获取 LDAP 连接
+		conn, err := common.GetLDAPConnByDN(g.GroupDN)
+		defer common.PutLADPConn(conn)
+		if err != nil {
+			return err
+		}
+		return conn.Add(adAddGroupRequest(g))
+	}
 	add := ldap.NewAddRequest(g.GroupDN, nil)
 	if g.GroupType == "ou" {
 		add.Attribute("objectClass", []string{"organizationalUnit", "top"}) // 如果定义了 groupOfNAmes，那么必须指定member，否则报错如下：object class 'groupOfNames' requires attribute 'member'
 	}
 	if g.GroupType == "cn" {
-		add.Attribute("objectClass", []string{"groupOfUniqueNames", "top"})
-		add.Attribute("uniqueMember", []string{config.Conf.Ldap.AdminDN}) // 所以这里创建组的时候，默认将admin加入其中，以免创建时没有人员而报上边的错误
+		schema := g.GroupSchema
+		if schema == "" {
+			schema = common.GroupSchemaUniqueNames
+		}
+		objectClass := []string{schema, "top"}
+		if PosixEnabled() && g.GidNumber > 0 {
+			objectClass = append(objectClass, "posixGroup")
+			add.Attribute("gidNumber", []string{strconv.FormatUint(uint64(g.GidNumber), 10)})
+		}
+		add.Attribute("objectClass", objectClass)
+		adminDN := common.LdapDirectoryConf(g.DirectoryID).AdminDN
+		add.Attribute(common.GroupMemberAttr(schema), []string{adminDN}) // 所以这里创建组的时候，默认将admin加入其中，以免创建时没有人员而报上边的错误
 	}
 	add.Attribute(g.GroupType, []string{g.GroupName})
 	add.Attribute("description", []string{g.Remark})
 
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(g.GroupDN)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
@@ -42,9 +64,14 @@ func (x GroupService) Add(g *model.Group) error { //organizationalUnit
 func (x GroupService) Update(oldGroup, newGroup *model.Group) error {
 	modify1 := ldap.NewModifyRequest(oldGroup.GroupDN, nil)
 	modify1.Replace("description", []string{newGroup.Remark})
+	// AD分组改名时同步修改sAMAccountName
+	renamed := config.Conf.Ldap.GroupNameModify && newGroup.GroupName != oldGroup.GroupName
+	if renamed && oldGroup.GroupType == "cn" && strings.EqualFold(oldGroup.GroupSchema, common.GroupSchemaAD) {
+		modify1.Replace("sAMAccountName", []string{newGroup.GroupName})
+	}
 
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(oldGroup.GroupDN)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
@@ -55,7 +82,7 @@ func (x GroupService) Update(oldGroup, newGroup *model.Group) error {
 		return err
 	}
 	// 如果配置文件允许修改分组名称，且分组名称发生了变化，那么执行修改分组名称
-	if config.Conf.Ldap.GroupNameModify && newGroup.GroupName != oldGroup.GroupName {
+	if renamed {
 		modify2 := ldap.NewModifyDNRequest(oldGroup.GroupDN, newGroup.GroupDN, true, "")
 		err := conn.ModifyDN(modify2)
 		if err != nil {
@@ -70,7 +97,7 @@ func (x GroupService) Delete(gdn string) error {
 	del := ldap.NewDelRequest(gdn, nil)
 
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(gdn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
@@ -85,32 +112,246 @@ func (x GroupService) AddUserToGroup(dn, udn string) error {
 	if dn[:3] == "ou=" {
 		return errors.New("不能添加用户到OU组织单元")
 	}
-	newmr := ldap.NewModifyRequest(dn, nil)
-	newmr.Add("uniqueMember", []string{udn})
-
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(dn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
 	}
 
-	return conn.Modify(newmr)
+	memberAttr, posix, err := groupMembership(conn, dn)
+	if err != nil {
+		return err
+	}
+	newmr := ldap.NewModifyRequest(dn, nil)
+	newmr.Add(memberAttr, []string{udn})
+	// posixGroup同时维护memberUid
+	if memberUid := posixMemberUid(udn); posix && memberUid != "" {
+		newmr.Add("memberUid", []string{memberUid})
+	}
+	err = conn.Modify(newmr)
+	// 开启嵌套分组展开时, 用户可能已经作为下级分组的成员写入
+	if config.Conf.Ldap.NestedGroupMaterialize && ldap.IsErrorWithCode(err, ldap.LDAPResultAttributeOrValueExists) {
+		return nil
+	}
+	return err
 }
 
 // DelUserFromGroup 将用户从分组删除
 func (x GroupService) RemoveUserFromGroup(gdn, udn string) error {
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(gdn)
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+
+	memberAttr, posix, err := groupMembership(conn, gdn)
+	if err != nil {
+		return err
+	}
 	newmr := ldap.NewModifyRequest(gdn, nil)
-	newmr.Delete("uniqueMember", []string{udn})
+	newmr.Delete(memberAttr, []string{udn})
+	if memberUid := posixMemberUid(udn); posix && memberUid != "" {
+		newmr.Delete("memberUid", []string{memberUid})
+	}
+	return conn.Modify(newmr)
+}
 
+// EnablePosix 为已存在的分组追加posixGroup及gidNumber, 并按成员写入memberUid, 用于回填
+func (x GroupService) EnablePosix(g *model.Group, memberUids []string) error {
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(g.GroupDN)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
 	}
 
-	return conn.Modify(newmr)
+	return enableGroupPosix(conn, g, memberUids)
+}
+
+// enableGroupPosix 追加posixGroup, 分组已有的gidNumber保持不变
+func enableGroupPosix(conn *ldap.Conn, g *model.Group, memberUids []string) error {
+	missing, err := missingObjectClasses(conn, g.GroupDN, []string{"posixGroup"})
+	if err != nil {
+		return err
+	}
+	entry, err := entryAttributes(conn, g.GroupDN, []string{"gidNumber"})
+	if err != nil {
+		return err
+	}
+	modify := ldap.NewModifyRequest(g.GroupDN, nil)
+	if len(missing) > 0 {
+		modify.Add("objectClass", missing)
+	}
+	if entry.GetEqualFoldAttributeValue("gidNumber") == "" {
+		modify.Add("gidNumber", []string{strconv.FormatUint(uint64(g.GidNumber), 10)})
+	}
+	modify.Replace("memberUid", memberUids)
+	return conn.Modify(modify)
+}
+
+// SetMembers 以成员DN覆盖分组的成员属性, posixGroup同时覆盖memberUid
+func (x GroupService) SetMembers(gdn string, memberDNs []string) error {
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(gdn)
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+
+	memberAttr, posix, err := groupMembership(conn, gdn)
+	if err != nil {
+		return err
+	}
+	modify := ldap.NewModifyRequest(gdn, nil)
+	modify.Replace(memberAttr, memberDNs)
+	if posix {
+		memberUids := make([]string, 0, len(memberDNs))
+		for _, memberDN := range memberDNs {
+			if memberUid := posixMemberUid(memberDN); memberUid != "" {
+				memberUids = append(memberUids, memberUid)
+			}
+		}
+		modify.Replace("memberUid", memberUids)
+	}
+	return conn.Modify(modify)
+}
+
+// ConvertSchema 将分组转换为指定的objectClass, 成员随之迁移到对应的成员属性
+// groupOfUniqueNames与groupOfNames都是结构化objectClass, 优先通过relax控制直接修改, 服务端不支持时删除后按原属性重建条目
+func (x GroupService) ConvertSchema(gdn, schema string) error {
+	if common.IsActiveDirectory() {
+		return errors.New("Active Directory的分组不支持转换objectClass")
+	}
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(gdn)
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+	return convertGroupSchema(conn, gdn, schema)
+}
+
+func convertGroupSchema(conn *ldap.Conn, gdn, schema string) error {
+	searchRequest := ldap.NewSearchRequest(
+		gdn,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"*"},
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
+	if err != nil {
+		return err
+	}
+	if len(sr.Entries) == 0 {
+		return fmt.Errorf("分组%s不存在", gdn)
+	}
+	entry := sr.Entries[0]
+
+	oldSchema := common.GroupSchemaUniqueNames
+	for _, objectClass := range entry.GetAttributeValues("objectClass") {
+		if strings.EqualFold(objectClass, common.GroupSchemaNames) {
+			oldSchema = common.GroupSchemaNames
+		}
+	}
+	if oldSchema == schema {
+		return nil
+	}
+	oldMemberAttr, newMemberAttr := common.GroupMemberAttr(oldSchema), common.GroupMemberAttr(schema)
+	objectClass := make([]string, 0)
+	for _, value := range entry.GetAttributeValues("objectClass") {
+		if strings.EqualFold(value, oldSchema) {
+			value = schema
+		}
+		objectClass = append(objectClass, value)
+	}
+	members := entry.GetAttributeValues(oldMemberAttr)
+
+	// 先携带relax控制在一次Modify中修改structural objectClass, 转换过程中分组始终存在
+	modify := ldap.NewModifyRequest(gdn, []ldap.Control{ldap.NewControlString(controlTypeRelax, true, "")})
+	modify.Replace("objectClass", objectClass)
+	if len(members) > 0 {
+		modify.Add(newMemberAttr, members)
+		modify.Delete(oldMemberAttr, nil)
+	}
+	err = conn.Modify(modify)
+	if err == nil || !relaxUnsupported(err) {
+		return err
+	}
+
+	// 服务端不支持修改structural objectClass时删除后重建
+	add := ldap.NewAddRequest(gdn, nil)
+	for _, attr := range entry.Attributes {
+		switch {
+		case strings.EqualFold(attr.Name, "objectClass"):
+			add.Attribute(attr.Name, objectClass)
+		case strings.EqualFold(attr.Name, oldMemberAttr):
+			add.Attribute(newMemberAttr, attr.Values)
+		default:
+			add.Attribute(attr.Name, attr.Values)
+		}
+	}
+
+	err = conn.Del(ldap.NewDelRequest(gdn, nil))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNotAllowedOnNonLeaf) {
+			return fmt.Errorf("分组%s下存在子条目，无法转换", gdn)
+		}
+		return err
+	}
+	err = conn.Add(add)
+	if err != nil {
+		// 重建失败时按原属性恢复分组
+		restore := ldap.NewAddRequest(gdn, nil)
+		for _, attr := range entry.Attributes {
+			restore.Attribute(attr.Name, attr.Values)
+		}
+		if restoreErr := conn.Add(restore); restoreErr != nil {
+			return fmt.Errorf("转换分组失败: %v, 恢复分组失败: %v", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// relaxUnsupported 服务端不支持relax控制或不允许修改structural objectClass
+func relaxUnsupported(err error) bool {
+	return ldap.IsErrorWithCode(err, ldap.LDAPResultUnavailableCriticalExtension) ||
+		ldap.IsErrorWithCode(err, ldap.LDAPResultObjectClassModsProhibited) ||
+		ldap.IsErrorWithCode(err, ldap.LDAPResultObjectClassViolation) ||
+		ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform)
+}
+
+// groupMembership 根据分组条目的objectClass确定成员属性, 以及是否需要维护memberUid
+func groupMembership(conn *ldap.Conn, gdn string) (memberAttr string, posix bool, err error) {
+	objectClasses, err := entryObjectClasses(conn, gdn)
+	if err != nil {
+		return "", false, err
+	}
+	schema := common.GroupSchemaUniqueNames
+	if objectClasses[strings.ToLower(common.GroupSchemaNames)] {
+		schema = common.GroupSchemaNames
+	}
+	if objectClasses[strings.ToLower(common.GroupSchemaAD)] {
+		schema = common.GroupSchemaAD
+	}
+	return common.GroupMemberAttr(schema), PosixEnabled() && objectClasses["posixgroup"], nil
+}
+
+// posixMemberUid 返回用户对应的memberUid
+func posixMemberUid(udn string) string {
+	dn, err := ldap.ParseDN(udn)
+	if err != nil || len(dn.RDNs) == 0 {
+		return ""
+	}
+	for _, attr := range dn.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, common.LdapUserRdnAttr()) {
+			return attr.Value
+		}
+	}
+	return ""
 }
 
 // DelUserFromGroup 将用户从分组删除
@@ -119,29 +360,34 @@ func (x GroupService) ListGroupDN() (groups []*model.Group, err error) {
 	searchRequest := ldap.NewSearchRequest(
 		config.Conf.Ldap.BaseDN,                                     // This is basedn, we will start searching from this node.
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, // Here several parameters are respectively scope, derefAliases, sizeLimit, timeLimit,  typesOnly
-		"(|(objectClass=organizationalUnit)(objectClass=groupOfUniqueNames))", // This is Filter for LDAP query
+		"(|(objectClass=organizationalUnit)(objectClass=groupOfUniqueNames)(objectClass=groupOfNames)(objectClass=group))", // This is Filter for LDAP query
 		[]string{"DN"}, // Here are the attributes returned by the query, provided as an array. If empty, all attributes are returned
 		nil,
 	)
 
-	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
-	defer common.PutLADPConn(conn)
-	if err != nil {
-		return groups, err
-	}
-	var sr *ldap.SearchResult
-	// Search through ldap built-in search
-	sr, err = conn.Search(searchRequest)
+	err = common.SearchLDAP(searchRequest, func(v *ldap.Entry) error {
+		groups = append(groups, &model.Group{
+			GroupDN: v.DN,
+		})
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if len(sr.Entries) > 0 {
-		for _, v := range sr.Entries {
-			groups = append(groups, &model.Group{
-				GroupDN: v.DN,
-			})
-		}
-	}
 	return
 }
+
+// Move 修改分组的dn, 新dn的上级与原来不同时移动到新的上级之下
+func (x GroupService) Move(oldDN, newDN string) error {
+	rdn, superior, _ := strings.Cut(newDN, ",")
+	modify := ldap.NewModifyDNRequest(oldDN, rdn, true, superior)
+
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(oldDN)
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+
+	return conn.ModifyDN(modify)
+}
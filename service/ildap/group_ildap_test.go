@@ -0,0 +1,84 @@
+package ildap
+
+import (
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func addUniqueNamesGroup(t *testing.T, conn *ldap.Conn, gdn string) {
+	add := ldap.NewAddRequest(gdn, nil)
+	add.Attribute("objectClass", []string{common.GroupSchemaUniqueNames, "top"})
+	add.Attribute("cn", []string{"dev"})
+	add.Attribute("description", []string{"研发"})
+	add.Attribute("uniqueMember", []string{"uid=zhangsan,ou=people,dc=eryajf,dc=net", "uid=lisi,ou=people,dc=eryajf,dc=net"})
+	if err := conn.Add(add); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertConverted(t *testing.T, srv *fakeLdapServer, gdn string) {
+	entry := srv.entry(gdn)
+	if entry == nil {
+		t.Fatal("转换后分组应存在")
+	}
+	objectClasses := entry.GetAttributeValues("objectClass")
+	if !contains(objectClasses, common.GroupSchemaNames) || contains(objectClasses, common.GroupSchemaUniqueNames) {
+		t.Fatalf("objectClass应转换为groupOfNames, 实际为%v", objectClasses)
+	}
+	if members := entry.GetAttributeValues("member"); len(members) != 2 {
+		t.Fatalf("成员应迁移到member, 实际为%v", members)
+	}
+	if members := entry.GetAttributeValues("uniqueMember"); len(members) != 0 {
+		t.Fatalf("uniqueMember应被移除, 实际为%v", members)
+	}
+	if got := entry.GetAttributeValue("description"); got != "研发" {
+		t.Fatalf("其他属性应保留, 实际description为%s", got)
+	}
+}
+
+func TestConvertGroupSchema(t *testing.T) {
+	config.Conf.Ldap = &config.LdapConfig{BaseDN: "dc=eryajf,dc=net"}
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+	gdn := "cn=dev,ou=groups,dc=eryajf,dc=net"
+	addUniqueNamesGroup(t, conn, gdn)
+
+	if err := convertGroupSchema(conn, gdn, common.GroupSchemaNames); err != nil {
+		t.Fatal(err)
+	}
+	assertConverted(t, srv, gdn)
+
+	// 已是目标objectClass时不做修改
+	if err := convertGroupSchema(conn, gdn, common.GroupSchemaNames); err != nil {
+		t.Fatal(err)
+	}
+	assertConverted(t, srv, gdn)
+}
+
+func TestConvertGroupSchemaWithoutRelax(t *testing.T) {
+	config.Conf.Ldap = &config.LdapConfig{BaseDN: "dc=eryajf,dc=net"}
+	srv := newFakeLdapServer(t)
+	srv.rejectRelax = true
+	conn := srv.dial(t)
+	gdn := "cn=dev,ou=groups,dc=eryajf,dc=net"
+	addUniqueNamesGroup(t, conn, gdn)
+
+	// 服务端不支持relax控制时删除后重建
+	if err := convertGroupSchema(conn, gdn, common.GroupSchemaNames); err != nil {
+		t.Fatal(err)
+	}
+	assertConverted(t, srv, gdn)
+}
+
+func TestConvertGroupSchemaMissing(t *testing.T) {
+	config.Conf.Ldap = &config.LdapConfig{BaseDN: "dc=eryajf,dc=net"}
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+	if err := convertGroupSchema(conn, "cn=none,dc=eryajf,dc=net", common.GroupSchemaNames); err == nil {
+		t.Fatal("分组不存在时应报错")
+	}
+}
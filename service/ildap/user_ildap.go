@@ -1,7 +1,11 @@
 package ildap
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/eryajf/go-ldap-admin/config"
 	"github.com/eryajf/go-ldap-admin/model"
@@ -9,61 +13,107 @@ import (
 	"github.com/eryajf/go-ldap-admin/public/tools"
 
 	ldap "github.com/go-ldap/ldap/v3"
+	"github.com/thoas/go-funk"
 )
 
 type UserService struct{}
 
-// 创建资源
-func (x UserService) Add(user *model.User) error {
+// 可用于保存密码修改时间的LDAP属性
+const (
+	PwdChangedAttrShadow  = "shadowLastChange"
+	PwdChangedAttrPpolicy = "pwdChangedTime"
+
+	// OpenLDAP的relax控制, 允许修改NO-USER-MODIFICATION属性
+	controlTypeRelax = "1.3.6.1.4.1.4203.666.5.12"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrAccountLocked      = errors.New("账号已被锁定，请联系管理员")
+	ErrPasswordExpired    = errors.New("密码已过期，请重置密码后再登录")
+
+	// 搜索到条目后用于提前结束分页搜索
+	errEntryFound = errors.New("entry found")
+)
+
+// 创建资源，数据库中只保存密码哈希，因此需要传入明文密码
+func (x UserService) Add(user *model.User, passwd string) error {
+	if common.IsActiveDirectory() {
+		// 获取 LDAP 连接
+		conn, err := common.GetLDAPConnByDN(user.UserDN)
+		defer common.PutLADPConn(conn)
+		if err != nil {
+			return err
+		}
+		return adAddUser(conn, user, passwd)
+	}
+
 	add := ldap.NewAddRequest(user.UserDN, nil)
-	add.Attribute("objectClass", []string{"inetOrgPerson"})
-	add.Attribute("cn", []string{user.Username})
-	add.Attribute("sn", []string{user.Nickname})
-	add.Attribute("businessCategory", []string{user.Departments})
-	add.Attribute("departmentNumber", []string{user.Position})
-	add.Attribute("description", []string{user.Introduction})
-	add.Attribute("displayName", []string{user.Nickname})
-	add.Attribute("mail", []string{user.Mail})
-	add.Attribute("employeeNumber", []string{user.JobNumber})
-	add.Attribute("givenName", []string{user.GivenName})
-	add.Attribute("postalAddress", []string{user.PostalAddress})
-	add.Attribute("mobile", []string{user.Mobile})
-	add.Attribute("uid", []string{user.Username})
+	objectClass := append([]string{}, common.GetLdapUserSchema().ObjectClasses...)
+	posix := PosixEnabled() && user.UidNumber > 0
+	if posix {
+		objectClass = append(objectClass, "posixAccount")
+		for name, values := range posixUserAttrs(user) {
+			add.Attribute(name, values)
+		}
+	}
+	if shadowEnabled(posix) {
+		objectClass = append(objectClass, "shadowAccount")
+		add.Attribute(PwdChangedAttrShadow, []string{shadowDays(time.Now())})
+	}
+	add.Attribute("objectClass", objectClass)
+	add.Attribute(common.LdapUserRdnAttr(), []string{user.Username})
+	for name, values := range groupLdapAttrs(common.LdapUserAttributes(user), false) {
+		add.Attribute(name, values)
+	}
 	var pass string
 	if config.Conf.Ldap.UserPasswordEncryptionType == "clear" {
-		pass = tools.NewParPasswd(user.Password)
+		pass = passwd
 	} else {
-		pass = tools.EncodePass([]byte(tools.NewParPasswd(user.Password)))
+		pass = tools.EncodePass([]byte(passwd))
 	}
 	add.Attribute("userPassword", []string{pass})
 
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(user.UserDN)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
 	}
 
-	return conn.Add(add)
+	err = conn.Add(add)
+	if err != nil {
+		return err
+	}
+	if posix && privateGroupEnabled() {
+		if err := addPrivateGroup(conn, user); err != nil {
+			// 私有组创建失败时撤销用户条目, 避免用户的主组不存在
+			if delErr := conn.Del(ldap.NewDelRequest(user.UserDN, nil)); delErr != nil {
+				return fmt.Errorf("创建用户私有组失败: %v, 撤销用户条目失败: %v", err, delErr)
+			}
+			return fmt.Errorf("创建用户私有组失败: %v", err)
+		}
+	}
+	if config.Conf.PasswordPolicy != nil && config.Conf.PasswordPolicy.LdapChangedAttr == PwdChangedAttrPpolicy {
+		x.mirrorPwdChangedTime(user.UserDN)
+	}
+	return nil
 }
 
 // Update 更新资源
 func (x UserService) Update(oldusername string, user *model.User) error {
 	modify := ldap.NewModifyRequest(user.UserDN, nil)
-	modify.Replace("cn", []string{user.Username})
-	modify.Replace("sn", []string{oldusername})
-	modify.Replace("businessCategory", []string{user.Departments})
-	modify.Replace("departmentNumber", []string{user.Position})
-	modify.Replace("description", []string{user.Introduction})
-	modify.Replace("displayName", []string{user.Nickname})
-	modify.Replace("mail", []string{user.Mail})
-	modify.Replace("employeeNumber", []string{user.JobNumber})
-	modify.Replace("givenName", []string{user.GivenName})
-	modify.Replace("postalAddress", []string{user.PostalAddress})
-	modify.Replace("mobile", []string{user.Mobile})
+	// 字段为空时替换为空值, 即删除该属性
+	attrs := groupLdapAttrs(common.LdapUserAttributes(user), true)
+	if common.IsActiveDirectory() {
+		attrs = adUserAttrs(user, true)
+	}
+	for name, values := range attrs {
+		modify.Replace(name, values)
+	}
 
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(user.UserDN)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
@@ -74,18 +124,40 @@ func (x UserService) Update(oldusername string, user *model.User) error {
 		return err
 	}
 	if config.Conf.Ldap.UserNameModify && oldusername != user.Username {
-		modifyDn := ldap.NewModifyDNRequest(fmt.Sprintf("uid=%s,%s", oldusername, config.Conf.Ldap.UserDN), fmt.Sprintf("uid=%s", user.Username), true, "")
-		return conn.ModifyDN(modifyDn)
+		modifyDn := ldap.NewModifyDNRequest(common.LdapUserDNOf(user.DirectoryID, oldusername), fmt.Sprintf("%s=%s", common.LdapUserRdnAttr(), user.Username), true, "")
+		if err := conn.ModifyDN(modifyDn); err != nil {
+			return err
+		}
+		if privateGroupEnabled() {
+			return renamePrivateGroup(conn, user, oldusername)
+		}
 	}
 	return nil
 }
 
+// renamePrivateGroup 用户改名后同步修改私有组的cn与成员
+func renamePrivateGroup(conn *ldap.Conn, user *model.User, oldusername string) error {
+	gdn := privateGroupDN(user.UserDN, oldusername)
+	entry, err := privateGroupEntry(conn, gdn, oldusername)
+	if err != nil || entry == nil {
+		return err
+	}
+	modifyDn := ldap.NewModifyDNRequest(gdn, fmt.Sprintf("cn=%s", user.Username), true, "")
+	if err := conn.ModifyDN(modifyDn); err != nil {
+		return err
+	}
+	modify := ldap.NewModifyRequest(privateGroupDN(user.UserDN, user.Username), nil)
+	modify.Replace(common.GroupMemberAttr(common.GroupSchemaUniqueNames), []string{user.UserDN})
+	modify.Replace("memberUid", []string{user.Username})
+	return conn.Modify(modify)
+}
+
 func (x UserService) Exist(filter map[string]any) (bool, error) {
 	filter_str := ""
 	for key, value := range filter {
 		filter_str += fmt.Sprintf("(%s=%s)", key, value)
 	}
-	search_filter := fmt.Sprintf("(&(|(objectClass=inetOrgPerson)(objectClass=simpleSecurityObject))%s)", filter_str)
+	search_filter := fmt.Sprintf("(&(|%s(objectClass=simpleSecurityObject))%s)", common.LdapUserObjectClassFilter(), filter_str)
 	// Construct query request
 	searchRequest := ldap.NewSearchRequest(
 		config.Conf.Ldap.BaseDN,                                     // This is basedn, we will start searching from this node.
@@ -95,45 +167,97 @@ func (x UserService) Exist(filter map[string]any) (bool, error) {
 		nil,
 	)
 
+	// 找到一个条目即可停止搜索
+	err := common.SearchLDAP(searchRequest, func(entry *ldap.Entry) error {
+		return errEntryFound
+	})
+	if errors.Is(err, errEntryFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+// Delete 删除资源, 同时删除用户的私有组
+func (x UserService) Delete(udn string) error {
+	del := ldap.NewDelRequest(udn, nil)
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(udn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
-		return false, err
+		return err
 	}
-	var sr *ldap.SearchResult
-	// Search through ldap built-in search
-	sr, err = conn.Search(searchRequest)
-	if err != nil {
-		return false, err
+	if err := conn.Del(del); err != nil {
+		return err
 	}
-	if len(sr.Entries) > 0 {
-		return true, nil
+	username := posixMemberUid(udn)
+	if !privateGroupEnabled() || username == "" {
+		return nil
 	}
-	return false, nil
+	gdn := privateGroupDN(udn, username)
+	entry, err := privateGroupEntry(conn, gdn, username)
+	if err != nil || entry == nil {
+		return err
+	}
+	return conn.Del(ldap.NewDelRequest(gdn, nil))
 }
 
-// Delete 删除资源
-func (x UserService) Delete(udn string) error {
-	del := ldap.NewDelRequest(udn, nil)
+// Disable 禁用用户, OpenLDAP没有通用的账号禁用属性, 直接删除用户条目; AD通过userAccountControl禁用账号
+func (x UserService) Disable(udn string) error {
+	if !common.IsActiveDirectory() {
+		return x.Delete(udn)
+	}
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(udn)
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+	return adSetAccountDisabled(conn, udn, true)
+}
+
+// Enable 启用AD中被禁用的账号, OpenLDAP中离职用户的条目已删除, 需要通过Add重新添加
+func (x UserService) Enable(udn string) error {
+	if !common.IsActiveDirectory() {
+		return errors.New("只有Active Directory支持启用账号")
+	}
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(udn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
 	}
-	return conn.Del(del)
+	return adSetAccountDisabled(conn, udn, false)
 }
 
 // ChangePwd 修改用户密码，此处旧密码也可以为空，ldap可以直接通过用户DN加上新密码来进行修改
 func (x UserService) ChangePwd(udn, oldpasswd, newpasswd string) error {
+	err := x.changePwd(udn, oldpasswd, newpasswd)
+	if err == nil {
+		x.mirrorPwdChangedTime(udn)
+	}
+	return err
+}
+
+func (x UserService) changePwd(udn, oldpasswd, newpasswd string) error {
+	if common.IsActiveDirectory() {
+		// 获取 LDAP 连接
+		conn, err := common.GetLDAPConnByDN(udn)
+		defer common.PutLADPConn(conn)
+		if err != nil {
+			return err
+		}
+		if err := adSetPassword(conn, udn, newpasswd); err != nil {
+			return fmt.Errorf("password modify failed for %s, err: %v", udn, err)
+		}
+		return nil
+	}
 	if config.Conf.Ldap.UserPasswordEncryptionType == "clear" {
 		return updatePasswordClear(udn, newpasswd)
 	}
 	modifyPass := ldap.NewPasswordModifyRequest(udn, oldpasswd, newpasswd)
 
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(udn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
@@ -146,33 +270,197 @@ func (x UserService) ChangePwd(udn, oldpasswd, newpasswd string) error {
 	return nil
 }
 
-// NewPwd 新旧密码都是空，通过管理员可以修改成功并返回新的密码
-func (x UserService) NewPwd(username string) (string, error) {
-	udn := fmt.Sprintf("uid=%s,%s", username, config.Conf.Ldap.UserDN)
-	if username == "admin" {
-		udn = config.Conf.Ldap.AdminDN
+// SetPwdChangedTime 将密码修改时间写入配置的LDAP属性
+func (x UserService) SetPwdChangedTime(udn string, t time.Time) error {
+	// AD自行维护pwdLastSet
+	if config.Conf.PasswordPolicy == nil || common.IsActiveDirectory() {
+		return nil
 	}
-	if config.Conf.Ldap.UserPasswordEncryptionType == "clear" {
-		newpass := tools.GenerateRandomPassword()
-		if err := updatePasswordClear(udn, newpass); err != nil {
-			return "", fmt.Errorf("password modify failed for %s, err: %v", username, err)
+	var modify *ldap.ModifyRequest
+	switch config.Conf.PasswordPolicy.LdapChangedAttr {
+	case PwdChangedAttrShadow:
+		modify = ldap.NewModifyRequest(udn, nil)
+		modify.Replace(PwdChangedAttrShadow, []string{shadowDays(t)})
+	case PwdChangedAttrPpolicy:
+		// pwdChangedTime为ppolicy的操作属性, 需携带relax控制才允许管理员写入
+		modify = ldap.NewModifyRequest(udn, []ldap.Control{ldap.NewControlString(controlTypeRelax, true, "")})
+		modify.Replace(PwdChangedAttrPpolicy, []string{t.UTC().Format("20060102150405Z")})
+	default:
+		return nil
+	}
+
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(udn)
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+
+	err = conn.Modify(modify)
+	// 旧用户条目没有shadowAccount时补上再写入
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultObjectClassViolation) && config.Conf.PasswordPolicy.LdapChangedAttr == PwdChangedAttrShadow {
+		modify.Add("objectClass", []string{"shadowAccount"})
+		err = conn.Modify(modify)
+	}
+	return err
+}
+
+// EnablePosix 为已存在的用户条目追加posixAccount及uidNumber等属性, 用于回填
+func (x UserService) EnablePosix(user *model.User) error {
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(user.UserDN)
+	defer common.PutLADPConn(conn)
+	if err != nil {
+		return err
+	}
+	if err := enableUserPosix(conn, user); err != nil {
+		return err
+	}
+	if !privateGroupEnabled() {
+		return nil
+	}
+	// 沿用的gidNumber已有对应的分组时, 该分组就是用户的主组, 不再创建私有组
+	exists, err := posixGroupExists(conn, common.LdapDirectoryConf(common.LdapDirectoryOf(user.UserDN)).BaseDN, user.GidNumber)
+	if err != nil || exists {
+		return err
+	}
+	return addPrivateGroup(conn, user)
+}
+
+// enableUserPosix 追加缺少的objectClass与posix属性, 条目已有的属性保持不变, 避免改变主机上文件的属主与用户的家目录
+func enableUserPosix(conn *ldap.Conn, user *model.User) error {
+	objectClass := []string{"posixAccount"}
+	if shadowEnabled(true) {
+		objectClass = append(objectClass, "shadowAccount")
+	}
+	missing, err := missingObjectClasses(conn, user.UserDN, objectClass)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0)
+	attrs := posixUserAttrs(user)
+	for name := range attrs {
+		names = append(names, name)
+	}
+	entry, err := entryAttributes(conn, user.UserDN, names)
+	if err != nil {
+		return err
+	}
+	modify := ldap.NewModifyRequest(user.UserDN, nil)
+	if len(missing) > 0 {
+		modify.Add("objectClass", missing)
+	}
+	for name, values := range attrs {
+		if len(entry.GetEqualFoldAttributeValues(name)) == 0 {
+			modify.Add(name, values)
 		}
-		return newpass, nil
 	}
-	modifyPass := ldap.NewPasswordModifyRequest(udn, "", "")
+	if len(modify.Changes) == 0 {
+		return nil
+	}
+	return conn.Modify(modify)
+}
 
+// SetSshKeys 以keys覆盖用户条目的sshPublicKey, keys为空时删除该属性
+func (x UserService) SetSshKeys(udn string, keys []string) error {
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(udn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	newpass, err := conn.PasswordModify(modifyPass)
+	modify := ldap.NewModifyRequest(udn, nil)
+	if len(keys) > 0 {
+		// sshPublicKey由openssh-lpk schema的ldapPublicKey辅助类提供
+		missing, err := missingObjectClasses(conn, udn, []string{"ldapPublicKey"})
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			modify.Add("objectClass", missing)
+		}
+	}
+	modify.Replace("sshPublicKey", keys)
+	return conn.Modify(modify)
+}
+
+// mirrorPwdChangedTime 同步密码修改时间, 失败只记录日志, 不影响密码修改结果
+func (x UserService) mirrorPwdChangedTime(udn string) {
+	if err := x.SetPwdChangedTime(udn, time.Now()); err != nil {
+		common.Log.Warnf("同步用户[%s]的密码修改时间到LDAP失败: %v", udn, err)
+	}
+}
+
+// shadowDays shadowLastChange以1970-01-01起的天数表示
+func shadowDays(t time.Time) string {
+	return strconv.FormatInt(t.Unix()/86400, 10)
+}
+
+// Auth 以用户DN进行简单绑定，校验用户在LDAP中的密码
+func (x UserService) Auth(udn, passwd string) error {
+	// 获取 LDAP 连接
+	conn, err := common.GetLDAPConnByDN(udn)
 	if err != nil {
-		return "", fmt.Errorf("password modify failed for %s, err: %v", username, err)
+		return err
 	}
-	return newpass.GeneratedPassword, nil
+	defer common.PutLADPConn(conn)
+
+	bindReq := ldap.NewSimpleBindRequest(udn, passwd, []ldap.Control{ldap.NewControlBeheraPasswordPolicy()})
+	result, bindErr := conn.SimpleBind(bindReq)
+
+	// 连接池中的连接需要恢复为所属目录的管理员身份再放回，恢复失败则关闭连接，由连接池丢弃
+	conf := common.LdapDirectoryConf(common.LdapDirectoryOf(udn))
+	if err := conn.Bind(conf.AdminDN, conf.AdminPass); err != nil {
+		common.Log.Warnf("用户绑定后恢复管理员绑定失败: %v", err)
+		conn.Close()
+	}
+	return parseBindError(result, bindErr)
+}
+
+// parseBindError 将绑定结果转换为明确的登录错误
+func parseBindError(result *ldap.SimpleBindResult, err error) error {
+	// 开启了ppolicy的OpenLDAP会在响应控制中返回具体原因
+	if result != nil {
+		for _, control := range result.Controls {
+			ppolicy, ok := control.(*ldap.ControlBeheraPasswordPolicy)
+			if !ok {
+				continue
+			}
+			switch ppolicy.Error {
+			case 0, 2: // passwordExpired, changeAfterReset
+				return ErrPasswordExpired
+			case 1: // accountLocked
+				return ErrAccountLocked
+			}
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	if ldap.IsErrorWithCode(err, ldap.ErrorEmptyPassword) {
+		return ErrInvalidCredentials
+	}
+
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) {
+		return fmt.Errorf("LDAP认证失败: %v", err)
+	}
+	switch ldapErr.ResultCode {
+	case ldap.LDAPResultInvalidCredentials:
+		// Active Directory 会在诊断信息中通过 data 子码说明原因
+		msg := ldapErr.Error()
+		switch {
+		case strings.Contains(msg, "data 775"), strings.Contains(msg, "data 533"), strings.Contains(msg, "data 701"):
+			return ErrAccountLocked
+		case strings.Contains(msg, "data 532"), strings.Contains(msg, "data 773"):
+			return ErrPasswordExpired
+		}
+		return ErrInvalidCredentials
+	case ldap.LDAPResultUnwillingToPerform:
+		return ErrAccountLocked
+	}
+	return fmt.Errorf("LDAP认证失败: %v", err)
 }
 
 func updatePasswordClear(udn, newpasswd string) error {
@@ -180,7 +468,7 @@ func updatePasswordClear(udn, newpasswd string) error {
 	modify.Replace("userPassword", []string{newpasswd})
 
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(udn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
 		return err
@@ -196,29 +484,282 @@ func (x UserService) ListUserDN() (users []*model.User, err error) {
 	searchRequest := ldap.NewSearchRequest(
 		config.Conf.Ldap.BaseDN,                                     // This is basedn, we will start searching from this node.
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, // Here several parameters are respectively scope, derefAliases, sizeLimit, timeLimit,  typesOnly
-		"(|(objectClass=inetOrgPerson)(objectClass=simpleSecurityObject))", // This is Filter for LDAP query
+		"(|"+common.LdapUserObjectClassFilter()+"(objectClass=simpleSecurityObject))", // This is Filter for LDAP query
 		[]string{"DN"}, // Here are the attributes returned by the query, provided as an array. If empty, all attributes are returned
 		nil,
 	)
 
+	err = common.SearchLDAP(searchRequest, func(v *ldap.Entry) error {
+		users = append(users, &model.User{
+			UserDN: v.DN,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// groupLdapAttrs 按属性名合并取值, 多个字段映射到同一属性时保存为多值, keepEmpty为false时忽略没有取值的属性
+func groupLdapAttrs(attrs []common.LdapAttr, keepEmpty bool) map[string][]string {
+	grouped := make(map[string][]string)
+	for _, attr := range attrs {
+		values := grouped[attr.Name]
+		if attr.Value != "" && !funk.ContainsString(values, attr.Value) {
+			values = append(values, attr.Value)
+		}
+		if len(values) > 0 || keepEmpty {
+			grouped[attr.Name] = values
+		}
+	}
+	return grouped
+}
+
+// PosixIds 获取条目中已有的uidNumber与gidNumber, 没有时为0
+func PosixIds(dn string) (uidNumber, gidNumber uint, err error) {
 	// 获取 LDAP 连接
-	conn, err := common.GetLDAPConn()
+	conn, err := common.GetLDAPConnByDN(dn)
 	defer common.PutLADPConn(conn)
 	if err != nil {
-		return users, err
+		return 0, 0, err
+	}
+	return entryPosixIds(conn, dn)
+}
+
+func entryPosixIds(conn *ldap.Conn, dn string) (uidNumber, gidNumber uint, err error) {
+	entry, err := entryAttributes(conn, dn, []string{"uidNumber", "gidNumber"})
+	if err != nil {
+		return 0, 0, err
+	}
+	parse := func(name string) (uint, error) {
+		value := entry.GetEqualFoldAttributeValue(name)
+		if value == "" {
+			return 0, nil
+		}
+		number, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("条目%s的%s格式错误: %s", dn, name, value)
+		}
+		return uint(number), nil
+	}
+	if uidNumber, err = parse("uidNumber"); err != nil {
+		return 0, 0, err
+	}
+	if gidNumber, err = parse("gidNumber"); err != nil {
+		return 0, 0, err
+	}
+	return uidNumber, gidNumber, nil
+}
+
+// PosixIdUsed 判断编号是否已被任一LDAP目录中的条目使用
+func PosixIdUsed(kind string, number uint) (bool, error) {
+	for _, id := range common.LdapDirectoryIds() {
+		conn, err := common.GetLDAPConnOf(id)
+		if err != nil {
+			common.PutLADPConn(conn)
+			return false, err
+		}
+		used, err := posixIdUsed(conn, common.LdapDirectoryConf(id).BaseDN, kind, number)
+		common.PutLADPConn(conn)
+		if err != nil || used {
+			return used, err
+		}
+	}
+	return false, nil
+}
+
+func posixIdUsed(conn *ldap.Conn, baseDN, kind string, number uint) (bool, error) {
+	attr := "gidNumber"
+	if kind == model.PosixIdKindUid {
+		attr = "uidNumber"
+	}
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false,
+		fmt.Sprintf("(%s=%d)", attr, number),
+		[]string{"dn"},
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(sr.Entries) > 0, nil
+}
+
+// posixGroupExists 判断目录中是否已有使用该gidNumber的posixGroup
+func posixGroupExists(conn *ldap.Conn, baseDN string, gidNumber uint) (bool, error) {
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false,
+		fmt.Sprintf("(&(objectClass=posixGroup)(gidNumber=%d))", gidNumber),
+		[]string{"dn"},
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(sr.Entries) > 0, nil
+}
+
+// PosixEnabled 是否开启了POSIX账号与分组
+func PosixEnabled() bool {
+	return config.Conf.Ldap.Posix != nil && config.Conf.Ldap.Posix.Enable
+}
+
+// shadowEnabled 用户条目是否需要shadowAccount, 密码修改时间写入shadowLastChange或POSIX账号开启了shadow时需要
+func shadowEnabled(posix bool) bool {
+	if config.Conf.PasswordPolicy != nil && config.Conf.PasswordPolicy.LdapChangedAttr == PwdChangedAttrShadow {
+		return true
+	}
+	return posix && config.Conf.Ldap.Posix.Shadow
+}
+
+// posixUserAttrs 用户的posixAccount属性
+func posixUserAttrs(user *model.User) map[string][]string {
+	conf := config.Conf.Ldap.Posix
+	gidNumber := user.GidNumber
+	if gidNumber == 0 {
+		gidNumber = user.UidNumber
+	}
+	homeDirectory := conf.HomeDirectory
+	if homeDirectory == "" {
+		homeDirectory = "/home/{username}"
+	}
+	attrs := map[string][]string{
+		"uidNumber":     {strconv.FormatUint(uint64(user.UidNumber), 10)},
+		"gidNumber":     {strconv.FormatUint(uint64(gidNumber), 10)},
+		"homeDirectory": {strings.ReplaceAll(homeDirectory, "{username}", user.Username)},
+	}
+	if conf.LoginShell != "" {
+		attrs["loginShell"] = []string{conf.LoginShell}
+	}
+	return attrs
+}
+
+// privateGroupEnabled 是否为用户创建私有组, 配置了统一的主组gidNumber时不需要
+func privateGroupEnabled() bool {
+	return PosixEnabled() && config.Conf.Ldap.Posix.UserGidNumber == 0 && !common.IsActiveDirectory()
+}
+
+// privateGroupDN 用户私有组的DN, 未配置私有组所在DN或不是默认目录时放在所属目录的基础DN下
+func privateGroupDN(udn, username string) string {
+	dirId := common.LdapDirectoryOf(udn)
+	baseDN := common.LdapDirectoryConf(dirId).BaseDN
+	if dirId == common.DefaultDirectoryID && config.Conf.Ldap.Posix.PrivateGroupDN != "" {
+		baseDN = config.Conf.Ldap.Posix.PrivateGroupDN
+	}
+	return fmt.Sprintf("cn=%s,%s", username, baseDN)
+}
+
+// addPrivateGroup 创建用户私有组, 条目已存在且gidNumber一致时视为已创建
+func addPrivateGroup(conn *ldap.Conn, user *model.User) error {
+	gdn := privateGroupDN(user.UserDN, user.Username)
+	gidNumber := strconv.FormatUint(uint64(user.GidNumber), 10)
+	add := ldap.NewAddRequest(gdn, nil)
+	add.Attribute("objectClass", []string{common.GroupSchemaUniqueNames, "top", "posixGroup"})
+	add.Attribute("cn", []string{user.Username})
+	add.Attribute("gidNumber", []string{gidNumber})
+	add.Attribute(common.GroupMemberAttr(common.GroupSchemaUniqueNames), []string{user.UserDN})
+	add.Attribute("memberUid", []string{user.Username})
+	add.Attribute("description", []string{fmt.Sprintf("用户%s的私有组", user.Username)})
+	err := conn.Add(add)
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultEntryAlreadyExists) {
+		return err
+	}
+	entry, err := privateGroupEntry(conn, gdn, user.Username)
+	if err != nil {
+		return err
+	}
+	if entry == nil || entry.GetAttributeValue("gidNumber") != gidNumber {
+		return fmt.Errorf("私有组%s已被其他分组占用", gdn)
+	}
+	return nil
+}
+
+// privateGroupEntry 获取用户的私有组条目, 只认posixGroup且memberUid包含该用户的条目, 避免误操作同名的普通分组
+func privateGroupEntry(conn *ldap.Conn, gdn, username string) (*ldap.Entry, error) {
+	searchRequest := ldap.NewSearchRequest(
+		gdn,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=posixGroup)(memberUid=%s))", ldap.EscapeFilter(username)),
+		[]string{"gidNumber"},
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	var sr *ldap.SearchResult
-	// Search through ldap built-in search
-	sr, err = conn.Search(searchRequest)
+	if len(sr.Entries) == 0 {
+		return nil, nil
+	}
+	return sr.Entries[0], nil
+}
+
+// entryObjectClasses 获取条目的objectClass, 键统一为小写
+func entryObjectClasses(conn *ldap.Conn, dn string) (map[string]bool, error) {
+	searchRequest := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"objectClass"},
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
 	if err != nil {
 		return nil, err
 	}
-	if len(sr.Entries) > 0 {
-		for _, v := range sr.Entries {
-			users = append(users, &model.User{
-				UserDN: v.DN,
-			})
+	if len(sr.Entries) == 0 {
+		return nil, fmt.Errorf("条目%s不存在", dn)
+	}
+	objectClasses := make(map[string]bool)
+	for _, objectClass := range sr.Entries[0].GetAttributeValues("objectClass") {
+		objectClasses[strings.ToLower(objectClass)] = true
+	}
+	return objectClasses, nil
+}
+
+// missingObjectClasses 返回条目上尚不存在的objectClass, 重复添加objectClass会报错
+// entryAttributes 获取条目的指定属性
+func entryAttributes(conn *ldap.Conn, dn string, attrs []string) (*ldap.Entry, error) {
+	searchRequest := ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		attrs,
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(sr.Entries) == 0 {
+		return nil, fmt.Errorf("条目%s不存在", dn)
+	}
+	return sr.Entries[0], nil
+}
+
+func missingObjectClasses(conn *ldap.Conn, dn string, objectClasses []string) ([]string, error) {
+	existing, err := entryObjectClasses(conn, dn)
+	if err != nil {
+		return nil, err
+	}
+	missing := make([]string, 0)
+	for _, objectClass := range objectClasses {
+		if !existing[strings.ToLower(objectClass)] {
+			missing = append(missing, objectClass)
 		}
 	}
-	return
+	return missing, nil
 }
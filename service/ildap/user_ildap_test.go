@@ -0,0 +1,203 @@
+package ildap
+
+import (
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/config"
+	"github.com/eryajf/go-ldap-admin/model"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func setupPosixConfig(privateGroupDN string) {
+	config.Conf.Ldap = &config.LdapConfig{
+		BaseDN: "dc=eryajf,dc=net",
+		UserDN: "ou=people,dc=eryajf,dc=net",
+		Posix: &config.LdapPosixConfig{
+			Enable:         true,
+			PrivateGroupDN: privateGroupDN,
+		},
+	}
+}
+
+func TestPrivateGroupDN(t *testing.T) {
+	setupPosixConfig("")
+	if got := privateGroupDN("uid=zhangsan,ou=people,dc=eryajf,dc=net", "zhangsan"); got != "cn=zhangsan,dc=eryajf,dc=net" {
+		t.Fatalf("未配置私有组DN时应放在base-dn下, 实际为%s", got)
+	}
+	setupPosixConfig("ou=private,dc=eryajf,dc=net")
+	if got := privateGroupDN("uid=zhangsan,ou=people,dc=eryajf,dc=net", "zhangsan"); got != "cn=zhangsan,ou=private,dc=eryajf,dc=net" {
+		t.Fatalf("私有组应放在配置的DN下, 实际为%s", got)
+	}
+}
+
+func TestAddPrivateGroup(t *testing.T) {
+	setupPosixConfig("")
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+
+	user := &model.User{
+		Username:  "zhangsan",
+		UserDN:    "uid=zhangsan,ou=people,dc=eryajf,dc=net",
+		UidNumber: 10001,
+		GidNumber: 10001,
+	}
+	if err := addPrivateGroup(conn, user); err != nil {
+		t.Fatal(err)
+	}
+	entry := srv.entry("cn=zhangsan,dc=eryajf,dc=net")
+	if entry == nil {
+		t.Fatal("私有组条目未创建")
+	}
+	if got := entry.GetAttributeValue("gidNumber"); got != "10001" {
+		t.Fatalf("私有组gidNumber应为10001, 实际为%s", got)
+	}
+	if !contains(entry.GetAttributeValues("objectClass"), "posixGroup") {
+		t.Fatal("私有组应包含posixGroup")
+	}
+	if got := entry.GetAttributeValue("memberUid"); got != "zhangsan" {
+		t.Fatalf("私有组memberUid应为zhangsan, 实际为%s", got)
+	}
+
+	// 重复创建同一私有组视为成功
+	if err := addPrivateGroup(conn, user); err != nil {
+		t.Fatalf("私有组已存在且gidNumber一致时不应报错: %v", err)
+	}
+
+	// 同名条目的gidNumber不同, 说明被其他分组占用
+	other := *user
+	other.GidNumber = 10002
+	if err := addPrivateGroup(conn, &other); err == nil {
+		t.Fatal("私有组被其他分组占用时应报错")
+	}
+}
+
+func addTestEntry(t *testing.T, conn *ldap.Conn, dn string, attrs map[string][]string) {
+	add := ldap.NewAddRequest(dn, nil)
+	for name, values := range attrs {
+		add.Attribute(name, values)
+	}
+	if err := conn.Add(add); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnableUserPosixKeepsExisting(t *testing.T) {
+	setupPosixConfig("")
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+	udn := "uid=zhangsan,ou=people,dc=eryajf,dc=net"
+	addTestEntry(t, conn, udn, map[string][]string{
+		"objectClass":   {"inetOrgPerson", "posixAccount"},
+		"uid":           {"zhangsan"},
+		"uidNumber":     {"500"},
+		"gidNumber":     {"100"},
+		"homeDirectory": {"/data/zhangsan"},
+	})
+
+	uidNumber, gidNumber, err := entryPosixIds(conn, udn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uidNumber != 500 || gidNumber != 100 {
+		t.Fatalf("应读取到已有的编号500/100, 实际为%d/%d", uidNumber, gidNumber)
+	}
+
+	user := &model.User{Username: "zhangsan", UserDN: udn, UidNumber: uidNumber, GidNumber: gidNumber}
+	config.Conf.Ldap.Posix.LoginShell = "/bin/bash"
+	if err := enableUserPosix(conn, user); err != nil {
+		t.Fatal(err)
+	}
+	entry := srv.entry(udn)
+	if got := entry.GetAttributeValues("uidNumber"); len(got) != 1 || got[0] != "500" {
+		t.Fatalf("已有的uidNumber不应被替换, 实际为%v", got)
+	}
+	if got := entry.GetAttributeValue("homeDirectory"); got != "/data/zhangsan" {
+		t.Fatalf("已有的homeDirectory不应被替换, 实际为%s", got)
+	}
+	if got := entry.GetAttributeValue("loginShell"); got != "/bin/bash" {
+		t.Fatalf("缺少的loginShell应补上, 实际为%s", got)
+	}
+}
+
+func TestEnableUserPosixNew(t *testing.T) {
+	setupPosixConfig("")
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+	udn := "uid=lisi,ou=people,dc=eryajf,dc=net"
+	addTestEntry(t, conn, udn, map[string][]string{"objectClass": {"inetOrgPerson"}, "uid": {"lisi"}})
+
+	if uidNumber, gidNumber, err := entryPosixIds(conn, udn); err != nil || uidNumber != 0 || gidNumber != 0 {
+		t.Fatalf("没有posix属性时编号应为0, 实际为%d/%d %v", uidNumber, gidNumber, err)
+	}
+	user := &model.User{Username: "lisi", UserDN: udn, UidNumber: 10001, GidNumber: 10001}
+	if err := enableUserPosix(conn, user); err != nil {
+		t.Fatal(err)
+	}
+	entry := srv.entry(udn)
+	if !contains(entry.GetAttributeValues("objectClass"), "posixAccount") || entry.GetAttributeValue("uidNumber") != "10001" {
+		t.Fatalf("应追加posixAccount及分配的uidNumber, 实际为%v %s", entry.GetAttributeValues("objectClass"), entry.GetAttributeValue("uidNumber"))
+	}
+	if got := entry.GetAttributeValue("homeDirectory"); got != "/home/lisi" {
+		t.Fatalf("homeDirectory应为/home/lisi, 实际为%s", got)
+	}
+}
+
+func TestEnableGroupPosixKeepsGid(t *testing.T) {
+	setupPosixConfig("")
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+	gdn := "cn=dev,dc=eryajf,dc=net"
+	addTestEntry(t, conn, gdn, map[string][]string{"objectClass": {"groupOfUniqueNames", "posixGroup"}, "cn": {"dev"}, "gidNumber": {"200"}})
+
+	if err := enableGroupPosix(conn, &model.Group{GroupDN: gdn, GidNumber: 200}, []string{"zhangsan"}); err != nil {
+		t.Fatal(err)
+	}
+	entry := srv.entry(gdn)
+	if got := entry.GetAttributeValues("gidNumber"); len(got) != 1 || got[0] != "200" {
+		t.Fatalf("已有的gidNumber不应被替换, 实际为%v", got)
+	}
+	if got := entry.GetAttributeValue("memberUid"); got != "zhangsan" {
+		t.Fatalf("memberUid应按成员写入, 实际为%s", got)
+	}
+}
+
+func TestPosixIdUsed(t *testing.T) {
+	setupPosixConfig("")
+	srv := newFakeLdapServer(t)
+	conn := srv.dial(t)
+	addTestEntry(t, conn, "uid=zhangsan,ou=people,dc=eryajf,dc=net", map[string][]string{"objectClass": {"posixAccount"}, "uidNumber": {"500"}, "gidNumber": {"100"}})
+	addTestEntry(t, conn, "uid=lisi,ou=people,dc=eryajf,dc=net", map[string][]string{"objectClass": {"posixAccount"}, "uidNumber": {"501"}, "gidNumber": {"100"}})
+	addTestEntry(t, conn, "cn=users,dc=eryajf,dc=net", map[string][]string{"objectClass": {"posixGroup"}, "gidNumber": {"100"}})
+	addTestEntry(t, conn, "uid=other,dc=example,dc=com", map[string][]string{"objectClass": {"posixAccount"}, "uidNumber": {"600"}})
+
+	cases := []struct {
+		kind   string
+		number uint
+		used   bool
+	}{
+		{model.PosixIdKindUid, 500, true},
+		{model.PosixIdKindUid, 100, false},
+		{model.PosixIdKindGid, 100, true},
+		{model.PosixIdKindGid, 500, false},
+		// 不在基础DN下的条目不计入
+		{model.PosixIdKindUid, 600, false},
+	}
+	for _, tc := range cases {
+		used, err := posixIdUsed(conn, "dc=eryajf,dc=net", tc.kind, tc.number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != tc.used {
+			t.Fatalf("%s编号%d的使用情况应为%v, 实际为%v", tc.kind, tc.number, tc.used, used)
+		}
+	}
+
+	if exists, err := posixGroupExists(conn, "dc=eryajf,dc=net", 100); err != nil || !exists {
+		t.Fatalf("gidNumber为100的分组应存在: %v", err)
+	}
+	// 只有用户条目使用的gidNumber不算分组
+	if exists, err := posixGroupExists(conn, "dc=eryajf,dc=net", 500); err != nil || exists {
+		t.Fatalf("gidNumber为500的分组不应存在: %v", err)
+	}
+}
@@ -1,11 +1,22 @@
 package isql
 
 var (
-	User          = &UserService{}
-	Group         = &GroupService{}
-	Api           = &ApiService{}
-	Menu          = &MenuService{}
-	Role          = &RoleService{}
-	OperationLog  = &OperationLogService{}
-	FieldRelation = &FieldRelationService{}
+	User            = &UserService{}
+	Group           = &GroupService{}
+	Api             = &ApiService{}
+	Menu            = &MenuService{}
+	Role            = &RoleService{}
+	OperationLog    = &OperationLogService{}
+	FieldRelation   = &FieldRelationService{}
+	UserMfa         = &UserMfaService{}
+	OidcClient      = &OidcClientService{}
+	PasswordHistory = &PasswordHistoryService{}
+	UserSession     = &UserSessionService{}
+	AccessToken     = &AccessTokenService{}
+	PosixId         = &PosixIdService{}
+	SshKey          = &SshKeyService{}
+	GroupNesting    = &GroupNestingService{}
+	Directory       = &DirectoryService{}
+	SyncPlan        = &SyncPlanService{}
+	SyncRun         = &SyncRunService{}
 )
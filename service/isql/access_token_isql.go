@@ -0,0 +1,46 @@
+package isql
+
+import (
+	"errors"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	"gorm.io/gorm"
+)
+
+type AccessTokenService struct{}
+
+// List 获取用户的访问令牌列表
+func (s AccessTokenService) List(userId uint) ([]*model.AccessToken, error) {
+	var list []*model.AccessToken
+	err := common.DB.Where("user_id = ?", userId).Order("id DESC").Find(&list).Error
+	return list, err
+}
+
+// Add 创建访问令牌
+func (s AccessTokenService) Add(token *model.AccessToken) error {
+	return common.DB.Create(token).Error
+}
+
+// Find 获取单个访问令牌
+func (s AccessTokenService) Find(filter map[string]any, data *model.AccessToken) error {
+	return common.DB.Where(filter).First(&data).Error
+}
+
+// Exist 判断访问令牌是否存在
+func (s AccessTokenService) Exist(filter map[string]any) bool {
+	var dataObj model.AccessToken
+	err := common.DB.Where(filter).First(&dataObj).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// UpdateLastUsed 更新最近使用时间与IP
+func (s AccessTokenService) UpdateLastUsed(id uint, values map[string]any) error {
+	return common.DB.Model(&model.AccessToken{}).Where("id = ?", id).UpdateColumns(values).Error
+}
+
+// Delete 删除访问令牌
+func (s AccessTokenService) Delete(filter map[string]any) error {
+	return common.DB.Where(filter).Unscoped().Delete(&model.AccessToken{}).Error
+}
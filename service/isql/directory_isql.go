@@ -0,0 +1,75 @@
+package isql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+
+	"gorm.io/gorm"
+)
+
+type DirectoryService struct{}
+
+// List 获取数据列表
+func (s DirectoryService) List(req *request.DirectoryListReq) ([]*model.Directory, error) {
+	var list []*model.Directory
+	db := common.DB.Model(&model.Directory{}).Order("id ASC")
+
+	name := strings.TrimSpace(req.Name)
+	if name != "" {
+		db = db.Where("name LIKE ?", fmt.Sprintf("%%%s%%", name))
+	}
+
+	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
+	err := db.Offset(pageReq.PageNum).Limit(pageReq.PageSize).Find(&list).Error
+	return list, err
+}
+
+// Count 获取资源总数
+func (s DirectoryService) Count() (int64, error) {
+	var count int64
+	err := common.DB.Model(&model.Directory{}).Count(&count).Error
+	return count, err
+}
+
+// Add 创建资源
+func (s DirectoryService) Add(directory *model.Directory) error {
+	return common.DB.Create(directory).Error
+}
+
+// Update 更新资源
+func (s DirectoryService) Update(directory *model.Directory) error {
+	return common.DB.Model(&model.Directory{}).Where("id = ?", directory.ID).Updates(directory).Error
+}
+
+// Find 获取单个资源
+func (s DirectoryService) Find(filter map[string]any, data *model.Directory) error {
+	return common.DB.Where(filter).First(&data).Error
+}
+
+// Exist 判断资源是否存在
+func (s DirectoryService) Exist(filter map[string]any) bool {
+	var dataObj model.Directory
+	err := common.DB.Where(filter).First(&dataObj).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// InUse 判断目录下是否还有用户或分组
+func (s DirectoryService) InUse(id uint) (bool, error) {
+	var count int64
+	if err := common.DB.Model(&model.User{}).Where("directory_id = ?", id).Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	err := common.DB.Model(&model.Group{}).Where("directory_id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+// Delete 批量删除
+func (s DirectoryService) Delete(ids []uint) error {
+	return common.DB.Where("id IN (?)", ids).Unscoped().Delete(&model.Directory{}).Error
+}
@@ -32,6 +32,12 @@ func (s GroupService) List(req *request.GroupListReq) ([]*model.Group, error) {
 	if syncState != 0 {
 		db = db.Where("sync_state = ?", syncState)
 	}
+	if req.DirectoryId != nil {
+		db = db.Where("directory_id = ?", *req.DirectoryId)
+	}
+	if req.DirectoryIds != nil {
+		db = db.Where("directory_id IN ?", req.DirectoryIds)
+	}
 
 	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
 	err := db.Offset(pageReq.PageNum).Limit(pageReq.PageSize).Preload("Users").Find(&list).Error
@@ -51,6 +57,12 @@ func (s GroupService) ListTree(req *request.GroupListReq) ([]*model.Group, error
 	if groupRemark != "" {
 		db = db.Where("remark LIKE ?", fmt.Sprintf("%%%s%%", groupRemark))
 	}
+	if req.DirectoryId != nil {
+		db = db.Where("directory_id = ?", *req.DirectoryId)
+	}
+	if req.DirectoryIds != nil {
+		db = db.Where("directory_id IN ?", req.DirectoryIds)
+	}
 
 	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
 	err := db.Offset(pageReq.PageNum).Limit(pageReq.PageSize).Find(&list).Error
@@ -146,3 +158,52 @@ func (s GroupService) DeptIdsToGroupIds(ids []string) (groupIds []uint, err erro
 	}
 	return tempGroupIds, nil
 }
+
+// GetUserGroups 根据group_users获取用户所在的分组
+func (s GroupService) GetUserGroups(userId uint) (datas []*model.Group, err error) {
+	err = common.DB.Where("id IN (?)", common.DB.Table("group_users").Select("group_id").Where("user_id = ?", userId)).Find(&datas).Error
+	return datas, err
+}
+
+// ListPosixPending 获取尚未分配gidNumber的cn类型分组及其成员, ids为空时返回全部
+func (s GroupService) ListPosixPending(ids []uint) ([]*model.Group, error) {
+	var list []*model.Group
+	db := common.DB.Where("gid_number = 0 AND group_type = ?", "cn")
+	if len(ids) > 0 {
+		db = db.Where("id IN (?)", ids)
+	}
+	err := db.Preload("Users").Find(&list).Error
+	return list, err
+}
+
+// UpdateGidNumber 更新分组的gidNumber
+func (s GroupService) UpdateGidNumber(id, gidNumber uint) error {
+	return common.DB.Model(&model.Group{}).Where("id = ?", id).UpdateColumn("gid_number", gidNumber).Error
+}
+
+// UpdateGroupSchema 更新分组的objectClass
+func (s GroupService) UpdateGroupSchema(id uint, schema string) error {
+	return common.DB.Model(&model.Group{}).Where("id = ?", id).UpdateColumn("group_schema", schema).Error
+}
+
+// UpdateSyncFields 更新同步来源变化的分组字段
+func (s GroupService) UpdateSyncFields(id uint, values map[string]any) error {
+	return common.DB.Model(&model.Group{}).Where("id = ?", id).UpdateColumns(values).Error
+}
+
+// ReplaceDNSuffix 分组dn变化之后, 更新其下级分组的dn
+func (s GroupService) ReplaceDNSuffix(oldDN, newDN string) error {
+	var list []*model.Group
+	err := common.DB.Where("group_dn LIKE ?", "%,"+oldDN).Find(&list).Error
+	if err != nil {
+		return err
+	}
+	for _, g := range list {
+		dn := strings.TrimSuffix(g.GroupDN, oldDN) + newDN
+		err = common.DB.Model(&model.Group{}).Where("id = ?", g.ID).UpdateColumn("group_dn", dn).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
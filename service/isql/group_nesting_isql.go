@@ -0,0 +1,34 @@
+package isql
+
+import (
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+)
+
+type GroupNestingService struct{}
+
+// List 获取全部分组嵌套关系
+func (s GroupNestingService) List() ([]*model.GroupNesting, error) {
+	var list []*model.GroupNesting
+	err := common.DB.Find(&list).Error
+	return list, err
+}
+
+// Add 将分组作为成员加入分组
+func (s GroupNestingService) Add(groupId uint, memberGroupIds []uint) error {
+	list := make([]*model.GroupNesting, 0, len(memberGroupIds))
+	for _, memberGroupId := range memberGroupIds {
+		list = append(list, &model.GroupNesting{GroupID: groupId, MemberGroupID: memberGroupId})
+	}
+	return common.DB.Create(&list).Error
+}
+
+// Delete 将分组从分组的成员中移除
+func (s GroupNestingService) Delete(groupId uint, memberGroupIds []uint) error {
+	return common.DB.Where("group_id = ? AND member_group_id IN (?)", groupId, memberGroupIds).Delete(&model.GroupNesting{}).Error
+}
+
+// DeleteByGroups 删除分组时清理其作为父分组或成员的全部嵌套关系
+func (s GroupNestingService) DeleteByGroups(groupIds []uint) error {
+	return common.DB.Where("group_id IN (?) OR member_group_id IN (?)", groupIds, groupIds).Delete(&model.GroupNesting{}).Error
+}
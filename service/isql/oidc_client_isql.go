@@ -0,0 +1,69 @@
+package isql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+
+	"gorm.io/gorm"
+)
+
+type OidcClientService struct{}
+
+// List 获取数据列表
+func (s OidcClientService) List(req *request.OidcClientListReq) ([]*model.OidcClient, error) {
+	var list []*model.OidcClient
+	db := common.DB.Model(&model.OidcClient{}).Order("created_at DESC")
+
+	name := strings.TrimSpace(req.Name)
+	if name != "" {
+		db = db.Where("name LIKE ?", fmt.Sprintf("%%%s%%", name))
+	}
+	clientId := strings.TrimSpace(req.ClientID)
+	if clientId != "" {
+		db = db.Where("client_id LIKE ?", fmt.Sprintf("%%%s%%", clientId))
+	}
+
+	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
+	err := db.Offset(pageReq.PageNum).Limit(pageReq.PageSize).Find(&list).Error
+	return list, err
+}
+
+// Count 获取资源总数
+func (s OidcClientService) Count() (int64, error) {
+	var count int64
+	err := common.DB.Model(&model.OidcClient{}).Count(&count).Error
+	return count, err
+}
+
+// Add 创建资源
+func (s OidcClientService) Add(client *model.OidcClient) error {
+	return common.DB.Create(client).Error
+}
+
+// Update 更新资源
+func (s OidcClientService) Update(client *model.OidcClient) error {
+	return common.DB.Model(&model.OidcClient{}).Where("id = ?", client.ID).Updates(client).Error
+}
+
+// Find 获取单个资源
+func (s OidcClientService) Find(filter map[string]any, data *model.OidcClient) error {
+	return common.DB.Where(filter).First(&data).Error
+}
+
+// Exist 判断资源是否存在
+func (s OidcClientService) Exist(filter map[string]any) bool {
+	var dataObj model.OidcClient
+	err := common.DB.Where(filter).First(&dataObj).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// Delete 批量删除
+func (s OidcClientService) Delete(ids []uint) error {
+	return common.DB.Where("id IN (?)", ids).Unscoped().Delete(&model.OidcClient{}).Error
+}
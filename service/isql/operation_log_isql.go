@@ -45,6 +45,11 @@ func (s OperationLogService) SaveOperationLogChannel(olc <-chan *model.Operation
 	}
 }
 
+// Add 直接写入一条操作日志, 用于记录非接口请求触发的事件
+func (s OperationLogService) Add(log *model.OperationLog) error {
+	return common.DB.Create(log).Error
+}
+
 // List 获取数据列表
 func (s OperationLogService) List(req *request.OperationLogListReq) ([]*model.OperationLog, error) {
 	var list []*model.OperationLog
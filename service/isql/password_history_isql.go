@@ -0,0 +1,51 @@
+package isql
+
+import (
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+)
+
+type PasswordHistoryService struct{}
+
+// Add 记录用户的密码哈希, 只保留最近keep条
+func (s PasswordHistoryService) Add(userId uint, hashPasswd string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	err := common.DB.Create(&model.PasswordHistory{UserID: userId, Password: hashPasswd}).Error
+	if err != nil {
+		return err
+	}
+	// MySQL不支持没有LIMIT的OFFSET, 先取出要保留的记录再删除其余的
+	var ids []uint
+	err = common.DB.Model(&model.PasswordHistory{}).Where("user_id = ?", userId).
+		Order("id DESC").Limit(keep).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return common.DB.Where("user_id = ? AND id NOT IN (?)", userId, ids).Unscoped().Delete(&model.PasswordHistory{}).Error
+}
+
+// Used 判断密码是否与最近n次使用过的密码相同
+func (s PasswordHistoryService) Used(userId uint, passwd string, n int) bool {
+	if n <= 0 {
+		return false
+	}
+	var list []model.PasswordHistory
+	err := common.DB.Where("user_id = ?", userId).Order("id DESC").Limit(n).Find(&list).Error
+	if err != nil {
+		return false
+	}
+	for _, history := range list {
+		if tools.ComparePasswd(history.Password, passwd) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete 删除用户的历史密码
+func (s PasswordHistoryService) Delete(userIds []uint) error {
+	return common.DB.Where("user_id IN (?)", userIds).Unscoped().Delete(&model.PasswordHistory{}).Error
+}
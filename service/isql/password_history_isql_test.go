@@ -0,0 +1,54 @@
+package isql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/model"
+
+	"gorm.io/gorm"
+)
+
+func TestPasswordHistoryAdd(t *testing.T) {
+	db := setupIsqlTest(t, &model.PasswordHistory{})
+	// sqlite会为OFFSET补上LIMIT -1, MySQL则直接拒绝没有LIMIT的OFFSET, 这里检查生成的语句
+	var queries []string
+	err := db.Callback().Query().After("gorm:query").Register("test:record_sql", func(tx *gorm.DB) {
+		queries = append(queries, tx.Statement.SQL.String())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超出保留数量后删除最早的记录, 不影响其他用户
+	if err := PasswordHistory.Add(2, "other", 3); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := PasswordHistory.Add(1, fmt.Sprintf("hash%d", i), 3); err != nil {
+			t.Fatalf("第%d次记录历史密码失败: %v", i, err)
+		}
+	}
+	for _, query := range queries {
+		if strings.Contains(query, "OFFSET") {
+			t.Fatalf("清理历史密码的查询不应使用OFFSET: %s", query)
+		}
+	}
+	var list []model.PasswordHistory
+	if err := db.Where("user_id = ?", 1).Order("id ASC").Find(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(list))
+	for _, history := range list {
+		got = append(got, history.Password)
+	}
+	if fmt.Sprint(got) != "[hash3 hash4 hash5]" {
+		t.Fatalf("应只保留最近3条历史密码, 实际为%v", got)
+	}
+	var count int64
+	db.Model(&model.PasswordHistory{}).Where("user_id = ?", 2).Count(&count)
+	if count != 1 {
+		t.Fatalf("其他用户的历史密码不应被删除, 实际剩余%d条", count)
+	}
+}
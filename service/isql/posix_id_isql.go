@@ -0,0 +1,107 @@
+package isql
+
+import (
+	"fmt"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+)
+
+type PosixIdService struct{}
+
+// 并发分配时编号被抢占后的重试次数
+const posixIdAllocRetry = 5
+
+// Allocate 在[min, max]范围内分配一个未使用的编号
+// used判断编号是否已在LDAP中被使用, 已使用的编号保留登记后继续分配下一个
+func (s PosixIdService) Allocate(kind string, min, max uint, used func(number uint) (bool, error)) (uint, error) {
+	if min == 0 || min > max {
+		return 0, fmt.Errorf("%s编号范围[%d, %d]配置有误", kind, min, max)
+	}
+	for i := 0; i < posixIdAllocRetry; {
+		number, err := s.next(kind, min, max)
+		if err != nil {
+			return 0, err
+		}
+		// 唯一索引冲突说明编号已被其他请求占用, 重新计算
+		err = common.DB.Create(&model.PosixId{Kind: kind, Number: number}).Error
+		if err != nil {
+			if s.Exist(kind, number) {
+				i++
+				continue
+			}
+			return 0, err
+		}
+		if used == nil {
+			return number, nil
+		}
+		inUse, err := used(number)
+		if err != nil {
+			s.release(kind, number)
+			return 0, err
+		}
+		if !inUse {
+			return number, nil
+		}
+	}
+	return 0, fmt.Errorf("分配%s编号失败，请重试", kind)
+}
+
+// Claim 登记指定的编号, 编号已被占用时返回false
+func (s PosixIdService) Claim(kind string, number uint) (bool, error) {
+	if s.Exist(kind, number) {
+		return false, nil
+	}
+	err := common.DB.Create(&model.PosixId{Kind: kind, Number: number}).Error
+	if err == nil {
+		return true, nil
+	}
+	// 唯一索引冲突说明编号刚被其他请求占用
+	if s.Exist(kind, number) {
+		return false, nil
+	}
+	return false, err
+}
+
+// release 释放刚登记但未能使用的编号
+func (s PosixIdService) release(kind string, number uint) {
+	common.DB.Where("kind = ? AND number = ?", kind, number).Delete(&model.PosixId{})
+}
+
+// Exist 判断编号是否已分配
+func (s PosixIdService) Exist(kind string, number uint) bool {
+	var count int64
+	common.DB.Model(&model.PosixId{}).Where("kind = ? AND number = ?", kind, number).Count(&count)
+	return count > 0
+}
+
+// next 优先取范围内已分配的最大编号加一, 用尽后再从头查找空闲的编号
+func (s PosixIdService) next(kind string, min, max uint) (uint, error) {
+	var last uint
+	err := common.DB.Model(&model.PosixId{}).Where("kind = ? AND number BETWEEN ? AND ?", kind, min, max).
+		Select("COALESCE(MAX(number), 0)").Scan(&last).Error
+	if err != nil {
+		return 0, err
+	}
+	if last < min {
+		return min, nil
+	}
+	if last < max {
+		return last + 1, nil
+	}
+
+	var numbers []uint
+	err = common.DB.Model(&model.PosixId{}).Where("kind = ? AND number BETWEEN ? AND ?", kind, min, max).
+		Order("number").Pluck("number", &numbers).Error
+	if err != nil {
+		return 0, err
+	}
+	expect := min
+	for _, number := range numbers {
+		if number != expect {
+			return expect, nil
+		}
+		expect++
+	}
+	return 0, fmt.Errorf("%s编号范围[%d, %d]已全部分配", kind, min, max)
+}
@@ -0,0 +1,54 @@
+package isql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eryajf/go-ldap-admin/model"
+)
+
+func TestPosixIdAllocate(t *testing.T) {
+	setupIsqlTest(t, &model.PosixId{})
+
+	// LDAP中已被使用的编号跳过, 并保留登记
+	inLdap := map[uint]bool{1000: true, 1001: true}
+	used := func(number uint) (bool, error) { return inLdap[number], nil }
+	number, err := PosixId.Allocate(model.PosixIdKindUid, 1000, 1010, used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if number != 1002 {
+		t.Fatalf("应跳过LDAP中已使用的编号分配1002, 实际为%d", number)
+	}
+	if !PosixId.Exist(model.PosixIdKindUid, 1000) || !PosixId.Exist(model.PosixIdKindUid, 1001) {
+		t.Fatal("LDAP中已使用的编号应登记为已分配")
+	}
+	if number, err = PosixId.Allocate(model.PosixIdKindUid, 1000, 1010, used); err != nil || number != 1003 {
+		t.Fatalf("再次分配应为1003, 实际为%d %v", number, err)
+	}
+
+	// 查询LDAP失败时释放刚登记的编号
+	failed := func(number uint) (bool, error) { return false, errors.New("ldap unavailable") }
+	if _, err := PosixId.Allocate(model.PosixIdKindUid, 1000, 1010, failed); err == nil {
+		t.Fatal("查询LDAP失败时应返回错误")
+	}
+	if PosixId.Exist(model.PosixIdKindUid, 1004) {
+		t.Fatal("分配失败的编号应释放")
+	}
+
+	// 范围内的编号全部被使用时报错
+	all := func(number uint) (bool, error) { return true, nil }
+	if _, err := PosixId.Allocate(model.PosixIdKindGid, 2000, 2002, all); err == nil {
+		t.Fatal("编号用尽时应返回错误")
+	}
+}
+
+func TestPosixIdClaim(t *testing.T) {
+	setupIsqlTest(t, &model.PosixId{})
+	if claimed, err := PosixId.Claim(model.PosixIdKindGid, 100); err != nil || !claimed {
+		t.Fatalf("未登记的编号应登记成功: %v", err)
+	}
+	if claimed, err := PosixId.Claim(model.PosixIdKindGid, 100); err != nil || claimed {
+		t.Fatalf("已登记的编号应返回false: %v", err)
+	}
+}
@@ -59,7 +59,12 @@ func (s RoleService) Add(role *model.Role) error {
 
 // Update 更新资源
 func (s RoleService) Update(role *model.Role) error {
-	return common.DB.Model(&model.Role{}).Where("id = ?", role.ID).Updates(role).Error
+	err := common.DB.Model(&model.Role{}).Where("id = ?", role.ID).Updates(role).Error
+	if err != nil {
+		return err
+	}
+	// Updates会忽略空值, 可管理目录为空表示不限制, 需要单独更新
+	return common.DB.Model(&model.Role{}).Where("id = ?", role.ID).Update("directory_ids", role.DirectoryIds).Error
 }
 
 // Find 获取单个资源
@@ -0,0 +1,56 @@
+package isql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	"gorm.io/gorm"
+)
+
+type SshKeyService struct{}
+
+// List 获取用户的SSH公钥列表
+func (s SshKeyService) List(userId uint) ([]*model.SshKey, error) {
+	var list []*model.SshKey
+	err := common.DB.Where("user_id = ?", userId).Order("id DESC").Find(&list).Error
+	return list, err
+}
+
+// ListByIds 根据ID获取SSH公钥
+func (s SshKeyService) ListByIds(ids []uint) ([]*model.SshKey, error) {
+	var list []*model.SshKey
+	err := common.DB.Where("id IN (?)", ids).Find(&list).Error
+	return list, err
+}
+
+// ListExpired 获取已过期的SSH公钥
+func (s SshKeyService) ListExpired(now time.Time) ([]*model.SshKey, error) {
+	var list []*model.SshKey
+	err := common.DB.Where("expires_at IS NOT NULL AND expires_at <= ?", now).Find(&list).Error
+	return list, err
+}
+
+// Add 添加SSH公钥
+func (s SshKeyService) Add(key *model.SshKey) error {
+	return common.DB.Create(key).Error
+}
+
+// Find 获取单个SSH公钥
+func (s SshKeyService) Find(filter map[string]any, data *model.SshKey) error {
+	return common.DB.Where(filter).First(&data).Error
+}
+
+// Exist 判断SSH公钥是否存在
+func (s SshKeyService) Exist(filter map[string]any) bool {
+	var dataObj model.SshKey
+	err := common.DB.Where(filter).First(&dataObj).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// Delete 删除SSH公钥, 指纹唯一, 因此直接物理删除
+func (s SshKeyService) Delete(filter map[string]any) error {
+	return common.DB.Where(filter).Unscoped().Delete(&model.SshKey{}).Error
+}
@@ -0,0 +1,82 @@
+package isql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type SyncPlanService struct{}
+
+// List 获取数据列表, 列表中不返回计划明细
+func (s SyncPlanService) List(req *request.SyncPlanListReq) ([]*model.SyncPlan, error) {
+	var list []*model.SyncPlan
+	db := s.filter(req).Omit("items").Order("id DESC")
+
+	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
+	err := db.Offset(pageReq.PageNum).Limit(pageReq.PageSize).Find(&list).Error
+	return list, err
+}
+
+// ListCount 获取符合条件的数据总数
+func (s SyncPlanService) ListCount(req *request.SyncPlanListReq) (int64, error) {
+	var count int64
+	err := s.filter(req).Count(&count).Error
+	return count, err
+}
+
+func (s SyncPlanService) filter(req *request.SyncPlanListReq) *gorm.DB {
+	db := common.DB.Model(&model.SyncPlan{})
+	if req.Source != "" {
+		db = db.Where("source = ?", req.Source)
+	}
+	if req.Target != "" {
+		db = db.Where("target = ?", req.Target)
+	}
+	if req.Status != 0 {
+		db = db.Where("status = ?", req.Status)
+	}
+	return db
+}
+
+// Add 创建资源
+func (s SyncPlanService) Add(plan *model.SyncPlan) error {
+	return common.DB.Create(plan).Error
+}
+
+// Find 获取单个资源
+func (s SyncPlanService) Find(filter map[string]any, data *model.SyncPlan) error {
+	return common.DB.Where(filter).First(&data).Error
+}
+
+// Exist 判断资源是否存在
+func (s SyncPlanService) Exist(filter map[string]any) bool {
+	var dataObj model.SyncPlan
+	err := common.DB.Where(filter).First(&dataObj).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// Claim 将待执行或执行失败的计划标记为已执行, 返回是否抢占成功, 避免同一计划被重复执行
+func (s SyncPlanService) Claim(id uint) (bool, error) {
+	db := common.DB.Model(&model.SyncPlan{}).Where("id = ? AND status IN (?)", id, []uint{1, 3}).
+		UpdateColumns(map[string]any{"status": 2, "applied_at": time.Now()})
+	return db.RowsAffected > 0, db.Error
+}
+
+// SaveResult 保存计划的执行状态与每一项的执行结果
+func (s SyncPlanService) SaveResult(id, status uint, items datatypes.JSON) error {
+	return common.DB.Model(&model.SyncPlan{}).Where("id = ?", id).
+		UpdateColumns(map[string]any{"status": status, "items": items}).Error
+}
+
+// Delete 批量删除
+func (s SyncPlanService) Delete(ids []uint) error {
+	return common.DB.Where("id IN (?)", ids).Unscoped().Delete(&model.SyncPlan{}).Error
+}
@@ -0,0 +1,101 @@
+package isql
+
+import (
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/model/request"
+	"github.com/eryajf/go-ldap-admin/public/common"
+	"github.com/eryajf/go-ldap-admin/public/tools"
+
+	"gorm.io/gorm"
+)
+
+type SyncRunService struct{}
+
+// List 获取数据列表
+func (s SyncRunService) List(req *request.SyncRunListReq) ([]*model.SyncRun, error) {
+	var list []*model.SyncRun
+	db := s.filter(req).Order("id DESC")
+
+	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
+	err := db.Offset(pageReq.PageNum).Limit(pageReq.PageSize).Find(&list).Error
+	return list, err
+}
+
+// ListCount 获取符合条件的数据总数
+func (s SyncRunService) ListCount(req *request.SyncRunListReq) (int64, error) {
+	var count int64
+	err := s.filter(req).Count(&count).Error
+	return count, err
+}
+
+func (s SyncRunService) filter(req *request.SyncRunListReq) *gorm.DB {
+	db := common.DB.Model(&model.SyncRun{})
+	if req.Source != "" {
+		db = db.Where("source = ?", req.Source)
+	}
+	if req.Target != "" {
+		db = db.Where("target = ?", req.Target)
+	}
+	if req.Trigger != "" {
+		db = db.Where("trigger_type = ?", req.Trigger)
+	}
+	if req.Status != 0 {
+		db = db.Where("status = ?", req.Status)
+	}
+	return db
+}
+
+// Count 获取资源总数
+func (s SyncRunService) Count() (int64, error) {
+	var count int64
+	err := common.DB.Model(&model.SyncRun{}).Count(&count).Error
+	return count, err
+}
+
+// Add 创建资源
+func (s SyncRunService) Add(run *model.SyncRun) error {
+	return common.DB.Create(run).Error
+}
+
+// Update 更新资源
+func (s SyncRunService) Update(run *model.SyncRun) error {
+	return common.DB.Model(run).Select("plan_id", "status", "ended_at", "summary", "failed", "error").Updates(run).Error
+}
+
+// FailStale 将上次服务退出时仍在执行中的同步记录标记为失败, 仍在执行的同步结束时会覆盖该状态
+func (s SyncRunService) FailStale() (int64, error) {
+	db := common.DB.Model(&model.SyncRun{}).Where("status = ?", 1).
+		UpdateColumns(map[string]any{"status": 4, "ended_at": time.Now(), "error": "服务重启，同步中断"})
+	return db.RowsAffected, db.Error
+}
+
+// Find 获取单个资源
+func (s SyncRunService) Find(filter map[string]any, data *model.SyncRun) error {
+	return common.DB.Where(filter).First(&data).Error
+}
+
+// AddItems 批量保存单项执行结果
+func (s SyncRunService) AddItems(items []*model.SyncRunItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return common.DB.CreateInBatches(items, 100).Error
+}
+
+// ListItems 获取同步记录的单项执行结果
+func (s SyncRunService) ListItems(req *request.SyncRunItemsReq) ([]*model.SyncRunItem, int64, error) {
+	var list []*model.SyncRunItem
+	var count int64
+	db := common.DB.Model(&model.SyncRunItem{}).Where("run_id = ?", req.RunID)
+	if req.Status != 0 {
+		db = db.Where("status = ?", req.Status)
+	}
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
+	err := db.Order("id ASC").Offset(pageReq.PageNum).Limit(pageReq.PageSize).Find(&list).Error
+	return list, count, err
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eryajf/go-ldap-admin/config"
 	"github.com/eryajf/go-ldap-admin/model"
 	"github.com/eryajf/go-ldap-admin/model/request"
 	"github.com/eryajf/go-ldap-admin/public/common"
@@ -19,15 +20,29 @@ import (
 
 type UserService struct{}
 
+var (
+	ErrUserNotExist = errors.New("用户不存在")
+	ErrWrongPasswd  = errors.New("密码错误")
+)
+
 // 当前用户信息缓存，避免频繁获取数据库
 var userInfoCache = cache.New(24*time.Hour, 48*time.Hour)
 
 // Add 添加资源
 func (s UserService) Add(user *model.User) error {
-	user.Password = tools.NewGenPasswd(user.Password)
+	// 密码为空时不生成哈希，避免空密码能够通过校验
+	if user.Password != "" {
+		user.Password = tools.GenPasswd(user.Password)
+		now := time.Now()
+		user.PwdChangedAt = &now
+	}
 	//result := common.DB.Create(user)
 	//return user.ID, result.Error
-	return common.DB.Create(user).Error
+	err := common.DB.Create(user).Error
+	if err == nil && user.Password != "" {
+		s.addPasswdHistory(user.ID, user.Password)
+	}
+	return err
 }
 
 // List 获取数据列表
@@ -63,6 +78,12 @@ func (s UserService) List(req *request.UserListReq) ([]*model.User, error) {
 	if syncState != 0 {
 		db = db.Where("sync_state = ?", syncState)
 	}
+	if req.DirectoryId != nil {
+		db = db.Where("directory_id = ?", *req.DirectoryId)
+	}
+	if req.DirectoryIds != nil {
+		db = db.Where("directory_id IN ?", req.DirectoryIds)
+	}
 
 	pageReq := tools.NewPageOption(req.PageNum, req.PageSize)
 	err := db.Offset(pageReq.PageNum).Limit(pageReq.PageSize).Preload("Roles").Find(&list).Debug().Error
@@ -102,6 +123,12 @@ func (s UserService) ListCount(req *request.UserListReq) (int64, error) {
 	if syncState != 0 {
 		db = db.Where("sync_state = ?", syncState)
 	}
+	if req.DirectoryId != nil {
+		db = db.Where("directory_id = ?", *req.DirectoryId)
+	}
+	if req.DirectoryIds != nil {
+		db = db.Where("directory_id IN ?", req.DirectoryIds)
+	}
 
 	err := db.Count(&count).Error
 	return count, err
@@ -244,24 +271,56 @@ func (s UserService) GetUserByIds(ids []uint) ([]model.User, error) {
 
 // ChangePwd 更新密码
 func (s UserService) ChangePwd(username string, hashNewPasswd string) error {
-	err := common.DB.Model(&model.User{}).Where("username = ?", username).Update("password", hashNewPasswd).Error
+	now := time.Now()
+	user, err := s.updatePasswd(username, tools.H{"password": hashNewPasswd, "pwd_changed_at": now})
+	if err == nil {
+		s.addPasswdHistory(user.ID, hashNewPasswd)
+	}
+	return err
+}
+
+// updatePasswd 更新密码相关字段并刷新当前用户信息缓存
+func (s UserService) updatePasswd(username string, values tools.H) (model.User, error) {
+	var user model.User
+	err := common.DB.Model(&model.User{}).Where("username = ?", username).Updates(map[string]any(values)).Error
 	// 如果更新密码成功，则更新当前用户信息缓存
-	// 先获取缓存
-	cacheUser, found := userInfoCache.Get(username)
 	if err == nil {
-		if found {
-			user := cacheUser.(model.User)
-			user.Password = hashNewPasswd
-			userInfoCache.Set(username, user, cache.DefaultExpiration)
-		} else {
-			// 没有缓存就获取用户信息缓存
-			var user model.User
-			common.DB.Where("username = ?", username).Preload("Roles").First(&user)
-			userInfoCache.Set(username, user, cache.DefaultExpiration)
-		}
+		common.DB.Where("username = ?", username).Preload("Roles").First(&user)
+		userInfoCache.Set(username, user, cache.DefaultExpiration)
 	}
 
-	return err
+	return user, err
+}
+
+// addPasswdHistory 记录历史密码, 失败时只记录日志不影响密码修改
+func (s UserService) addPasswdHistory(userId uint, hashPasswd string) {
+	if userId == 0 {
+		return
+	}
+	if err := PasswordHistory.Add(userId, hashPasswd, tools.GetPasswordPolicy().HistoryCount); err != nil {
+		common.Log.Warnf("记录用户[%d]的历史密码失败: %v", userId, err)
+	}
+}
+
+// ListPwdChangedBetween 获取在职用户中密码修改时间处于[from, to)区间的用户
+func (s UserService) ListPwdChangedBetween(from, to time.Time) ([]model.User, error) {
+	var users []model.User
+	err := common.DB.Model(&model.User{}).
+		Where("status = ? AND pwd_changed_at >= ? AND pwd_changed_at < ?", 1, from, to).
+		Find(&users).Error
+	return users, err
+}
+
+// UpdateLoginLock 更新登录失败次数与锁定状态
+func (s UserService) UpdateLoginLock(userId uint, failures uint, lastFailedAt, lockedUntil *time.Time) error {
+	return common.DB.Model(&model.User{}).Where("id = ?", userId).
+		UpdateColumns(map[string]any{"login_failures": failures, "last_failed_at": lastFailedAt, "locked_until": lockedUntil}).Error
+}
+
+// Unlock 解除用户的登录失败锁定
+func (s UserService) Unlock(ids []uint) error {
+	return common.DB.Model(&model.User{}).Where("id IN (?)", ids).
+		UpdateColumns(map[string]any{"login_failures": 0, "last_failed_at": nil, "locked_until": nil}).Error
 }
 
 // ChangeStatus 更新状态
@@ -317,7 +376,7 @@ func (s UserService) Login(user *model.User) (*model.User, error) {
 	// }
 	err := s.Find(tools.H{"username": user.Username}, &firstUser)
 	if err != nil {
-		return nil, errors.New("用户不存在")
+		return nil, ErrUserNotExist
 	}
 	// 判断用户的状态
 	userStatus := firstUser.Status
@@ -340,18 +399,58 @@ func (s UserService) Login(user *model.User) (*model.User, error) {
 	// 	return nil, errors.New("用户角色被禁用")
 	// }
 
-	if tools.NewParPasswd(firstUser.Password) != user.Password {
-		return nil, errors.New("密码错误")
-	}
-
 	// 校验密码
-	// err = tools.ComparePasswd(firstUser.Password, user.Password)
-	// if err != nil {
-	// 	return &firstUser, errors.New("密码错误")
-	// }
+	err = tools.ComparePasswd(firstUser.Password, user.Password)
+	if err != nil {
+		return nil, ErrWrongPasswd
+	}
+	s.RehashLegacyPasswd(&firstUser, user.Password)
 	return &firstUser, nil
 }
 
+// RehashLegacyPasswd 用户登录成功后，将旧版本RSA加密保存的密码转换为不可逆哈希
+func (s UserService) RehashLegacyPasswd(user *model.User, passwd string) {
+	if !tools.IsLegacyPasswd(user.Password) {
+		return
+	}
+	hashPasswd := tools.GenPasswd(passwd)
+	// 仅转换存储方式, 密码本身未变化, 不计入历史密码
+	if _, err := s.updatePasswd(user.Username, tools.H{"password": hashPasswd}); err != nil {
+		common.Log.Warnf("转换用户[%s]的密码存储方式失败: %v", user.Username, err)
+		return
+	}
+	user.Password = hashPasswd
+}
+
+// MigrateLegacyPasswd 将所有旧版本RSA加密保存的密码转换为不可逆哈希
+func (s UserService) MigrateLegacyPasswd() (migrated, failed int, err error) {
+	var users []model.User
+	err = common.DB.Model(&model.User{}).Select("id", "username", "password").Find(&users).Error
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, user := range users {
+		if !tools.IsLegacyPasswd(user.Password) {
+			continue
+		}
+		passwd := tools.NewParPasswd(user.Password)
+		if passwd == "" {
+			common.Log.Warnf("用户[%s]的密码无法解密，跳过转换，请为该用户重置密码", user.Username)
+			failed++
+			continue
+		}
+		err = common.DB.Model(&model.User{}).Where("id = ?", user.ID).Update("password", tools.GenPasswd(passwd)).Error
+		if err != nil {
+			common.Log.Warnf("转换用户[%s]的密码存储方式失败: %v", user.Username, err)
+			failed++
+			continue
+		}
+		migrated++
+	}
+	userInfoCache.Flush()
+	return migrated, failed, nil
+}
+
 // ClearUserInfoCache 清理所有用户信息缓存
 func (s UserService) ClearUserInfoCache() {
 	userInfoCache.Flush()
@@ -363,3 +462,20 @@ func (us UserService) GetUserById(id uint) (model.User, error) {
 	err := common.DB.Where("id = ?", id).Preload("Roles").First(&user).Error
 	return user, err
 }
+
+// ListPosixPending 获取尚未分配uidNumber的用户, ids为空时返回全部
+func (s UserService) ListPosixPending(ids []uint) ([]*model.User, error) {
+	var list []*model.User
+	db := common.DB.Where("uid_number = 0 AND user_dn <> ?", config.Conf.Ldap.AdminDN)
+	if len(ids) > 0 {
+		db = db.Where("id IN (?)", ids)
+	}
+	err := db.Find(&list).Error
+	return list, err
+}
+
+// UpdatePosix 更新用户的uidNumber与主组gidNumber
+func (s UserService) UpdatePosix(id, uidNumber, gidNumber uint) error {
+	return common.DB.Model(&model.User{}).Where("id = ?", id).
+		UpdateColumns(map[string]any{"uid_number": uidNumber, "gid_number": gidNumber}).Error
+}
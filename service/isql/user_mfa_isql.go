@@ -0,0 +1,58 @@
+package isql
+
+import (
+	"errors"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	"gorm.io/gorm"
+)
+
+type UserMfaService struct{}
+
+// Exist 判断用户是否已启用二次验证
+func (s UserMfaService) Exist(filter map[string]any) bool {
+	var dataObj model.UserMfa
+	err := common.DB.Where(filter).First(&dataObj).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// Find 获取用户的二次验证信息
+func (s UserMfaService) Find(filter map[string]any, data *model.UserMfa) error {
+	return common.DB.Where(filter).First(&data).Error
+}
+
+// Save 保存用户的二次验证信息, 不存在则创建
+func (s UserMfaService) Save(mfa *model.UserMfa) error {
+	var old model.UserMfa
+	err := common.DB.Where("user_id = ?", mfa.UserID).First(&old).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return common.DB.Create(mfa).Error
+	}
+	if err != nil {
+		return err
+	}
+	mfa.ID = old.ID
+	return common.DB.Model(&model.UserMfa{}).Where("id = ?", old.ID).Updates(map[string]any{
+		"secret":         mfa.Secret,
+		"recovery_codes": mfa.RecoveryCodes,
+		"status":         mfa.Status,
+	}).Error
+}
+
+// UpdateRecoveryCodes 更新剩余的恢复码
+func (s UserMfaService) UpdateRecoveryCodes(userId uint, recoveryCodes string) error {
+	return common.DB.Model(&model.UserMfa{}).Where("user_id = ?", userId).Update("recovery_codes", recoveryCodes).Error
+}
+
+// ClaimStep 记录通过校验的TOTP时间步, 时间步不大于已记录的值时返回false, 并发使用同一验证码时只有一个成功
+func (s UserMfaService) ClaimStep(userId uint, step int64) (bool, error) {
+	db := common.DB.Model(&model.UserMfa{}).Where("user_id = ? AND last_step < ?", userId, step).Update("last_step", step)
+	return db.RowsAffected > 0, db.Error
+}
+
+// Delete 删除用户的二次验证信息
+func (s UserMfaService) Delete(userIds []uint) error {
+	return common.DB.Where("user_id IN (?)", userIds).Unscoped().Delete(&model.UserMfa{}).Error
+}
@@ -0,0 +1,60 @@
+package isql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/eryajf/go-ldap-admin/model"
+	"github.com/eryajf/go-ldap-admin/public/common"
+
+	"gorm.io/gorm"
+)
+
+type UserSessionService struct{}
+
+// Add 记录新签发的令牌
+func (s UserSessionService) Add(session *model.UserSession) error {
+	return common.DB.Create(session).Error
+}
+
+// Exist 判断会话是否存在
+func (s UserSessionService) Exist(filter map[string]any) bool {
+	var dataObj model.UserSession
+	err := common.DB.Where(filter).First(&dataObj).Error
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// List 获取用户的会话列表
+func (s UserSessionService) List(userId uint) ([]*model.UserSession, error) {
+	var list []*model.UserSession
+	err := common.DB.Where("user_id = ?", userId).Order("id DESC").Find(&list).Error
+	return list, err
+}
+
+// GetByIds 根据ID获取会话
+func (s UserSessionService) GetByIds(ids []uint) ([]model.UserSession, error) {
+	var list []model.UserSession
+	err := common.DB.Where("id IN (?)", ids).Find(&list).Error
+	return list, err
+}
+
+// Touch 刷新令牌后更新过期时间与活跃时间
+func (s UserSessionService) Touch(sessionId string, values map[string]any) error {
+	return common.DB.Model(&model.UserSession{}).Where("session_id = ?", sessionId).UpdateColumns(values).Error
+}
+
+// Delete 吊销会话
+func (s UserSessionService) Delete(filter map[string]any) ([]string, error) {
+	var sessionIds []string
+	err := common.DB.Model(&model.UserSession{}).Where(filter).Pluck("session_id", &sessionIds).Error
+	if err != nil || len(sessionIds) == 0 {
+		return nil, err
+	}
+	err = common.DB.Where("session_id IN (?)", sessionIds).Unscoped().Delete(&model.UserSession{}).Error
+	return sessionIds, err
+}
+
+// Clean 清理已无法再刷新的过期会话
+func (s UserSessionService) Clean(before time.Time) error {
+	return common.DB.Where("expires_at < ?", before).Unscoped().Delete(&model.UserSession{}).Error
+}